@@ -13,8 +13,11 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"github.com/vantageedge/backend/internal/cache/redis"
 	"github.com/vantageedge/backend/internal/controlplane/handlers"
 	"github.com/vantageedge/backend/internal/controlplane/service"
+	"github.com/vantageedge/backend/internal/gateway/circuitbreaker"
+	gatewaymiddleware "github.com/vantageedge/backend/internal/gateway/middleware"
 	"github.com/vantageedge/backend/internal/repository"
 	"github.com/vantageedge/backend/pkg/config"
 	"github.com/vantageedge/backend/pkg/database"
@@ -44,8 +47,25 @@ func main() {
 	// Initialize repositories
 	repos := repository.New(db)
 
+	// Cache purges reach the gateways, and their circuit breaker reports
+	// come back, through Redis
+	var purger service.CachePurger
+	var circuits service.CircuitReports
+	redisClient, err := redis.New(&cfg.Redis)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to connect to Redis, cache purging and circuit breaker state are disabled")
+	} else {
+		defer redisClient.Close()
+		if cachePurger, err := gatewaymiddleware.NewCachePurger(redisClient); err != nil {
+			log.Warn().Err(err).Msg("Cache purging is disabled")
+		} else {
+			purger = cachePurger
+		}
+		circuits = circuitbreaker.NewStore(redisClient, 3*cfg.CircuitBreaker.ReportInterval)
+	}
+
 	// Initialize services
-	svc := service.New(repos, log)
+	svc := service.New(repos, cfg, purger, circuits, log)
 
 	// Initialize HTTP handlers
 	h := handlers.New(svc, log)
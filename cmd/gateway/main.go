@@ -9,7 +9,18 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/auth/jwt"
+	"github.com/vantageedge/backend/internal/cache/redis"
+	"github.com/vantageedge/backend/internal/gateway/circuitbreaker"
+	"github.com/vantageedge/backend/internal/gateway/middleware"
 	"github.com/vantageedge/backend/internal/gateway/router"
+	"github.com/vantageedge/backend/internal/gateway/routetable"
+	"github.com/vantageedge/backend/internal/loadbalancer"
+	"github.com/vantageedge/backend/internal/loadbalancer/outlier"
+	"github.com/vantageedge/backend/internal/observability"
+	"github.com/vantageedge/backend/internal/ratelimit"
+	"github.com/vantageedge/backend/internal/ratelimit/gcra"
 	"github.com/vantageedge/backend/internal/repository"
 	"github.com/vantageedge/backend/pkg/config"
 	"github.com/vantageedge/backend/pkg/database"
@@ -38,8 +49,152 @@ func main() {
 	// Initialize repositories
 	repos := repository.New(db)
 
+	// Load the compiled route table and keep it in sync with the database
+	routes := routetable.New(repos, log)
+	loadCtx, loadCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	if err := routes.Refresh(loadCtx); err != nil {
+		log.Fatal().Err(err).Msg("Failed to load route table")
+	}
+	loadCancel()
+	routes.Start(cfg.Gateway.RouteRefreshInterval)
+	defer routes.Stop()
+
+	// Health check origins so unhealthy pool members are left out of load
+	// balancing, following origin changes as the route table reloads
+	healthChecker := loadbalancer.NewHealthChecker(repos.Origin, cfg.LoadBalancer.HealthCheckInterval, cfg.LoadBalancer.HealthCheckTimeout, log)
+	healthChecker.Sync(routes.Origins())
+	routes.OnReload(func() { healthChecker.Sync(routes.Origins()) })
+	defer healthChecker.Stop()
+
+	// Load the JWKS used to verify JWTs and keep it refreshed
+	jwtValidator := jwt.NewJWTValidator(jwt.Config{
+		JWKSURL:            cfg.JWT.JWKSURL,
+		Issuer:             cfg.JWT.Issuer,
+		Audience:           cfg.JWT.Audience,
+		ClockSkew:          cfg.JWT.ClockSkew,
+		RefreshInterval:    cfg.JWT.JWKSRefreshInterval,
+		MinRefetchInterval: cfg.JWT.JWKSMinRefetchInterval,
+	})
+	jwksCtx, jwksCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if err := jwtValidator.Start(jwksCtx); err != nil {
+		log.Warn().Err(err).Msg("Failed to load JWKS, keys will be fetched on first use")
+	}
+	jwksCancel()
+	defer jwtValidator.Stop()
+
+	// Redis is shared by the rate limiter and the cache when either uses it.
+	// Cache purges and circuit breaker reports go through it as well, so the
+	// gateway always connects but only requires it for those backends.
+	var redisClient *redis.Client
+	redisRequired := cfg.RateLimit.Backend == "redis" || cfg.Cache.Backend == "redis"
+	redisClient, err = redis.New(&cfg.Redis)
+	switch {
+	case err == nil:
+		defer redisClient.Close()
+	case redisRequired:
+		log.Fatal().Err(err).Msg("Failed to connect to Redis")
+	default:
+		redisClient = nil
+		log.Warn().Err(err).Msg("Failed to connect to Redis, cache purges and circuit breaker reports are disabled")
+	}
+
+	// Select the rate limiter backend
+	var limiter ratelimit.Limiter = ratelimit.NewRegistry(cfg.RateLimit.IdleTTL)
+	if cfg.RateLimit.Backend == "redis" {
+		limiter = gcra.NewLimiter(redisClient, gcra.Options{
+			FailOpen: cfg.RateLimit.FailureMode == gcra.FailOpen,
+			Timeout:  cfg.RateLimit.RedisTimeout,
+		})
+	}
+
+	// Response cache, with Redis as a shared second tier when configured
+	metrics := observability.NewMetrics()
+	var sharedCache middleware.SharedCache
+	if cfg.Cache.Backend == "redis" {
+		redisCache, err := middleware.NewRedisCache(redisClient)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to set up the shared cache")
+		}
+		sharedCache = redisCache
+	}
+	cache := middleware.NewCache(int64(cfg.Cache.MaxSizeMB)<<20, sharedCache, cfg.Cache.RedisTimeout, metrics)
+
+	// Background work stops with the server
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	// Apply purges issued through the control plane
+	if cfg.Cache.Enabled && redisClient != nil {
+		go func() {
+			if err := middleware.SubscribePurges(backgroundCtx, redisClient, cache, log); err != nil {
+				log.Error().Err(err).Msg("Cache purge subscription failed")
+			}
+		}()
+	}
+
+	// Circuit breakers per route and origin, reported to the control plane
+	breakers := circuitbreaker.NewRegistry(circuitbreaker.Settings{
+		ErrorRatePercent: cfg.CircuitBreaker.ErrorRatePercent,
+		MinRequests:      cfg.CircuitBreaker.MinRequests,
+		Window:           cfg.CircuitBreaker.Window,
+		OpenTimeout:      cfg.CircuitBreaker.OpenTimeout,
+		HalfOpenProbes:   cfg.CircuitBreaker.HalfOpenProbes,
+	}, func(t circuitbreaker.Transition) {
+		log.Warn().
+			Str("route_id", t.RouteID.String()).
+			Str("origin_id", t.OriginID.String()).
+			Str("from", t.From).
+			Str("to", t.To).
+			Msg("Circuit breaker state changed")
+		metrics.RecordCircuitTransition(t.RouteID.String()+"|"+t.OriginID.String(), t.To)
+	})
+	if redisClient != nil {
+		instance, _ := os.Hostname()
+		instance += "-" + uuid.NewString()[:8]
+		store := circuitbreaker.NewStore(redisClient, 3*cfg.CircuitBreaker.ReportInterval)
+		go circuitbreaker.RunReporter(backgroundCtx, store, breakers, instance, cfg.CircuitBreaker.ReportInterval, log)
+	}
+
+	// Eject pool origins failing live traffic
+	var outliers *outlier.Detector
+	if cfg.Outlier.Enabled {
+		outliers = outlier.NewDetector(outlier.Settings{
+			ConsecutiveFailures: cfg.Outlier.ConsecutiveFailures,
+			ErrorRatePercent:    cfg.Outlier.ErrorRatePercent,
+			MinRequests:         cfg.Outlier.MinRequests,
+			Window:              cfg.Outlier.Window,
+			BaseEjectionTime:    cfg.Outlier.BaseEjectionTime,
+			MaxEjectionTime:     cfg.Outlier.MaxEjectionTime,
+			MaxEjectionPercent:  cfg.Outlier.MaxEjectionPercent,
+		}, func(e outlier.Event) {
+			if e.Ejected {
+				log.Warn().
+					Str("origin_id", e.OriginID.String()).
+					Str("reason", e.Reason).
+					Dur("duration", e.Duration).
+					Int("ejections", e.Ejections).
+					Msg("Origin ejected")
+			} else {
+				log.Info().Str("origin_id", e.OriginID.String()).Msg("Origin returned from ejection")
+			}
+			metrics.RecordOutlierEjection(e.OriginID.String(), e.Ejected, e.Reason)
+		})
+	}
+
 	// Initialize gateway router
-	handler := router.New(cfg, repos, log)
+	handler := router.New(cfg, repos, routes, jwtValidator, limiter, cache, breakers, healthChecker, outliers, log)
+
+	if cfg.Observability.MetricsEnabled {
+		metricsAddr := fmt.Sprintf("%s:%d", cfg.Gateway.Host, cfg.Observability.MetricsPort)
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", metrics.Handler())
+		go func() {
+			log.Info().Str("addr", metricsAddr).Msg("Metrics listening")
+			if err := http.ListenAndServe(metricsAddr, metricsMux); err != nil {
+				log.Error().Err(err).Msg("Metrics server failed")
+			}
+		}()
+	}
 
 	// HTTP server
 	addr := fmt.Sprintf("%s:%d", cfg.Gateway.Host, cfg.Gateway.Port)
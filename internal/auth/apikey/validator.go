@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/vantageedge/backend/internal/repository"
@@ -44,24 +45,26 @@ func (v *Validator) ValidateKey(ctx context.Context, keyString string) (*KeyInfo
 	}
 
 	// Check expiration
-	if apiKey.ExpiresAt != nil && apiKey.ExpiresAt.Before(now()) {
+	if apiKey.ExpiresAt != nil && apiKey.ExpiresAt.Before(time.Now()) {
 		return nil, fmt.Errorf("api key is expired")
 	}
 
 	return &KeyInfo{
-		ID:       apiKey.ID,
-		TenantID: apiKey.TenantID,
-		UserID:   apiKey.UserID,
-		Scopes:   apiKey.Scopes,
+		ID:                apiKey.ID,
+		TenantID:          apiKey.TenantID,
+		UserID:            apiKey.UserID,
+		Scopes:            apiKey.Scopes,
+		RateLimitOverride: apiKey.RateLimitOverride,
 	}, nil
 }
 
 // KeyInfo contains the validated key information
 type KeyInfo struct {
-	ID       uuid.UUID
-	TenantID uuid.UUID
-	UserID   *uuid.UUID
-	Scopes   []string
+	ID                uuid.UUID
+	TenantID          uuid.UUID
+	UserID            *uuid.UUID
+	Scopes            []string
+	RateLimitOverride *int // requests per second, replacing the route's rate
 }
 
 // HasScope checks if the key has a specific scope
@@ -73,9 +76,3 @@ func (k *KeyInfo) HasScope(scope string) bool {
 	}
 	return false
 }
-
-func now() interface{} {
-	// Placeholder - will be imported from time package in actual implementation
-	// This avoids compiler error from unused now() function
-	return nil
-}
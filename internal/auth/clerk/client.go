@@ -3,14 +3,17 @@ package clerk
 import (
 	"context"
 	"fmt"
+
+	"github.com/vantageedge/backend/internal/auth/jwt"
 )
 
 type ClerkClient struct {
-	apiKey string
+	apiKey    string
+	validator *jwt.JWTValidator
 }
 
-func NewClerkClient(apiKey string) *ClerkClient {
-	return &ClerkClient{apiKey: apiKey}
+func NewClerkClient(apiKey string, validator *jwt.JWTValidator) *ClerkClient {
+	return &ClerkClient{apiKey: apiKey, validator: validator}
 }
 
 // UserInfo represents Clerk user information
@@ -35,17 +38,18 @@ func (c *ClerkClient) GetUser(ctx context.Context, clerkUserID string) (*UserInf
 	return nil, fmt.Errorf("clerk integration not fully implemented")
 }
 
-// VerifyToken verifies a JWT token issued by Clerk
+// VerifyToken verifies a JWT token issued by Clerk against Clerk's JWKS
 func (c *ClerkClient) VerifyToken(ctx context.Context, token string) (bool, error) {
 	if token == "" {
 		return false, fmt.Errorf("token is empty")
 	}
+	if c.validator == nil {
+		return false, fmt.Errorf("token verification is not configured")
+	}
 
-	// TODO: In production, verify the token signature using Clerk's public key
-	// This would involve:
-	// 1. Getting Clerk's public key
-	// 2. Verifying the JWT signature
-	// 3. Checking expiration and claims
+	if _, err := c.validator.ValidateTokenContext(ctx, token); err != nil {
+		return false, err
+	}
 
 	return true, nil
 }
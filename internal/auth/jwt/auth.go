@@ -1,8 +1,11 @@
 package jwt
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -13,19 +16,112 @@ type Claims struct {
 	Email       string
 	Role        string
 	jwt.RegisteredClaims
+
+	// Raw holds every claim of the token as decoded from JSON
+	Raw map[string]interface{} `json:"-"`
+}
+
+// UnmarshalJSON decodes the known claims and keeps all of them in Raw
+func (c *Claims) UnmarshalJSON(data []byte) error {
+	type plain Claims
+	if err := json.Unmarshal(data, (*plain)(c)); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &c.Raw)
 }
 
+// Claim returns a claim of the token by name, following dots into nested
+// objects, e.g. "org.id"
+func (c *Claims) Claim(name string) (interface{}, bool) {
+	if c == nil {
+		return nil, false
+	}
+	var value interface{} = c.Raw
+	for _, key := range strings.Split(name, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// Config configures token verification. Issuer and Audience are only checked
+// when set.
+type Config struct {
+	JWKSURL            string
+	Issuer             string
+	Audience           string
+	ClockSkew          time.Duration
+	RefreshInterval    time.Duration // background JWKS refresh, 0 disables it
+	MinRefetchInterval time.Duration // minimum gap between refetches on unknown kid
+}
+
+// signingMethods are the algorithms accepted from the JWKS keys
+var signingMethods = []string{
+	jwt.SigningMethodRS256.Alg(),
+	jwt.SigningMethodES256.Alg(),
+	jwt.SigningMethodEdDSA.Alg(),
+}
+
+// JWTValidator verifies Clerk JWT signatures against the keys published at the
+// configured JWKS URL and validates exp, nbf, iss and aud
 type JWTValidator struct {
-	// No secret needed for Clerk JWT validation - uses public key
+	keys            *KeySet
+	parser          *jwt.Parser
+	refreshInterval time.Duration
+}
+
+func NewJWTValidator(cfg Config) *JWTValidator {
+	return NewJWTValidatorWithKeySet(cfg, NewKeySet(cfg.JWKSURL, nil, cfg.MinRefetchInterval))
 }
 
-func NewJWTValidator() *JWTValidator {
-	return &JWTValidator{}
+// NewJWTValidatorWithKeySet builds a validator around an existing key set
+func NewJWTValidatorWithKeySet(cfg Config, keys *KeySet) *JWTValidator {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithLeeway(cfg.ClockSkew),
+		jwt.WithExpirationRequired(),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+
+	return &JWTValidator{
+		keys:            keys,
+		parser:          jwt.NewParser(opts...),
+		refreshInterval: cfg.RefreshInterval,
+	}
 }
 
-// ValidateToken validates a JWT token from Clerk
-// For production, this should verify the signature using Clerk's public key
+// Start loads the key set and keeps it refreshed in the background. A failed
+// initial load is returned but not fatal: keys are fetched on first use.
+func (v *JWTValidator) Start(ctx context.Context) error {
+	err := v.keys.Refresh(ctx)
+	if v.refreshInterval > 0 {
+		v.keys.Start(v.refreshInterval)
+	}
+	return err
+}
+
+// Stop stops the background key refresh
+func (v *JWTValidator) Stop() {
+	v.keys.Stop()
+}
+
+// ValidateToken verifies a JWT token from Clerk and returns its claims
 func (v *JWTValidator) ValidateToken(tokenString string) (*Claims, error) {
+	return v.ValidateTokenContext(context.Background(), tokenString)
+}
+
+// ValidateTokenContext is ValidateToken bounded by ctx for any JWKS fetch
+func (v *JWTValidator) ValidateTokenContext(ctx context.Context, tokenString string) (*Claims, error) {
 	if tokenString == "" {
 		return nil, fmt.Errorf("token is empty")
 	}
@@ -33,25 +129,28 @@ func (v *JWTValidator) ValidateToken(tokenString string) (*Claims, error) {
 	// Remove "Bearer " prefix if present
 	tokenString = strings.TrimPrefix(tokenString, "Bearer ")
 
-	// Parse token without verification for now
-	// In production, use Clerk SDK to verify signatures
-	token, _, err := new(jwt.Parser).ParseUnverified(tokenString, &Claims{})
+	token, err := v.parser.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, fmt.Errorf("token has no kid header")
+		}
+		return v.keys.Key(ctx, kid)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse token: %w", err)
+		return nil, fmt.Errorf("failed to verify token: %w", err)
 	}
 
 	claims, ok := token.Claims.(*Claims)
-	if !ok {
+	if !ok || !token.Valid {
 		return nil, fmt.Errorf("invalid token claims")
 	}
 
 	return claims, nil
 }
 
-// ExtractClerkUserID extracts the Clerk user ID from a token string
-func ExtractClerkUserID(tokenString string) (string, error) {
-	validator := NewJWTValidator()
-	claims, err := validator.ValidateToken(tokenString)
+// ExtractClerkUserID verifies a token and returns its Clerk user ID
+func (v *JWTValidator) ExtractClerkUserID(tokenString string) (string, error) {
+	claims, err := v.ValidateToken(tokenString)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,309 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwksServer serves a mutable JWKS document and counts fetches
+type jwksServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	keys    []jsonWebKey
+	fetches atomic.Int32
+}
+
+func newJWKSServer(t *testing.T) *jwksServer {
+	s := &jwksServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.fetches.Add(1)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		json.NewEncoder(w).Encode(jsonWebKeySet{Keys: s.keys})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) setKeys(keys ...jsonWebKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+type signingKey struct {
+	kid    string
+	method jwt.SigningMethod
+	key    crypto.Signer
+}
+
+func (k *signingKey) jwk() jsonWebKey {
+	enc := base64.RawURLEncoding
+	switch pub := k.key.Public().(type) {
+	case *rsa.PublicKey:
+		return jsonWebKey{Kty: "RSA", Kid: k.kid, Use: "sig", N: enc.EncodeToString(pub.N.Bytes()), E: enc.EncodeToString(big.NewInt(int64(pub.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		return jsonWebKey{Kty: "EC", Kid: k.kid, Crv: "P-256", X: enc.EncodeToString(pub.X.FillBytes(make([]byte, 32))), Y: enc.EncodeToString(pub.Y.FillBytes(make([]byte, 32)))}
+	case ed25519.PublicKey:
+		return jsonWebKey{Kty: "OKP", Kid: k.kid, Crv: "Ed25519", X: enc.EncodeToString(pub)}
+	}
+	panic("unsupported key")
+}
+
+func (k *signingKey) sign(t *testing.T, claims *Claims) string {
+	token := jwt.NewWithClaims(k.method, claims)
+	token.Header["kid"] = k.kid
+	signed, err := token.SignedString(k.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func newRSAKey(t *testing.T, kid string) *signingKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &signingKey{kid: kid, method: jwt.SigningMethodRS256, key: key}
+}
+
+func newECKey(t *testing.T, kid string) *signingKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &signingKey{kid: kid, method: jwt.SigningMethodES256, key: key}
+}
+
+func newEdKey(t *testing.T, kid string) *signingKey {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &signingKey{kid: kid, method: jwt.SigningMethodEdDSA, key: key}
+}
+
+func validClaims() *Claims {
+	now := time.Now()
+	return &Claims{
+		ClerkUserID: "user_1",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "user_1",
+			Issuer:    "https://issuer.test",
+			Audience:  jwt.ClaimStrings{"vantageedge-api"},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	}
+}
+
+func newTestValidator(url string) *JWTValidator {
+	return NewJWTValidator(Config{
+		JWKSURL:            url,
+		Issuer:             "https://issuer.test",
+		Audience:           "vantageedge-api",
+		ClockSkew:          30 * time.Second,
+		MinRefetchInterval: time.Hour,
+	})
+}
+
+func TestValidateTokenAlgorithms(t *testing.T) {
+	server := newJWKSServer(t)
+	keys := []*signingKey{newRSAKey(t, "rsa"), newECKey(t, "ec"), newEdKey(t, "ed")}
+	server.setKeys(keys[0].jwk(), keys[1].jwk(), keys[2].jwk())
+
+	v := newTestValidator(server.URL)
+	for _, key := range keys {
+		t.Run(key.method.Alg(), func(t *testing.T) {
+			claims, err := v.ValidateToken(key.sign(t, validClaims()))
+			if err != nil {
+				t.Fatalf("ValidateToken: %v", err)
+			}
+			if claims.ClerkUserID != "user_1" {
+				t.Fatalf("unexpected claims %+v", claims)
+			}
+		})
+	}
+
+	if got := server.fetches.Load(); got != 1 {
+		t.Fatalf("expected a single JWKS fetch, got %d", got)
+	}
+}
+
+func TestValidateTokenRejectsForgedAndUnsigned(t *testing.T) {
+	server := newJWKSServer(t)
+	published := newRSAKey(t, "k1")
+	server.setKeys(published.jwk())
+	v := newTestValidator(server.URL)
+
+	// Same kid, different private key
+	forger := newRSAKey(t, "k1")
+	if _, err := v.ValidateToken(forger.sign(t, validClaims())); err == nil {
+		t.Fatal("expected forged signature to be rejected")
+	}
+
+	unsigned := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims())
+	unsigned.Header["kid"] = "k1"
+	token, err := unsigned.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.ValidateToken(token); err == nil {
+		t.Fatal("expected unsigned token to be rejected")
+	}
+}
+
+func TestValidateTokenClaims(t *testing.T) {
+	server := newJWKSServer(t)
+	key := newECKey(t, "k1")
+	server.setKeys(key.jwk())
+	v := newTestValidator(server.URL)
+
+	tests := []struct {
+		name   string
+		mutate func(c *Claims)
+		valid  bool
+	}{
+		{"valid", func(c *Claims) {}, true},
+		{"expired within skew", func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-10 * time.Second)) }, true},
+		{"expired beyond skew", func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute)) }, false},
+		{"missing exp", func(c *Claims) { c.ExpiresAt = nil }, false},
+		{"not yet valid within skew", func(c *Claims) { c.NotBefore = jwt.NewNumericDate(time.Now().Add(10 * time.Second)) }, true},
+		{"not yet valid beyond skew", func(c *Claims) { c.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Minute)) }, false},
+		{"wrong issuer", func(c *Claims) { c.Issuer = "https://evil.test" }, false},
+		{"wrong audience", func(c *Claims) { c.Audience = jwt.ClaimStrings{"other"} }, false},
+		{"audience in list", func(c *Claims) { c.Audience = jwt.ClaimStrings{"other", "vantageedge-api"} }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			tt.mutate(claims)
+			_, err := v.ValidateToken(key.sign(t, claims))
+			if tt.valid && err != nil {
+				t.Fatalf("expected valid token, got %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Fatal("expected token to be rejected")
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	server := newJWKSServer(t)
+	oldKey, newKey := newRSAKey(t, "old"), newEdKey(t, "new")
+	server.setKeys(oldKey.jwk())
+
+	v := NewJWTValidator(Config{JWKSURL: server.URL})
+	if _, err := v.ValidateToken(oldKey.sign(t, validClaims())); err != nil {
+		t.Fatalf("old key: %v", err)
+	}
+
+	// The new kid is unknown to the cached set and triggers a refetch
+	server.setKeys(oldKey.jwk(), newKey.jwk())
+	if _, err := v.ValidateToken(newKey.sign(t, validClaims())); err != nil {
+		t.Fatalf("rotated key: %v", err)
+	}
+	if got := server.fetches.Load(); got != 2 {
+		t.Fatalf("expected 2 fetches, got %d", got)
+	}
+
+	// Once the old key is retired a background refresh drops it
+	server.setKeys(newKey.jwk())
+	if err := v.keys.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.ValidateToken(oldKey.sign(t, validClaims())); err == nil {
+		t.Fatal("expected retired key to be rejected")
+	}
+}
+
+func TestUnknownKidRefetchIsRateLimited(t *testing.T) {
+	server := newJWKSServer(t)
+	key := newRSAKey(t, "k1")
+	server.setKeys(key.jwk())
+	v := newTestValidator(server.URL)
+
+	if _, err := v.ValidateToken(key.sign(t, validClaims())); err != nil {
+		t.Fatal(err)
+	}
+
+	unknown := newRSAKey(t, "unknown")
+	for i := 0; i < 10; i++ {
+		_, err := v.ValidateToken(unknown.sign(t, validClaims()))
+		if !errors.Is(err, ErrUnknownKey) {
+			t.Fatalf("expected ErrUnknownKey, got %v", err)
+		}
+	}
+
+	if got := server.fetches.Load(); got != 1 {
+		t.Fatalf("expected unknown kids not to refetch within the interval, got %d fetches", got)
+	}
+}
+
+func TestBackgroundRefresh(t *testing.T) {
+	server := newJWKSServer(t)
+	oldKey, newKey := newRSAKey(t, "old"), newRSAKey(t, "new")
+	server.setKeys(oldKey.jwk())
+
+	v := NewJWTValidator(Config{
+		JWKSURL:            server.URL,
+		RefreshInterval:    10 * time.Millisecond,
+		MinRefetchInterval: time.Hour,
+	})
+	if err := v.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer v.Stop()
+
+	server.setKeys(newKey.jwk())
+	deadline := time.Now().Add(2 * time.Second)
+	for server.fetches.Load() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("background refresh did not run")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if _, err := v.ValidateToken(newKey.sign(t, validClaims())); err != nil {
+		t.Fatalf("new key after background refresh: %v", err)
+	}
+}
+
+func TestClaimsKeepRawClaims(t *testing.T) {
+	var claims Claims
+	if err := json.Unmarshal([]byte(`{"ClerkUserID":"user_1","sub":"user_1","plan":"pro","org":{"id":"org_1"}}`), &claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims.ClerkUserID != "user_1" || claims.Subject != "user_1" {
+		t.Fatalf("expected the known claims decoded, got %+v", claims)
+	}
+	for name, want := range map[string]interface{}{"plan": "pro", "org.id": "org_1", "sub": "user_1"} {
+		if got, ok := claims.Claim(name); !ok || got != want {
+			t.Errorf("claim %s: got %v, want %v", name, got, want)
+		}
+	}
+	if _, ok := claims.Claim("org.name"); ok {
+		t.Error("expected a missing claim to be reported")
+	}
+}
@@ -0,0 +1,117 @@
+package lru
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Cache is a least recently used cache bounded by the total size of its
+// values. Sizes are supplied by the caller, so the bound can be in bytes.
+// It is safe for concurrent use.
+type Cache[V any] struct {
+	mu      sync.Mutex
+	maxSize int64
+	size    int64
+	ll      *list.List
+	items   map[string]*list.Element
+}
+
+type item[V any] struct {
+	key   string
+	value V
+	size  int64
+}
+
+// New returns a cache holding at most maxSize worth of values
+func New[V any](maxSize int64) *Cache[V] {
+	return &Cache[V]{
+		maxSize: maxSize,
+		ll:      list.New(),
+		items:   make(map[string]*list.Element),
+	}
+}
+
+// Get returns the value stored under key and marks it as recently used
+func (c *Cache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	c.ll.MoveToFront(el)
+	return el.Value.(*item[V]).value, true
+}
+
+// Add stores value under key, evicting the least recently used values until
+// it fits. Values larger than the whole cache are not stored and Add
+// returns false.
+func (c *Cache[V]) Add(key string, value V, size int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+	if size > c.maxSize {
+		return false
+	}
+
+	c.items[key] = c.ll.PushFront(&item[V]{key: key, value: value, size: size})
+	c.size += size
+	for c.size > c.maxSize {
+		c.removeElement(c.ll.Back())
+	}
+	return true
+}
+
+// Remove deletes key from the cache
+func (c *Cache[V]) Remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+// RemoveFunc deletes every value for which match returns true and returns
+// how many were deleted. match is called with the cache locked and must not
+// use it.
+func (c *Cache[V]) RemoveFunc(match func(key string, value V) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for el := c.ll.Front(); el != nil; {
+		next := el.Next()
+		if it := el.Value.(*item[V]); match(it.key, it.value) {
+			c.removeElement(el)
+			removed++
+		}
+		el = next
+	}
+	return removed
+}
+
+// Len returns the number of values in the cache
+func (c *Cache[V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// Size returns the total size of the values in the cache
+func (c *Cache[V]) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+func (c *Cache[V]) removeElement(el *list.Element) {
+	it := c.ll.Remove(el).(*item[V])
+	delete(c.items, it.key)
+	c.size -= it.size
+}
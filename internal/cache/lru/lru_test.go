@@ -0,0 +1,58 @@
+package lru
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := New[string](30)
+	c.Add("a", "a", 10)
+	c.Add("b", "b", 10)
+	c.Add("c", "c", 10)
+
+	// Touch a so b is the least recently used
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("expected a to be cached")
+	}
+	c.Add("d", "d", 10)
+
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("expected b to be evicted")
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if _, ok := c.Get(key); !ok {
+			t.Fatalf("expected %s to be cached", key)
+		}
+	}
+	if c.Size() != 30 || c.Len() != 3 {
+		t.Fatalf("expected 3 values of size 30, got %d of size %d", c.Len(), c.Size())
+	}
+}
+
+func TestCacheAccountsSizes(t *testing.T) {
+	c := New[int](100)
+	for i := 0; i < 10; i++ {
+		c.Add(strconv.Itoa(i), i, 10)
+	}
+
+	// A large value evicts as many old ones as needed
+	c.Add("big", 0, 55)
+	if c.Size() > 100 || c.Len() != 5 {
+		t.Fatalf("expected 4 small values and the big one, got %d of size %d", c.Len(), c.Size())
+	}
+
+	// Replacing a value updates the accounted size
+	c.Add("big", 1, 5)
+	if v, _ := c.Get("big"); v != 1 || c.Size() != 45 {
+		t.Fatalf("expected the replaced value to be accounted, got %d size %d", v, c.Size())
+	}
+
+	if c.Add("huge", 0, 101) {
+		t.Fatal("expected a value larger than the cache to be rejected")
+	}
+	c.Remove("big")
+	if c.Size() != 40 {
+		t.Fatalf("expected size 40 after removal, got %d", c.Size())
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"github.com/vantageedge/backend/pkg/config"
 )
 
 type Client struct {
@@ -23,6 +25,21 @@ func NewClient(redisURL string) (*Client, error) {
 		return nil, fmt.Errorf("failed to parse redis URL: %w", err)
 	}
 
+	return connect(opts)
+}
+
+// New connects to the Redis server described by cfg
+func New(cfg *config.RedisConfig) (*Client, error) {
+	return connect(&redis.Options{
+		Addr:       cfg.Address(),
+		Password:   cfg.Password,
+		DB:         cfg.DB,
+		MaxRetries: cfg.MaxRetries,
+		PoolSize:   cfg.PoolSize,
+	})
+}
+
+func connect(opts *redis.Options) (*Client, error) {
 	client := redis.NewClient(opts)
 
 	// Test connection
@@ -30,6 +47,7 @@ func NewClient(redisURL string) (*Client, error) {
 	defer cancel()
 
 	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to connect to redis: %w", err)
 	}
 
@@ -109,6 +127,66 @@ func (c *Client) SetIfNotExists(ctx context.Context, key string, value interface
 	return result, err
 }
 
+// ClusterEnabled reports whether the server runs in Redis Cluster mode
+func (c *Client) ClusterEnabled(ctx context.Context) (bool, error) {
+	info, err := c.client.Info(ctx, "cluster").Result()
+	if err != nil {
+		return false, err
+	}
+	return strings.Contains(info, "cluster_enabled:1"), nil
+}
+
+// Script is a Lua script run with EVALSHA, falling back to EVAL when the
+// server does not have it cached yet
+type Script struct {
+	script *redis.Script
+}
+
+func NewScript(src string) *Script {
+	return &Script{script: redis.NewScript(src)}
+}
+
+// Run executes script atomically on the server
+func (c *Client) Run(ctx context.Context, script *Script, keys []string, args ...interface{}) (interface{}, error) {
+	return script.script.Run(ctx, c.client, keys, args...).Result()
+}
+
+// Publish sends value, marshalled to JSON, to the subscribers of channel
+func (c *Client) Publish(ctx context.Context, channel string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal value: %w", err)
+	}
+
+	return c.client.Publish(ctx, channel, data).Err()
+}
+
+// Subscribe calls handle with the payload of each message published on
+// channel until ctx is done. The subscription is re-established when the
+// connection drops; messages published meanwhile are lost.
+func (c *Client) Subscribe(ctx context.Context, channel string, handle func(payload string)) error {
+	sub := c.client.Subscribe(ctx, channel)
+	defer sub.Close()
+
+	// Wait for the subscription to be confirmed
+	if _, err := sub.Receive(ctx); err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", channel, err)
+	}
+
+	messages := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			handle(msg.Payload)
+		}
+	}
+}
+
 // Close closes the Redis connection
 func (c *Client) Close() error {
 	return c.client.Close()
@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
@@ -76,6 +78,15 @@ func (h *Handlers) RegisterRoutes(r chi.Router) {
 		r.Delete("/{id}", h.DeleteOrigin)
 	})
 
+	// Upstream pools
+	r.Route("/pools", func(r chi.Router) {
+		r.Post("/", h.CreatePool)
+		r.Get("/{id}", h.GetPool)
+		r.Get("/tenant/{tenant_id}", h.ListPools)
+		r.Put("/{id}", h.UpdatePool)
+		r.Delete("/{id}", h.DeletePool)
+	})
+
 	// Route rules
 	r.Route("/routes", func(r chi.Router) {
 		r.Post("/", h.CreateRoute)
@@ -84,6 +95,7 @@ func (h *Handlers) RegisterRoutes(r chi.Router) {
 		r.Put("/{id}", h.UpdateRoute)
 		r.Patch("/{id}", h.UpdateRoute)
 		r.Delete("/{id}", h.DeleteRoute)
+		r.Get("/{id}/circuit-breakers", h.ListCircuitBreakers)
 	})
 
 	// API keys
@@ -91,7 +103,21 @@ func (h *Handlers) RegisterRoutes(r chi.Router) {
 		r.Post("/", h.CreateAPIKey)
 		r.Get("/tenant/{tenant_id}", h.ListAPIKeys)
 		r.Delete("/{id}", h.DeleteAPIKey)
+		r.Put("/{id}/rate-limit", h.SetAPIKeyRateLimit)
+		r.Delete("/{id}/rate-limit", h.ClearAPIKeyRateLimit)
 	})
+
+	// Custom domains
+	r.Route("/domains", func(r chi.Router) {
+		r.Post("/", h.CreateDomain)
+		r.Get("/{id}", h.GetDomain)
+		r.Get("/tenant/{tenant_id}", h.ListDomains)
+		r.Post("/{id}/verify", h.VerifyDomain)
+		r.Delete("/{id}", h.DeleteDomain)
+	})
+
+	// Response cache
+	r.Post("/cache/purge", h.PurgeCache)
 }
 
 func (h *Handlers) CreateTenant(w http.ResponseWriter, r *http.Request) {
@@ -220,6 +246,24 @@ func (h *Handlers) CreateOrigin(w http.ResponseWriter, r *http.Request) {
 	if interval, ok := reqBody["health_check_interval"].(float64); ok {
 		req.HealthCheckInterval = int(interval)
 	}
+	if checkType, ok := reqBody["health_check_type"].(string); ok {
+		req.HealthCheckType = checkType
+	}
+	if timeout, ok := reqBody["health_check_timeout_seconds"].(float64); ok {
+		req.HealthCheckTimeout = int(timeout)
+	}
+	if threshold, ok := reqBody["healthy_threshold"].(float64); ok {
+		req.HealthyThreshold = int(threshold)
+	}
+	if threshold, ok := reqBody["unhealthy_threshold"].(float64); ok {
+		req.UnhealthyThreshold = int(threshold)
+	}
+	if statuses, ok := reqBody["health_check_expected_statuses"].(string); ok {
+		req.ExpectedStatuses = statuses
+	}
+	if match, ok := reqBody["health_check_body_match"].(string); ok {
+		req.BodyMatch = match
+	}
 	if timeout, ok := reqBody["timeout_seconds"].(float64); ok {
 		req.TimeoutSeconds = int(timeout)
 	}
@@ -239,7 +283,7 @@ func (h *Handlers) CreateOrigin(w http.ResponseWriter, r *http.Request) {
 	origin, err := h.service.Origin.CreateOrigin(r.Context(), &req)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to create origin")
-		h.respondError(w, http.StatusInternalServerError, "Failed to create origin")
+		h.respondServiceError(w, err, "Failed to create origin")
 		return
 	}
 
@@ -299,7 +343,7 @@ func (h *Handlers) UpdateOrigin(w http.ResponseWriter, r *http.Request) {
 	origin, err := h.service.Origin.UpdateOrigin(r.Context(), id, &req)
 	if err != nil {
 		h.logger.Error().Err(err).Str("id", id.String()).Msg("Failed to update origin")
-		h.respondError(w, http.StatusInternalServerError, "Failed to update origin")
+		h.respondServiceError(w, err, "Failed to update origin")
 		return
 	}
 
@@ -322,6 +366,154 @@ func (h *Handlers) DeleteOrigin(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// Pool handlers
+func (h *Handlers) CreatePool(w http.ResponseWriter, r *http.Request) {
+	var reqBody map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Get tenant ID from request body or query parameter
+	tenantIDStr := ""
+	if tid, ok := reqBody["tenant_id"].(string); ok {
+		tenantIDStr = tid
+	}
+	if tenantIDStr == "" {
+		tenantIDStr = r.URL.Query().Get("tenant_id")
+	}
+
+	if tenantIDStr == "" {
+		h.respondError(w, http.StatusBadRequest, "Tenant ID is required")
+		return
+	}
+
+	// Resolve tenant ID (UUID or Clerk ID)
+	tenantID, err := h.resolveTenantID(r.Context(), tenantIDStr)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to resolve tenant ID")
+		h.respondError(w, http.StatusInternalServerError, "Failed to resolve tenant ID")
+		return
+	}
+
+	req := service.CreatePoolRequest{TenantID: tenantID}
+	if name, ok := reqBody["name"].(string); ok {
+		req.Name = name
+	}
+	if strategy, ok := reqBody["strategy"].(string); ok {
+		req.Strategy = strategy
+	}
+	if source, ok := reqBody["hash_key_source"].(string); ok {
+		req.HashKeySource = source
+	}
+	if name, ok := reqBody["hash_key_name"].(string); ok {
+		req.HashKeyName = name
+	}
+	if factor, ok := reqBody["hash_load_factor"].(float64); ok {
+		req.HashLoadFactor = factor
+	}
+	if ids, ok := reqBody["origin_ids"].([]interface{}); ok {
+		for _, raw := range ids {
+			idStr, _ := raw.(string)
+			id, parseErr := uuid.Parse(idStr)
+			if parseErr != nil {
+				h.respondError(w, http.StatusBadRequest, "Invalid origin ID")
+				return
+			}
+			req.OriginIDs = append(req.OriginIDs, id)
+		}
+	}
+
+	pool, err := h.service.Pool.CreatePool(r.Context(), &req)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to create pool")
+		h.respondServiceError(w, err, "Failed to create pool")
+		return
+	}
+
+	h.respondJSON(w, http.StatusCreated, pool)
+}
+
+func (h *Handlers) GetPool(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid pool ID")
+		return
+	}
+
+	pool, err := h.service.Pool.GetPool(r.Context(), id)
+	if err != nil {
+		h.respondError(w, http.StatusNotFound, "Pool not found")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, pool)
+}
+
+func (h *Handlers) ListPools(w http.ResponseWriter, r *http.Request) {
+	tenantIDStr := chi.URLParam(r, "tenant_id")
+
+	// Resolve tenant ID (UUID or Clerk ID)
+	tenantID, err := h.resolveTenantID(r.Context(), tenantIDStr)
+	if err != nil {
+		// If tenant doesn't exist, return empty array
+		h.respondJSON(w, http.StatusOK, []interface{}{})
+		return
+	}
+
+	pools, err := h.service.Pool.ListByTenant(r.Context(), tenantID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to list pools")
+		h.respondError(w, http.StatusInternalServerError, "Failed to list pools")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, pools)
+}
+
+func (h *Handlers) UpdatePool(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid pool ID")
+		return
+	}
+
+	var req service.UpdatePoolRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	pool, err := h.service.Pool.UpdatePool(r.Context(), id, &req)
+	if errors.Is(err, sql.ErrNoRows) {
+		h.respondError(w, http.StatusNotFound, "Pool not found")
+		return
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Str("id", id.String()).Msg("Failed to update pool")
+		h.respondServiceError(w, err, "Failed to update pool")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, pool)
+}
+
+func (h *Handlers) DeletePool(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid pool ID")
+		return
+	}
+
+	if err := h.service.Pool.DeletePool(r.Context(), id); err != nil {
+		h.logger.Error().Err(err).Str("id", id.String()).Msg("Failed to delete pool")
+		h.respondServiceError(w, err, "Failed to delete pool")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // Route handlers
 func (h *Handlers) CreateRoute(w http.ResponseWriter, r *http.Request) {
 	var reqBody map[string]interface{}
@@ -369,7 +561,15 @@ func (h *Handlers) CreateRoute(w http.ResponseWriter, r *http.Request) {
 			h.respondError(w, http.StatusBadRequest, "Invalid origin ID")
 			return
 		}
-		req.OriginID = originID
+		req.OriginID = &originID
+	}
+	if poolIDStr, ok := reqBody["pool_id"].(string); ok {
+		poolID, parseErr := uuid.Parse(poolIDStr)
+		if parseErr != nil {
+			h.respondError(w, http.StatusBadRequest, "Invalid pool ID")
+			return
+		}
+		req.PoolID = &poolID
 	}
 	if methods, ok := reqBody["methods"].([]interface{}); ok {
 		for _, method := range methods {
@@ -408,12 +608,59 @@ func (h *Handlers) CreateRoute(w http.ResponseWriter, r *http.Request) {
 	if pattern, ok := reqBody["cache_key_pattern"].(string); ok {
 		req.CacheKeyPattern = pattern
 	}
+	if rules, ok := reqBody["cache_bypass_rules"]; ok && rules != nil {
+		raw, _ := json.Marshal(rules)
+		if err := json.Unmarshal(raw, &req.CacheBypassRules); err != nil {
+			h.respondError(w, http.StatusBadRequest, "Invalid cache bypass rules")
+			return
+		}
+	}
+	if swr, ok := reqBody["cache_stale_while_revalidate_seconds"].(float64); ok {
+		req.CacheStaleWhileRevalidateSeconds = int(swr)
+	}
+	if sie, ok := reqBody["cache_stale_if_error_seconds"].(float64); ok {
+		req.CacheStaleIfErrorSeconds = int(sie)
+	}
 	if timeout, ok := reqBody["timeout_seconds"].(float64); ok {
 		req.TimeoutSeconds = int(timeout)
 	}
 	if retries, ok := reqBody["retry_attempts"].(float64); ok {
 		req.RetryAttempts = int(retries)
 	}
+	if conditions, ok := reqBody["retry_on"].([]interface{}); ok {
+		for _, c := range conditions {
+			if condition, ok := c.(string); ok {
+				req.RetryOn = append(req.RetryOn, condition)
+			}
+		}
+	}
+	if nonIdempotent, ok := reqBody["retry_non_idempotent"].(bool); ok {
+		req.RetryNonIdempotent = nonIdempotent
+	}
+	if pattern, ok := reqBody["path_rewrite_pattern"].(string); ok && pattern != "" {
+		req.PathRewritePattern = &pattern
+	}
+	if target, ok := reqBody["path_rewrite_target"].(string); ok && target != "" {
+		req.PathRewriteTarget = &target
+	}
+	if prefix, ok := reqBody["strip_path_prefix"].(string); ok && prefix != "" {
+		req.StripPathPrefix = &prefix
+	}
+	if prefix, ok := reqBody["add_path_prefix"].(string); ok && prefix != "" {
+		req.AddPathPrefix = &prefix
+	}
+	if rules, ok := reqBody["request_headers"].(map[string]interface{}); ok {
+		req.RequestHeaders = models.JSONB(rules)
+	}
+	if rules, ok := reqBody["response_headers"].(map[string]interface{}); ok {
+		req.ResponseHeaders = models.JSONB(rules)
+	}
+	if rules, ok := reqBody["request_body_transform"].(map[string]interface{}); ok {
+		req.RequestBodyTransform = models.JSONB(rules)
+	}
+	if rules, ok := reqBody["response_body_transform"].(map[string]interface{}); ok {
+		req.ResponseBodyTransform = models.JSONB(rules)
+	}
 
 	// Validate request
 	if req.Name == "" || req.PathPattern == "" {
@@ -421,15 +668,15 @@ func (h *Handlers) CreateRoute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.OriginID == uuid.Nil {
-		h.respondError(w, http.StatusBadRequest, "Origin ID is required")
+	if req.OriginID == nil && req.PoolID == nil {
+		h.respondError(w, http.StatusBadRequest, "Origin ID or pool ID is required")
 		return
 	}
 
 	route, err := h.service.Route.CreateRoute(r.Context(), &req)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to create route")
-		h.respondError(w, http.StatusInternalServerError, "Failed to create route")
+		h.respondServiceError(w, err, "Failed to create route")
 		return
 	}
 
@@ -489,7 +736,7 @@ func (h *Handlers) UpdateRoute(w http.ResponseWriter, r *http.Request) {
 	route, err := h.service.Route.UpdateRoute(r.Context(), id, &req)
 	if err != nil {
 		h.logger.Error().Err(err).Str("id", id.String()).Msg("Failed to update route")
-		h.respondError(w, http.StatusInternalServerError, "Failed to update route")
+		h.respondServiceError(w, err, "Failed to update route")
 		return
 	}
 
@@ -560,6 +807,17 @@ func (h *Handlers) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
 		req.ExpiresAt = &expiresAt
 	}
 
+	if override := reqBody["rate_limit_override"]; override != nil {
+		// Decoded as a float64; only whole numbers that fit an int are valid
+		var rps int
+		encoded, _ := json.Marshal(override)
+		if err := json.Unmarshal(encoded, &rps); err != nil || rps <= 0 {
+			h.respondError(w, http.StatusBadRequest, "rate_limit_override must be a positive integer")
+			return
+		}
+		req.RateLimitOverride = &rps
+	}
+
 	// Validate request
 	if req.Name == "" {
 		h.respondError(w, http.StatusBadRequest, "API key name is required")
@@ -573,18 +831,19 @@ func (h *Handlers) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
 	apiKey, keyString, err := h.service.APIKey.CreateAPIKey(r.Context(), &req)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to create API key")
-		h.respondError(w, http.StatusInternalServerError, "Failed to create API key")
+		h.respondServiceError(w, err, "Failed to create API key")
 		return
 	}
 
 	// Return the key only once (will be hashed in database)
 	response := map[string]interface{}{
-		"id":         apiKey.ID,
-		"name":       apiKey.Name,
-		"key":        keyString,
-		"scopes":     apiKey.Scopes,
-		"is_active":  apiKey.IsActive,
-		"created_at": apiKey.CreatedAt,
+		"id":                  apiKey.ID,
+		"name":                apiKey.Name,
+		"key":                 keyString,
+		"scopes":              apiKey.Scopes,
+		"rate_limit_override": apiKey.RateLimitOverride,
+		"is_active":           apiKey.IsActive,
+		"created_at":          apiKey.CreatedAt,
 	}
 
 	h.respondJSON(w, http.StatusCreated, response)
@@ -627,6 +886,238 @@ func (h *Handlers) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// SetAPIKeyRateLimit sets or changes the key's rate limit override. A null
+// rate_limit_override clears it.
+func (h *Handlers) SetAPIKeyRateLimit(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+
+	var req struct {
+		RateLimitOverride *int `json:"rate_limit_override"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	h.setAPIKeyRateLimit(w, r, id, req.RateLimitOverride)
+}
+
+func (h *Handlers) ClearAPIKeyRateLimit(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+
+	h.setAPIKeyRateLimit(w, r, id, nil)
+}
+
+func (h *Handlers) setAPIKeyRateLimit(w http.ResponseWriter, r *http.Request, id uuid.UUID, override *int) {
+	key, err := h.service.APIKey.SetRateLimitOverride(r.Context(), id, override)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondError(w, http.StatusNotFound, "API key not found")
+			return
+		}
+		h.logger.Error().Err(err).Str("id", id.String()).Msg("Failed to set API key rate limit")
+		h.respondServiceError(w, err, "Failed to set API key rate limit")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, key)
+}
+
+// Domain handlers
+func (h *Handlers) CreateDomain(w http.ResponseWriter, r *http.Request) {
+	var reqBody map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Get tenant ID from request body or query parameter
+	tenantIDStr := ""
+	if tid, ok := reqBody["tenant_id"].(string); ok {
+		tenantIDStr = tid
+	}
+	if tenantIDStr == "" {
+		tenantIDStr = r.URL.Query().Get("tenant_id")
+	}
+
+	if tenantIDStr == "" {
+		h.respondError(w, http.StatusBadRequest, "Tenant ID is required")
+		return
+	}
+
+	// Resolve tenant ID (UUID or Clerk ID)
+	tenantID, err := h.resolveTenantID(r.Context(), tenantIDStr)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to resolve tenant ID")
+		h.respondError(w, http.StatusInternalServerError, "Failed to resolve tenant ID")
+		return
+	}
+
+	req := service.AddDomainRequest{TenantID: tenantID}
+	if domain, ok := reqBody["domain"].(string); ok {
+		req.Domain = domain
+	}
+
+	domain, err := h.service.Domain.AddDomain(r.Context(), &req)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to add domain")
+		h.respondServiceError(w, err, "Failed to add domain")
+		return
+	}
+
+	h.respondJSON(w, http.StatusCreated, domainResponse(domain))
+}
+
+func (h *Handlers) GetDomain(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid domain ID")
+		return
+	}
+
+	domain, err := h.service.Domain.GetDomain(r.Context(), id)
+	if err != nil {
+		h.respondError(w, http.StatusNotFound, "Domain not found")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, domainResponse(domain))
+}
+
+func (h *Handlers) ListDomains(w http.ResponseWriter, r *http.Request) {
+	tenantIDStr := chi.URLParam(r, "tenant_id")
+
+	// Resolve tenant ID (UUID or Clerk ID)
+	tenantID, err := h.resolveTenantID(r.Context(), tenantIDStr)
+	if err != nil {
+		// If tenant doesn't exist, return empty array
+		h.respondJSON(w, http.StatusOK, []interface{}{})
+		return
+	}
+
+	domains, err := h.service.Domain.ListByTenant(r.Context(), tenantID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to list domains")
+		h.respondError(w, http.StatusInternalServerError, "Failed to list domains")
+		return
+	}
+
+	response := make([]map[string]interface{}, 0, len(domains))
+	for _, domain := range domains {
+		response = append(response, domainResponse(domain))
+	}
+
+	h.respondJSON(w, http.StatusOK, response)
+}
+
+func (h *Handlers) VerifyDomain(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid domain ID")
+		return
+	}
+
+	domain, err := h.service.Domain.VerifyDomain(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondError(w, http.StatusNotFound, "Domain not found")
+			return
+		}
+		h.logger.Error().Err(err).Str("id", id.String()).Msg("Failed to verify domain")
+		h.respondServiceError(w, err, "Failed to verify domain")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, domainResponse(domain))
+}
+
+func (h *Handlers) DeleteDomain(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid domain ID")
+		return
+	}
+
+	if err := h.service.Domain.RemoveDomain(r.Context(), id); err != nil {
+		h.logger.Error().Err(err).Str("id", id.String()).Msg("Failed to delete domain")
+		h.respondError(w, http.StatusInternalServerError, "Failed to delete domain")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// domainResponse adds the TXT record to publish to a domain's JSON form
+func domainResponse(domain *models.TenantDomain) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                  domain.ID,
+		"tenant_id":           domain.TenantID,
+		"domain":              domain.Domain,
+		"status":              domain.Status,
+		"verified_at":         domain.VerifiedAt,
+		"verification_record": service.VerificationRecord(domain),
+		"created_at":          domain.CreatedAt,
+		"updated_at":          domain.UpdatedAt,
+	}
+}
+
+// PurgeCache invalidates cached responses of a tenant on every gateway
+// instance and in the shared cache tier
+func (h *Handlers) PurgeCache(w http.ResponseWriter, r *http.Request) {
+	var req service.PurgeCacheRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	result, err := h.service.Cache.Purge(r.Context(), &req)
+	if errors.Is(err, service.ErrCachePurgeUnavailable) {
+		h.respondError(w, http.StatusServiceUnavailable, "Cache purging is unavailable")
+		return
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Str("tenant_id", req.TenantID.String()).Msg("Failed to purge cache")
+		h.respondServiceError(w, err, "Failed to purge cache")
+		return
+	}
+
+	h.respondJSON(w, http.StatusAccepted, result)
+}
+
+// ListCircuitBreakers reports the route's breakers on every gateway instance
+func (h *Handlers) ListCircuitBreakers(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid route ID")
+		return
+	}
+
+	states, err := h.service.Circuit.ListByRoute(r.Context(), id)
+	if errors.Is(err, service.ErrCircuitStateUnavailable) {
+		h.respondError(w, http.StatusServiceUnavailable, "Circuit breaker state is unavailable")
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		h.respondError(w, http.StatusNotFound, "Route not found")
+		return
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Str("route_id", id.String()).Msg("Failed to list circuit breakers")
+		h.respondError(w, http.StatusInternalServerError, "Failed to list circuit breakers")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, states)
+}
+
 func (h *Handlers) respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -636,3 +1127,14 @@ func (h *Handlers) respondJSON(w http.ResponseWriter, status int, data interface
 func (h *Handlers) respondError(w http.ResponseWriter, status int, message string) {
 	h.respondJSON(w, status, map[string]string{"error": message})
 }
+
+// respondServiceError reports validation errors as 400 with their message and
+// anything else as 500 with the given generic message
+func (h *Handlers) respondServiceError(w http.ResponseWriter, err error, message string) {
+	var validationErr *service.ValidationError
+	if errors.As(err, &validationErr) {
+		h.respondError(w, http.StatusBadRequest, validationErr.Error())
+		return
+	}
+	h.respondError(w, http.StatusInternalServerError, message)
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/controlplane/service"
+	"github.com/vantageedge/backend/internal/models"
+	"github.com/vantageedge/backend/internal/repository"
+	"github.com/vantageedge/backend/pkg/logger"
+)
+
+// fakeAPIKeys stores rate limit overrides, implementing only the methods the
+// rate limit endpoints call
+type fakeAPIKeys struct {
+	repository.APIKeyRepository
+	keys map[uuid.UUID]*models.APIKey
+}
+
+func (f *fakeAPIKeys) SetRateLimitOverride(ctx context.Context, id uuid.UUID, override *int) (*models.APIKey, error) {
+	key, ok := f.keys[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	key.RateLimitOverride = override
+	return key, nil
+}
+
+func (f *fakeAPIKeys) Create(ctx context.Context, key *models.APIKey) error {
+	key.ID = uuid.New()
+	f.keys[key.ID] = key
+	return nil
+}
+
+func newAPIKeyRouter(keys *fakeAPIKeys) http.Handler {
+	log := logger.New("error", "json")
+	repos := &repository.Repository{APIKey: keys}
+	h := New(&service.Service{APIKey: service.NewAPIKeyService(repos, log)}, log)
+	r := chi.NewRouter()
+	h.RegisterRoutes(r)
+	return r
+}
+
+func TestAPIKeyRateLimitEndpoints(t *testing.T) {
+	key := &models.APIKey{ID: uuid.New(), Name: "ci"}
+	keys := &fakeAPIKeys{keys: map[uuid.UUID]*models.APIKey{key.ID: key}}
+	router := newAPIKeyRouter(keys)
+
+	send := func(method, id, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/api-keys/"+id+"/rate-limit", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	w := send(http.MethodPut, key.ID.String(), `{"rate_limit_override": 50}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("set: got %d %s", w.Code, w.Body.String())
+	}
+	var got models.APIKey
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.RateLimitOverride == nil || *got.RateLimitOverride != 50 {
+		t.Fatalf("expected an override of 50 in the response, got %v", got.RateLimitOverride)
+	}
+
+	for _, body := range []string{`{"rate_limit_override": 0}`, `{"rate_limit_override": -5}`} {
+		if w := send(http.MethodPut, key.ID.String(), body); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "rate_limit_override") {
+			t.Fatalf("%s: expected a validation error, got %d %s", body, w.Code, w.Body.String())
+		}
+	}
+	if key.RateLimitOverride == nil || *key.RateLimitOverride != 50 {
+		t.Fatalf("expected invalid overrides not to be stored, got %v", key.RateLimitOverride)
+	}
+
+	// A null override clears it like DELETE does
+	if w := send(http.MethodPut, key.ID.String(), `{"rate_limit_override": null}`); w.Code != http.StatusOK || key.RateLimitOverride != nil {
+		t.Fatalf("put null: got %d, override %v", w.Code, key.RateLimitOverride)
+	}
+	override := 10
+	key.RateLimitOverride = &override
+	if w := send(http.MethodDelete, key.ID.String(), ""); w.Code != http.StatusOK || key.RateLimitOverride != nil {
+		t.Fatalf("delete: got %d, override %v", w.Code, key.RateLimitOverride)
+	}
+
+	unknown := uuid.New().String()
+	if w := send(http.MethodPut, unknown, `{"rate_limit_override": 5}`); w.Code != http.StatusNotFound {
+		t.Fatalf("put on an unknown key: got %d", w.Code)
+	}
+	if w := send(http.MethodDelete, unknown, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("delete on an unknown key: got %d", w.Code)
+	}
+	if w := send(http.MethodPut, "not-a-uuid", `{"rate_limit_override": 5}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("put with an invalid id: got %d", w.Code)
+	}
+}
+
+func TestCreateAPIKeyRateLimitOverride(t *testing.T) {
+	keys := &fakeAPIKeys{keys: map[uuid.UUID]*models.APIKey{}}
+	router := newAPIKeyRouter(keys)
+	tenantID := uuid.New().String()
+
+	create := func(override string) *httptest.ResponseRecorder {
+		body := `{"tenant_id": "` + tenantID + `", "name": "ci"`
+		if override != "" {
+			body += `, "rate_limit_override": ` + override
+		}
+		r := httptest.NewRequest(http.MethodPost, "/api-keys/", strings.NewReader(body+"}"))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	for override, want := range map[string]*int{"": nil, "null": nil, "100": intPtr(100), "1e2": intPtr(100)} {
+		w := create(override)
+		if w.Code != http.StatusCreated && w.Code != http.StatusOK {
+			t.Fatalf("%q: got %d %s", override, w.Code, w.Body.String())
+		}
+		var got struct {
+			RateLimitOverride *int `json:"rate_limit_override"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if (got.RateLimitOverride == nil) != (want == nil) || (want != nil && *got.RateLimitOverride != *want) {
+			t.Fatalf("%q: got override %v, want %v", override, got.RateLimitOverride, want)
+		}
+	}
+
+	created := len(keys.keys)
+	for _, override := range []string{`"100"`, "0.5", "0", "-1", "1e30", "true", "[100]"} {
+		if w := create(override); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "rate_limit_override") {
+			t.Errorf("%s: expected a 400 about the override, got %d %s", override, w.Code, w.Body.String())
+		}
+	}
+	if len(keys.keys) != created {
+		t.Fatal("expected no key to be created with an invalid override")
+	}
+}
+
+func intPtr(v int) *int {
+	return &v
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
 
 	"github.com/google/uuid"
 	"github.com/vantageedge/backend/internal/models"
@@ -16,14 +17,16 @@ type APIKeyService interface {
 	ListByTenant(ctx context.Context, tenantID uuid.UUID) ([]*models.APIKey, error)
 	CreateAPIKey(ctx context.Context, req *CreateAPIKeyRequest) (*models.APIKey, string, error)
 	DeleteAPIKey(ctx context.Context, id uuid.UUID) error
+	SetRateLimitOverride(ctx context.Context, id uuid.UUID, override *int) (*models.APIKey, error)
 }
 
 type CreateAPIKeyRequest struct {
-	TenantID  uuid.UUID  `json:"tenant_id"`
-	UserID    *uuid.UUID `json:"user_id,omitempty"`
-	Name      string     `json:"name"`
-	Scopes    []string   `json:"scopes"`
-	ExpiresAt *string    `json:"expires_at,omitempty"`
+	TenantID          uuid.UUID  `json:"tenant_id"`
+	UserID            *uuid.UUID `json:"user_id,omitempty"`
+	Name              string     `json:"name"`
+	Scopes            []string   `json:"scopes"`
+	RateLimitOverride *int       `json:"rate_limit_override,omitempty"`
+	ExpiresAt         *string    `json:"expires_at,omitempty"`
 }
 
 type apiKeyService struct {
@@ -40,6 +43,10 @@ func (s *apiKeyService) ListByTenant(ctx context.Context, tenantID uuid.UUID) ([
 }
 
 func (s *apiKeyService) CreateAPIKey(ctx context.Context, req *CreateAPIKeyRequest) (*models.APIKey, string, error) {
+	if err := validateRateLimitOverride(req.RateLimitOverride); err != nil {
+		return nil, "", err
+	}
+
 	// Generate secure random key (32 bytes = 64 hex characters)
 	randomBytes := make([]byte, 32)
 	if _, err := rand.Read(randomBytes); err != nil {
@@ -56,15 +63,16 @@ func (s *apiKeyService) CreateAPIKey(ctx context.Context, req *CreateAPIKeyReque
 	keyHash := hex.EncodeToString(hash[:])
 
 	key := &models.APIKey{
-		TenantID:   req.TenantID,
-		UserID:     req.UserID,
-		Name:       req.Name,
-		KeyPrefix:  "ve_live_",
-		KeyHash:    keyHash,
-		Scopes:     models.StringArray(req.Scopes),
-		IsActive:   true,
-		UsageCount: 0,
-		Metadata:   models.JSONB{},
+		TenantID:          req.TenantID,
+		UserID:            req.UserID,
+		Name:              req.Name,
+		KeyPrefix:         "ve_live_",
+		KeyHash:           keyHash,
+		Scopes:            models.StringArray(req.Scopes),
+		RateLimitOverride: req.RateLimitOverride,
+		IsActive:          true,
+		UsageCount:        0,
+		Metadata:          models.JSONB{},
 	}
 
 	// Set expiration if provided
@@ -86,3 +94,32 @@ func (s *apiKeyService) CreateAPIKey(ctx context.Context, req *CreateAPIKeyReque
 func (s *apiKeyService) DeleteAPIKey(ctx context.Context, id uuid.UUID) error {
 	return s.repos.APIKey.Delete(ctx, id)
 }
+
+// SetRateLimitOverride sets the requests per second granted to a key on
+// rate-limited routes, taking precedence over the route's own rate. A nil
+// override clears it.
+func (s *apiKeyService) SetRateLimitOverride(ctx context.Context, id uuid.UUID, override *int) (*models.APIKey, error) {
+	if err := validateRateLimitOverride(override); err != nil {
+		return nil, err
+	}
+
+	key, err := s.repos.APIKey.SetRateLimitOverride(ctx, id, override)
+	if err != nil {
+		s.logger.Error().Err(err).Str("key_id", id.String()).Msg("Failed to set API key rate limit override")
+		return nil, err
+	}
+
+	if override == nil {
+		s.logger.Info().Str("key_id", id.String()).Msg("API key rate limit override cleared")
+	} else {
+		s.logger.Info().Str("key_id", id.String()).Int("rate_limit_override", *override).Msg("API key rate limit override set")
+	}
+	return key, nil
+}
+
+func validateRateLimitOverride(override *int) error {
+	if override != nil && *override <= 0 {
+		return &ValidationError{Field: "rate_limit_override", Err: fmt.Errorf("must be a positive number of requests per second")}
+	}
+	return nil
+}
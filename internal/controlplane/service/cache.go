@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/gateway/middleware"
+	"github.com/vantageedge/backend/internal/repository"
+	"github.com/vantageedge/backend/pkg/logger"
+)
+
+// ErrCachePurgeUnavailable is returned when the control plane has no
+// connection to the Redis instance shared with the gateways
+var ErrCachePurgeUnavailable = errors.New("cache purging is unavailable")
+
+// CachePurger invalidates cached responses on every gateway instance.
+// *middleware.CachePurger satisfies it.
+type CachePurger interface {
+	Purge(ctx context.Context, p *middleware.CachePurge) (int, error)
+}
+
+type CacheService interface {
+	Purge(ctx context.Context, req *PurgeCacheRequest) (*PurgeCacheResult, error)
+}
+
+// PurgeCacheRequest selects cached responses of a tenant to purge: by exact
+// URL, by route, by path prefix or by the tags origins list in Surrogate-Key
+// or Cache-Tag. Responses matching any selector are purged.
+type PurgeCacheRequest struct {
+	TenantID   uuid.UUID  `json:"tenant_id"`
+	URLs       []string   `json:"urls,omitempty"`
+	RouteID    *uuid.UUID `json:"route_id,omitempty"`
+	PathPrefix string     `json:"path_prefix,omitempty"`
+	Tags       []string   `json:"tags,omitempty"`
+}
+
+type PurgeCacheResult struct {
+	// SharedEntriesPurged counts the entries deleted from the shared tier;
+	// gateway memory tiers are purged asynchronously
+	SharedEntriesPurged int `json:"shared_entries_purged"`
+}
+
+type cacheService struct {
+	repos  *repository.Repository
+	purger CachePurger
+	logger *logger.Logger
+}
+
+// NewCacheService returns the cache service. purger may be nil, in which
+// case purges fail with ErrCachePurgeUnavailable.
+func NewCacheService(repos *repository.Repository, purger CachePurger, log *logger.Logger) CacheService {
+	return &cacheService{repos: repos, purger: purger, logger: log}
+}
+
+func (s *cacheService) Purge(ctx context.Context, req *PurgeCacheRequest) (*PurgeCacheResult, error) {
+	purge, err := s.validatePurge(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if s.purger == nil {
+		return nil, ErrCachePurgeUnavailable
+	}
+
+	purged, err := s.purger.Purge(ctx, purge)
+	if err != nil {
+		return nil, fmt.Errorf("failed to purge cache: %w", err)
+	}
+	s.logger.Info().
+		Str("tenant_id", req.TenantID.String()).
+		Int("shared_entries", purged).
+		Msg("Cache purged")
+	return &PurgeCacheResult{SharedEntriesPurged: purged}, nil
+}
+
+func (s *cacheService) validatePurge(ctx context.Context, req *PurgeCacheRequest) (*middleware.CachePurge, error) {
+	if req.TenantID == uuid.Nil {
+		return nil, &ValidationError{Field: "tenant_id", Err: fmt.Errorf("is required")}
+	}
+
+	purge := &middleware.CachePurge{
+		TenantID:   req.TenantID,
+		RouteID:    req.RouteID,
+		PathPrefix: req.PathPrefix,
+	}
+	for _, raw := range req.URLs {
+		u, err := url.Parse(raw)
+		if err != nil || (u.Path != "" && !strings.HasPrefix(u.Path, "/")) || (u.Path == "" && u.Host == "") {
+			return nil, &ValidationError{Field: "urls", Err: fmt.Errorf("%q is not a path or absolute URL", raw)}
+		}
+		purge.URLs = append(purge.URLs, raw)
+	}
+	if req.PathPrefix != "" && !strings.HasPrefix(req.PathPrefix, "/") {
+		return nil, &ValidationError{Field: "path_prefix", Err: fmt.Errorf("must start with /")}
+	}
+	for _, tag := range req.Tags {
+		if tag = strings.TrimSpace(tag); tag == "" || strings.ContainsAny(tag, " ,") {
+			return nil, &ValidationError{Field: "tags", Err: fmt.Errorf("%q is not a valid tag", tag)}
+		}
+		purge.Tags = append(purge.Tags, tag)
+	}
+	if purge.Empty() {
+		return nil, &ValidationError{Field: "purge", Err: fmt.Errorf("one of urls, route_id, path_prefix or tags is required")}
+	}
+
+	if req.RouteID != nil {
+		route, err := s.repos.Route.GetByID(ctx, *req.RouteID)
+		if err != nil || route.TenantID != req.TenantID {
+			return nil, &ValidationError{Field: "route_id", Err: fmt.Errorf("route not found")}
+		}
+	}
+	return purge, nil
+}
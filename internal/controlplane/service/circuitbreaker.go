@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/gateway/circuitbreaker"
+	"github.com/vantageedge/backend/internal/repository"
+	"github.com/vantageedge/backend/pkg/logger"
+)
+
+// ErrCircuitStateUnavailable is returned when the control plane has no
+// connection to the Redis instance the gateways report to
+var ErrCircuitStateUnavailable = errors.New("circuit breaker state is unavailable")
+
+// CircuitReports loads the circuit breaker reports of the gateway instances.
+// *circuitbreaker.Store satisfies it.
+type CircuitReports interface {
+	Load(ctx context.Context) ([]*circuitbreaker.Report, error)
+}
+
+type CircuitBreakerService interface {
+	ListByRoute(ctx context.Context, routeID uuid.UUID) ([]*CircuitBreakerState, error)
+}
+
+// CircuitBreakerState is the state of a route's breaker for one origin on
+// one gateway instance
+type CircuitBreakerState struct {
+	Instance string    `json:"instance"`
+	OriginID uuid.UUID `json:"origin_id"`
+	circuitbreaker.Status
+}
+
+type circuitBreakerService struct {
+	repos   *repository.Repository
+	reports CircuitReports
+	logger  *logger.Logger
+}
+
+// NewCircuitBreakerService returns the circuit breaker service. reports may
+// be nil, in which case listing fails with ErrCircuitStateUnavailable.
+func NewCircuitBreakerService(repos *repository.Repository, reports CircuitReports, log *logger.Logger) CircuitBreakerService {
+	return &circuitBreakerService{repos: repos, reports: reports, logger: log}
+}
+
+func (s *circuitBreakerService) ListByRoute(ctx context.Context, routeID uuid.UUID) ([]*CircuitBreakerState, error) {
+	if _, err := s.repos.Route.GetByID(ctx, routeID); err != nil {
+		return nil, fmt.Errorf("failed to get route: %w", err)
+	}
+	if s.reports == nil {
+		return nil, ErrCircuitStateUnavailable
+	}
+
+	reports, err := s.reports.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	states := make([]*CircuitBreakerState, 0)
+	for _, report := range reports {
+		for _, breaker := range report.Breakers {
+			if breaker.RouteID == routeID {
+				states = append(states, &CircuitBreakerState{
+					Instance: report.Instance,
+					OriginID: breaker.OriginID,
+					Status:   breaker.Status,
+				})
+			}
+		}
+	}
+	return states, nil
+}
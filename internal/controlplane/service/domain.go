@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/vantageedge/backend/internal/models"
+	"github.com/vantageedge/backend/internal/repository"
+	"github.com/vantageedge/backend/pkg/logger"
+)
+
+const (
+	// domainVerificationLabel is prepended to a custom domain to build the
+	// name of the TXT record holding its verification token
+	domainVerificationLabel = "_vantageedge-challenge"
+
+	// domainVerificationPrefix prefixes the token inside the TXT record value
+	domainVerificationPrefix = "vantageedge-verification="
+)
+
+// TXTResolver looks up DNS TXT records. *net.Resolver satisfies it.
+type TXTResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// NewTXTResolver returns a resolver querying the given "host:port" nameserver,
+// or the system resolver when addr is empty
+func NewTXTResolver(addr string) TXTResolver {
+	if addr == "" {
+		return net.DefaultResolver
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+}
+
+type DomainService interface {
+	AddDomain(ctx context.Context, req *AddDomainRequest) (*models.TenantDomain, error)
+	GetDomain(ctx context.Context, id uuid.UUID) (*models.TenantDomain, error)
+	ListByTenant(ctx context.Context, tenantID uuid.UUID) ([]*models.TenantDomain, error)
+	VerifyDomain(ctx context.Context, id uuid.UUID) (*models.TenantDomain, error)
+	RemoveDomain(ctx context.Context, id uuid.UUID) error
+}
+
+type AddDomainRequest struct {
+	TenantID uuid.UUID `json:"tenant_id"`
+	Domain   string    `json:"domain"`
+}
+
+// DomainVerificationRecord describes the TXT record a tenant must publish to
+// prove ownership of a custom domain
+type DomainVerificationRecord struct {
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// VerificationRecord returns the TXT record expected for a domain
+func VerificationRecord(domain *models.TenantDomain) DomainVerificationRecord {
+	return DomainVerificationRecord{
+		Type:  "TXT",
+		Name:  domainVerificationLabel + "." + domain.Domain,
+		Value: domainVerificationPrefix + domain.VerificationToken,
+	}
+}
+
+type domainService struct {
+	repos         *repository.Repository
+	resolver      TXTResolver
+	gatewayDomain string
+	logger        *logger.Logger
+}
+
+func NewDomainService(repos *repository.Repository, resolver TXTResolver, gatewayDomain string, log *logger.Logger) DomainService {
+	return &domainService{
+		repos:         repos,
+		resolver:      resolver,
+		gatewayDomain: strings.ToLower(gatewayDomain),
+		logger:        log,
+	}
+}
+
+func (s *domainService) AddDomain(ctx context.Context, req *AddDomainRequest) (*models.TenantDomain, error) {
+	name, err := s.normalizeDomain(req.Domain)
+	if err != nil {
+		return nil, &ValidationError{Field: "domain", Err: err}
+	}
+
+	tokenBytes := make([]byte, 16)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		s.logger.Error().Err(err).Msg("Failed to generate verification token")
+		return nil, err
+	}
+
+	domain := &models.TenantDomain{
+		TenantID:          req.TenantID,
+		Domain:            name,
+		VerificationToken: hex.EncodeToString(tokenBytes),
+	}
+
+	if err := s.repos.Domain.Create(ctx, domain); err != nil {
+		if isUniqueViolation(err) {
+			return nil, &ValidationError{Field: "domain", Err: fmt.Errorf("%s is already registered for this tenant", name)}
+		}
+		s.logger.Error().Err(err).Msg("Failed to create tenant domain")
+		return nil, err
+	}
+
+	s.logger.Info().Str("domain_id", domain.ID.String()).Str("domain", domain.Domain).Msg("Tenant domain added")
+	return domain, nil
+}
+
+func (s *domainService) GetDomain(ctx context.Context, id uuid.UUID) (*models.TenantDomain, error) {
+	return s.repos.Domain.GetByID(ctx, id)
+}
+
+func (s *domainService) ListByTenant(ctx context.Context, tenantID uuid.UUID) ([]*models.TenantDomain, error) {
+	return s.repos.Domain.ListByTenant(ctx, tenantID)
+}
+
+// VerifyDomain looks up the domain's TXT challenge record and marks the domain
+// verified when it carries the expected token
+func (s *domainService) VerifyDomain(ctx context.Context, id uuid.UUID) (*models.TenantDomain, error) {
+	domain, err := s.repos.Domain.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if domain.Status == "verified" {
+		return domain, nil
+	}
+
+	record := VerificationRecord(domain)
+	values, err := s.resolver.LookupTXT(ctx, record.Name)
+	if err != nil {
+		s.logger.Warn().Err(err).Str("domain", domain.Domain).Msg("Domain verification lookup failed")
+		return nil, &ValidationError{Field: "domain", Err: fmt.Errorf("no TXT record found at %s", record.Name)}
+	}
+
+	found := false
+	for _, value := range values {
+		if strings.TrimSpace(value) == record.Value {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, &ValidationError{Field: "domain", Err: fmt.Errorf("TXT record at %s does not contain the verification token", record.Name)}
+	}
+
+	if err := s.repos.Domain.MarkVerified(ctx, id); err != nil {
+		if isUniqueViolation(err) {
+			return nil, &ValidationError{Field: "domain", Err: fmt.Errorf("%s is already verified by another tenant", domain.Domain)}
+		}
+		s.logger.Error().Err(err).Str("domain_id", id.String()).Msg("Failed to mark domain verified")
+		return nil, err
+	}
+
+	s.logger.Info().Str("domain_id", id.String()).Str("domain", domain.Domain).Msg("Tenant domain verified")
+	return s.repos.Domain.GetByID(ctx, id)
+}
+
+func (s *domainService) RemoveDomain(ctx context.Context, id uuid.UUID) error {
+	if err := s.repos.Domain.Delete(ctx, id); err != nil {
+		s.logger.Error().Err(err).Str("domain_id", id.String()).Msg("Failed to delete tenant domain")
+		return err
+	}
+
+	s.logger.Info().Str("domain_id", id.String()).Msg("Tenant domain removed")
+	return nil
+}
+
+// normalizeDomain lowercases a host name and checks that it is a valid fully
+// qualified domain outside the gateway's own domain
+func (s *domainService) normalizeDomain(raw string) (string, error) {
+	name := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(raw)), ".")
+	if name == "" {
+		return "", fmt.Errorf("domain is required")
+	}
+	if len(name) > 253 {
+		return "", fmt.Errorf("domain is too long")
+	}
+
+	labels := strings.Split(name, ".")
+	if len(labels) < 2 {
+		return "", fmt.Errorf("domain must be fully qualified")
+	}
+	for _, label := range labels {
+		if !isDNSLabel(label) {
+			return "", fmt.Errorf("invalid domain label %q", label)
+		}
+	}
+
+	if s.gatewayDomain != "" && (name == s.gatewayDomain || strings.HasSuffix(name, "."+s.gatewayDomain)) {
+		return "", fmt.Errorf("domains under %s are served through tenant subdomains", s.gatewayDomain)
+	}
+
+	return name, nil
+}
+
+func isDNSLabel(label string) bool {
+	if len(label) == 0 || len(label) > 63 {
+		return false
+	}
+	if label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+	for _, c := range label {
+		if !(c >= 'a' && c <= 'z') && !(c >= '0' && c <= '9') && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+func isUniqueViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23505"
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/models"
+	"github.com/vantageedge/backend/internal/repository"
+	"github.com/vantageedge/backend/pkg/logger"
+)
+
+// stubResolver serves TXT records from a map, failing like a resolver does
+// for names without records
+type stubResolver map[string][]string
+
+func (r stubResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	values, ok := r[name]
+	if !ok {
+		return nil, fmt.Errorf("lookup %s: no such host", name)
+	}
+	return values, nil
+}
+
+// fakeDomains keeps tenant domains in memory, implementing only the methods
+// VerifyDomain calls
+type fakeDomains struct {
+	repository.TenantDomainRepository
+	domains map[uuid.UUID]*models.TenantDomain
+}
+
+func (f *fakeDomains) GetByID(ctx context.Context, id uuid.UUID) (*models.TenantDomain, error) {
+	domain, ok := f.domains[id]
+	if !ok {
+		return nil, fmt.Errorf("domain %s not found", id)
+	}
+	copied := *domain
+	return &copied, nil
+}
+
+func (f *fakeDomains) MarkVerified(ctx context.Context, id uuid.UUID) error {
+	f.domains[id].Status = "verified"
+	return nil
+}
+
+func TestVerifyDomain(t *testing.T) {
+	const token = "0123456789abcdef"
+	challenge := "_vantageedge-challenge.api.example.com"
+
+	tests := []struct {
+		name    string
+		records stubResolver
+		wantErr string
+	}{
+		{
+			name:    "matching token",
+			records: stubResolver{challenge: {"unrelated", " vantageedge-verification=" + token + " "}},
+		},
+		{
+			name:    "missing record",
+			records: stubResolver{},
+			wantErr: "no TXT record found at " + challenge,
+		},
+		{
+			name:    "wrong token",
+			records: stubResolver{challenge: {"vantageedge-verification=fedcba9876543210"}},
+			wantErr: "does not contain the verification token",
+		},
+		{
+			name:    "record on the domain itself",
+			records: stubResolver{"api.example.com": {"vantageedge-verification=" + token}},
+			wantErr: "no TXT record found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			domain := &models.TenantDomain{ID: uuid.New(), Domain: "api.example.com", VerificationToken: token, Status: "pending"}
+			domains := &fakeDomains{domains: map[uuid.UUID]*models.TenantDomain{domain.ID: domain}}
+			svc := NewDomainService(&repository.Repository{Domain: domains}, tt.records, "vantageedge.io", logger.New("error", "json"))
+
+			got, err := svc.VerifyDomain(context.Background(), domain.ID)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Status != "verified" {
+					t.Fatalf("expected the domain verified, got status %q", got.Status)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected a validation error containing %q, got %v", tt.wantErr, err)
+			}
+			if domain.Status != "pending" {
+				t.Fatalf("expected the domain to stay pending, got %q", domain.Status)
+			}
+		})
+	}
+}
+
+func TestNormalizeDomain(t *testing.T) {
+	s := &domainService{gatewayDomain: "vantageedge.io"}
+
+	valid := map[string]string{
+		"api.example.com":       "api.example.com",
+		"  API.Example.COM.  ":  "api.example.com",
+		"a-b.c-d.example.co.uk": "a-b.c-d.example.co.uk",
+		"xn--bcher-kva.example": "xn--bcher-kva.example",
+		"vantageedge.io.evil":   "vantageedge.io.evil",
+		"myvantageedge.io":      "myvantageedge.io",
+	}
+	for raw, want := range valid {
+		if got, err := s.normalizeDomain(raw); err != nil || got != want {
+			t.Errorf("%q: got %q, %v, want %q", raw, got, err, want)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"localhost",
+		"-api.example.com",
+		"api-.example.com",
+		"api..example.com",
+		"api_v1.example.com",
+		"api.example.com:8080",
+		strings.Repeat("a", 64) + ".example.com",
+		strings.Repeat("abcdefghi.", 26) + "com",
+		"vantageedge.io",
+		"acme.vantageedge.io",
+	}
+	for _, raw := range invalid {
+		if got, err := s.normalizeDomain(raw); err == nil {
+			t.Errorf("%q: expected an error, got %q", raw, got)
+		}
+	}
+}
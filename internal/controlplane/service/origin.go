@@ -2,8 +2,11 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/loadbalancer"
 	"github.com/vantageedge/backend/internal/models"
 	"github.com/vantageedge/backend/internal/repository"
 	"github.com/vantageedge/backend/pkg/logger"
@@ -17,22 +20,50 @@ type OriginService interface {
 	DeleteOrigin(ctx context.Context, id uuid.UUID) error
 }
 
+// DefaultOriginWeight is the weight of origins created without one
+const DefaultOriginWeight = 100
+
+// Health check options of origins created without them
+const (
+	DefaultHealthCheckInterval = 30 // seconds
+	DefaultHealthCheckTimeout  = 5  // seconds
+	DefaultHealthyThreshold    = 2
+	DefaultUnhealthyThreshold  = 3
+	DefaultExpectedStatuses    = "200-299"
+)
+
 type CreateOriginRequest struct {
 	TenantID            uuid.UUID `json:"tenant_id"`
 	Name                string    `json:"name"`
 	URL                 string    `json:"url"`
+	HealthCheckType     string    `json:"health_check_type"`
 	HealthCheckPath     string    `json:"health_check_path"`
 	HealthCheckInterval int       `json:"health_check_interval"`
+	HealthCheckTimeout  int       `json:"health_check_timeout_seconds"`
+	HealthyThreshold    int       `json:"healthy_threshold"`
+	UnhealthyThreshold  int       `json:"unhealthy_threshold"`
+	ExpectedStatuses    string    `json:"health_check_expected_statuses"`
+	BodyMatch           string    `json:"health_check_body_match"`
 	TimeoutSeconds      int       `json:"timeout_seconds"`
 	MaxRetries          int       `json:"max_retries"`
 	Weight              int       `json:"weight"`
 }
 
+// UpdateOriginRequest replaces an origin's name, URL and timeout. A zero
+// Weight and nil health check options keep the current ones.
 type UpdateOriginRequest struct {
-	Name           string `json:"name"`
-	URL            string `json:"url"`
-	TimeoutSeconds int    `json:"timeout_seconds"`
-	Weight         int    `json:"weight"`
+	Name                string  `json:"name"`
+	URL                 string  `json:"url"`
+	TimeoutSeconds      int     `json:"timeout_seconds"`
+	Weight              int     `json:"weight"`
+	HealthCheckType     *string `json:"health_check_type"`
+	HealthCheckPath     *string `json:"health_check_path"`
+	HealthCheckInterval *int    `json:"health_check_interval"`
+	HealthCheckTimeout  *int    `json:"health_check_timeout_seconds"`
+	HealthyThreshold    *int    `json:"healthy_threshold"`
+	UnhealthyThreshold  *int    `json:"unhealthy_threshold"`
+	ExpectedStatuses    *string `json:"health_check_expected_statuses"`
+	BodyMatch           *string `json:"health_check_body_match"`
 }
 
 type originService struct {
@@ -45,17 +76,33 @@ func NewOriginService(repos *repository.Repository, log *logger.Logger) OriginSe
 }
 
 func (s *originService) CreateOrigin(ctx context.Context, req *CreateOriginRequest) (*models.Origin, error) {
+	if err := validateWeight(req.Weight); err != nil {
+		return nil, err
+	}
+	if req.Weight == 0 {
+		req.Weight = DefaultOriginWeight
+	}
+
 	origin := &models.Origin{
 		TenantID:            req.TenantID,
 		Name:                req.Name,
 		URL:                 req.URL,
+		HealthCheckType:     orDefault(req.HealthCheckType, loadbalancer.HealthCheckHTTP),
 		HealthCheckPath:     req.HealthCheckPath,
-		HealthCheckInterval: req.HealthCheckInterval,
+		HealthCheckInterval: orDefault(req.HealthCheckInterval, DefaultHealthCheckInterval),
+		HealthCheckTimeout:  orDefault(req.HealthCheckTimeout, DefaultHealthCheckTimeout),
+		HealthyThreshold:    orDefault(req.HealthyThreshold, DefaultHealthyThreshold),
+		UnhealthyThreshold:  orDefault(req.UnhealthyThreshold, DefaultUnhealthyThreshold),
+		ExpectedStatuses:    orDefault(strings.TrimSpace(req.ExpectedStatuses), DefaultExpectedStatuses),
+		BodyMatch:           req.BodyMatch,
 		TimeoutSeconds:      req.TimeoutSeconds,
 		MaxRetries:          req.MaxRetries,
 		Weight:              req.Weight,
 		IsHealthy:           true,
 	}
+	if err := validateHealthCheck(origin); err != nil {
+		return nil, err
+	}
 
 	if err := s.repos.Origin.Create(ctx, origin); err != nil {
 		s.logger.Error().Err(err).Msg("Failed to create origin")
@@ -75,6 +122,10 @@ func (s *originService) ListByTenant(ctx context.Context, tenantID uuid.UUID) ([
 }
 
 func (s *originService) UpdateOrigin(ctx context.Context, id uuid.UUID, req *UpdateOriginRequest) (*models.Origin, error) {
+	if err := validateWeight(req.Weight); err != nil {
+		return nil, err
+	}
+
 	origin, err := s.repos.Origin.GetByID(ctx, id)
 	if err != nil {
 		s.logger.Error().Err(err).Str("origin_id", id.String()).Msg("Origin not found")
@@ -84,7 +135,20 @@ func (s *originService) UpdateOrigin(ctx context.Context, id uuid.UUID, req *Upd
 	origin.Name = req.Name
 	origin.URL = req.URL
 	origin.TimeoutSeconds = req.TimeoutSeconds
-	origin.Weight = req.Weight
+	if req.Weight != 0 {
+		origin.Weight = req.Weight
+	}
+	setIfPresent(&origin.HealthCheckType, req.HealthCheckType)
+	setIfPresent(&origin.HealthCheckPath, req.HealthCheckPath)
+	setIfPresent(&origin.HealthCheckInterval, req.HealthCheckInterval)
+	setIfPresent(&origin.HealthCheckTimeout, req.HealthCheckTimeout)
+	setIfPresent(&origin.HealthyThreshold, req.HealthyThreshold)
+	setIfPresent(&origin.UnhealthyThreshold, req.UnhealthyThreshold)
+	setIfPresent(&origin.ExpectedStatuses, req.ExpectedStatuses)
+	setIfPresent(&origin.BodyMatch, req.BodyMatch)
+	if err := validateHealthCheck(origin); err != nil {
+		return nil, err
+	}
 
 	if err := s.repos.Origin.Update(ctx, origin); err != nil {
 		s.logger.Error().Err(err).Str("origin_id", id.String()).Msg("Failed to update origin")
@@ -104,3 +168,52 @@ func (s *originService) DeleteOrigin(ctx context.Context, id uuid.UUID) error {
 	s.logger.Info().Str("origin_id", id.String()).Msg("Origin deleted")
 	return nil
 }
+
+// validateWeight checks an origin's load balancing weight; zero means unset
+func validateWeight(weight int) error {
+	if weight < 0 {
+		return &ValidationError{Field: "weight", Err: fmt.Errorf("must be positive")}
+	}
+	return nil
+}
+
+// validateHealthCheck checks an origin's active health check options
+func validateHealthCheck(origin *models.Origin) error {
+	switch origin.HealthCheckType {
+	case loadbalancer.HealthCheckHTTP, loadbalancer.HealthCheckTCP, loadbalancer.HealthCheckNone:
+	default:
+		return &ValidationError{Field: "health_check_type", Err: fmt.Errorf("must be http, tcp or none")}
+	}
+	if origin.HealthCheckInterval <= 0 {
+		return &ValidationError{Field: "health_check_interval", Err: fmt.Errorf("must be positive")}
+	}
+	if origin.HealthCheckTimeout <= 0 || origin.HealthCheckTimeout > origin.HealthCheckInterval {
+		return &ValidationError{Field: "health_check_timeout_seconds", Err: fmt.Errorf("must be positive and at most the interval")}
+	}
+	if origin.HealthyThreshold < 1 {
+		return &ValidationError{Field: "healthy_threshold", Err: fmt.Errorf("must be at least 1")}
+	}
+	if origin.UnhealthyThreshold < 1 {
+		return &ValidationError{Field: "unhealthy_threshold", Err: fmt.Errorf("must be at least 1")}
+	}
+	if _, err := loadbalancer.ParseStatusRanges(origin.ExpectedStatuses); err != nil {
+		return &ValidationError{Field: "health_check_expected_statuses", Err: err}
+	}
+	return nil
+}
+
+// orDefault returns v, or def when v is the zero value
+func orDefault[T comparable](v, def T) T {
+	var zero T
+	if v == zero {
+		return def
+	}
+	return v
+}
+
+// setIfPresent sets *dst to *v when v is given
+func setIfPresent[T any](dst *T, v *T) {
+	if v != nil {
+		*dst = *v
+	}
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/vantageedge/backend/internal/loadbalancer"
+	"github.com/vantageedge/backend/internal/models"
+	"github.com/vantageedge/backend/internal/repository"
+	"github.com/vantageedge/backend/pkg/logger"
+)
+
+type PoolService interface {
+	CreatePool(ctx context.Context, req *CreatePoolRequest) (*models.UpstreamPool, error)
+	GetPool(ctx context.Context, id uuid.UUID) (*models.UpstreamPool, error)
+	ListByTenant(ctx context.Context, tenantID uuid.UUID) ([]*models.UpstreamPool, error)
+	UpdatePool(ctx context.Context, id uuid.UUID, req *UpdatePoolRequest) (*models.UpstreamPool, error)
+	DeletePool(ctx context.Context, id uuid.UUID) error
+}
+
+type CreatePoolRequest struct {
+	TenantID       uuid.UUID   `json:"tenant_id"`
+	Name           string      `json:"name"`
+	Strategy       string      `json:"strategy"`
+	HashKeySource  string      `json:"hash_key_source"`
+	HashKeyName    string      `json:"hash_key_name"`
+	HashLoadFactor float64     `json:"hash_load_factor"`
+	OriginIDs      []uuid.UUID `json:"origin_ids"`
+}
+
+// UpdatePoolRequest replaces a pool's name, balancing options and members. A
+// nil OriginIDs keeps the members.
+type UpdatePoolRequest struct {
+	Name           string      `json:"name"`
+	Strategy       string      `json:"strategy"`
+	HashKeySource  string      `json:"hash_key_source"`
+	HashKeyName    string      `json:"hash_key_name"`
+	HashLoadFactor float64     `json:"hash_load_factor"`
+	OriginIDs      []uuid.UUID `json:"origin_ids"`
+}
+
+type poolService struct {
+	repos  *repository.Repository
+	logger *logger.Logger
+}
+
+func NewPoolService(repos *repository.Repository, log *logger.Logger) PoolService {
+	return &poolService{repos: repos, logger: log}
+}
+
+func (s *poolService) CreatePool(ctx context.Context, req *CreatePoolRequest) (*models.UpstreamPool, error) {
+	pool := &models.UpstreamPool{
+		TenantID:       req.TenantID,
+		Name:           strings.TrimSpace(req.Name),
+		Strategy:       req.Strategy,
+		HashKeySource:  req.HashKeySource,
+		HashKeyName:    strings.TrimSpace(req.HashKeyName),
+		HashLoadFactor: req.HashLoadFactor,
+		OriginIDs:      req.OriginIDs,
+		Metadata:       models.JSONB{},
+	}
+	if err := s.validatePool(ctx, pool); err != nil {
+		return nil, err
+	}
+
+	if err := s.repos.Pool.Create(ctx, pool); err != nil {
+		if isUniqueViolation(err) {
+			return nil, &ValidationError{Field: "name", Err: fmt.Errorf("a pool named %q already exists", pool.Name)}
+		}
+		s.logger.Error().Err(err).Msg("Failed to create pool")
+		return nil, err
+	}
+
+	s.logger.Info().Str("pool_id", pool.ID.String()).Str("name", pool.Name).Msg("Pool created")
+	return pool, nil
+}
+
+func (s *poolService) GetPool(ctx context.Context, id uuid.UUID) (*models.UpstreamPool, error) {
+	return s.repos.Pool.GetByID(ctx, id)
+}
+
+func (s *poolService) ListByTenant(ctx context.Context, tenantID uuid.UUID) ([]*models.UpstreamPool, error) {
+	return s.repos.Pool.ListByTenant(ctx, tenantID)
+}
+
+func (s *poolService) UpdatePool(ctx context.Context, id uuid.UUID, req *UpdatePoolRequest) (*models.UpstreamPool, error) {
+	pool, err := s.repos.Pool.GetByID(ctx, id)
+	if err != nil {
+		s.logger.Error().Err(err).Str("pool_id", id.String()).Msg("Pool not found")
+		return nil, err
+	}
+
+	pool.Name = strings.TrimSpace(req.Name)
+	pool.Strategy = req.Strategy
+	pool.HashKeySource = req.HashKeySource
+	pool.HashKeyName = strings.TrimSpace(req.HashKeyName)
+	pool.HashLoadFactor = req.HashLoadFactor
+	if req.OriginIDs != nil {
+		pool.OriginIDs = req.OriginIDs
+	}
+	if err := s.validatePool(ctx, pool); err != nil {
+		return nil, err
+	}
+
+	if err := s.repos.Pool.Update(ctx, pool); err != nil {
+		if isUniqueViolation(err) {
+			return nil, &ValidationError{Field: "name", Err: fmt.Errorf("a pool named %q already exists", pool.Name)}
+		}
+		s.logger.Error().Err(err).Str("pool_id", id.String()).Msg("Failed to update pool")
+		return nil, err
+	}
+
+	s.logger.Info().Str("pool_id", id.String()).Msg("Pool updated")
+	return pool, nil
+}
+
+func (s *poolService) DeletePool(ctx context.Context, id uuid.UUID) error {
+	if err := s.repos.Pool.Delete(ctx, id); err != nil {
+		if isForeignKeyViolation(err) {
+			return &ValidationError{Field: "pool", Err: fmt.Errorf("pool is used by routes")}
+		}
+		s.logger.Error().Err(err).Str("pool_id", id.String()).Msg("Failed to delete pool")
+		return err
+	}
+
+	s.logger.Info().Str("pool_id", id.String()).Msg("Pool deleted")
+	return nil
+}
+
+// validatePool checks the pool's name and balancing options and that its
+// members are distinct origins of its tenant
+func (s *poolService) validatePool(ctx context.Context, pool *models.UpstreamPool) error {
+	if pool.Name == "" {
+		return &ValidationError{Field: "name", Err: fmt.Errorf("is required")}
+	}
+	if pool.Strategy != "" {
+		if err := loadbalancer.ValidateStrategy(pool.Strategy); err != nil {
+			return &ValidationError{Field: "strategy", Err: err}
+		}
+	}
+	if err := loadbalancer.ValidateHashKey(pool.HashKeySource, pool.HashKeyName); err != nil {
+		return &ValidationError{Field: "hash_key_source", Err: err}
+	}
+	if err := loadbalancer.ValidateLoadFactor(pool.HashLoadFactor); err != nil {
+		return &ValidationError{Field: "hash_load_factor", Err: err}
+	}
+	if len(pool.OriginIDs) == 0 {
+		return &ValidationError{Field: "origin_ids", Err: fmt.Errorf("at least one origin is required")}
+	}
+
+	seen := make(map[uuid.UUID]bool, len(pool.OriginIDs))
+	for _, id := range pool.OriginIDs {
+		if seen[id] {
+			return &ValidationError{Field: "origin_ids", Err: fmt.Errorf("origin %s is listed twice", id)}
+		}
+		seen[id] = true
+		origin, err := s.repos.Origin.GetByID(ctx, id)
+		if err != nil || origin.TenantID != pool.TenantID {
+			return &ValidationError{Field: "origin_ids", Err: fmt.Errorf("origin %s not found", id)}
+		}
+	}
+	return nil
+}
+
+// isForeignKeyViolation reports whether err is a PostgreSQL foreign key
+// violation
+func isForeignKeyViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23503"
+}
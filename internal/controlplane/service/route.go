@@ -2,9 +2,16 @@ package service
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/gateway/middleware"
+	"github.com/vantageedge/backend/internal/gateway/pathpattern"
+	"github.com/vantageedge/backend/internal/gateway/proxy"
+	"github.com/vantageedge/backend/internal/gateway/retry"
+	"github.com/vantageedge/backend/internal/gateway/transform"
 	"github.com/vantageedge/backend/internal/models"
+	"github.com/vantageedge/backend/internal/ratelimit"
 	"github.com/vantageedge/backend/internal/repository"
 	"github.com/vantageedge/backend/pkg/logger"
 )
@@ -18,36 +25,73 @@ type RouteService interface {
 }
 
 type CreateRouteRequest struct {
-	TenantID                      uuid.UUID `json:"tenant_id"`
-	OriginID                      uuid.UUID `json:"origin_id"`
-	Name                          string    `json:"name"`
-	PathPattern                   string    `json:"path_pattern"`
-	Methods                       []string  `json:"methods"`
-	Priority                      int       `json:"priority"`
-	AuthMode                      string    `json:"auth_mode"`
-	IsActive                      bool      `json:"is_active"`
-	RateLimitEnabled              bool      `json:"rate_limit_enabled"`
-	RateLimitRequestsPerSecond    int       `json:"rate_limit_requests_per_second"`
-	RateLimitBurst                int       `json:"rate_limit_burst"`
-	RateLimitKeyStrategy          string    `json:"rate_limit_key_strategy"`
-	CacheEnabled                  bool      `json:"cache_enabled"`
-	CacheTTLSeconds               int       `json:"cache_ttl_seconds"`
-	CacheKeyPattern               string    `json:"cache_key_pattern"`
-	TimeoutSeconds                int       `json:"timeout_seconds"`
-	RetryAttempts                 int       `json:"retry_attempts"`
+	TenantID                         uuid.UUID               `json:"tenant_id"`
+	OriginID                         *uuid.UUID              `json:"origin_id"`
+	PoolID                           *uuid.UUID              `json:"pool_id"`
+	Name                             string                  `json:"name"`
+	PathPattern                      string                  `json:"path_pattern"`
+	Methods                          []string                `json:"methods"`
+	Priority                         int                     `json:"priority"`
+	AuthMode                         string                  `json:"auth_mode"`
+	IsActive                         bool                    `json:"is_active"`
+	RateLimitEnabled                 bool                    `json:"rate_limit_enabled"`
+	RateLimitRequestsPerSecond       int                     `json:"rate_limit_requests_per_second"`
+	RateLimitBurst                   int                     `json:"rate_limit_burst"`
+	RateLimitKeyStrategy             string                  `json:"rate_limit_key_strategy"`
+	CacheEnabled                     bool                    `json:"cache_enabled"`
+	CacheTTLSeconds                  int                     `json:"cache_ttl_seconds"`
+	CacheKeyPattern                  string                  `json:"cache_key_pattern"`
+	CacheBypassRules                 models.CacheBypassRules `json:"cache_bypass_rules"`
+	CacheStaleWhileRevalidateSeconds int                     `json:"cache_stale_while_revalidate_seconds"`
+	CacheStaleIfErrorSeconds         int                     `json:"cache_stale_if_error_seconds"`
+	TimeoutSeconds                   int                     `json:"timeout_seconds"`
+	RetryAttempts                    int                     `json:"retry_attempts"`
+	RetryOn                          []string                `json:"retry_on"`
+	RetryNonIdempotent               bool                    `json:"retry_non_idempotent"`
+	PathRewritePattern               *string                 `json:"path_rewrite_pattern"`
+	PathRewriteTarget                *string                 `json:"path_rewrite_target"`
+	StripPathPrefix                  *string                 `json:"strip_path_prefix"`
+	AddPathPrefix                    *string                 `json:"add_path_prefix"`
+	RequestHeaders                   models.JSONB            `json:"request_headers"`
+	ResponseHeaders                  models.JSONB            `json:"response_headers"`
+	RequestBodyTransform             models.JSONB            `json:"request_body_transform"`
+	ResponseBodyTransform            models.JSONB            `json:"response_body_transform"`
 }
 
+// UpdateRouteRequest replaces a route's settings. Nil path rewrite fields
+// keep the current ones and empty ones clear them; nil header and body rules
+// keep the current ones.
 type UpdateRouteRequest struct {
-	Name                       string   `json:"name"`
-	PathPattern                string   `json:"path_pattern"`
-	Methods                    []string `json:"methods"`
-	Priority                   int      `json:"priority"`
-	AuthMode                   string   `json:"auth_mode"`
-	IsActive                   bool     `json:"is_active"`
-	RateLimitEnabled           bool     `json:"rate_limit_enabled"`
-	RateLimitRequestsPerSecond int      `json:"rate_limit_requests_per_second"`
-	CacheEnabled               bool     `json:"cache_enabled"`
-	CacheTTLSeconds            int      `json:"cache_ttl_seconds"`
+	OriginID                         *uuid.UUID              `json:"origin_id"`
+	PoolID                           *uuid.UUID              `json:"pool_id"`
+	Name                             string                  `json:"name"`
+	PathPattern                      string                  `json:"path_pattern"`
+	Methods                          []string                `json:"methods"`
+	Priority                         int                     `json:"priority"`
+	AuthMode                         string                  `json:"auth_mode"`
+	IsActive                         bool                    `json:"is_active"`
+	RateLimitEnabled                 bool                    `json:"rate_limit_enabled"`
+	RateLimitRequestsPerSecond       int                     `json:"rate_limit_requests_per_second"`
+	RateLimitBurst                   int                     `json:"rate_limit_burst"`
+	RateLimitKeyStrategy             string                  `json:"rate_limit_key_strategy"`
+	CacheEnabled                     bool                    `json:"cache_enabled"`
+	CacheTTLSeconds                  int                     `json:"cache_ttl_seconds"`
+	CacheKeyPattern                  string                  `json:"cache_key_pattern"`
+	CacheBypassRules                 models.CacheBypassRules `json:"cache_bypass_rules"`
+	CacheStaleWhileRevalidateSeconds int                     `json:"cache_stale_while_revalidate_seconds"`
+	CacheStaleIfErrorSeconds         int                     `json:"cache_stale_if_error_seconds"`
+	TimeoutSeconds                   int                     `json:"timeout_seconds"`
+	RetryAttempts                    int                     `json:"retry_attempts"`
+	RetryOn                          []string                `json:"retry_on"`
+	RetryNonIdempotent               bool                    `json:"retry_non_idempotent"`
+	PathRewritePattern               *string                 `json:"path_rewrite_pattern"`
+	PathRewriteTarget                *string                 `json:"path_rewrite_target"`
+	StripPathPrefix                  *string                 `json:"strip_path_prefix"`
+	AddPathPrefix                    *string                 `json:"add_path_prefix"`
+	RequestHeaders                   models.JSONB            `json:"request_headers"`
+	ResponseHeaders                  models.JSONB            `json:"response_headers"`
+	RequestBodyTransform             models.JSONB            `json:"request_body_transform"`
+	ResponseBodyTransform            models.JSONB            `json:"response_body_transform"`
 }
 
 type routeService struct {
@@ -60,25 +104,91 @@ func NewRouteService(repos *repository.Repository, log *logger.Logger) RouteServ
 }
 
 func (s *routeService) CreateRoute(ctx context.Context, req *CreateRouteRequest) (*models.Route, error) {
+	if err := validatePathPattern(req.PathPattern); err != nil {
+		return nil, err
+	}
+	if err := validateKeyStrategy(req.RateLimitKeyStrategy); err != nil {
+		return nil, err
+	}
+	if req.RateLimitKeyStrategy == "" {
+		req.RateLimitKeyStrategy = ratelimit.DefaultKeyStrategy
+	}
+	if err := validateCacheSettings(req.CacheKeyPattern, req.CacheBypassRules); err != nil {
+		return nil, err
+	}
+	if err := validateStaleWindows(req.CacheStaleWhileRevalidateSeconds, req.CacheStaleIfErrorSeconds); err != nil {
+		return nil, err
+	}
+	if err := validateRetryPolicy(req.RetryAttempts, req.RetryOn); err != nil {
+		return nil, err
+	}
+	if err := validateHeaderRules(req.RequestHeaders, req.ResponseHeaders); err != nil {
+		return nil, err
+	}
+	if err := validateBodyRules(req.RequestBodyTransform, req.ResponseBodyTransform); err != nil {
+		return nil, err
+	}
+	if err := s.validateUpstream(ctx, req.TenantID, req.OriginID, req.PoolID); err != nil {
+		return nil, err
+	}
+	if req.CacheKeyPattern == "" {
+		req.CacheKeyPattern = middleware.DefaultCacheKeyPattern
+	}
+	if req.CacheBypassRules == nil {
+		req.CacheBypassRules = models.CacheBypassRules{}
+	}
+	if len(req.RetryOn) == 0 {
+		req.RetryOn = retry.DefaultConditions
+	}
+	if req.RequestHeaders == nil {
+		req.RequestHeaders = models.JSONB{}
+	}
+	if req.ResponseHeaders == nil {
+		req.ResponseHeaders = models.JSONB{}
+	}
+	if req.RequestBodyTransform == nil {
+		req.RequestBodyTransform = models.JSONB{}
+	}
+	if req.ResponseBodyTransform == nil {
+		req.ResponseBodyTransform = models.JSONB{}
+	}
+
 	route := &models.Route{
-		TenantID:                      req.TenantID,
-		OriginID:                      req.OriginID,
-		Name:                          req.Name,
-		PathPattern:                   req.PathPattern,
-		Methods:                       models.StringArray(req.Methods),
-		Priority:                      req.Priority,
-		AuthMode:                      req.AuthMode,
-		IsActive:                      req.IsActive,
-		RateLimitEnabled:              req.RateLimitEnabled,
-		RateLimitRequestsPerSecond:    req.RateLimitRequestsPerSecond,
-		RateLimitBurst:                req.RateLimitBurst,
-		RateLimitKeyStrategy:          req.RateLimitKeyStrategy,
-		CacheEnabled:                  req.CacheEnabled,
-		CacheTTLSeconds:               req.CacheTTLSeconds,
-		CacheKeyPattern:               req.CacheKeyPattern,
-		TimeoutSeconds:                req.TimeoutSeconds,
-		RetryAttempts:                 req.RetryAttempts,
-		Metadata:                      models.JSONB{},
+		TenantID:                         req.TenantID,
+		OriginID:                         req.OriginID,
+		PoolID:                           req.PoolID,
+		Name:                             req.Name,
+		PathPattern:                      req.PathPattern,
+		Methods:                          models.StringArray(req.Methods),
+		Priority:                         req.Priority,
+		AuthMode:                         req.AuthMode,
+		IsActive:                         req.IsActive,
+		RateLimitEnabled:                 req.RateLimitEnabled,
+		RateLimitRequestsPerSecond:       req.RateLimitRequestsPerSecond,
+		RateLimitBurst:                   req.RateLimitBurst,
+		RateLimitKeyStrategy:             req.RateLimitKeyStrategy,
+		CacheEnabled:                     req.CacheEnabled,
+		CacheTTLSeconds:                  req.CacheTTLSeconds,
+		CacheKeyPattern:                  req.CacheKeyPattern,
+		CacheBypassRules:                 req.CacheBypassRules,
+		CacheStaleWhileRevalidateSeconds: req.CacheStaleWhileRevalidateSeconds,
+		CacheStaleIfErrorSeconds:         req.CacheStaleIfErrorSeconds,
+		TimeoutSeconds:                   req.TimeoutSeconds,
+		RetryAttempts:                    req.RetryAttempts,
+		RetryOn:                          models.StringArray(req.RetryOn),
+		RetryNonIdempotent:               req.RetryNonIdempotent,
+		PathRewritePattern:               req.PathRewritePattern,
+		PathRewriteTarget:                req.PathRewriteTarget,
+		StripPathPrefix:                  req.StripPathPrefix,
+		AddPathPrefix:                    req.AddPathPrefix,
+		RequestHeaders:                   req.RequestHeaders,
+		ResponseHeaders:                  req.ResponseHeaders,
+		RequestBodyTransform:             req.RequestBodyTransform,
+		ResponseBodyTransform:            req.ResponseBodyTransform,
+		Metadata:                         models.JSONB{},
+	}
+	if err := validatePathRewrite(route); err != nil {
+		return nil, err
 	}
 
 	if err := s.repos.Route.Create(ctx, route); err != nil {
@@ -99,11 +209,39 @@ func (s *routeService) ListByTenant(ctx context.Context, tenantID uuid.UUID) ([]
 }
 
 func (s *routeService) UpdateRoute(ctx context.Context, id uuid.UUID, req *UpdateRouteRequest) (*models.Route, error) {
+	if err := validatePathPattern(req.PathPattern); err != nil {
+		return nil, err
+	}
+	if err := validateKeyStrategy(req.RateLimitKeyStrategy); err != nil {
+		return nil, err
+	}
+	if err := validateCacheSettings(req.CacheKeyPattern, req.CacheBypassRules); err != nil {
+		return nil, err
+	}
+	if err := validateStaleWindows(req.CacheStaleWhileRevalidateSeconds, req.CacheStaleIfErrorSeconds); err != nil {
+		return nil, err
+	}
+	if err := validateRetryPolicy(req.RetryAttempts, req.RetryOn); err != nil {
+		return nil, err
+	}
+	if err := validateHeaderRules(req.RequestHeaders, req.ResponseHeaders); err != nil {
+		return nil, err
+	}
+	if err := validateBodyRules(req.RequestBodyTransform, req.ResponseBodyTransform); err != nil {
+		return nil, err
+	}
+
 	route, err := s.repos.Route.GetByID(ctx, id)
 	if err != nil {
 		s.logger.Error().Err(err).Str("route_id", id.String()).Msg("Route not found")
 		return nil, err
 	}
+	if req.OriginID != nil || req.PoolID != nil {
+		if err := s.validateUpstream(ctx, route.TenantID, req.OriginID, req.PoolID); err != nil {
+			return nil, err
+		}
+		route.OriginID, route.PoolID = req.OriginID, req.PoolID
+	}
 
 	route.Name = req.Name
 	route.PathPattern = req.PathPattern
@@ -113,8 +251,55 @@ func (s *routeService) UpdateRoute(ctx context.Context, id uuid.UUID, req *Updat
 	route.IsActive = req.IsActive
 	route.RateLimitEnabled = req.RateLimitEnabled
 	route.RateLimitRequestsPerSecond = req.RateLimitRequestsPerSecond
+	route.RateLimitBurst = req.RateLimitBurst
+	if req.RateLimitKeyStrategy != "" {
+		route.RateLimitKeyStrategy = req.RateLimitKeyStrategy
+	}
 	route.CacheEnabled = req.CacheEnabled
 	route.CacheTTLSeconds = req.CacheTTLSeconds
+	if req.CacheKeyPattern != "" {
+		route.CacheKeyPattern = req.CacheKeyPattern
+	}
+	if req.CacheBypassRules != nil {
+		route.CacheBypassRules = req.CacheBypassRules
+	}
+	route.CacheStaleWhileRevalidateSeconds = req.CacheStaleWhileRevalidateSeconds
+	route.CacheStaleIfErrorSeconds = req.CacheStaleIfErrorSeconds
+	if req.TimeoutSeconds > 0 {
+		route.TimeoutSeconds = req.TimeoutSeconds
+	}
+	route.RetryAttempts = req.RetryAttempts
+	if len(req.RetryOn) > 0 {
+		route.RetryOn = models.StringArray(req.RetryOn)
+	}
+	route.RetryNonIdempotent = req.RetryNonIdempotent
+	if req.PathRewritePattern != nil {
+		route.PathRewritePattern = req.PathRewritePattern
+	}
+	if req.PathRewriteTarget != nil {
+		route.PathRewriteTarget = req.PathRewriteTarget
+	}
+	if req.StripPathPrefix != nil {
+		route.StripPathPrefix = req.StripPathPrefix
+	}
+	if req.AddPathPrefix != nil {
+		route.AddPathPrefix = req.AddPathPrefix
+	}
+	if req.RequestHeaders != nil {
+		route.RequestHeaders = req.RequestHeaders
+	}
+	if req.ResponseHeaders != nil {
+		route.ResponseHeaders = req.ResponseHeaders
+	}
+	if req.RequestBodyTransform != nil {
+		route.RequestBodyTransform = req.RequestBodyTransform
+	}
+	if req.ResponseBodyTransform != nil {
+		route.ResponseBodyTransform = req.ResponseBodyTransform
+	}
+	if err := validatePathRewrite(route); err != nil {
+		return nil, err
+	}
 
 	if err := s.repos.Route.Update(ctx, route); err != nil {
 		s.logger.Error().Err(err).Str("route_id", id.String()).Msg("Failed to update route")
@@ -134,3 +319,145 @@ func (s *routeService) DeleteRoute(ctx context.Context, id uuid.UUID) error {
 	s.logger.Info().Str("route_id", id.String()).Msg("Route deleted")
 	return nil
 }
+
+// validateUpstream checks that a route targets exactly one origin or pool
+// of its tenant
+func (s *routeService) validateUpstream(ctx context.Context, tenantID uuid.UUID, originID, poolID *uuid.UUID) error {
+	switch {
+	case originID == nil && poolID == nil:
+		return &ValidationError{Field: "origin_id", Err: fmt.Errorf("origin_id or pool_id is required")}
+	case originID != nil && poolID != nil:
+		return &ValidationError{Field: "pool_id", Err: fmt.Errorf("a route targets either an origin or a pool")}
+	case originID != nil:
+		origin, err := s.repos.Origin.GetByID(ctx, *originID)
+		if err != nil || origin.TenantID != tenantID {
+			return &ValidationError{Field: "origin_id", Err: fmt.Errorf("origin not found")}
+		}
+	default:
+		pool, err := s.repos.Pool.GetByID(ctx, *poolID)
+		if err != nil || pool.TenantID != tenantID {
+			return &ValidationError{Field: "pool_id", Err: fmt.Errorf("pool not found")}
+		}
+	}
+	return nil
+}
+
+// validatePathPattern checks that a pattern parses with the gateway's path
+// pattern language so invalid routes are rejected instead of being skipped
+// by the gateway at load time
+func validatePathPattern(raw string) error {
+	if _, err := pathpattern.Parse(raw); err != nil {
+		return &ValidationError{Field: "path_pattern", Err: err}
+	}
+	return nil
+}
+
+// validatePathRewrite checks that the route's path rewrite compiles the way
+// the gateway compiles it and only refers to parameters of its path pattern
+func validatePathRewrite(route *models.Route) error {
+	strip, pattern, target, add := stringValue(route.StripPathPrefix), stringValue(route.PathRewritePattern),
+		stringValue(route.PathRewriteTarget), stringValue(route.AddPathPrefix)
+	if _, err := proxy.NewPathRewrite(strip, "", "", ""); err != nil {
+		return &ValidationError{Field: "strip_path_prefix", Err: err}
+	}
+	if _, err := proxy.NewPathRewrite("", "", "", add); err != nil {
+		return &ValidationError{Field: "add_path_prefix", Err: err}
+	}
+	if _, err := proxy.NewPathRewrite("", pattern, "", ""); err != nil {
+		return &ValidationError{Field: "path_rewrite_pattern", Err: err}
+	}
+	rewrite, err := proxy.NewPathRewrite(strip, pattern, target, add)
+	if err != nil {
+		return &ValidationError{Field: "path_rewrite_target", Err: err}
+	}
+
+	parsed, err := pathpattern.Parse(route.PathPattern)
+	if err != nil {
+		return &ValidationError{Field: "path_pattern", Err: err}
+	}
+	params := make(map[string]bool)
+	for _, name := range parsed.ParamNames() {
+		params[name] = true
+	}
+	for _, name := range rewrite.Params() {
+		if !params[name] {
+			return &ValidationError{Field: "path_rewrite_target", Err: fmt.Errorf("path pattern has no parameter %q", name)}
+		}
+	}
+	return nil
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// validateHeaderRules checks the request and response header rules against
+// the schema and template variables the gateway implements
+func validateHeaderRules(request, response models.JSONB) error {
+	if _, err := transform.ParseHeaders(request); err != nil {
+		return &ValidationError{Field: "request_headers", Err: err}
+	}
+	if _, err := transform.ParseHeaders(response); err != nil {
+		return &ValidationError{Field: "response_headers", Err: err}
+	}
+	return nil
+}
+
+// validateBodyRules checks the request and response body rules against the
+// schema and selector syntax the gateway implements
+func validateBodyRules(request, response models.JSONB) error {
+	if _, err := transform.ParseBody(request); err != nil {
+		return &ValidationError{Field: "request_body_transform", Err: err}
+	}
+	if _, err := transform.ParseBody(response); err != nil {
+		return &ValidationError{Field: "response_body_transform", Err: err}
+	}
+	return nil
+}
+
+// validateKeyStrategy checks the rate limit key strategy against the ones the
+// gateway implements
+func validateKeyStrategy(strategy string) error {
+	if err := ratelimit.ValidateKeyStrategy(strategy); err != nil {
+		return &ValidationError{Field: "rate_limit_key_strategy", Err: err}
+	}
+	return nil
+}
+
+// validateCacheSettings checks the cache key pattern and bypass rules
+// against the ones the gateway implements
+func validateCacheSettings(pattern string, rules models.CacheBypassRules) error {
+	if err := middleware.ValidateCacheKeyPattern(pattern); err != nil {
+		return &ValidationError{Field: "cache_key_pattern", Err: err}
+	}
+	if err := middleware.ValidateCacheBypassRules(rules); err != nil {
+		return &ValidationError{Field: "cache_bypass_rules", Err: err}
+	}
+	return nil
+}
+
+// validateStaleWindows checks that the windows for serving expired cache
+// entries are not negative
+func validateStaleWindows(whileRevalidate, ifError int) error {
+	if whileRevalidate < 0 {
+		return &ValidationError{Field: "cache_stale_while_revalidate_seconds", Err: fmt.Errorf("must not be negative")}
+	}
+	if ifError < 0 {
+		return &ValidationError{Field: "cache_stale_if_error_seconds", Err: fmt.Errorf("must not be negative")}
+	}
+	return nil
+}
+
+// validateRetryPolicy checks the retry count and retry-on conditions
+func validateRetryPolicy(attempts int, conditions []string) error {
+	if attempts < 0 {
+		return &ValidationError{Field: "retry_attempts", Err: fmt.Errorf("must not be negative")}
+	}
+	if err := retry.ValidateConditions(conditions); err != nil {
+		return &ValidationError{Field: "retry_on", Err: err}
+	}
+	return nil
+}
@@ -1,28 +1,56 @@
 package service
 
 import (
+	"fmt"
+
 	"github.com/vantageedge/backend/internal/repository"
+	"github.com/vantageedge/backend/pkg/config"
 	"github.com/vantageedge/backend/pkg/logger"
 )
 
 type Service struct {
-	Tenant TenantService
-	User   UserService
-	Origin OriginService
-	Route  RouteService
-	APIKey APIKeyService
-	Repos  *repository.Repository
-	logger *logger.Logger
+	Tenant  TenantService
+	User    UserService
+	Origin  OriginService
+	Pool    PoolService
+	Route   RouteService
+	APIKey  APIKeyService
+	Domain  DomainService
+	Cache   CacheService
+	Circuit CircuitBreakerService
+	Repos   *repository.Repository
+	logger  *logger.Logger
 }
 
-func New(repos *repository.Repository, log *logger.Logger) *Service {
+// New wires the services. purger and circuits may be nil when Redis is
+// unavailable, in which case cache purges and circuit breaker state fail.
+func New(repos *repository.Repository, cfg *config.Config, purger CachePurger, circuits CircuitReports, log *logger.Logger) *Service {
 	return &Service{
-		Tenant: NewTenantService(repos, log),
-		User:   NewUserService(repos, log),
-		Origin: NewOriginService(repos, log),
-		Route:  NewRouteService(repos, log),
-		APIKey: NewAPIKeyService(repos, log),
-		Repos:  repos,
-		logger: log,
+		Tenant:  NewTenantService(repos, log),
+		User:    NewUserService(repos, log),
+		Origin:  NewOriginService(repos, log),
+		Pool:    NewPoolService(repos, log),
+		Route:   NewRouteService(repos, log),
+		APIKey:  NewAPIKeyService(repos, log),
+		Domain:  NewDomainService(repos, NewTXTResolver(cfg.ControlPlane.DNSResolver), cfg.Gateway.Domain, log),
+		Cache:   NewCacheService(repos, purger, log),
+		Circuit: NewCircuitBreakerService(repos, circuits, log),
+		Repos:   repos,
+		logger:  log,
 	}
 }
+
+// ValidationError is returned when a request is rejected before it reaches the
+// repositories. Handlers report it to the client as a bad request.
+type ValidationError struct {
+	Field string
+	Err   error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %v", e.Field, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
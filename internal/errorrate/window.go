@@ -0,0 +1,68 @@
+package errorrate
+
+import "time"
+
+// buckets is the resolution of a window
+const buckets = 10
+
+// Window counts requests and failures over a rolling period, in buckets of a
+// tenth of it. It is not safe for concurrent use; callers hold their own
+// lock.
+type Window struct {
+	length  time.Duration
+	buckets [buckets]bucket
+}
+
+type bucket struct {
+	start    time.Time
+	total    int
+	failures int
+}
+
+// NewWindow returns an empty window over the given period
+func NewWindow(length time.Duration) *Window {
+	return &Window{length: length}
+}
+
+// Add counts a request made at now
+func (w *Window) Add(now time.Time, failed bool) {
+	width := w.length / buckets
+	if width <= 0 {
+		width = time.Second
+	}
+	start := now.Truncate(width)
+	bk := &w.buckets[int(now.UnixNano()/int64(width))%buckets]
+	if !bk.start.Equal(start) {
+		*bk = bucket{start: start}
+	}
+	bk.total++
+	if failed {
+		bk.failures++
+	}
+}
+
+// Counts sums the requests and failures within the period before now
+func (w *Window) Counts(now time.Time) (total, failures int) {
+	for _, bk := range w.buckets {
+		if !bk.start.IsZero() && now.Sub(bk.start) < w.length {
+			total += bk.total
+			failures += bk.failures
+		}
+	}
+	return total, failures
+}
+
+// Exceeds reports whether at least percent of the requests within the period
+// failed, once it holds minRequests. A percent of zero disables the check.
+func (w *Window) Exceeds(now time.Time, percent, minRequests int) bool {
+	if percent <= 0 {
+		return false
+	}
+	total, failures := w.Counts(now)
+	return total >= minRequests && total > 0 && failures*100 >= total*percent
+}
+
+// Reset clears the counts
+func (w *Window) Reset() {
+	w.buckets = [buckets]bucket{}
+}
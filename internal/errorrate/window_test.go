@@ -0,0 +1,58 @@
+package errorrate
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindowCountsWithinPeriod(t *testing.T) {
+	w := NewWindow(10 * time.Second)
+	start := time.Unix(1700000000, 0)
+
+	for i := 0; i < 4; i++ {
+		w.Add(start.Add(time.Duration(i)*time.Second), i%2 == 0)
+	}
+	if total, failures := w.Counts(start.Add(5 * time.Second)); total != 4 || failures != 2 {
+		t.Fatalf("got %d requests and %d failures, want 4 and 2", total, failures)
+	}
+
+	// Buckets older than the period drop out, and reused buckets start over
+	if total, failures := w.Counts(start.Add(11500 * time.Millisecond)); total != 2 || failures != 1 {
+		t.Fatalf("got %d requests and %d failures after 11.5s, want 2 and 1", total, failures)
+	}
+	w.Add(start.Add(12*time.Second), true)
+	if total, failures := w.Counts(start.Add(12 * time.Second)); total != 2 || failures != 1 {
+		t.Fatalf("got %d requests and %d failures after reusing a bucket, want 2 and 1", total, failures)
+	}
+
+	w.Reset()
+	if total, _ := w.Counts(start.Add(12 * time.Second)); total != 0 {
+		t.Fatalf("expected no requests after a reset, got %d", total)
+	}
+}
+
+func TestWindowExceeds(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	w := NewWindow(10 * time.Second)
+	for i := 0; i < 10; i++ {
+		w.Add(now, i < 5)
+	}
+
+	tests := []struct {
+		percent, minRequests int
+		want                 bool
+	}{
+		{percent: 50, minRequests: 10, want: true},
+		{percent: 51, minRequests: 10},
+		{percent: 50, minRequests: 11},
+		{percent: 0, minRequests: 0},
+	}
+	for _, tt := range tests {
+		if got := w.Exceeds(now, tt.percent, tt.minRequests); got != tt.want {
+			t.Errorf("Exceeds(%d%%, %d) = %v, want %v", tt.percent, tt.minRequests, got, tt.want)
+		}
+	}
+	if NewWindow(time.Second).Exceeds(now, 50, 0) {
+		t.Error("expected an empty window not to exceed any rate")
+	}
+}
@@ -0,0 +1,207 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/vantageedge/backend/internal/errorrate"
+)
+
+// Circuit states
+const (
+	StateClosed   = "closed"    // requests flow and outcomes are counted
+	StateOpen     = "open"      // requests fail fast until OpenTimeout elapses
+	StateHalfOpen = "half_open" // a few probe requests decide whether to close
+)
+
+// ErrOpen is matched by the error returned for requests refused by an open
+// circuit
+var ErrOpen = errors.New("circuit breaker is open")
+
+// OpenError refuses a request while the circuit is open
+type OpenError struct {
+	RetryAfter time.Duration // until the circuit lets probes through
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("%v, retry after %v", ErrOpen, e.RetryAfter)
+}
+
+func (e *OpenError) Is(target error) bool {
+	return target == ErrOpen
+}
+
+// Outcome is the result of a request let through by a breaker
+type Outcome int
+
+const (
+	Success Outcome = iota
+	Failure
+	Ignored // e.g. the client went away; counts neither way
+)
+
+// Settings tune a breaker. The circuit opens after ConsecutiveFailures
+// failures in a row, or when at least ErrorRatePercent of the requests in
+// Window failed once Window holds MinRequests.
+type Settings struct {
+	ConsecutiveFailures int
+	ErrorRatePercent    int
+	MinRequests         int
+	Window              time.Duration
+	OpenTimeout         time.Duration // how long the circuit stays open before probing
+	HalfOpenProbes      int           // probes let through while half-open; all must succeed to close
+}
+
+// Breaker is a circuit breaker for one upstream. It is safe for concurrent
+// use.
+type Breaker struct {
+	mu       sync.Mutex
+	settings Settings
+	onChange func(from, to string)
+	now      func() time.Time
+
+	state       string
+	since       time.Time
+	consecutive int
+	window      *errorrate.Window
+	probes      int // probes in flight while half-open
+	successes   int // successful probes while half-open
+}
+
+// New returns a closed breaker. onChange, if set, is called with the breaker
+// locked on every state change and must not use it.
+func New(settings Settings, onChange func(from, to string)) *Breaker {
+	if settings.HalfOpenProbes < 1 {
+		settings.HalfOpenProbes = 1
+	}
+	return &Breaker{
+		settings: settings,
+		onChange: onChange,
+		now:      time.Now,
+		state:    StateClosed,
+		since:    time.Now(),
+		window:   errorrate.NewWindow(settings.Window),
+	}
+}
+
+// Allow asks to send a request. While the circuit is open it returns an
+// *OpenError; otherwise the returned func must be called exactly once with
+// the request's outcome.
+func (b *Breaker) Allow() (func(Outcome), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if b.state == StateOpen {
+		if wait := b.since.Add(b.settings.OpenTimeout).Sub(now); wait > 0 {
+			return nil, &OpenError{RetryAfter: wait}
+		}
+		b.setState(StateHalfOpen, now)
+	}
+
+	if b.state == StateHalfOpen {
+		if b.probes+b.successes >= b.settings.HalfOpenProbes {
+			return nil, &OpenError{RetryAfter: b.settings.OpenTimeout}
+		}
+		b.probes++
+		return b.doneFunc(true), nil
+	}
+	return b.doneFunc(false), nil
+}
+
+func (b *Breaker) doneFunc(probe bool) func(Outcome) {
+	var once sync.Once
+	return func(outcome Outcome) {
+		once.Do(func() { b.record(outcome, probe) })
+	}
+}
+
+func (b *Breaker) record(outcome Outcome, probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if probe {
+		if b.state != StateHalfOpen {
+			return
+		}
+		b.probes--
+		switch outcome {
+		case Success:
+			b.successes++
+			if b.successes >= b.settings.HalfOpenProbes {
+				b.setState(StateClosed, now)
+			}
+		case Failure:
+			b.setState(StateOpen, now)
+		}
+		return
+	}
+
+	if outcome == Ignored || b.state != StateClosed {
+		return
+	}
+	b.window.Add(now, outcome == Failure)
+	if outcome == Success {
+		b.consecutive = 0
+		return
+	}
+	b.consecutive++
+	if b.settings.ConsecutiveFailures > 0 && b.consecutive >= b.settings.ConsecutiveFailures {
+		b.setState(StateOpen, now)
+		return
+	}
+	if b.window.Exceeds(now, b.settings.ErrorRatePercent, b.settings.MinRequests) {
+		b.setState(StateOpen, now)
+	}
+}
+
+// setState moves to state, resetting the counters; b.mu must be held
+func (b *Breaker) setState(state string, now time.Time) {
+	from := b.state
+	b.state, b.since = state, now
+	b.consecutive, b.probes, b.successes = 0, 0, 0
+	b.window.Reset()
+	if b.onChange != nil && from != state {
+		b.onChange(from, state)
+	}
+}
+
+// setThreshold changes the consecutive failures that trip the circuit
+func (b *Breaker) setThreshold(failures int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.settings.ConsecutiveFailures = failures
+}
+
+// Status describes the state of a breaker
+type Status struct {
+	State               string    `json:"state"`
+	Since               time.Time `json:"since"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	WindowRequests      int       `json:"window_requests"`
+	WindowFailures      int       `json:"window_failures"`
+}
+
+// Status returns the breaker's current state
+func (b *Breaker) Status() Status {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	state := b.state
+	if state == StateOpen && !now.Before(b.since.Add(b.settings.OpenTimeout)) {
+		// Due for probing on the next request
+		state = StateHalfOpen
+	}
+	total, failures := b.window.Counts(now)
+	return Status{
+		State:               state,
+		Since:               b.since,
+		ConsecutiveFailures: b.consecutive,
+		WindowRequests:      total,
+		WindowFailures:      failures,
+	}
+}
@@ -0,0 +1,119 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestBreaker(settings Settings) (*Breaker, *clock, *[]string) {
+	var transitions []string
+	b := New(settings, func(from, to string) { transitions = append(transitions, to) })
+	c := &clock{t: time.Unix(1700000000, 0)}
+	b.now = c.now
+	return b, c, &transitions
+}
+
+func request(t *testing.T, b *Breaker, outcome Outcome) {
+	t.Helper()
+	done, err := b.Allow()
+	if err != nil {
+		t.Fatalf("expected the request to be allowed, got %v", err)
+	}
+	done(outcome)
+}
+
+func TestBreakerTripsOnConsecutiveFailures(t *testing.T) {
+	b, c, _ := newTestBreaker(Settings{ConsecutiveFailures: 3, OpenTimeout: 30 * time.Second})
+
+	request(t, b, Failure)
+	request(t, b, Failure)
+	request(t, b, Success) // resets the streak
+	request(t, b, Failure)
+	request(t, b, Failure)
+	if b.Status().State != StateClosed {
+		t.Fatal("expected the circuit to stay closed below the threshold")
+	}
+	request(t, b, Failure)
+
+	c.advance(10 * time.Second)
+	_, err := b.Allow()
+	var openErr *OpenError
+	if !errors.Is(err, ErrOpen) || !errors.As(err, &openErr) || openErr.RetryAfter != 20*time.Second {
+		t.Fatalf("expected an open circuit retrying after 20s, got %v", err)
+	}
+}
+
+func TestBreakerTripsOnErrorRate(t *testing.T) {
+	b, c, _ := newTestBreaker(Settings{ErrorRatePercent: 50, MinRequests: 4, Window: 10 * time.Second, OpenTimeout: time.Second})
+
+	// Failures that fell out of the window do not count
+	request(t, b, Failure)
+	request(t, b, Failure)
+	c.advance(15 * time.Second)
+
+	request(t, b, Success)
+	request(t, b, Failure)
+	request(t, b, Success)
+	if b.Status().State != StateClosed {
+		t.Fatal("expected the circuit to stay closed below MinRequests")
+	}
+	request(t, b, Failure)
+	if status := b.Status(); status.State != StateOpen {
+		t.Fatalf("expected the circuit to open at a 50%% error rate, got %+v", status)
+	}
+}
+
+func TestBreakerHalfOpenProbes(t *testing.T) {
+	b, c, transitions := newTestBreaker(Settings{ConsecutiveFailures: 1, OpenTimeout: time.Second, HalfOpenProbes: 1})
+
+	request(t, b, Failure)
+	c.advance(time.Second)
+
+	// A single probe is let through; a failed probe reopens the circuit
+	probe, err := b.Allow()
+	if err != nil {
+		t.Fatalf("expected a probe once the open timeout elapsed, got %v", err)
+	}
+	if _, err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Fatalf("expected concurrent requests to be refused while probing, got %v", err)
+	}
+	probe(Failure)
+	if _, err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Fatalf("expected the failed probe to reopen the circuit, got %v", err)
+	}
+
+	// A successful probe closes it
+	c.advance(time.Second)
+	request(t, b, Success)
+	request(t, b, Success)
+
+	want := []string{StateOpen, StateHalfOpen, StateOpen, StateHalfOpen, StateClosed}
+	if len(*transitions) != len(want) {
+		t.Fatalf("expected transitions %v, got %v", want, *transitions)
+	}
+	for i := range want {
+		if (*transitions)[i] != want[i] {
+			t.Fatalf("expected transitions %v, got %v", want, *transitions)
+		}
+	}
+}
+
+func TestBreakerIgnoresIgnoredOutcomes(t *testing.T) {
+	b, _, _ := newTestBreaker(Settings{ConsecutiveFailures: 2, Window: 10 * time.Second, OpenTimeout: time.Second})
+
+	request(t, b, Failure)
+	request(t, b, Ignored)
+	if status := b.Status(); status.State != StateClosed || status.ConsecutiveFailures != 1 || status.WindowRequests != 1 {
+		t.Fatalf("expected the ignored outcome not to count, got %+v", status)
+	}
+	request(t, b, Failure)
+	if b.Status().State != StateOpen {
+		t.Fatal("expected the second failure to open the circuit")
+	}
+}
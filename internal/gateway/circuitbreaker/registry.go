@@ -0,0 +1,93 @@
+package circuitbreaker
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Key identifies the breaker of a route's traffic to one origin
+type Key struct {
+	TenantID uuid.UUID `json:"tenant_id"`
+	RouteID  uuid.UUID `json:"route_id"`
+	OriginID uuid.UUID `json:"origin_id"`
+}
+
+// Transition is a state change of a breaker
+type Transition struct {
+	Key
+	From string
+	To   string
+}
+
+// Registry holds the breakers of a gateway instance, created on first use
+type Registry struct {
+	mu       sync.Mutex
+	settings Settings
+	onChange func(Transition)
+	breakers map[Key]*Breaker
+}
+
+// NewRegistry returns a registry whose breakers use settings, with the
+// consecutive failure threshold set per route. onChange, if set, is called
+// on every state change.
+func NewRegistry(settings Settings, onChange func(Transition)) *Registry {
+	return &Registry{
+		settings: settings,
+		onChange: onChange,
+		breakers: make(map[Key]*Breaker),
+	}
+}
+
+// Get returns the breaker of key, tripping after threshold consecutive
+// failures
+func (r *Registry) Get(key Key, threshold int) *Breaker {
+	r.mu.Lock()
+	b, ok := r.breakers[key]
+	if !ok {
+		settings := r.settings
+		settings.ConsecutiveFailures = threshold
+		b = New(settings, func(from, to string) {
+			if r.onChange != nil {
+				r.onChange(Transition{Key: key, From: from, To: to})
+			}
+		})
+		r.breakers[key] = b
+	}
+	r.mu.Unlock()
+
+	if ok {
+		// The route's threshold may have changed since
+		b.setThreshold(threshold)
+	}
+	return b
+}
+
+// KeyStatus is the status of the breaker of a key
+type KeyStatus struct {
+	Key
+	Status
+}
+
+// Snapshot returns the status of every breaker, ordered by route and origin
+func (r *Registry) Snapshot() []KeyStatus {
+	r.mu.Lock()
+	statuses := make([]KeyStatus, 0, len(r.breakers))
+	breakers := make(map[Key]*Breaker, len(r.breakers))
+	for key, b := range r.breakers {
+		breakers[key] = b
+	}
+	r.mu.Unlock()
+
+	for key, b := range breakers {
+		statuses = append(statuses, KeyStatus{Key: key, Status: b.Status()})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].RouteID != statuses[j].RouteID {
+			return statuses[i].RouteID.String() < statuses[j].RouteID.String()
+		}
+		return statuses[i].OriginID.String() < statuses[j].OriginID.String()
+	})
+	return statuses
+}
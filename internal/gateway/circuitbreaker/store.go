@@ -0,0 +1,117 @@
+package circuitbreaker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/vantageedge/backend/internal/cache/redis"
+	"github.com/vantageedge/backend/pkg/logger"
+)
+
+// Redis keys of the reports: a sorted set of instances scored by the time
+// of their last report, and one report per instance
+const (
+	instancesKey      = "circuit:instances"
+	instanceKeyPrefix = "circuit:instance:"
+)
+
+// publishScript stores an instance's report and drops instances that have
+// stopped reporting.
+// KEYS[1] = instances, KEYS[2] = report
+// ARGV[1] = instance, ARGV[2] = report JSON, ARGV[3] = TTL in ms, ARGV[4] = now in ms
+var publishScript = redis.NewScript(`
+redis.call('SET', KEYS[2], ARGV[2], 'PX', ARGV[3])
+redis.call('ZADD', KEYS[1], ARGV[4], ARGV[1])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', tonumber(ARGV[4]) - tonumber(ARGV[3]))
+return 1
+`)
+
+// loadScript returns the reports of the instances that reported recently.
+// KEYS[1] = instances
+// ARGV[1] = oldest report time in ms, ARGV[2] = report key prefix
+var loadScript = redis.NewScript(`
+local reports = {}
+for _, instance in ipairs(redis.call('ZRANGEBYSCORE', KEYS[1], ARGV[1], '+inf')) do
+	local report = redis.call('GET', ARGV[2] .. instance)
+	if report then
+		table.insert(reports, report)
+	end
+end
+return reports
+`)
+
+// Report is the state of the breakers of one gateway instance
+type Report struct {
+	Instance   string      `json:"instance"`
+	ReportedAt time.Time   `json:"reported_at"`
+	Breakers   []KeyStatus `json:"breakers"`
+}
+
+// Store shares breaker reports between gateway instances and the control
+// plane through Redis. Reports expire after ttl, so instances that stop
+// reporting disappear.
+type Store struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func NewStore(client *redis.Client, ttl time.Duration) *Store {
+	return &Store{client: client, ttl: ttl}
+}
+
+// Publish stores the report of an instance
+func (s *Store) Publish(ctx context.Context, report *Report) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal circuit breaker report: %w", err)
+	}
+	keys := []string{instancesKey, instanceKeyPrefix + report.Instance}
+	_, err = s.client.Run(ctx, publishScript, keys, report.Instance, data, s.ttl.Milliseconds(), report.ReportedAt.UnixMilli())
+	return err
+}
+
+// Load returns the reports of the instances that are still reporting
+func (s *Store) Load(ctx context.Context) ([]*Report, error) {
+	oldest := time.Now().Add(-s.ttl).UnixMilli()
+	res, err := s.client.Run(ctx, loadScript, []string{instancesKey}, oldest, instanceKeyPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load circuit breaker reports: %w", err)
+	}
+
+	values, _ := res.([]interface{})
+	reports := make([]*Report, 0, len(values))
+	for _, v := range values {
+		data, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var report Report
+		if err := json.Unmarshal([]byte(data), &report); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal circuit breaker report: %w", err)
+		}
+		reports = append(reports, &report)
+	}
+	return reports, nil
+}
+
+// RunReporter publishes the breakers of registry under instance every
+// interval until ctx is done
+func RunReporter(ctx context.Context, store *Store, registry *Registry, instance string, interval time.Duration, log *logger.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report := &Report{Instance: instance, ReportedAt: time.Now(), Breakers: registry.Snapshot()}
+		if err := store.Publish(ctx, report); err != nil && ctx.Err() == nil {
+			log.Warn().Err(err).Msg("Failed to report circuit breakers")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -2,37 +2,220 @@ package middleware
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"strings"
+
+	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/auth/apikey"
+	"github.com/vantageedge/backend/internal/auth/jwt"
+	"github.com/vantageedge/backend/internal/models"
+	"github.com/vantageedge/backend/internal/repository"
 )
 
 type contextKey string
 
 const (
-	TenantIDKey contextKey = "tenant_id"
-	UserIDKey   contextKey = "user_id"
+	IdentityKey contextKey = "identity"
 )
 
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Extract auth header
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
+// Route auth modes, matching the auth_mode enum of the routes table
+const (
+	AuthModePublic         = "public"
+	AuthModeJWTRequired    = "jwt_required"
+	AuthModeAPIKeyRequired = "apikey_required"
+	AuthModeBoth           = "both"
+)
+
+// Auth methods recorded in RequestLog.AuthMethod
+const (
+	AuthMethodJWT    = "jwt"
+	AuthMethodAPIKey = "api_key"
+)
+
+// Identity is the authenticated caller of a gateway request
+type Identity struct {
+	TenantID          uuid.UUID
+	UserID            *uuid.UUID
+	ClerkUserID       string
+	APIKeyID          *uuid.UUID
+	Scopes            []string
+	RateLimitOverride *int // per-key requests per second, API keys only
+	Claims            *jwt.Claims
+	Method            string // empty for anonymous requests on public routes
+}
+
+// WithIdentity returns a copy of ctx carrying the identity
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, IdentityKey, identity)
+}
+
+// IdentityFromContext returns the identity stored in ctx, if any
+func IdentityFromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(IdentityKey).(*Identity)
+	return identity, ok
+}
+
+// AuthError is an authentication or authorization failure with the status
+// and code reported to the client
+type AuthError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *AuthError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func unauthorized(code, message string) *AuthError {
+	return &AuthError{Status: http.StatusUnauthorized, Code: code, Message: message}
+}
+
+func forbidden(code, message string) *AuthError {
+	return &AuthError{Status: http.StatusForbidden, Code: code, Message: message}
+}
+
+// Authenticator enforces Route.AuthMode using API keys and Clerk JWTs
+type Authenticator struct {
+	apiKeys *apikey.Validator
+	jwt     *jwt.JWTValidator
+	users   repository.UserRepository
+}
 
-		// Validate token (placeholder)
-		token := strings.TrimPrefix(authHeader, "Bearer ")
-		if token == "" {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
-			return
+func NewAuthenticator(apiKeys *apikey.Validator, jwtValidator *jwt.JWTValidator, users repository.UserRepository) *Authenticator {
+	return &Authenticator{
+		apiKeys: apiKeys,
+		jwt:     jwtValidator,
+		users:   users,
+	}
+}
+
+// Authenticate checks the request credentials against the route's auth mode.
+// On "both" either a JWT or an API key is accepted. Credentials must belong
+// to tenantID.
+func (a *Authenticator) Authenticate(r *http.Request, route *models.Route, tenantID uuid.UUID) (*Identity, *AuthError) {
+	apiKey := extractAPIKey(r)
+	bearer := extractBearerToken(r)
+
+	switch route.AuthMode {
+	case AuthModePublic:
+		return &Identity{TenantID: tenantID}, nil
+	case AuthModeAPIKeyRequired:
+		if apiKey == "" {
+			return nil, unauthorized("missing_credentials", "An API key is required")
+		}
+		return a.authenticateAPIKey(r.Context(), apiKey, tenantID)
+	case AuthModeBoth:
+		if apiKey != "" {
+			return a.authenticateAPIKey(r.Context(), apiKey, tenantID)
+		}
+		if bearer == "" {
+			return nil, unauthorized("missing_credentials", "A bearer token or API key is required")
+		}
+		return a.authenticateJWT(r.Context(), bearer, tenantID)
+	default:
+		// jwt_required, and the safest choice for unknown modes
+		if bearer == "" {
+			return nil, unauthorized("missing_credentials", "A bearer token is required")
 		}
+		return a.authenticateJWT(r.Context(), bearer, tenantID)
+	}
+}
+
+func (a *Authenticator) authenticateAPIKey(ctx context.Context, key string, tenantID uuid.UUID) (*Identity, *AuthError) {
+	info, err := a.apiKeys.ValidateKey(ctx, key)
+	if err != nil {
+		return nil, unauthorized("invalid_api_key", err.Error())
+	}
+	if info.TenantID != tenantID {
+		return nil, forbidden("tenant_mismatch", "API key does not belong to this tenant")
+	}
+
+	keyID := info.ID
+	return &Identity{
+		TenantID:          tenantID,
+		UserID:            info.UserID,
+		APIKeyID:          &keyID,
+		Scopes:            info.Scopes,
+		RateLimitOverride: info.RateLimitOverride,
+		Method:            AuthMethodAPIKey,
+	}, nil
+}
+
+func (a *Authenticator) authenticateJWT(ctx context.Context, token string, tenantID uuid.UUID) (*Identity, *AuthError) {
+	claims, err := a.jwt.ValidateTokenContext(ctx, token)
+	if err != nil {
+		return nil, unauthorized("invalid_token", "Invalid or expired token")
+	}
+
+	clerkUserID := claims.ClerkUserID
+	if clerkUserID == "" {
+		clerkUserID = claims.Subject
+	}
+	if clerkUserID == "" {
+		return nil, unauthorized("invalid_token", "Token has no subject")
+	}
 
-		// TODO: Validate JWT or API key
-		// TODO: Extract user and tenant ID
-		
-		ctx := context.WithValue(r.Context(), UserIDKey, "user_123")
-		next.ServeHTTP(w, r.WithContext(ctx))
+	user, err := a.users.GetByClerkID(ctx, clerkUserID)
+	if err != nil {
+		return nil, forbidden("unknown_user", "User is not registered")
+	}
+	if user.TenantID != tenantID {
+		return nil, forbidden("tenant_mismatch", "User does not belong to this tenant")
+	}
+
+	userID := user.ID
+	return &Identity{
+		TenantID:    tenantID,
+		UserID:      &userID,
+		ClerkUserID: clerkUserID,
+		Claims:      claims,
+		Method:      AuthMethodJWT,
+	}, nil
+}
+
+// WriteAuthError writes an authentication or authorization failure
+func WriteAuthError(w http.ResponseWriter, authErr *AuthError) {
+	if authErr.Status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="vantageedge", ApiKey realm="vantageedge"`)
+	}
+	WriteError(w, authErr.Status, authErr.Code, authErr.Message)
+}
+
+// WriteError writes the JSON error body shared by every response the gateway
+// generates itself
+func WriteError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":   code,
+		"message": message,
 	})
 }
+
+// extractAPIKey reads an API key from X-API-Key or "Authorization: ApiKey <key>"
+func extractAPIKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if key, ok := cutPrefixFold(r.Header.Get("Authorization"), "ApiKey "); ok {
+		return strings.TrimSpace(key)
+	}
+	return ""
+}
+
+// extractBearerToken reads the token from "Authorization: Bearer <token>"
+func extractBearerToken(r *http.Request) string {
+	if token, ok := cutPrefixFold(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) < len(prefix) || !strings.EqualFold(s[:len(prefix)], prefix) {
+		return "", false
+	}
+	return s[len(prefix):], true
+}
@@ -0,0 +1,229 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/auth/apikey"
+	"github.com/vantageedge/backend/internal/auth/jwt"
+	"github.com/vantageedge/backend/internal/models"
+	"github.com/vantageedge/backend/internal/repository"
+)
+
+// fakeAPIKeys serves API keys by hash
+type fakeAPIKeys struct {
+	repository.APIKeyRepository
+	keys map[string]*models.APIKey
+}
+
+func (f fakeAPIKeys) GetByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	if key, ok := f.keys[hash]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("not found")
+}
+
+// fakeUsers serves users by Clerk ID
+type fakeUsers struct {
+	repository.UserRepository
+	users map[string]*models.User
+}
+
+func (f fakeUsers) GetByClerkID(ctx context.Context, clerkUserID string) (*models.User, error) {
+	if user, ok := f.users[clerkUserID]; ok {
+		return user, nil
+	}
+	return nil, fmt.Errorf("not found")
+}
+
+// tokenSigner signs Ed25519 tokens whose key is published by a JWKS server
+type tokenSigner struct {
+	kid string
+	key ed25519.PrivateKey
+}
+
+func newTokenSigner(t *testing.T, kid string) *tokenSigner {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tokenSigner{kid: kid, key: key}
+}
+
+func (s *tokenSigner) sign(t *testing.T, subject string) string {
+	token := gojwt.NewWithClaims(gojwt.SigningMethodEdDSA, gojwt.MapClaims{
+		"sub": subject,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	token.Header["kid"] = s.kid
+	signed, err := token.SignedString(s.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func newJWKSServer(t *testing.T, signers ...*tokenSigner) *httptest.Server {
+	type jwk struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+	}
+	var keys []jwk
+	for _, s := range signers {
+		keys = append(keys, jwk{Kty: "OKP", Kid: s.kid, Crv: "Ed25519", X: base64.RawURLEncoding.EncodeToString(s.key.Public().(ed25519.PublicKey))})
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func hashKey(raw string) string {
+	hash := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(hash[:])
+}
+
+func TestAuthenticate(t *testing.T) {
+	tenantID, otherTenantID := uuid.New(), uuid.New()
+	override := 20
+	acmeKey := &models.APIKey{ID: uuid.New(), TenantID: tenantID, IsActive: true, Scopes: models.StringArray{"read"}, RateLimitOverride: &override}
+	otherKey := &models.APIKey{ID: uuid.New(), TenantID: otherTenantID, IsActive: true}
+	inactiveKey := &models.APIKey{ID: uuid.New(), TenantID: tenantID}
+	apiKeys := fakeAPIKeys{keys: map[string]*models.APIKey{
+		hashKey("acme-key"):     acmeKey,
+		hashKey("other-key"):    otherKey,
+		hashKey("inactive-key"): inactiveKey,
+	}}
+
+	acmeUser := &models.User{ID: uuid.New(), TenantID: tenantID, ClerkUserID: "user_acme"}
+	users := fakeUsers{users: map[string]*models.User{
+		"user_acme":  acmeUser,
+		"user_other": {ID: uuid.New(), TenantID: otherTenantID, ClerkUserID: "user_other"},
+	}}
+
+	signer, stranger := newTokenSigner(t, "k1"), newTokenSigner(t, "k2")
+	jwks := newJWKSServer(t, signer)
+	validator := jwt.NewJWTValidator(jwt.Config{JWKSURL: jwks.URL, MinRefetchInterval: time.Hour})
+	a := NewAuthenticator(apikey.NewValidator(&repository.Repository{APIKey: apiKeys}), validator, users)
+
+	acmeToken := "Bearer " + signer.sign(t, "user_acme")
+	tests := []struct {
+		name     string
+		mode     string
+		header   http.Header
+		status   int    // 0 when authenticated
+		code     string // error code, or the auth method when authenticated
+		wantUser bool
+		wantKey  bool
+	}{
+		{name: "public anonymous", mode: AuthModePublic},
+		{name: "public ignores credentials", mode: AuthModePublic, header: http.Header{"X-Api-Key": {"bad-key"}}},
+
+		{name: "api key", mode: AuthModeAPIKeyRequired, header: http.Header{"X-Api-Key": {"acme-key"}}, code: AuthMethodAPIKey, wantKey: true},
+		{name: "api key scheme", mode: AuthModeAPIKeyRequired, header: http.Header{"Authorization": {"apikey acme-key"}}, code: AuthMethodAPIKey, wantKey: true},
+		{name: "api key missing", mode: AuthModeAPIKeyRequired, header: http.Header{"Authorization": {acmeToken}}, status: 401, code: "missing_credentials"},
+		{name: "api key unknown", mode: AuthModeAPIKeyRequired, header: http.Header{"X-Api-Key": {"bad-key"}}, status: 401, code: "invalid_api_key"},
+		{name: "api key inactive", mode: AuthModeAPIKeyRequired, header: http.Header{"X-Api-Key": {"inactive-key"}}, status: 401, code: "invalid_api_key"},
+		{name: "api key of another tenant", mode: AuthModeAPIKeyRequired, header: http.Header{"X-Api-Key": {"other-key"}}, status: 403, code: "tenant_mismatch"},
+
+		{name: "jwt", mode: AuthModeJWTRequired, header: http.Header{"Authorization": {acmeToken}}, code: AuthMethodJWT, wantUser: true},
+		{name: "jwt missing", mode: AuthModeJWTRequired, header: http.Header{"X-Api-Key": {"acme-key"}}, status: 401, code: "missing_credentials"},
+		{name: "jwt unknown signer", mode: AuthModeJWTRequired, header: http.Header{"Authorization": {"Bearer " + stranger.sign(t, "user_acme")}}, status: 401, code: "invalid_token"},
+		{name: "jwt malformed", mode: AuthModeJWTRequired, header: http.Header{"Authorization": {"Bearer not-a-token"}}, status: 401, code: "invalid_token"},
+		{name: "jwt unknown user", mode: AuthModeJWTRequired, header: http.Header{"Authorization": {"Bearer " + signer.sign(t, "user_ghost")}}, status: 403, code: "unknown_user"},
+		{name: "jwt user of another tenant", mode: AuthModeJWTRequired, header: http.Header{"Authorization": {"Bearer " + signer.sign(t, "user_other")}}, status: 403, code: "tenant_mismatch"},
+		{name: "unknown mode requires jwt", mode: "custom", header: http.Header{"X-Api-Key": {"acme-key"}}, status: 401, code: "missing_credentials"},
+
+		{name: "both with api key", mode: AuthModeBoth, header: http.Header{"X-Api-Key": {"acme-key"}}, code: AuthMethodAPIKey, wantKey: true},
+		{name: "both with jwt", mode: AuthModeBoth, header: http.Header{"Authorization": {acmeToken}}, code: AuthMethodJWT, wantUser: true},
+		{name: "both prefers api key", mode: AuthModeBoth, header: http.Header{"X-Api-Key": {"acme-key"}, "Authorization": {acmeToken}}, code: AuthMethodAPIKey, wantKey: true},
+		{name: "both missing", mode: AuthModeBoth, status: 401, code: "missing_credentials"},
+		{name: "both with api key of another tenant", mode: AuthModeBoth, header: http.Header{"X-Api-Key": {"other-key"}}, status: 403, code: "tenant_mismatch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header = tt.header
+			if r.Header == nil {
+				r.Header = http.Header{}
+			}
+
+			identity, authErr := a.Authenticate(r, &models.Route{AuthMode: tt.mode}, tenantID)
+			if tt.status != 0 {
+				if authErr == nil || authErr.Status != tt.status || authErr.Code != tt.code {
+					t.Fatalf("expected %d %s, got %v", tt.status, tt.code, authErr)
+				}
+				return
+			}
+			if authErr != nil {
+				t.Fatal(authErr)
+			}
+
+			if identity.TenantID != tenantID || identity.Method != tt.code {
+				t.Fatalf("got tenant %s with method %q, want %q", identity.TenantID, identity.Method, tt.code)
+			}
+			if tt.wantKey {
+				if identity.APIKeyID == nil || *identity.APIKeyID != acmeKey.ID || identity.RateLimitOverride != &override || len(identity.Scopes) != 1 {
+					t.Fatalf("expected the API key's identity, got %+v", identity)
+				}
+			} else if identity.APIKeyID != nil {
+				t.Fatalf("expected no API key, got %s", identity.APIKeyID)
+			}
+			if tt.wantUser {
+				if identity.UserID == nil || *identity.UserID != acmeUser.ID || identity.ClerkUserID != "user_acme" || identity.Claims == nil {
+					t.Fatalf("expected the user's identity, got %+v", identity)
+				}
+			} else if identity.UserID != nil {
+				t.Fatalf("expected no user, got %s", identity.UserID)
+			}
+		})
+	}
+}
+
+func TestWriteAuthError(t *testing.T) {
+	tests := []struct {
+		err       *AuthError
+		challenge bool
+	}{
+		{err: unauthorized("invalid_api_key", "invalid api key"), challenge: true},
+		{err: forbidden("tenant_mismatch", "API key does not belong to this tenant")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Code, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			WriteAuthError(w, tt.err)
+
+			if w.Code != tt.err.Status {
+				t.Fatalf("got status %d, want %d", w.Code, tt.err.Status)
+			}
+			if got := w.Header().Get("WWW-Authenticate") != ""; got != tt.challenge {
+				t.Fatalf("expected a WWW-Authenticate challenge %v, got %q", tt.challenge, w.Header().Get("WWW-Authenticate"))
+			}
+			if w.Header().Get("Content-Type") != "application/json" {
+				t.Fatalf("got Content-Type %q", w.Header().Get("Content-Type"))
+			}
+			var body map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body["error"] != tt.err.Code || body["message"] != tt.err.Message || len(body) != 2 {
+				t.Fatalf("unexpected body %v", body)
+			}
+		})
+	}
+}
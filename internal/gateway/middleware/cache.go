@@ -1,66 +1,248 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
-	"sync"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/cache/lru"
+	"github.com/vantageedge/backend/internal/observability"
+)
+
+// X-Cache values reported on cache-enabled routes
+const (
+	CacheHit         = "HIT"
+	CacheMiss        = "MISS"
+	CacheBypass      = "BYPASS"
+	CacheRevalidated = "REVALIDATED"
+	CacheStale       = "STALE"
 )
 
+// CacheEntry is a stored origin response. It is fresh until ExpiresAt and
+// kept until RetainUntil so stale entries can be revalidated or served
+// within their stale windows.
 type CacheEntry struct {
-	data      []byte
-	expiresAt time.Time
+	RouteID              uuid.UUID
+	StatusCode           int
+	Header               http.Header
+	Body                 []byte
+	Vary                 []string      // request headers selecting this variant
+	Tags                 []string      // purge tags from Surrogate-Key and Cache-Tag
+	Age                  time.Duration // age of the response when it was stored
+	StoredAt             time.Time
+	ExpiresAt            time.Time
+	RetainUntil          time.Time
+	StaleWhileRevalidate time.Duration
+	StaleIfError         time.Duration
+}
+
+// Fresh reports whether the entry may be served without revalidation
+func (e *CacheEntry) Fresh(now time.Time) bool {
+	return now.Before(e.ExpiresAt)
+}
+
+// ServableWhileRevalidating reports whether the stale entry may be served
+// while it is refreshed in the background
+func (e *CacheEntry) ServableWhileRevalidating(now time.Time) bool {
+	return now.Before(e.ExpiresAt.Add(e.StaleWhileRevalidate))
 }
 
+// ServableOnError reports whether the stale entry may be served in place of
+// an origin error
+func (e *CacheEntry) ServableOnError(now time.Time) bool {
+	return now.Before(e.ExpiresAt.Add(e.StaleIfError))
+}
+
+// CurrentAge is the age of the response as reported in the Age header
+func (e *CacheEntry) CurrentAge(now time.Time) time.Duration {
+	return e.Age + now.Sub(e.StoredAt)
+}
+
+// HasValidators reports whether the origin can revalidate the entry
+func (e *CacheEntry) HasValidators() bool {
+	return e.Header.Get("ETag") != "" || e.Header.Get("Last-Modified") != ""
+}
+
+// SetConditionals adds the entry's validators to a request to the origin
+func (e *CacheEntry) SetConditionals(h http.Header) {
+	if etag := e.Header.Get("ETag"); etag != "" {
+		h.Set("If-None-Match", etag)
+	}
+	if lastModified := e.Header.Get("Last-Modified"); lastModified != "" {
+		h.Set("If-Modified-Since", lastModified)
+	}
+}
+
+// Serve writes the cached response to w, or a 304 when the validators of r
+// match it
+func (e *CacheEntry) Serve(w http.ResponseWriter, r *http.Request) {
+	age := int64(e.CurrentAge(time.Now()) / time.Second)
+	w.Header().Set("Age", strconv.FormatInt(age, 10))
+
+	if e.StatusCode == http.StatusOK && NotModified(r, e.Header) {
+		WriteNotModified(w, e.Header)
+		return
+	}
+
+	header := w.Header()
+	for key, values := range e.Header {
+		header[key] = append([]string(nil), values...)
+	}
+	header.Set("Content-Length", strconv.Itoa(len(e.Body)))
+	w.WriteHeader(e.StatusCode)
+	w.Write(e.Body)
+}
+
+// Cache tiers reported in metrics
+const (
+	CacheTierMemory = "memory"
+	CacheTierShared = "shared"
+)
+
+// entryOverhead approximates the memory used by an entry besides its body
+// and headers
+const entryOverhead = 256
+
+// SharedCache is a cache tier shared by every gateway instance
+type SharedCache interface {
+	Get(ctx context.Context, key string) (*CacheEntry, bool, error)
+	Set(ctx context.Context, key string, entry *CacheEntry) error
+}
+
+// Cache is the two-tier response cache: a size-bounded LRU in memory in
+// front of an optional shared tier. Entries found in the shared tier are
+// promoted to memory; stores write through to both tiers.
 type Cache struct {
-	entries map[string]*CacheEntry
-	mu      sync.RWMutex
+	memory        *lru.Cache[*CacheEntry]
+	shared        SharedCache
+	sharedTimeout time.Duration
+	metrics       *observability.Metrics
 }
 
-func NewCache() *Cache {
-	c := &Cache{
-		entries: make(map[string]*CacheEntry),
+// NewCache returns a cache holding up to maxBytes of responses in memory.
+// shared and metrics may be nil.
+func NewCache(maxBytes int64, shared SharedCache, sharedTimeout time.Duration, metrics *observability.Metrics) *Cache {
+	return &Cache{
+		memory:        lru.New[*CacheEntry](maxBytes),
+		shared:        shared,
+		sharedTimeout: sharedTimeout,
+		metrics:       metrics,
 	}
-	
-	// Cleanup expired entries
-	go c.cleanup()
-	
-	return c
 }
 
-func (c *Cache) Get(key string) ([]byte, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	
-	entry, exists := c.entries[key]
-	if !exists || time.Now().After(entry.expiresAt) {
+// Get returns the entry stored under key, which may be stale
+func (c *Cache) Get(ctx context.Context, key string) (*CacheEntry, bool) {
+	now := time.Now()
+	if entry, ok := c.memory.Get(key); ok {
+		if now.Before(entry.RetainUntil) {
+			c.record(CacheTierMemory, true)
+			return entry, true
+		}
+		c.memory.Remove(key)
+	}
+	c.record(CacheTierMemory, false)
+
+	if c.shared == nil {
 		return nil, false
 	}
-	
-	return entry.data, true
+	ctx, cancel := c.sharedContext(ctx)
+	defer cancel()
+	entry, ok, err := c.shared.Get(ctx, key)
+	if err != nil || !ok || !now.Before(entry.RetainUntil) {
+		c.record(CacheTierShared, false)
+		return nil, false
+	}
+	c.record(CacheTierShared, true)
+	c.memory.Add(key, entry, entry.size(key))
+	return entry, true
+}
+
+// Set stores entry under key in memory and, in the background, in the
+// shared tier
+func (c *Cache) Set(ctx context.Context, key string, entry *CacheEntry) {
+	c.memory.Add(key, entry, entry.size(key))
+	if c.shared == nil {
+		return
+	}
+
+	ctx, cancel := c.sharedContext(context.WithoutCancel(ctx))
+	go func() {
+		defer cancel()
+		c.shared.Set(ctx, key, entry)
+	}()
+}
+
+func (c *Cache) sharedContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.sharedTimeout > 0 {
+		return context.WithTimeout(ctx, c.sharedTimeout)
+	}
+	return context.WithCancel(ctx)
 }
 
-func (c *Cache) Set(key string, data []byte, ttl time.Duration) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	
-	c.entries[key] = &CacheEntry{
-		data:      data,
-		expiresAt: time.Now().Add(ttl),
+func (c *Cache) record(tier string, hit bool) {
+	if c.metrics != nil {
+		c.metrics.RecordCacheLookup(tier, hit)
 	}
 }
 
-func (c *Cache) cleanup() {
-	ticker := time.NewTicker(1 * time.Minute)
-	defer ticker.Stop()
-	
-	for range ticker.C {
-		c.mu.Lock()
-		now := time.Now()
-		for key, entry := range c.entries {
-			if now.After(entry.expiresAt) {
-				delete(c.entries, key)
-			}
+// Purge removes the entries selected by p from the memory tier and returns
+// how many were removed. The shared tier is purged separately, by RedisCache.
+func (c *Cache) Purge(p *CachePurge) int {
+	return c.memory.RemoveFunc(p.matcher())
+}
+
+// size approximates the memory held by the entry stored under key
+func (e *CacheEntry) size(key string) int64 {
+	n := int64(len(key) + len(e.Body) + entryOverhead)
+	for name, values := range e.Header {
+		n += int64(len(name))
+		for _, v := range values {
+			n += int64(len(v))
 		}
-		c.mu.Unlock()
 	}
+	return n
+}
+
+// Lookup returns the variant of the response stored under key that matches
+// the request headers named by its Vary header
+func (c *Cache) Lookup(key string, r *http.Request) (*CacheEntry, bool) {
+	entry, ok := c.Get(r.Context(), key)
+	if !ok || len(entry.Vary) == 0 {
+		return entry, ok
+	}
+	return c.Get(r.Context(), variantKey(key, entry.Vary, r))
+}
+
+// Store saves a response under key. Responses with a Vary header are stored
+// per variant, with a marker under key recording which headers to match.
+func (c *Cache) Store(key string, r *http.Request, entry *CacheEntry) {
+	ctx := r.Context()
+	if len(entry.Vary) == 0 {
+		c.Set(ctx, key, entry)
+		return
+	}
+
+	marker := &CacheEntry{RouteID: entry.RouteID, Vary: entry.Vary, RetainUntil: entry.RetainUntil}
+	if existing, ok := c.memory.Get(key); ok && existing.RetainUntil.After(marker.RetainUntil) {
+		marker.RetainUntil = existing.RetainUntil
+	}
+	c.Set(ctx, key, marker)
+	c.Set(ctx, variantKey(key, entry.Vary, r), entry)
+}
+
+// variantKey extends key with the request's values of the Vary headers
+func variantKey(key string, vary []string, r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(key)
+	for _, name := range vary {
+		b.WriteString("|v:")
+		b.WriteString(strings.ToLower(name))
+		b.WriteByte('=')
+		b.WriteString(url.QueryEscape(strings.Join(r.Header.Values(name), ",")))
+	}
+	return b.String()
 }
@@ -0,0 +1,293 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/cache/redis"
+	"github.com/vantageedge/backend/pkg/logger"
+)
+
+// Shared tier key layout. Besides the entries themselves, every stored key is
+// indexed so purges can find it without scanning the keyspace: in a sorted
+// set per tenant, whose lexicographic order serves URL and path prefix
+// purges, and in sets per route and per tag. Entries expire on their own, so
+// stores sweep the indexes they touch for keys whose entry is gone, resuming
+// from a cursor kept under the sweep prefix.
+//
+// The scripts derive entry and sweep cursor keys from these prefixes rather
+// than receiving them in KEYS, and a purge touches every entry of a tenant, so
+// the shared tier needs a single Redis node (with replicas, if any). Redis
+// Cluster is refused by NewRedisCache.
+const (
+	sharedCachePrefix = "cache:"
+	sharedIndexPrefix = "cache:index:"
+	sharedRoutePrefix = "cache:route:"
+	sharedTagPrefix   = "cache:tag:"
+	sharedSweepPrefix = "cache:sweep:"
+
+	// purgeBatchSize bounds the keys deleted by one script call so purges
+	// of large sets do not block Redis
+	purgeBatchSize = 500
+
+	// sweepBatchSize is the number of index members a store checks for
+	// expired entries in each index
+	sweepBatchSize = 20
+)
+
+// storeScript stores an entry, adds its key to the indexes, which are kept
+// at least as long as the entry, and removes a batch of expired keys from
+// each index.
+// KEYS[1] = entry, KEYS[2] = tenant index, KEYS[3..] = route and tag sets
+// ARGV[1] = entry JSON, ARGV[2] = TTL in ms, ARGV[3] = cache key,
+// ARGV[4] = entry prefix, ARGV[5] = sweep cursor prefix, ARGV[6] = sweep
+// batch size
+var storeScript = redis.NewScript(`
+local ttl = tonumber(ARGV[2])
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ttl)
+redis.call('ZADD', KEYS[2], 0, ARGV[3])
+for i = 3, #KEYS do
+	redis.call('SADD', KEYS[i], ARGV[3])
+end
+for i = 2, #KEYS do
+	if redis.call('PTTL', KEYS[i]) < ttl then
+		redis.call('PEXPIRE', KEYS[i], ttl)
+	end
+end
+
+local function sweep(index, scan, remove, stride)
+	local cursorKey = ARGV[5] .. index
+	local cursor = redis.call('GET', cursorKey) or '0'
+	local reply = redis.call(scan, index, cursor, 'COUNT', tonumber(ARGV[6]))
+	local expired = {}
+	for i = 1, #reply[2], stride do
+		local key = reply[2][i]
+		if redis.call('EXISTS', ARGV[4] .. key) == 0 then
+			expired[#expired + 1] = key
+		end
+	end
+	if #expired > 0 then
+		redis.call(remove, index, unpack(expired))
+	end
+	if reply[1] == '0' then
+		redis.call('DEL', cursorKey)
+	else
+		redis.call('SET', cursorKey, reply[1], 'PX', redis.call('PTTL', index))
+	end
+end
+
+-- ZSCAN replies alternate members and scores
+sweep(KEYS[2], 'ZSCAN', 'ZREM', 2)
+for i = 3, #KEYS do
+	sweep(KEYS[i], 'SSCAN', 'SREM', 1)
+end
+return 1
+`)
+
+// purgeRangeScript deletes a batch of the entries whose keys fall in a
+// lexicographic range of the tenant index.
+// KEYS[1] = tenant index
+// ARGV[1], ARGV[2] = range, ARGV[3] = batch size, ARGV[4] = entry prefix
+// Returns the number of keys taken from the index and of entries deleted.
+var purgeRangeScript = redis.NewScript(`
+local keys = redis.call('ZRANGEBYLEX', KEYS[1], ARGV[1], ARGV[2], 'LIMIT', 0, tonumber(ARGV[3]))
+local deleted = 0
+for _, key in ipairs(keys) do
+	deleted = deleted + redis.call('DEL', ARGV[4] .. key)
+end
+if #keys > 0 then
+	redis.call('ZREM', KEYS[1], unpack(keys))
+end
+return {#keys, deleted}
+`)
+
+// purgeSetScript deletes a batch of the entries whose keys are in a route or
+// tag set.
+// KEYS[1] = set
+// ARGV[1] = batch size, ARGV[2] = entry prefix
+// Returns the number of keys taken from the set and of entries deleted.
+var purgeSetScript = redis.NewScript(`
+local keys = redis.call('SPOP', KEYS[1], tonumber(ARGV[1]))
+local deleted = 0
+for _, key in ipairs(keys) do
+	deleted = deleted + redis.call('DEL', ARGV[2] .. key)
+end
+return {#keys, deleted}
+`)
+
+// RedisCache is the shared cache tier, storing entries as JSON in Redis
+// until they are no longer retained
+type RedisCache struct {
+	client *redis.Client
+}
+
+var _ SharedCache = (*RedisCache)(nil)
+
+// NewRedisCache returns the shared tier stored in client, which must not be
+// a Redis Cluster node
+func NewRedisCache(client *redis.Client) (*RedisCache, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cluster, err := client.ClusterEnabled(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check redis cluster mode: %w", err)
+	}
+	if cluster {
+		return nil, fmt.Errorf("the shared cache needs a single redis node, redis cluster is not supported")
+	}
+	return &RedisCache{client: client}, nil
+}
+
+func (c *RedisCache) Get(ctx context.Context, key string) (*CacheEntry, bool, error) {
+	data, err := c.client.Get(ctx, sharedCachePrefix+key)
+	if err != nil || data == "" {
+		return nil, false, err
+	}
+
+	var entry CacheEntry
+	if err := json.Unmarshal([]byte(data), &entry); err != nil {
+		return nil, false, err
+	}
+	return &entry, true, nil
+}
+
+func (c *RedisCache) Set(ctx context.Context, key string, entry *CacheEntry) error {
+	ttl := time.Until(entry.RetainUntil)
+	if ttl <= 0 {
+		return nil
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache entry: %w", err)
+	}
+
+	tenant, _, _ := strings.Cut(key, "|")
+	keys := []string{
+		sharedCachePrefix + key,
+		sharedIndexPrefix + tenant,
+		sharedRoutePrefix + entry.RouteID.String(),
+	}
+	for _, tag := range entry.Tags {
+		keys = append(keys, tagSetKey(tenant, tag))
+	}
+	_, err = c.client.Run(ctx, storeScript, keys, data, ttl.Milliseconds(), key, sharedCachePrefix, sharedSweepPrefix, sweepBatchSize)
+	return err
+}
+
+// Purge deletes the entries selected by p from Redis and returns how many
+// were deleted
+func (c *RedisCache) Purge(ctx context.Context, p *CachePurge) (int, error) {
+	index := sharedIndexPrefix + p.TenantID.String()
+	purged := 0
+
+	purgeRange := func(min, max string) error {
+		n, err := c.purgeBatches(ctx, purgeRangeScript, index, min, max, purgeBatchSize, sharedCachePrefix)
+		purged += n
+		return err
+	}
+	purgeSet := func(set string) error {
+		n, err := c.purgeBatches(ctx, purgeSetScript, set, purgeBatchSize, sharedCachePrefix)
+		purged += n
+		return err
+	}
+
+	for _, key := range p.urlKeys() {
+		if err := purgeRange("["+key, "["+key); err != nil {
+			return purged, err
+		}
+		if err := purgeRange("["+key+"|", "["+key+"|\xff"); err != nil {
+			return purged, err
+		}
+	}
+	if p.PathPrefix != "" {
+		prefix := p.pathKey(p.PathPrefix)
+		if err := purgeRange("["+prefix, "["+prefix+"\xff"); err != nil {
+			return purged, err
+		}
+	}
+	if p.RouteID != nil {
+		if err := purgeSet(sharedRoutePrefix + p.RouteID.String()); err != nil {
+			return purged, err
+		}
+	}
+	for _, tag := range p.Tags {
+		if err := purgeSet(tagSetKey(p.TenantID.String(), tag)); err != nil {
+			return purged, err
+		}
+	}
+	return purged, nil
+}
+
+// purgeBatches runs a purge script until it finds no more keys and returns
+// the number of entries deleted
+func (c *RedisCache) purgeBatches(ctx context.Context, script *redis.Script, key string, args ...interface{}) (int, error) {
+	deleted := 0
+	for {
+		res, err := c.client.Run(ctx, script, []string{key}, args...)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to purge %s: %w", key, err)
+		}
+		counts, ok := res.([]interface{})
+		if !ok || len(counts) != 2 {
+			return deleted, fmt.Errorf("unexpected purge script result %v", res)
+		}
+		found, _ := counts[0].(int64)
+		n, _ := counts[1].(int64)
+		deleted += int(n)
+		if found == 0 {
+			return deleted, nil
+		}
+	}
+}
+
+func tagSetKey(tenant, tag string) string {
+	return sharedTagPrefix + tenant + ":" + tag
+}
+
+// CachePurger purges cached responses across the fleet: entries are deleted
+// from Redis and every gateway instance is then told to drop them from
+// memory
+type CachePurger struct {
+	client *redis.Client
+	shared *RedisCache
+}
+
+func NewCachePurger(client *redis.Client) (*CachePurger, error) {
+	shared, err := NewRedisCache(client)
+	if err != nil {
+		return nil, err
+	}
+	return &CachePurger{client: client, shared: shared}, nil
+}
+
+// Purge returns the number of entries deleted from Redis; gateway instances
+// purge their memory tier asynchronously
+func (c *CachePurger) Purge(ctx context.Context, p *CachePurge) (int, error) {
+	purged, err := c.shared.Purge(ctx, p)
+	if err != nil {
+		return purged, err
+	}
+	if err := c.client.Publish(ctx, CachePurgeChannel, p); err != nil {
+		return purged, fmt.Errorf("failed to publish purge: %w", err)
+	}
+	return purged, nil
+}
+
+// SubscribePurges applies the purges published on CachePurgeChannel to the
+// memory tier of cache until ctx is done
+func SubscribePurges(ctx context.Context, client *redis.Client, cache *Cache, log *logger.Logger) error {
+	return client.Subscribe(ctx, CachePurgeChannel, func(payload string) {
+		var p CachePurge
+		if err := json.Unmarshal([]byte(payload), &p); err != nil || p.TenantID == uuid.Nil {
+			log.Warn().Str("payload", payload).Msg("Ignoring malformed cache purge")
+			return
+		}
+		n := cache.Purge(&p)
+		log.Info().Str("tenant_id", p.TenantID.String()).Int("entries", n).Msg("Purged cached responses")
+	})
+}
@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vantageedge/backend/internal/observability"
+)
+
+// mapCache is an in-process stand-in for the shared tier
+type mapCache struct {
+	mu      sync.Mutex
+	entries map[string]*CacheEntry
+}
+
+func (m *mapCache) Get(ctx context.Context, key string) (*CacheEntry, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.entries[key]
+	return entry, ok, nil
+}
+
+func (m *mapCache) Set(ctx context.Context, key string, entry *CacheEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = entry
+	return nil
+}
+
+func (m *mapCache) len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.entries)
+}
+
+func testEntry(body string) *CacheEntry {
+	now := time.Now()
+	return &CacheEntry{
+		StatusCode:  http.StatusOK,
+		Header:      http.Header{},
+		Body:        []byte(body),
+		StoredAt:    now,
+		ExpiresAt:   now.Add(time.Minute),
+		RetainUntil: now.Add(time.Minute),
+	}
+}
+
+func TestCacheBoundsMemory(t *testing.T) {
+	c := NewCache(4096, nil, 0, nil)
+	ctx := context.Background()
+
+	for _, key := range []string{"a", "b", "c"} {
+		c.Set(ctx, key, testEntry(strings.Repeat("x", 1500)))
+	}
+	if _, ok := c.Get(ctx, "a"); ok {
+		t.Fatal("expected the oldest entry to be evicted")
+	}
+	if _, ok := c.Get(ctx, "c"); !ok {
+		t.Fatal("expected the newest entry to be cached")
+	}
+	if c.memory.Size() > 4096 {
+		t.Fatalf("expected memory tier within 4096 bytes, got %d", c.memory.Size())
+	}
+}
+
+func TestCachePromotesFromSharedTier(t *testing.T) {
+	shared := &mapCache{entries: make(map[string]*CacheEntry)}
+	metrics := observability.NewMetrics()
+	ctx := context.Background()
+
+	// One replica stores, another finds the entry in the shared tier
+	NewCache(1<<20, shared, time.Second, nil).Set(ctx, "k", testEntry("hello"))
+	deadline := time.Now().Add(time.Second)
+	for shared.len() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the entry to be written to the shared tier")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	c := NewCache(1<<20, shared, time.Second, metrics)
+	for i := 0; i < 2; i++ {
+		entry, ok := c.Get(ctx, "k")
+		if !ok || string(entry.Body) != "hello" {
+			t.Fatalf("lookup %d: expected the shared entry, got %+v", i, entry)
+		}
+	}
+	if _, ok := c.Get(ctx, "missing"); ok {
+		t.Fatal("expected a miss")
+	}
+
+	m := metrics.GetMetrics()
+	hits := m["cache_tier_hits"].(map[string]int64)
+	misses := m["cache_tier_misses"].(map[string]int64)
+	if hits[CacheTierMemory] != 1 || misses[CacheTierMemory] != 2 {
+		t.Fatalf("expected 1 memory hit after promotion and 2 misses, got %d and %d", hits[CacheTierMemory], misses[CacheTierMemory])
+	}
+	if hits[CacheTierShared] != 1 || misses[CacheTierShared] != 1 {
+		t.Fatalf("expected 1 shared hit and 1 miss, got %d and %d", hits[CacheTierShared], misses[CacheTierShared])
+	}
+}
+
+func TestCacheIgnoresExpiredSharedEntries(t *testing.T) {
+	shared := &mapCache{entries: make(map[string]*CacheEntry)}
+	entry := testEntry("old")
+	entry.RetainUntil = time.Now().Add(-time.Second)
+	shared.entries["k"] = entry
+
+	c := NewCache(1<<20, shared, time.Second, nil)
+	if _, ok := c.Lookup("k", httptest.NewRequest(http.MethodGet, "/", nil)); ok {
+		t.Fatal("expected entries past their retention to be ignored")
+	}
+}
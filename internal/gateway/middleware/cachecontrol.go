@@ -0,0 +1,299 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cacheableStatus lists the statuses a shared cache may store
+// (RFC 9110, section 15.1)
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusPermanentRedirect:    true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+	http.StatusRequestURITooLong:    true,
+	http.StatusNotImplemented:       true,
+}
+
+// notModifiedHeaders are copied from the stored response into a 304
+// (RFC 9110, section 15.4.5)
+var notModifiedHeaders = []string{
+	"Cache-Control",
+	"Content-Location",
+	"Date",
+	"ETag",
+	"Expires",
+	"Last-Modified",
+	"Vary",
+}
+
+// CacheControl holds the Cache-Control directives the gateway acts on
+type CacheControl struct {
+	NoStore        bool
+	NoCache        bool
+	Private        bool
+	Public         bool
+	MustRevalidate bool
+	HasMaxAge      bool
+	MaxAge         time.Duration
+	HasSMaxAge     bool
+	SMaxAge        time.Duration
+
+	// RFC 5861 extensions
+	HasStaleWhileRevalidate bool
+	StaleWhileRevalidate    time.Duration
+	HasStaleIfError         bool
+	StaleIfError            time.Duration
+}
+
+// CachePolicy is the caching configuration applied to a route's responses
+type CachePolicy struct {
+	DefaultTTL           time.Duration // lifetime of responses without explicit freshness
+	RetainStale          time.Duration // how long stale entries with validators are kept for revalidation
+	StaleWhileRevalidate time.Duration // how long stale entries are served while being refreshed
+	StaleIfError         time.Duration // how long stale entries are served when the origin fails
+}
+
+// ParseCacheControl parses the Cache-Control header of a request or
+// response. Unknown directives are ignored.
+func ParseCacheControl(h http.Header) CacheControl {
+	var cc CacheControl
+	for _, value := range h.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			arg = strings.Trim(arg, `"`)
+			switch strings.ToLower(name) {
+			case "no-store":
+				cc.NoStore = true
+			case "no-cache":
+				cc.NoCache = true
+			case "private":
+				cc.Private = true
+			case "public":
+				cc.Public = true
+			case "must-revalidate", "proxy-revalidate":
+				cc.MustRevalidate = true
+			case "max-age":
+				if d, ok := parseDeltaSeconds(arg); ok {
+					cc.HasMaxAge, cc.MaxAge = true, d
+				}
+			case "s-maxage":
+				if d, ok := parseDeltaSeconds(arg); ok {
+					cc.HasSMaxAge, cc.SMaxAge = true, d
+				}
+			case "stale-while-revalidate":
+				if d, ok := parseDeltaSeconds(arg); ok {
+					cc.HasStaleWhileRevalidate, cc.StaleWhileRevalidate = true, d
+				}
+			case "stale-if-error":
+				if d, ok := parseDeltaSeconds(arg); ok {
+					cc.HasStaleIfError, cc.StaleIfError = true, d
+				}
+			}
+		}
+	}
+	return cc
+}
+
+// credentialed reports whether r carried credentials: an Authorization or
+// X-API-Key header, or any the gateway authenticated the request with
+func credentialed(r *http.Request) bool {
+	if r.Header.Get("Authorization") != "" || r.Header.Get("X-API-Key") != "" {
+		return true
+	}
+	identity, ok := IdentityFromContext(r.Context())
+	return ok && identity.Method != ""
+}
+
+func parseDeltaSeconds(s string) (time.Duration, bool) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// NewCacheEntry builds the cache entry for a response to r if a shared cache
+// may store it. The policy's DefaultTTL applies when the origin sends neither
+// Cache-Control max-age nor Expires, and its stale windows when the origin
+// sends no stale-while-revalidate or stale-if-error directives.
+func NewCacheEntry(r *http.Request, status int, header http.Header, policy CachePolicy, now time.Time) (*CacheEntry, bool) {
+	if !cacheableStatus[status] || header.Get("Set-Cookie") != "" {
+		return nil, false
+	}
+	if ParseCacheControl(r.Header).NoStore {
+		return nil, false
+	}
+
+	cc := ParseCacheControl(header)
+	if cc.NoStore || cc.Private {
+		return nil, false
+	}
+	// Responses to authorized requests are per user unless the origin says
+	// otherwise (RFC 9111, section 3.5)
+	if credentialed(r) && !cc.Public && !cc.HasSMaxAge && !cc.MustRevalidate {
+		return nil, false
+	}
+
+	vary := varyHeaders(header)
+	for _, name := range vary {
+		if name == "*" {
+			return nil, false
+		}
+	}
+
+	lifetime := policy.DefaultTTL
+	switch {
+	case cc.HasSMaxAge:
+		lifetime = cc.SMaxAge
+	case cc.HasMaxAge:
+		lifetime = cc.MaxAge
+	case header.Get("Expires") != "":
+		// An invalid Expires means already expired
+		lifetime = 0
+		if expires, err := http.ParseTime(header.Get("Expires")); err == nil {
+			date := now
+			if d, err := http.ParseTime(header.Get("Date")); err == nil {
+				date = d
+			}
+			lifetime = expires.Sub(date)
+		}
+	}
+	if cc.NoCache {
+		lifetime = 0
+	}
+
+	age := responseAge(header, now)
+	ttl := lifetime - age
+	if ttl < 0 {
+		ttl = 0
+	}
+
+	entry := &CacheEntry{
+		StatusCode:           status,
+		Header:               header,
+		Vary:                 vary,
+		Tags:                 cacheTags(header),
+		Age:                  age,
+		StoredAt:             now,
+		ExpiresAt:            now.Add(ttl),
+		StaleWhileRevalidate: policy.StaleWhileRevalidate,
+		StaleIfError:         policy.StaleIfError,
+	}
+	if cc.HasStaleWhileRevalidate {
+		entry.StaleWhileRevalidate = cc.StaleWhileRevalidate
+	}
+	if cc.HasStaleIfError {
+		entry.StaleIfError = cc.StaleIfError
+	}
+	// Stale responses must not be served without revalidation
+	if cc.MustRevalidate || cc.NoCache {
+		entry.StaleWhileRevalidate, entry.StaleIfError = 0, 0
+	}
+
+	retain := entry.StaleWhileRevalidate
+	if entry.StaleIfError > retain {
+		retain = entry.StaleIfError
+	}
+	if entry.HasValidators() && policy.RetainStale > retain {
+		retain = policy.RetainStale
+	}
+	entry.RetainUntil = entry.ExpiresAt.Add(retain)
+	if !entry.RetainUntil.After(now) {
+		return nil, false
+	}
+	return entry, true
+}
+
+// responseAge is the age of a response when it reached the gateway: the
+// larger of its Age header and the time since its Date
+func responseAge(header http.Header, now time.Time) time.Duration {
+	var age time.Duration
+	if d, ok := parseDeltaSeconds(header.Get("Age")); ok {
+		age = d
+	}
+	if date, err := http.ParseTime(header.Get("Date")); err == nil {
+		if apparent := now.Sub(date); apparent > age {
+			age = apparent
+		}
+	}
+	return age
+}
+
+// varyHeaders returns the canonical header names listed in Vary
+func varyHeaders(header http.Header) []string {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
+
+// StripConditionals removes the client's validators from a request so the
+// origin answers with a full response the cache can store
+func StripConditionals(h http.Header) {
+	h.Del("If-None-Match")
+	h.Del("If-Modified-Since")
+}
+
+// NotModified reports whether the validators of r match a response with the
+// given headers, i.e. whether the client may be sent a 304
+// (RFC 9110, section 13.2.2)
+func NotModified(r *http.Request, header http.Header) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		etag := header.Get("ETag")
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || weakTag(candidate) == weakTag(etag) {
+				return true
+			}
+		}
+		return false
+	}
+
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	lastModified, err := http.ParseTime(header.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return !lastModified.After(ims)
+}
+
+// weakTag strips the weak indicator for the weak comparison used by
+// If-None-Match
+func weakTag(tag string) string {
+	return strings.TrimPrefix(tag, "W/")
+}
+
+// WriteNotModified writes a 304 carrying the validators and caching headers
+// of the response it stands in for
+func WriteNotModified(w http.ResponseWriter, header http.Header) {
+	for _, name := range notModifiedHeaders {
+		if values := header.Values(name); len(values) > 0 {
+			w.Header()[name] = append([]string(nil), values...)
+		}
+	}
+	w.WriteHeader(http.StatusNotModified)
+}
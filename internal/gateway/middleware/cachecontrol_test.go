@@ -0,0 +1,176 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newEntry(t *testing.T, header http.Header, now time.Time) (*CacheEntry, bool) {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/items", nil)
+	return NewCacheEntry(r, http.StatusOK, header, CachePolicy{DefaultTTL: time.Minute, RetainStale: time.Hour}, now)
+}
+
+func TestNewCacheEntryFreshness(t *testing.T) {
+	now := time.Now()
+	date := now.UTC().Format(http.TimeFormat)
+
+	cases := []struct {
+		name   string
+		header http.Header
+		ttl    time.Duration
+	}{
+		{"fallback", http.Header{}, time.Minute},
+		{"max-age", http.Header{"Cache-Control": {"max-age=30"}}, 30 * time.Second},
+		{"s-maxage wins", http.Header{"Cache-Control": {"max-age=30, s-maxage=90"}}, 90 * time.Second},
+		{"expires", http.Header{
+			"Date":    {date},
+			"Expires": {now.Add(2 * time.Hour).UTC().Format(http.TimeFormat)},
+		}, 2 * time.Hour},
+		{"age", http.Header{"Cache-Control": {"max-age=30"}, "Age": {"10"}}, 20 * time.Second},
+	}
+	for _, tc := range cases {
+		entry, ok := newEntry(t, tc.header, now)
+		if !ok {
+			t.Errorf("%s: expected response to be storable", tc.name)
+			continue
+		}
+		// Date has second precision
+		if got := entry.ExpiresAt.Sub(now); got < tc.ttl-time.Second || got > tc.ttl {
+			t.Errorf("%s: expected ttl %v, got %v", tc.name, tc.ttl, got)
+		}
+	}
+}
+
+func TestNewCacheEntryNotStorable(t *testing.T) {
+	now := time.Now()
+	for name, header := range map[string]http.Header{
+		"no-store":                {"Cache-Control": {"no-store"}},
+		"private":                 {"Cache-Control": {"private, max-age=60"}},
+		"vary star":               {"Vary": {"*"}},
+		"set-cookie":              {"Set-Cookie": {"session=1"}},
+		"expired, no validators":  {"Cache-Control": {"max-age=0"}},
+		"no-cache, no validators": {"Cache-Control": {"no-cache"}},
+	} {
+		if _, ok := newEntry(t, header, now); ok {
+			t.Errorf("%s: expected response not to be stored", name)
+		}
+	}
+
+	// Expired responses with validators are kept for revalidation
+	entry, ok := newEntry(t, http.Header{"Cache-Control": {"no-cache"}, "Etag": {`"v1"`}}, now)
+	if !ok || entry.Fresh(now) || !entry.RetainUntil.After(now) {
+		t.Fatalf("expected a stale, retained entry, got %+v", entry)
+	}
+}
+
+func TestNewCacheEntryStaleWindows(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/items", nil)
+	now := time.Now()
+	policy := CachePolicy{StaleWhileRevalidate: time.Minute, StaleIfError: time.Hour}
+
+	entry, _ := NewCacheEntry(r, http.StatusOK, http.Header{"Cache-Control": {"max-age=10"}}, policy, now)
+	later := now.Add(30 * time.Second)
+	if entry.Fresh(later) || !entry.ServableWhileRevalidating(later) || !entry.ServableOnError(later) {
+		t.Fatalf("expected the route's windows to apply, got %+v", entry)
+	}
+	if !entry.RetainUntil.Equal(now.Add(10*time.Second + time.Hour)) {
+		t.Fatalf("expected the entry to be kept for the longest window, got %v", entry.RetainUntil.Sub(now))
+	}
+
+	// Origin directives take precedence
+	entry, _ = NewCacheEntry(r, http.StatusOK, http.Header{"Cache-Control": {"max-age=10, stale-while-revalidate=5"}}, policy, now)
+	if entry.ServableWhileRevalidating(later) {
+		t.Fatal("expected the origin's stale-while-revalidate to apply")
+	}
+	entry, _ = NewCacheEntry(r, http.StatusOK, http.Header{"Cache-Control": {"max-age=10, must-revalidate"}}, policy, now)
+	if entry.ServableWhileRevalidating(later) || entry.ServableOnError(later) {
+		t.Fatal("expected must-revalidate to disable stale serving")
+	}
+}
+
+func TestNewCacheEntryAuthorization(t *testing.T) {
+	bearer := httptest.NewRequest(http.MethodGet, "/items", nil)
+	bearer.Header.Set("Authorization", "Bearer token")
+	apiKey := httptest.NewRequest(http.MethodGet, "/items", nil)
+	apiKey.Header.Set("X-API-Key", "key")
+	authenticated := httptest.NewRequest(http.MethodGet, "/items", nil)
+	authenticated = authenticated.WithContext(WithIdentity(authenticated.Context(), &Identity{Method: AuthMethodAPIKey}))
+
+	for name, r := range map[string]*http.Request{"bearer": bearer, "api key": apiKey, "authenticated": authenticated} {
+		if _, ok := NewCacheEntry(r, http.StatusOK, http.Header{"Cache-Control": {"max-age=60"}}, CachePolicy{}, time.Now()); ok {
+			t.Errorf("%s: expected an authorized response not to be shared by default", name)
+		}
+		if _, ok := NewCacheEntry(r, http.StatusOK, http.Header{"Cache-Control": {"public, max-age=60"}}, CachePolicy{}, time.Now()); !ok {
+			t.Errorf("%s: expected a public response to be stored", name)
+		}
+	}
+
+	anonymous := httptest.NewRequest(http.MethodGet, "/items", nil)
+	anonymous = anonymous.WithContext(WithIdentity(anonymous.Context(), &Identity{}))
+	if _, ok := NewCacheEntry(anonymous, http.StatusOK, http.Header{"Cache-Control": {"max-age=60"}}, CachePolicy{}, time.Now()); !ok {
+		t.Fatal("expected an anonymous response to be stored")
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	lastModified := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	header := http.Header{
+		"Etag":          {`W/"v1"`},
+		"Last-Modified": {lastModified.Format(http.TimeFormat)},
+	}
+
+	cases := []struct {
+		name     string
+		header   string
+		value    string
+		expected bool
+	}{
+		{"matching etag", "If-None-Match", `"v1"`, true},
+		{"etag list", "If-None-Match", `"v0", W/"v1"`, true},
+		{"wildcard", "If-None-Match", "*", true},
+		{"stale etag", "If-None-Match", `"v0"`, false},
+		{"not modified since", "If-Modified-Since", lastModified.Add(time.Hour).Format(http.TimeFormat), true},
+		{"modified since", "If-Modified-Since", lastModified.Add(-time.Hour).Format(http.TimeFormat), false},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/items", nil)
+		r.Header.Set(tc.header, tc.value)
+		if got := NotModified(r, header); got != tc.expected {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestCacheVariants(t *testing.T) {
+	c := NewCache(1<<20, nil, 0, nil)
+	now := time.Now()
+
+	request := func(lang string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/items", nil)
+		r.Header.Set("Accept-Language", lang)
+		return r
+	}
+	store := func(lang string) {
+		entry, ok := newEntry(t, http.Header{"Vary": {"accept-language"}}, now)
+		if !ok {
+			t.Fatal("expected response to be storable")
+		}
+		entry.Body = []byte(lang)
+		c.Store("k", request(lang), entry)
+	}
+
+	store("en")
+	store("de")
+	for _, lang := range []string{"en", "de"} {
+		entry, ok := c.Lookup("k", request(lang))
+		if !ok || string(entry.Body) != lang {
+			t.Fatalf("expected the %s variant, got %+v", lang, entry)
+		}
+	}
+	if _, ok := c.Lookup("k", request("fr")); ok {
+		t.Fatal("expected no variant for an unseen header value")
+	}
+}
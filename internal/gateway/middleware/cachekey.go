@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/models"
+)
+
+// Cache key pattern components, joined with "+" in routes.cache_key_pattern,
+// e.g. "path+query+header:Accept-Language+cookie:region"
+const (
+	CacheKeyPath   = "path"
+	CacheKeyQuery  = "query"
+	CacheKeyHeader = "header:"
+	CacheKeyCookie = "cookie:"
+
+	DefaultCacheKeyPattern = "path+query"
+)
+
+// Cache bypass rule types
+const (
+	BypassHeader     = "header"
+	BypassCookie     = "cookie"
+	BypassQuery      = "query"
+	BypassMethod     = "method"
+	BypassPathPrefix = "path_prefix"
+)
+
+// ValidateCacheKeyPattern checks that every component of pattern is known
+// and that the path is part of the key
+func ValidateCacheKeyPattern(pattern string) error {
+	if pattern == "" {
+		return nil
+	}
+
+	hasPath := false
+	for _, part := range strings.Split(pattern, "+") {
+		switch {
+		case part == CacheKeyPath:
+			hasPath = true
+		case part == CacheKeyQuery:
+		case strings.HasPrefix(part, CacheKeyHeader) && len(part) > len(CacheKeyHeader):
+		case strings.HasPrefix(part, CacheKeyCookie) && len(part) > len(CacheKeyCookie):
+		default:
+			return fmt.Errorf("unknown cache key component %q", part)
+		}
+	}
+	if !hasPath {
+		return fmt.Errorf("cache key pattern must include %q", CacheKeyPath)
+	}
+	return nil
+}
+
+// CacheKey builds the cache key of a request under the route's key pattern.
+// Keys are namespaced by tenant and method; the query string is normalised
+// so parameter order does not split the cache.
+func CacheKey(tenantID uuid.UUID, pattern string, r *http.Request) string {
+	if pattern == "" {
+		pattern = DefaultCacheKeyPattern
+	}
+
+	var b strings.Builder
+	b.WriteString(tenantID.String())
+	b.WriteByte('|')
+	b.WriteString(r.Method)
+	b.WriteByte('|')
+	b.WriteString(r.URL.Path)
+
+	parts := strings.Split(pattern, "+")
+	for _, part := range parts {
+		if part == CacheKeyQuery && r.URL.RawQuery != "" {
+			b.WriteByte('?')
+			b.WriteString(r.URL.Query().Encode())
+		}
+	}
+	for _, part := range parts {
+		switch {
+		case strings.HasPrefix(part, CacheKeyHeader):
+			name := part[len(CacheKeyHeader):]
+			b.WriteString("|h:")
+			b.WriteString(strings.ToLower(name))
+			b.WriteByte('=')
+			b.WriteString(url.QueryEscape(strings.Join(r.Header.Values(name), ",")))
+		case strings.HasPrefix(part, CacheKeyCookie):
+			name := part[len(CacheKeyCookie):]
+			b.WriteString("|c:")
+			b.WriteString(name)
+			b.WriteByte('=')
+			if cookie, err := r.Cookie(name); err == nil {
+				b.WriteString(url.QueryEscape(cookie.Value))
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// ValidateCacheBypassRules checks the type and required fields of each rule
+func ValidateCacheBypassRules(rules models.CacheBypassRules) error {
+	for i, rule := range rules {
+		switch rule.Type {
+		case BypassHeader, BypassCookie, BypassQuery:
+			if rule.Name == "" {
+				return fmt.Errorf("rule %d: %s rules require a name", i, rule.Type)
+			}
+		case BypassMethod, BypassPathPrefix:
+			if rule.Value == "" {
+				return fmt.Errorf("rule %d: %s rules require a value", i, rule.Type)
+			}
+		default:
+			return fmt.Errorf("rule %d: unknown type %q", i, rule.Type)
+		}
+	}
+	return nil
+}
+
+// CacheBypassed reports whether any rule sends the request past the cache
+func CacheBypassed(r *http.Request, rules models.CacheBypassRules) bool {
+	for _, rule := range rules {
+		if bypassMatches(r, rule) {
+			return true
+		}
+	}
+	return false
+}
+
+func bypassMatches(r *http.Request, rule models.CacheBypassRule) bool {
+	switch rule.Type {
+	case BypassHeader:
+		values := r.Header.Values(rule.Name)
+		if rule.Value == "" {
+			return len(values) > 0
+		}
+		for _, v := range values {
+			if strings.EqualFold(strings.TrimSpace(v), rule.Value) {
+				return true
+			}
+		}
+	case BypassCookie:
+		cookie, err := r.Cookie(rule.Name)
+		if err != nil {
+			return false
+		}
+		return rule.Value == "" || cookie.Value == rule.Value
+	case BypassQuery:
+		values, ok := r.URL.Query()[rule.Name]
+		if !ok {
+			return false
+		}
+		if rule.Value == "" {
+			return true
+		}
+		for _, v := range values {
+			if v == rule.Value {
+				return true
+			}
+		}
+	case BypassMethod:
+		return strings.EqualFold(r.Method, rule.Value)
+	case BypassPathPrefix:
+		return strings.HasPrefix(r.URL.Path, rule.Value)
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/models"
+)
+
+func TestCacheKeyNormalisesQuery(t *testing.T) {
+	tenant := uuid.New()
+	a := CacheKey(tenant, "path+query", httptest.NewRequest(http.MethodGet, "/items?b=2&a=1", nil))
+	b := CacheKey(tenant, "path+query", httptest.NewRequest(http.MethodGet, "/items?a=1&b=2", nil))
+	if a != b {
+		t.Fatalf("expected query order not to matter, got %q and %q", a, b)
+	}
+
+	if CacheKey(tenant, "path", httptest.NewRequest(http.MethodGet, "/items?a=1", nil)) !=
+		CacheKey(tenant, "path", httptest.NewRequest(http.MethodGet, "/items?a=2", nil)) {
+		t.Fatal("expected the query to be ignored by a path-only pattern")
+	}
+	if CacheKey(uuid.New(), "path", httptest.NewRequest(http.MethodGet, "/items", nil)) ==
+		CacheKey(tenant, "path", httptest.NewRequest(http.MethodGet, "/items", nil)) {
+		t.Fatal("expected keys to be namespaced by tenant")
+	}
+}
+
+func TestCacheKeyHeadersAndCookies(t *testing.T) {
+	tenant := uuid.New()
+	pattern := "path+header:Accept-Language+cookie:region"
+
+	req := func(lang, region string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/items", nil)
+		r.Header.Set("Accept-Language", lang)
+		r.AddCookie(&http.Cookie{Name: "region", Value: region})
+		return r
+	}
+
+	if CacheKey(tenant, pattern, req("en", "eu")) == CacheKey(tenant, pattern, req("de", "eu")) {
+		t.Fatal("expected the header to vary the key")
+	}
+	if CacheKey(tenant, pattern, req("en", "eu")) == CacheKey(tenant, pattern, req("en", "us")) {
+		t.Fatal("expected the cookie to vary the key")
+	}
+	if CacheKey(tenant, pattern, req("en", "eu")) != CacheKey(tenant, pattern, req("en", "eu")) {
+		t.Fatal("expected identical requests to share a key")
+	}
+}
+
+func TestValidateCacheKeyPattern(t *testing.T) {
+	for _, p := range []string{"", "path", "path+query", "path+header:Accept+cookie:session"} {
+		if err := ValidateCacheKeyPattern(p); err != nil {
+			t.Errorf("%q: unexpected error %v", p, err)
+		}
+	}
+	for _, p := range []string{"query", "path+body", "path+header:", "path+"} {
+		if err := ValidateCacheKeyPattern(p); err == nil {
+			t.Errorf("%q: expected error", p)
+		}
+	}
+}
+
+func TestCacheBypassed(t *testing.T) {
+	rules := models.CacheBypassRules{
+		{Type: BypassHeader, Name: "Cache-Control", Value: "no-cache"},
+		{Type: BypassCookie, Name: "session"},
+		{Type: BypassQuery, Name: "preview", Value: "true"},
+		{Type: BypassPathPrefix, Value: "/admin/"},
+	}
+
+	cases := []struct {
+		name   string
+		req    func() *http.Request
+		bypass bool
+	}{
+		{"plain", func() *http.Request { return httptest.NewRequest(http.MethodGet, "/items", nil) }, false},
+		{"header", func() *http.Request {
+			r := httptest.NewRequest(http.MethodGet, "/items", nil)
+			r.Header.Set("Cache-Control", "No-Cache")
+			return r
+		}, true},
+		{"other header value", func() *http.Request {
+			r := httptest.NewRequest(http.MethodGet, "/items", nil)
+			r.Header.Set("Cache-Control", "max-age=0")
+			return r
+		}, false},
+		{"cookie present", func() *http.Request {
+			r := httptest.NewRequest(http.MethodGet, "/items", nil)
+			r.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+			return r
+		}, true},
+		{"query", func() *http.Request { return httptest.NewRequest(http.MethodGet, "/items?preview=true", nil) }, true},
+		{"query other value", func() *http.Request { return httptest.NewRequest(http.MethodGet, "/items?preview=false", nil) }, false},
+		{"path prefix", func() *http.Request { return httptest.NewRequest(http.MethodGet, "/admin/users", nil) }, true},
+	}
+	for _, tc := range cases {
+		if got := CacheBypassed(tc.req(), rules); got != tc.bypass {
+			t.Errorf("%s: expected bypass %v, got %v", tc.name, tc.bypass, got)
+		}
+	}
+}
+
+func TestValidateCacheBypassRules(t *testing.T) {
+	valid := models.CacheBypassRules{
+		{Type: BypassHeader, Name: "Authorization"},
+		{Type: BypassMethod, Value: "GET"},
+	}
+	if err := ValidateCacheBypassRules(valid); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	for _, rule := range []models.CacheBypassRule{
+		{Type: "body"},
+		{Type: BypassCookie},
+		{Type: BypassPathPrefix},
+	} {
+		if err := ValidateCacheBypassRules(models.CacheBypassRules{rule}); err == nil {
+			t.Errorf("%+v: expected error", rule)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// CachePurgeChannel is the Redis channel purges are published on so every
+// gateway instance drops the matching entries from its memory tier
+const CachePurgeChannel = "cache:purge"
+
+// Response headers listing the tags an entry can be purged by. Surrogate-Key
+// separates tags with spaces and Cache-Tag with commas.
+const (
+	SurrogateKeyHeader = "Surrogate-Key"
+	CacheTagHeader     = "Cache-Tag"
+)
+
+// CachePurge selects cached responses of a tenant to invalidate. Entries
+// matching any of the selectors are purged.
+type CachePurge struct {
+	TenantID   uuid.UUID  `json:"tenant_id"`
+	URLs       []string   `json:"urls,omitempty"`        // paths or absolute URLs, with their query
+	RouteID    *uuid.UUID `json:"route_id,omitempty"`    // every response cached for the route
+	PathPrefix string     `json:"path_prefix,omitempty"` // every response under the path
+	Tags       []string   `json:"tags,omitempty"`        // responses tagged by the origin
+}
+
+// Empty reports whether the purge selects nothing
+func (p *CachePurge) Empty() bool {
+	return len(p.URLs) == 0 && p.RouteID == nil && p.PathPrefix == "" && len(p.Tags) == 0
+}
+
+// tenantPrefix is the prefix shared by the cache keys of the tenant
+func (p *CachePurge) tenantPrefix() string {
+	return p.TenantID.String() + "|"
+}
+
+// pathKey is the start of the cache keys of GET requests for path
+func (p *CachePurge) pathKey(path string) string {
+	return p.tenantPrefix() + http.MethodGet + "|" + path
+}
+
+// urlKeys returns, for each purged URL, the cache key it has on routes whose
+// key includes the query and the one it has on routes whose key does not.
+// Entries are stored under these keys or under keys extending them with
+// header, cookie or Vary components.
+func (p *CachePurge) urlKeys() []string {
+	var keys []string
+	for _, raw := range p.URLs {
+		u, err := url.Parse(raw)
+		if err != nil {
+			continue
+		}
+		path := u.Path
+		if path == "" {
+			path = "/"
+		}
+		base := p.pathKey(path)
+		keys = append(keys, base)
+		if u.RawQuery != "" {
+			keys = append(keys, base+"?"+u.Query().Encode())
+		}
+	}
+	return keys
+}
+
+// matcher compiles the purge into a predicate over cache keys and entries
+func (p *CachePurge) matcher() func(key string, entry *CacheEntry) bool {
+	tenant := p.tenantPrefix()
+	urlKeys := p.urlKeys()
+	var prefix string
+	if p.PathPrefix != "" {
+		prefix = p.pathKey(p.PathPrefix)
+	}
+	tags := make(map[string]bool, len(p.Tags))
+	for _, tag := range p.Tags {
+		tags[tag] = true
+	}
+
+	return func(key string, entry *CacheEntry) bool {
+		if !strings.HasPrefix(key, tenant) {
+			return false
+		}
+		if p.RouteID != nil && entry.RouteID == *p.RouteID {
+			return true
+		}
+		if prefix != "" && strings.HasPrefix(key, prefix) {
+			return true
+		}
+		for _, k := range urlKeys {
+			if key == k || strings.HasPrefix(key, k+"|") {
+				return true
+			}
+		}
+		for _, tag := range entry.Tags {
+			if tags[tag] {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// cacheTags returns the distinct tags listed in the Surrogate-Key and
+// Cache-Tag headers of a response
+func cacheTags(header http.Header) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	add := func(tag string) {
+		if tag = strings.TrimSpace(tag); tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	for _, value := range header.Values(SurrogateKeyHeader) {
+		for _, tag := range strings.Fields(value) {
+			add(tag)
+		}
+	}
+	for _, value := range header.Values(CacheTagHeader) {
+		for _, tag := range strings.Split(value, ",") {
+			add(tag)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCachePurgeSelectors(t *testing.T) {
+	tenant, other := uuid.New(), uuid.New()
+	route := uuid.New()
+
+	// store caches a response to target under pattern and returns its key
+	store := func(c *Cache, tenantID uuid.UUID, pattern, target string, routeID uuid.UUID, tags ...string) string {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		key := CacheKey(tenantID, pattern, r)
+		entry := testEntry("body")
+		entry.RouteID = routeID
+		entry.Tags = tags
+		c.Set(context.Background(), key, entry)
+		return key
+	}
+
+	tests := []struct {
+		name   string
+		purge  CachePurge
+		purged []string // targets of the tenant expected to be purged
+	}{
+		{
+			name:   "exact URL",
+			purge:  CachePurge{TenantID: tenant, URLs: []string{"https://acme.example.com/posts?b=2&a=1"}},
+			purged: []string{"/posts?a=1&b=2", "/posts (path only)"},
+		},
+		{
+			name:   "path prefix",
+			purge:  CachePurge{TenantID: tenant, PathPrefix: "/posts"},
+			purged: []string{"/posts?a=1&b=2", "/posts/1", "/posts (path only)"},
+		},
+		{
+			name:   "route",
+			purge:  CachePurge{TenantID: tenant, RouteID: &route},
+			purged: []string{"/posts/1"},
+		},
+		{
+			name:   "tag",
+			purge:  CachePurge{TenantID: tenant, Tags: []string{"author-7"}},
+			purged: []string{"/users/7", "/posts?a=1&b=2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCache(1<<20, nil, 0, nil)
+			keys := map[string]string{
+				"/posts?a=1&b=2":     store(c, tenant, DefaultCacheKeyPattern, "/posts?a=1&b=2", uuid.Nil, "author-7"),
+				"/posts (path only)": store(c, tenant, CacheKeyPath, "/posts?x=1", uuid.Nil),
+				"/posts/1":           store(c, tenant, DefaultCacheKeyPattern, "/posts/1", route),
+				"/users/7":           store(c, tenant, "path+header:Accept", "/users/7", uuid.Nil, "author-7"),
+			}
+			otherKey := store(c, other, DefaultCacheKeyPattern, "/posts?a=1&b=2", route, "author-7")
+
+			if n := c.Purge(&tt.purge); n != len(tt.purged) {
+				t.Errorf("expected %d entries purged, got %d", len(tt.purged), n)
+			}
+			for _, target := range tt.purged {
+				if _, ok := c.Get(context.Background(), keys[target]); ok {
+					t.Errorf("expected %s to be purged", target)
+				}
+			}
+			if _, ok := c.Get(context.Background(), otherKey); !ok {
+				t.Error("expected other tenants' entries to be kept")
+			}
+		})
+	}
+}
+
+func TestCacheTags(t *testing.T) {
+	header := http.Header{}
+	header.Add(SurrogateKeyHeader, "post-1  author-7")
+	header.Add(CacheTagHeader, "author-7, posts,")
+
+	if tags := cacheTags(header); !reflect.DeepEqual(tags, []string{"post-1", "author-7", "posts"}) {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+}
@@ -1,47 +1,66 @@
 package middleware
 
 import (
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/vantageedge/backend/internal/ratelimit"
 )
 
+// ErrCodeRateLimited is the error code of 429 responses
+const ErrCodeRateLimited = "rate_limited"
+
 type RateLimiter struct {
-	requests map[string]int
-	mu       sync.Mutex
-	limit    int
-	window   time.Duration
+	requests    map[string]int
+	mu          sync.Mutex
+	limit       int
+	window      time.Duration
+	windowStart time.Time
 }
 
 func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
 	rl := &RateLimiter{
-		requests: make(map[string]int),
-		limit:    limit,
-		window:   window,
+		requests:    make(map[string]int),
+		limit:       limit,
+		window:      window,
+		windowStart: time.Now(),
 	}
-	
+
 	// Cleanup goroutine
 	go rl.cleanup()
-	
+
 	return rl
 }
 
 func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		key := r.RemoteAddr
-		
+
 		rl.mu.Lock()
 		count := rl.requests[key]
-		
-		if count >= rl.limit {
-			rl.mu.Unlock()
-			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
-			return
+		decision := ratelimit.Decision{
+			Allowed:    count < rl.limit,
+			Limit:      rl.limit,
+			ResetAfter: time.Until(rl.windowStart.Add(rl.window)),
+		}
+		if decision.Allowed {
+			rl.requests[key]++
+			decision.Remaining = rl.limit - count - 1
+		} else {
+			decision.RetryAfter = decision.ResetAfter
 		}
-		
-		rl.requests[key]++
 		rl.mu.Unlock()
-		
+
+		SetRateLimitHeaders(w.Header(), decision, rl.window)
+		if !decision.Allowed {
+			WriteRateLimitError(w, decision)
+			return
+		}
+
 		next.ServeHTTP(w, r)
 	})
 }
@@ -49,10 +68,80 @@ func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
 func (rl *RateLimiter) cleanup() {
 	ticker := time.NewTicker(rl.window)
 	defer ticker.Stop()
-	
-	for range ticker.C {
+
+	for now := range ticker.C {
 		rl.mu.Lock()
 		rl.requests = make(map[string]int)
+		rl.windowStart = now
 		rl.mu.Unlock()
 	}
 }
+
+// SetRateLimitHeaders writes the IETF RateLimit-* fields and their legacy
+// X-RateLimit-* equivalents. window is the time the quota takes to refill
+// from empty. Decisions without a known quota write nothing.
+func SetRateLimitHeaders(h http.Header, d ratelimit.Decision, window time.Duration) {
+	if d.Limit <= 0 {
+		return
+	}
+
+	limit := strconv.Itoa(d.Limit)
+	remaining := strconv.Itoa(d.Remaining)
+	reset := ceilSeconds(d.ResetAfter)
+
+	h.Set("RateLimit-Limit", limit)
+	h.Set("RateLimit-Remaining", remaining)
+	h.Set("RateLimit-Reset", strconv.FormatInt(reset, 10))
+	h.Set("RateLimit-Policy", limit+";w="+strconv.FormatInt(max(ceilSeconds(window), 1), 10))
+
+	h.Set("X-RateLimit-Limit", limit)
+	h.Set("X-RateLimit-Remaining", remaining)
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Unix()+reset, 10))
+}
+
+// WriteRateLimitError writes the 429 response for a throttled request
+func WriteRateLimitError(w http.ResponseWriter, d ratelimit.Decision) {
+	w.Header().Set("Retry-After", strconv.FormatInt(max(ceilSeconds(d.RetryAfter), 1), 10))
+	WriteError(w, http.StatusTooManyRequests, ErrCodeRateLimited, "Rate limit exceeded")
+}
+
+// ceilSeconds rounds d up to whole seconds
+func ceilSeconds(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// RateLimitKey derives the bucket key for a request from a route's
+// rate_limit_key_strategy. Strategies whose value is missing from the request
+// (no user, no API key, no header) fall back to the client IP.
+func RateLimitKey(strategy string, r *http.Request, identity *Identity, clientIP string) string {
+	if strategy == "" {
+		strategy = ratelimit.DefaultKeyStrategy
+	}
+
+	switch strategy {
+	case ratelimit.KeyStrategyTenant:
+		return "tenant:" + identity.TenantID.String()
+	case ratelimit.KeyStrategyTenantUser:
+		if identity.UserID != nil {
+			return "user:" + identity.UserID.String()
+		}
+		if identity.APIKeyID != nil {
+			return "api_key:" + identity.APIKeyID.String()
+		}
+	case ratelimit.KeyStrategyAPIKey:
+		if identity.APIKeyID != nil {
+			return "api_key:" + identity.APIKeyID.String()
+		}
+	default:
+		if name, ok := strings.CutPrefix(strategy, ratelimit.KeyStrategyHeader); ok {
+			if value := r.Header.Get(name); value != "" {
+				return "header:" + value
+			}
+		}
+	}
+
+	return "ip:" + clientIP
+}
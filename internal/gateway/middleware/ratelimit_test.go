@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/vantageedge/backend/internal/ratelimit"
+)
+
+func TestSetRateLimitHeaders(t *testing.T) {
+	h := http.Header{}
+	SetRateLimitHeaders(h, ratelimit.Decision{
+		Allowed:    true,
+		Limit:      200,
+		Remaining:  150,
+		ResetAfter: 1200 * time.Millisecond,
+	}, 2*time.Second)
+
+	want := map[string]string{
+		"RateLimit-Limit":       "200",
+		"RateLimit-Remaining":   "150",
+		"RateLimit-Reset":       "2",
+		"RateLimit-Policy":      "200;w=2",
+		"X-RateLimit-Limit":     "200",
+		"X-RateLimit-Remaining": "150",
+	}
+	for name, value := range want {
+		if got := h.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+
+	reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil || reset < time.Now().Unix()+1 || reset > time.Now().Unix()+3 {
+		t.Errorf("X-RateLimit-Reset = %q, want an epoch about 2s ahead", h.Get("X-RateLimit-Reset"))
+	}
+}
+
+func TestSetRateLimitHeadersWithoutQuota(t *testing.T) {
+	h := http.Header{}
+	SetRateLimitHeaders(h, ratelimit.Decision{Allowed: true}, time.Second)
+	if len(h) != 0 {
+		t.Fatalf("expected no headers for a policy decision, got %v", h)
+	}
+}
+
+func TestWriteRateLimitError(t *testing.T) {
+	for _, tt := range []struct {
+		retryAfter time.Duration
+		want       string
+	}{
+		{250 * time.Millisecond, "1"},
+		{2100 * time.Millisecond, "3"},
+		{0, "1"},
+	} {
+		rec := httptest.NewRecorder()
+		WriteRateLimitError(rec, ratelimit.Decision{RetryAfter: tt.retryAfter})
+
+		if rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("status = %d, want 429", rec.Code)
+		}
+		if got := rec.Header().Get("Retry-After"); got != tt.want {
+			t.Errorf("Retry-After for %v = %q, want %q", tt.retryAfter, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package pathpattern
+
+import "context"
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the route parameters
+func NewContext(ctx context.Context, params Params) context.Context {
+	return context.WithValue(ctx, contextKey{}, params)
+}
+
+// FromContext returns the route parameters stored in ctx, if any
+func FromContext(ctx context.Context) Params {
+	params, _ := ctx.Value(contextKey{}).(Params)
+	return params
+}
@@ -0,0 +1,264 @@
+// Package pathpattern implements the language used by Route.PathPattern.
+//
+// A pattern is an absolute path made of literal text and placeholders:
+//
+//	/users/{id}               {name} captures one path segment
+//	/v{version:[0-9]+}/items  {name:regex} captures text matching regex
+//	/files/{name}.{ext}       placeholders may share a segment with literals
+//	/static/*                 a trailing * captures the rest of the path as "*"
+//
+// A trailing % is accepted as an alias for * so patterns written for the old
+// SQL LIKE matcher keep working. Parameter values never contain '/', except
+// for the trailing wildcard.
+package pathpattern
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// WildcardParam is the parameter name under which a trailing * is captured
+const WildcardParam = "*"
+
+// Params holds the values captured from a request path, keyed by name
+type Params map[string]string
+
+// Specificity describes how narrowly a pattern matches. It is used to order
+// routes that share the same priority.
+type Specificity struct {
+	Literal     int  // number of literal characters
+	Constrained int  // number of {name:regex} parameters
+	Params      int  // number of parameters of any kind
+	Wildcard    bool // whether the pattern ends with *
+}
+
+// Compare returns 1 when s is more specific than o, -1 when it is less
+// specific and 0 when they are equal. More literal text wins, then patterns
+// without a wildcard, then more constrained parameters, then fewer parameters.
+func (s Specificity) Compare(o Specificity) int {
+	switch {
+	case s.Literal != o.Literal:
+		return sign(s.Literal - o.Literal)
+	case s.Wildcard != o.Wildcard:
+		if o.Wildcard {
+			return 1
+		}
+		return -1
+	case s.Constrained != o.Constrained:
+		return sign(s.Constrained - o.Constrained)
+	case s.Params != o.Params:
+		return sign(o.Params - s.Params)
+	}
+	return 0
+}
+
+// Pattern is a parsed path pattern. It is immutable and safe for concurrent use.
+type Pattern struct {
+	raw         string
+	prefix      string
+	re          *regexp.Regexp
+	params      []param
+	specificity Specificity
+}
+
+type param struct {
+	name        string
+	group       int
+	constrained bool
+}
+
+// Parse parses and validates a path pattern
+func Parse(raw string) (*Pattern, error) {
+	if !strings.HasPrefix(raw, "/") {
+		return nil, fmt.Errorf("path pattern must start with '/'")
+	}
+
+	p := &Pattern{raw: raw}
+	var expr strings.Builder
+	var literal strings.Builder
+	dynamic := false
+	seen := make(map[string]bool)
+
+	flushLiteral := func() {
+		if literal.Len() == 0 {
+			return
+		}
+		p.specificity.Literal += literal.Len()
+		if dynamic {
+			expr.WriteString(regexp.QuoteMeta(literal.String()))
+		} else {
+			p.prefix += literal.String()
+		}
+		literal.Reset()
+	}
+
+	for i := 0; i < len(raw); i++ {
+		switch c := raw[i]; c {
+		case '{':
+			end, err := closingBrace(raw, i)
+			if err != nil {
+				return nil, err
+			}
+			name, constraint, constrained := strings.Cut(raw[i+1:end], ":")
+			if !isIdentifier(name) {
+				return nil, fmt.Errorf("invalid parameter name %q", name)
+			}
+			if seen[name] {
+				return nil, fmt.Errorf("duplicate parameter %q", name)
+			}
+			seen[name] = true
+
+			flushLiteral()
+			dynamic = true
+			groupName := fmt.Sprintf("p%d", len(p.params))
+			if constrained {
+				if constraint == "" {
+					return nil, fmt.Errorf("empty constraint for parameter %q", name)
+				}
+				if _, err := regexp.Compile(constraint); err != nil {
+					return nil, fmt.Errorf("invalid constraint for parameter %q: %w", name, err)
+				}
+				fmt.Fprintf(&expr, "(?P<%s>(?:%s))", groupName, constraint)
+				p.specificity.Constrained++
+			} else {
+				fmt.Fprintf(&expr, "(?P<%s>[^/]+)", groupName)
+			}
+			p.params = append(p.params, param{name: name, constrained: constrained})
+			p.specificity.Params++
+			i = end
+		case '}':
+			return nil, fmt.Errorf("unexpected '}' at offset %d", i)
+		case '*', '%':
+			if i != len(raw)-1 {
+				return nil, fmt.Errorf("wildcard is only allowed at the end of the pattern")
+			}
+			flushLiteral()
+			dynamic = true
+			fmt.Fprintf(&expr, "(?P<p%d>.*)", len(p.params))
+			p.params = append(p.params, param{name: WildcardParam})
+			p.specificity.Params++
+			p.specificity.Wildcard = true
+		default:
+			literal.WriteByte(c)
+		}
+	}
+	flushLiteral()
+
+	if dynamic {
+		re, err := regexp.Compile("^" + expr.String() + "$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid path pattern: %w", err)
+		}
+		p.re = re
+		for i := range p.params {
+			p.params[i].group = re.SubexpIndex(fmt.Sprintf("p%d", i))
+		}
+	}
+
+	return p, nil
+}
+
+// MustParse is like Parse but panics on error
+func MustParse(raw string) *Pattern {
+	p, err := Parse(raw)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// String returns the pattern as written
+func (p *Pattern) String() string {
+	return p.raw
+}
+
+// Prefix returns the literal text before the first placeholder
+func (p *Pattern) Prefix() string {
+	return p.prefix
+}
+
+// ParamNames returns the names of the pattern's parameters in order, with
+// WildcardParam for a trailing wildcard
+func (p *Pattern) ParamNames() []string {
+	names := make([]string, len(p.params))
+	for i, prm := range p.params {
+		names[i] = prm.name
+	}
+	return names
+}
+
+// Specificity returns the specificity of the pattern
+func (p *Pattern) Specificity() Specificity {
+	return p.specificity
+}
+
+// Match reports whether path matches the pattern and returns the captured
+// parameters. Params is nil for patterns without placeholders.
+func (p *Pattern) Match(path string) (Params, bool) {
+	if !strings.HasPrefix(path, p.prefix) {
+		return nil, false
+	}
+	rest := path[len(p.prefix):]
+
+	if p.re == nil {
+		return nil, rest == ""
+	}
+
+	m := p.re.FindStringSubmatch(rest)
+	if m == nil {
+		return nil, false
+	}
+
+	params := make(Params, len(p.params))
+	for _, prm := range p.params {
+		value := m[prm.group]
+		if prm.constrained && strings.Contains(value, "/") {
+			return nil, false
+		}
+		params[prm.name] = value
+	}
+	return params, true
+}
+
+// closingBrace returns the index of the '}' closing the '{' at start,
+// allowing balanced braces inside regex constraints such as [0-9]{4}
+func closingBrace(s string, start int) (int, error) {
+	depth := 0
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unterminated '{' at offset %d", start)
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		isLetter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		isDigit := c >= '0' && c <= '9'
+		if !isLetter && !(isDigit && i > 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func sign(n int) int {
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,82 @@
+package pathpattern
+
+import (
+	"testing"
+)
+
+func TestParseErrors(t *testing.T) {
+	invalid := []string{
+		"",
+		"users",
+		"/users/{",
+		"/users/}",
+		"/users/{}",
+		"/users/{1id}",
+		"/users/{id}/{id}",
+		"/users/{id:}",
+		"/users/{id:[0-9}",
+		"/static/*/more",
+		"/api/%/more",
+	}
+	for _, raw := range invalid {
+		if _, err := Parse(raw); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", raw)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+		params        Params
+	}{
+		{"/users", "/users", true, nil},
+		{"/users", "/users/", false, nil},
+		{"/users/{id}", "/users/42", true, Params{"id": "42"}},
+		{"/users/{id}", "/users/42/posts", false, nil},
+		{"/users/{id}", "/users/", false, nil},
+		{"/years/{y:[0-9]{4}}", "/years/2024", true, Params{"y": "2024"}},
+		{"/years/{y:[0-9]{4}}", "/years/24", false, nil},
+		{"/any/{v:.+}", "/any/a/b", false, nil},
+		{"/static/*", "/static/", true, Params{"*": ""}},
+		{"/static/*", "/static/css/app.css", true, Params{"*": "css/app.css"}},
+		{"/legacy/%", "/legacy/x", true, Params{"*": "x"}},
+		{"/a.b/{x}", "/aXb/1", false, nil},
+	}
+
+	for _, tt := range tests {
+		params, ok := MustParse(tt.pattern).Match(tt.path)
+		if ok != tt.want {
+			t.Errorf("%s matching %s = %v, want %v", tt.pattern, tt.path, ok, tt.want)
+			continue
+		}
+		if len(params) != len(tt.params) {
+			t.Errorf("%s matching %s: got params %v, want %v", tt.pattern, tt.path, params, tt.params)
+			continue
+		}
+		for k, v := range tt.params {
+			if params[k] != v {
+				t.Errorf("%s matching %s: param %s = %q, want %q", tt.pattern, tt.path, k, params[k], v)
+			}
+		}
+	}
+}
+
+func TestSpecificityOrder(t *testing.T) {
+	// Each pattern is more specific than the next one
+	ordered := []string{
+		"/users/me",
+		"/users/{id:[0-9]+}",
+		"/users/{id}",
+		"/users/*",
+		"/u*",
+	}
+	for i := 0; i+1 < len(ordered); i++ {
+		a := MustParse(ordered[i]).Specificity()
+		b := MustParse(ordered[i+1]).Specificity()
+		if a.Compare(b) <= 0 || b.Compare(a) >= 0 {
+			t.Errorf("%s should be more specific than %s", ordered[i], ordered[i+1])
+		}
+	}
+}
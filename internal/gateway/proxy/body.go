@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/vantageedge/backend/internal/gateway/transform"
+)
+
+// DefaultMaxTransformBytes is the size above which bodies are not
+// transformed when no limit is configured
+const DefaultMaxTransformBytes = 1 << 20
+
+// BodyTransforms are the JSON body transformations of a route. Nil ones
+// leave bodies as they are.
+type BodyTransforms struct {
+	Request  *transform.Body
+	Response *transform.Body
+}
+
+// transformRequest applies t to the body of an outgoing request
+func (rp *ReverseProxy) transformRequest(req *http.Request, t *transform.Body) {
+	body, out, ok := rp.transformBody(req.Body, req.ContentLength, req.Header, t)
+	req.Body = body
+	if !ok {
+		return
+	}
+	req.ContentLength = int64(len(out))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(out)), nil
+	}
+}
+
+// transformResponse applies t to the body of an origin response. The
+// response no longer carries the origin's representation, so a strong ETag
+// becomes weak.
+func (rp *ReverseProxy) transformResponse(resp *http.Response, t *transform.Body) {
+	body, out, ok := rp.transformBody(resp.Body, resp.ContentLength, resp.Header, t)
+	resp.Body = body
+	if !ok {
+		return
+	}
+	resp.ContentLength = int64(len(out))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(out)))
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		resp.Header.Set("ETag", "W/"+etag)
+	}
+}
+
+// transformBody applies t to a body of length bytes, -1 when unknown. Bodies
+// that are not JSON, are content encoded or are larger than the limit stream
+// through unchanged, and so do bodies that fail to transform. It returns the
+// body to send on, and the transformed bytes when t applied.
+func (rp *ReverseProxy) transformBody(body io.ReadCloser, length int64, header http.Header, t *transform.Body) (io.ReadCloser, []byte, bool) {
+	if t == nil || body == nil || body == http.NoBody || length > rp.maxTransformBytes ||
+		!isJSON(header.Get("Content-Type")) || isEncoded(header) {
+		return body, nil, false
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, rp.maxTransformBytes+1))
+	if err != nil {
+		return &readCloser{Reader: io.MultiReader(bytes.NewReader(data), errReader{err}), Closer: body}, nil, false
+	}
+	if int64(len(data)) > rp.maxTransformBytes {
+		return &readCloser{Reader: io.MultiReader(bytes.NewReader(data), body), Closer: body}, nil, false
+	}
+	body.Close()
+
+	out, err := t.Transform(data)
+	if err != nil {
+		return io.NopCloser(bytes.NewReader(data)), nil, false
+	}
+	return io.NopCloser(bytes.NewReader(out)), out, true
+}
+
+// isJSON reports whether a content type is application/json or a +json type
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// isEncoded reports whether a body has a content coding such as gzip
+func isEncoded(header http.Header) bool {
+	encoding := header.Get("Content-Encoding")
+	return encoding != "" && !strings.EqualFold(encoding, "identity")
+}
+
+// readCloser reads a body put back together after part of it was read
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// errReader returns the error that interrupted reading a body
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/vantageedge/backend/internal/gateway/transform"
+	"github.com/vantageedge/backend/internal/models"
+)
+
+func TestProxyRequestTransformsBodies(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+		w.Header().Set("Content-Type", r.URL.Query().Get("type"))
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`{"data":{"id":1},"padding":"` + strings.Repeat("x", 64) + `"}`))
+	}))
+	defer server.Close()
+
+	requestBody, err := transform.CompileBody(transform.BodyRules{Wrap: "$.payload"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	responseBody, err := transform.CompileBody(transform.BodyRules{Unwrap: "$.data"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bodies := BodyTransforms{Request: requestBody, Response: responseBody}
+	origin := &models.Origin{URL: server.URL}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		maxBytes    int64
+		wantSent    string
+		transformed bool
+	}{
+		{name: "json", contentType: "application/json; charset=utf-8", body: `{"id":1}`, wantSent: `{"payload":{"id":1}}`, transformed: true},
+		{name: "json suffix", contentType: "application/vnd.api+json", body: `{"id":1}`, wantSent: `{"payload":{"id":1}}`, transformed: true},
+		{name: "not json", contentType: "text/plain", body: `{"id":1}`, wantSent: `{"id":1}`},
+		{name: "invalid request json", contentType: "application/json", body: `{"id":`, wantSent: `{"id":`, transformed: true},
+		{name: "over the limit", contentType: "application/json", body: `{"id":1}`, maxBytes: 4, wantSent: `{"id":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rp := NewReverseProxy(tt.maxBytes)
+			req := httptest.NewRequest(http.MethodPost, "/items?type="+url.QueryEscape(tt.contentType), strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			// An unknown length exercises the limit while reading
+			req.ContentLength = -1
+
+			resp, err := rp.ProxyRequest(context.Background(), req, origin, "", bodies)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if received != tt.wantSent {
+				t.Errorf("origin received %s, want %s", received, tt.wantSent)
+			}
+			if got := string(body) == `{"id":1}`; got != tt.transformed {
+				t.Errorf("expected response transformed=%v, got %s", tt.transformed, body)
+			}
+			if got := resp.Header.Get("Content-Length"); got != "" && got != strconv.Itoa(len(body)) {
+				t.Errorf("Content-Length %s does not match the %d byte body", got, len(body))
+			}
+			if tt.transformed && resp.Header.Get("ETag") != `W/"v1"` {
+				t.Errorf("expected a weak ETag, got %s", resp.Header.Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestProxyRequestSkipsEncodedBodies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "br")
+		w.Write([]byte("compressed"))
+	}))
+	defer server.Close()
+
+	responseBody, err := transform.CompileBody(transform.BodyRules{Unwrap: "$.data"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	resp, err := NewReverseProxy(0).ProxyRequest(context.Background(), req, &models.Origin{URL: server.URL}, "", BodyTransforms{Response: responseBody})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); string(body) != "compressed" {
+		t.Fatalf("expected the encoded body unchanged, got %q", body)
+	}
+}
@@ -2,43 +2,63 @@ package proxy
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
 
+	"github.com/vantageedge/backend/internal/gateway/transform"
 	"github.com/vantageedge/backend/internal/models"
 )
 
+// ErrInvalidRequest is matched by the errors of origin requests that could
+// not be built from the client's request. They say nothing about the origin.
+var ErrInvalidRequest = errors.New("invalid origin request")
+
 type ReverseProxy struct {
-	client *http.Client
+	client            *http.Client
+	maxTransformBytes int64 // bodies larger than this are not transformed
 }
 
-func NewReverseProxy() *ReverseProxy {
+// NewReverseProxy returns a proxy transforming bodies of up to
+// maxTransformBytes, or DefaultMaxTransformBytes when it is not positive
+func NewReverseProxy(maxTransformBytes int64) *ReverseProxy {
+	if maxTransformBytes <= 0 {
+		maxTransformBytes = DefaultMaxTransformBytes
+	}
 	return &ReverseProxy{
+		maxTransformBytes: maxTransformBytes,
 		client: &http.Client{
 			Timeout: 30 * 1000000000, // 30 seconds
+			// Redirects are relayed to the client, not followed
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
 		},
 	}
 }
 
-// ProxyRequest forwards a request to an origin and returns the response
+// ProxyRequest forwards a request to an origin at path, or at the request's
+// own path when path is empty, and returns the response. The JSON bodies of
+// the request and the response are transformed by bodies.
 func (rp *ReverseProxy) ProxyRequest(
 	ctx context.Context,
 	req *http.Request,
 	origin *models.Origin,
-	pathRewrite *PathRewrite,
+	path string,
+	bodies BodyTransforms,
 ) (*http.Response, error) {
 	// Clone the request
 	proxyReq := req.Clone(ctx)
 
 	// Build the target URL
-	targetURL := origin.URL
-	if pathRewrite != nil {
-		targetURL += pathRewrite.RewritePath(req.URL.Path)
-	} else {
-		targetURL += req.URL.Path
+	if path == "" {
+		path = req.URL.Path
 	}
+	targetURL := OriginURL(origin, path)
 
 	if req.URL.RawQuery != "" {
 		targetURL += "?" + req.URL.RawQuery
@@ -47,28 +67,61 @@ func (rp *ReverseProxy) ProxyRequest(
 	// Parse and set the target URL
 	parsedURL, err := url.Parse(targetURL)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
 	}
 	proxyReq.URL = parsedURL
 	proxyReq.RequestURI = ""
 
 	// Remove hop-by-hop headers
-	removeHopByHopHeaders(proxyReq)
+	RemoveHopByHopHeaders(proxyReq.Header)
 
 	// Add forwarding headers
-	proxyReq.Header.Set("X-Forwarded-For", req.RemoteAddr)
-	proxyReq.Header.Set("X-Forwarded-Proto", req.Proto)
+	if ip, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		if prior := req.Header.Get("X-Forwarded-For"); prior != "" {
+			ip = prior + ", " + ip
+		}
+		proxyReq.Header.Set("X-Forwarded-For", ip)
+	}
+	proto := "http"
+	if req.TLS != nil {
+		proto = "https"
+	}
+	proxyReq.Header.Set("X-Forwarded-Proto", proto)
 	proxyReq.Header.Set("X-Forwarded-Host", req.Host)
 
+	if err := checkHeader(proxyReq.Header); err != nil {
+		return nil, err
+	}
+
+	rp.transformRequest(proxyReq, bodies.Request)
+
 	// Send the request
 	resp, err := rp.client.Do(proxyReq)
 	if err != nil {
 		return nil, err
 	}
 
+	rp.transformResponse(resp, bodies.Response)
 	return resp, nil
 }
 
+// checkHeader rejects header values the transport would refuse to send
+func checkHeader(header http.Header) error {
+	for name, values := range header {
+		for _, value := range values {
+			if !transform.ValidHeaderValue(value) {
+				return fmt.Errorf("%w: header %s has an invalid value", ErrInvalidRequest, name)
+			}
+		}
+	}
+	return nil
+}
+
+// OriginURL returns the URL of path on origin, without query
+func OriginURL(origin *models.Origin, path string) string {
+	return strings.TrimSuffix(origin.URL, "/") + path
+}
+
 // WriteResponse writes a proxied response back to the client
 func (rp *ReverseProxy) WriteResponse(w http.ResponseWriter, resp *http.Response) error {
 	// Copy headers
@@ -79,7 +132,7 @@ func (rp *ReverseProxy) WriteResponse(w http.ResponseWriter, resp *http.Response
 	}
 
 	// Remove hop-by-hop headers
-	removeHopByHopHeadersFromWriter(w)
+	RemoveHopByHopHeaders(w.Header())
 
 	// Write status code
 	w.WriteHeader(resp.StatusCode)
@@ -91,54 +144,21 @@ func (rp *ReverseProxy) WriteResponse(w http.ResponseWriter, resp *http.Response
 	return err
 }
 
-// PathRewrite handles path rewriting rules
-type PathRewrite struct {
-	Pattern string
-	Target  string
-}
-
-func (pr *PathRewrite) RewritePath(originalPath string) string {
-	if pr == nil || pr.Pattern == "" {
-		return originalPath
-	}
-
-	// Simple string replacement
-	// For complex patterns, consider using regex
-	return strings.ReplaceAll(originalPath, pr.Pattern, pr.Target)
-}
-
-// removeHopByHopHeaders removes hop-by-hop headers from a request
-func removeHopByHopHeaders(req *http.Request) {
-	hopHeaders := []string{
-		"Connection",
-		"Keep-Alive",
-		"Proxy-Authenticate",
-		"Proxy-Authorization",
-		"TE",
-		"Trailers",
-		"Transfer-Encoding",
-		"Upgrade",
-	}
-
-	for _, header := range hopHeaders {
-		req.Header.Del(header)
-	}
+// hopHeaders apply to a single connection and are never forwarded or cached
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"TE",
+	"Trailers",
+	"Transfer-Encoding",
+	"Upgrade",
 }
 
-// removeHopByHopHeadersFromWriter removes hop-by-hop headers from response writer
-func removeHopByHopHeadersFromWriter(w http.ResponseWriter) {
-	hopHeaders := []string{
-		"Connection",
-		"Keep-Alive",
-		"Proxy-Authenticate",
-		"Proxy-Authorization",
-		"TE",
-		"Trailers",
-		"Transfer-Encoding",
-		"Upgrade",
-	}
-
+// RemoveHopByHopHeaders removes hop-by-hop headers from h
+func RemoveHopByHopHeaders(h http.Header) {
 	for _, header := range hopHeaders {
-		w.Header().Del(header)
+		h.Del(header)
 	}
 }
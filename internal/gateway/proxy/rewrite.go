@@ -0,0 +1,245 @@
+package proxy
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/vantageedge/backend/internal/gateway/pathpattern"
+	"github.com/vantageedge/backend/internal/models"
+)
+
+// PathRewrite turns the path a client requested into the path sent to the
+// origin, in three steps:
+//
+//  1. StripPrefix is removed from the start of the path, on a segment
+//     boundary
+//  2. Every match of Pattern, a regular expression, is replaced by Target
+//  3. AddPrefix is put in front of the path
+//
+// Target may refer to the pattern's capture groups as $1, ${1} or ${name},
+// and to the route's path parameters as {name}, or {*} for a trailing
+// wildcard. $$ is a literal $. A PathRewrite is immutable and safe for
+// concurrent use; a nil one leaves paths unchanged.
+type PathRewrite struct {
+	StripPrefix string
+	Pattern     string
+	Target      string
+	AddPrefix   string
+
+	re     *regexp.Regexp
+	target []templatePart
+}
+
+// templatePart is a piece of a rewrite target: literal text, a capture group
+// of the pattern or a route parameter
+type templatePart struct {
+	literal string
+	group   int // capture group index, or -1
+	param   string
+}
+
+// NewPathRewrite validates and compiles a rewrite. It returns nil when every
+// part is empty.
+func NewPathRewrite(stripPrefix, pattern, target, addPrefix string) (*PathRewrite, error) {
+	if stripPrefix == "" && pattern == "" && target == "" && addPrefix == "" {
+		return nil, nil
+	}
+	for _, prefix := range []string{stripPrefix, addPrefix} {
+		if prefix != "" && !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("prefix %q must start with '/'", prefix)
+		}
+	}
+
+	pr := &PathRewrite{StripPrefix: strings.TrimSuffix(stripPrefix, "/"), Pattern: pattern, Target: target, AddPrefix: strings.TrimSuffix(addPrefix, "/")}
+	if pattern == "" {
+		if target != "" {
+			return nil, fmt.Errorf("rewrite target needs a pattern")
+		}
+		return pr, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rewrite pattern: %w", err)
+	}
+	pr.re = re
+	if pr.target, err = parseTarget(re, target); err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
+
+// RouteRewrite compiles the path rewrite of a route, or returns nil when it
+// has none
+func RouteRewrite(route *models.Route) (*PathRewrite, error) {
+	return NewPathRewrite(deref(route.StripPathPrefix), deref(route.PathRewritePattern), deref(route.PathRewriteTarget), deref(route.AddPathPrefix))
+}
+
+// Params returns the names of the route parameters the target refers to
+func (pr *PathRewrite) Params() []string {
+	if pr == nil {
+		return nil
+	}
+	var names []string
+	for _, part := range pr.target {
+		if part.param != "" {
+			names = append(names, part.param)
+		}
+	}
+	return names
+}
+
+// RewritePath returns the origin path for path, given the route parameters
+// captured from it. The result always starts with '/'.
+func (pr *PathRewrite) RewritePath(path string, params pathpattern.Params) string {
+	if pr == nil {
+		return path
+	}
+
+	if pr.StripPrefix != "" && strings.HasPrefix(path, pr.StripPrefix) {
+		if rest := path[len(pr.StripPrefix):]; rest == "" || rest[0] == '/' {
+			path = rest
+		}
+	}
+
+	if pr.re != nil {
+		if matches := pr.re.FindAllStringSubmatchIndex(path, -1); matches != nil {
+			var b strings.Builder
+			last := 0
+			for _, m := range matches {
+				b.WriteString(path[last:m[0]])
+				pr.expand(&b, path, m, params)
+				last = m[1]
+			}
+			b.WriteString(path[last:])
+			path = b.String()
+		}
+	}
+
+	path = pr.AddPrefix + path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path
+}
+
+// expand writes the target for the match m of the pattern in path
+func (pr *PathRewrite) expand(b *strings.Builder, path string, m []int, params pathpattern.Params) {
+	for _, part := range pr.target {
+		switch {
+		case part.group >= 0:
+			if start := m[2*part.group]; start >= 0 {
+				b.WriteString(path[start:m[2*part.group+1]])
+			}
+		case part.param != "":
+			b.WriteString(params[part.param])
+		default:
+			b.WriteString(part.literal)
+		}
+	}
+}
+
+// parseTarget splits a rewrite target into its parts, checking capture
+// group references against re
+func parseTarget(re *regexp.Regexp, target string) ([]templatePart, error) {
+	var parts []templatePart
+	var literal strings.Builder
+	flush := func() {
+		if literal.Len() > 0 {
+			parts = append(parts, templatePart{literal: literal.String(), group: -1})
+			literal.Reset()
+		}
+	}
+
+	for i := 0; i < len(target); i++ {
+		switch c := target[i]; {
+		case c == '$' && i+1 < len(target) && target[i+1] == '$':
+			literal.WriteByte('$')
+			i++
+		case c == '$':
+			ref, n := groupRef(target[i+1:])
+			if n == 0 {
+				return nil, fmt.Errorf("invalid group reference at offset %d", i)
+			}
+			group, err := groupIndex(re, ref)
+			if err != nil {
+				return nil, err
+			}
+			flush()
+			parts = append(parts, templatePart{group: group})
+			i += n
+		case c == '{':
+			end := strings.IndexByte(target[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated '{' at offset %d", i)
+			}
+			name := target[i+1 : i+end]
+			if name != pathpattern.WildcardParam && !isIdentifier(name) {
+				return nil, fmt.Errorf("invalid parameter name %q", name)
+			}
+			flush()
+			parts = append(parts, templatePart{group: -1, param: name})
+			i += end
+		default:
+			literal.WriteByte(c)
+		}
+	}
+	flush()
+	return parts, nil
+}
+
+// groupRef reads the group reference after a '$': digits, or a name or
+// digits in braces. It returns the reference and the bytes it took.
+func groupRef(s string) (string, int) {
+	if strings.HasPrefix(s, "{") {
+		end := strings.IndexByte(s, '}')
+		if end <= 1 {
+			return "", 0
+		}
+		return s[1:end], end + 1
+	}
+	n := 0
+	for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+		n++
+	}
+	return s[:n], n
+}
+
+// groupIndex resolves a group number or name of re
+func groupIndex(re *regexp.Regexp, ref string) (int, error) {
+	if n, err := strconv.Atoi(ref); err == nil {
+		if n < 0 || n > re.NumSubexp() {
+			return 0, fmt.Errorf("rewrite pattern has no group %d", n)
+		}
+		return n, nil
+	}
+	if n := re.SubexpIndex(ref); n > 0 {
+		return n, nil
+	}
+	return 0, fmt.Errorf("rewrite pattern has no group %q", ref)
+}
+
+// isIdentifier reports whether s is a valid path parameter name, as in
+// pathpattern
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		isLetter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		isDigit := c >= '0' && c <= '9'
+		if !isLetter && !(isDigit && i > 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
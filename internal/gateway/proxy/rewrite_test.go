@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/vantageedge/backend/internal/gateway/pathpattern"
+)
+
+func TestRewritePath(t *testing.T) {
+	tests := []struct {
+		name                           string
+		strip, pattern, target, prefix string
+		path                           string
+		params                         pathpattern.Params
+		want                           string
+	}{
+		{name: "no match", pattern: "^/old/", target: "/new/", path: "/other/x", want: "/other/x"},
+		{name: "numbered groups", pattern: `^/users/([0-9]+)/posts/([0-9]+)$`, target: "/posts/$2/by/$1", path: "/users/7/posts/9", want: "/posts/9/by/7"},
+		{name: "braced and named groups", pattern: `^/(?P<version>v[0-9]+)/(.*)$`, target: "/${2}/${version}", path: "/v2/items", want: "/items/v2"},
+		{name: "every match", pattern: "_", target: "-", path: "/a_b/c_d", want: "/a-b/c-d"},
+		{name: "literal dollar", pattern: "^/price$", target: "/$$", path: "/price", want: "/$"},
+		{name: "route params", pattern: "^.*$", target: "/accounts/{id}/{*}", path: "/u/5/files/a.txt", params: pathpattern.Params{"id": "5", "*": "files/a.txt"}, want: "/accounts/5/files/a.txt"},
+		{name: "strip prefix", strip: "/api", path: "/api/users", want: "/users"},
+		{name: "strip whole path", strip: "/api/", path: "/api", want: "/"},
+		{name: "strip on segment boundary only", strip: "/api", path: "/apiv2/users", want: "/apiv2/users"},
+		{name: "add prefix", prefix: "/backend/", path: "/users", want: "/backend/users"},
+		{name: "strip, rewrite and add", strip: "/public", pattern: "^/v1", target: "/v2", prefix: "/svc", path: "/public/v1/items", want: "/svc/v2/items"},
+		{name: "leading slash kept", pattern: "^/", target: "", path: "/items", want: "/items"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr, err := NewPathRewrite(tt.strip, tt.pattern, tt.target, tt.prefix)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := pr.RewritePath(tt.path, tt.params); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPathRewriteRejectsInvalidRewrites(t *testing.T) {
+	tests := []struct {
+		name                           string
+		strip, pattern, target, prefix string
+	}{
+		{name: "invalid regex", pattern: "([0-9]"},
+		{name: "missing group", pattern: "^/(a)$", target: "/$2"},
+		{name: "missing named group", pattern: "^/(a)$", target: "/${name}"},
+		{name: "dangling dollar", pattern: "^/(a)$", target: "/$"},
+		{name: "target without pattern", target: "/new"},
+		{name: "bad parameter", pattern: "^/$", target: "/{1id}"},
+		{name: "unterminated parameter", pattern: "^/$", target: "/{id"},
+		{name: "relative strip prefix", strip: "api"},
+		{name: "relative add prefix", prefix: "api"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewPathRewrite(tt.strip, tt.pattern, tt.target, tt.prefix); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestNilPathRewrite(t *testing.T) {
+	pr, err := NewPathRewrite("", "", "", "")
+	if err != nil || pr != nil {
+		t.Fatalf("expected no rewrite, got %v, %v", pr, err)
+	}
+	if got := pr.RewritePath("/items", nil); got != "/items" {
+		t.Fatalf("expected the path unchanged, got %q", got)
+	}
+}
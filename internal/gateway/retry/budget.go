@@ -0,0 +1,83 @@
+package retry
+
+import (
+	"sync"
+	"time"
+)
+
+// budgetWindow bounds how many unused retries a budget accumulates, in
+// seconds' worth of its minimum rate
+const budgetWindow = 10
+
+// retryCost is the price of a retry. Tokens are counted in hundredths so
+// percentage deposits add up exactly.
+const retryCost = 100
+
+// Budget limits the retries sent to each origin so an origin in trouble is
+// not hit with a multiple of its normal load. Every request deposits a
+// fraction of a token and every retry withdraws one; tokens also accrue at
+// a minimum rate so origins with little traffic can still be retried.
+type Budget struct {
+	mu           sync.Mutex
+	percent      float64
+	minPerSecond float64
+	capacity     float64
+	accounts     map[string]*budgetAccount
+	now          func() time.Time
+}
+
+type budgetAccount struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewBudget returns a budget allowing retries of percent of the requests
+// of each origin plus minPerSecond retries per second
+func NewBudget(percent, minPerSecond int) *Budget {
+	capacity := float64(max(minPerSecond, 1) * budgetWindow * retryCost)
+	return &Budget{
+		percent:      float64(percent),
+		minPerSecond: float64(minPerSecond),
+		capacity:     capacity,
+		accounts:     make(map[string]*budgetAccount),
+		now:          time.Now,
+	}
+}
+
+// Deposit credits key for a request
+func (b *Budget) Deposit(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	a := b.account(key)
+	a.tokens = min(a.tokens+b.percent, b.capacity)
+}
+
+// Withdraw takes a token for a retry to key and reports whether one was
+// available
+func (b *Budget) Withdraw(key string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	a := b.account(key)
+	if a.tokens < retryCost {
+		return false
+	}
+	a.tokens -= retryCost
+	return true
+}
+
+// account returns the account of key with the tokens accrued since its last
+// use; b.mu must be held
+func (b *Budget) account(key string) *budgetAccount {
+	now := b.now()
+	a, ok := b.accounts[key]
+	if !ok {
+		a = &budgetAccount{tokens: b.minPerSecond * retryCost, last: now}
+		b.accounts[key] = a
+		return a
+	}
+	a.tokens = min(a.tokens+now.Sub(a.last).Seconds()*b.minPerSecond*retryCost, b.capacity)
+	a.last = now
+	return a
+}
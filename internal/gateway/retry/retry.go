@@ -0,0 +1,191 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Retry-on conditions, listed in routes.retry_on. Statuses are listed by
+// their code, e.g. "503".
+const (
+	OnConnectError = "connect_error" // the origin could not be reached
+	OnTimeout      = "timeout"       // the attempt timed out
+	OnReset        = "reset"         // the connection failed once the request was sent
+)
+
+// DefaultConditions are the conditions of routes that list none
+var DefaultConditions = []string{OnConnectError, OnTimeout, "502", "503", "504"}
+
+// IdempotencyKeyHeader marks a request the origin deduplicates, which makes
+// retrying it safe whatever its method
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// Conditions is a parsed set of retry-on conditions
+type Conditions struct {
+	connectError bool
+	timeout      bool
+	reset        bool
+	statuses     map[int]bool
+}
+
+// ParseConditions parses retry-on conditions, using DefaultConditions when
+// none are given
+func ParseConditions(conditions []string) (Conditions, error) {
+	if len(conditions) == 0 {
+		conditions = DefaultConditions
+	}
+
+	c := Conditions{statuses: make(map[int]bool)}
+	for _, condition := range conditions {
+		switch condition {
+		case OnConnectError:
+			c.connectError = true
+		case OnTimeout:
+			c.timeout = true
+		case OnReset:
+			c.reset = true
+		default:
+			status, err := strconv.Atoi(condition)
+			if err != nil || status < 500 || status > 599 {
+				return Conditions{}, fmt.Errorf("unknown retry condition %q", condition)
+			}
+			c.statuses[status] = true
+		}
+	}
+	return c, nil
+}
+
+// ValidateConditions checks that every condition is known
+func ValidateConditions(conditions []string) error {
+	_, err := ParseConditions(conditions)
+	return err
+}
+
+// match returns the condition an attempt's outcome meets, or "" if it is not
+// to be retried
+func (c Conditions) match(resp *http.Response, err error) string {
+	if err == nil {
+		if c.statuses[resp.StatusCode] {
+			return strconv.Itoa(resp.StatusCode)
+		}
+		return ""
+	}
+
+	var condition string
+	var netErr net.Error
+	var opErr *net.OpError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		condition = OnTimeout
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		condition = OnConnectError
+	default:
+		condition = OnReset
+	}
+	if (condition == OnTimeout && c.timeout) || (condition == OnConnectError && c.connectError) || (condition == OnReset && c.reset) {
+		return condition
+	}
+	return ""
+}
+
+// Idempotent reports whether r may be sent more than once: safe methods, or
+// requests carrying an Idempotency-Key
+func Idempotent(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return r.Header.Get(IdempotencyKeyHeader) != ""
+}
+
+// Permanent marks err as not to be retried, whatever the policy's conditions
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Policy describes how a request is retried
+type Policy struct {
+	MaxRetries  int
+	Conditions  Conditions
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+
+	// OnRetry, if set, is called before each retry with its number,
+	// starting at 1, and the condition that triggered it
+	OnRetry func(retry int, condition string)
+}
+
+// Attempt sends attempt n of a request, 0 being the first
+type Attempt func(ctx context.Context, n int) (*http.Response, error)
+
+// Retrier retries failed attempts with exponential backoff and full jitter,
+// within per-origin retry budgets
+type Retrier struct {
+	budget *Budget
+}
+
+func NewRetrier(budget *Budget) *Retrier {
+	return &Retrier{budget: budget}
+}
+
+// Do runs attempts until one succeeds, its failure is not retryable under
+// policy, the retries or the budget of origin are exhausted, or ctx is done.
+// It returns the outcome of the last attempt and the number of retries made.
+func (r *Retrier) Do(ctx context.Context, origin string, policy Policy, attempt Attempt) (*http.Response, int, error) {
+	r.budget.Deposit(origin)
+
+	for n := 0; ; n++ {
+		resp, err := attempt(ctx, n)
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return resp, n, permanent.err
+		}
+		if n >= policy.MaxRetries || ctx.Err() != nil {
+			return resp, n, err
+		}
+		condition := policy.Conditions.match(resp, err)
+		if condition == "" || !r.budget.Withdraw(origin) {
+			return resp, n, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if policy.OnRetry != nil {
+			policy.OnRetry(n+1, condition)
+		}
+
+		timer := time.NewTimer(Backoff(policy.BaseBackoff, policy.MaxBackoff, n))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, n, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Backoff returns the delay before retry n+1: a random duration up to base
+// doubled n times, capped at max when max is positive
+func Backoff(base, max time.Duration, n int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	ceiling := max
+	if n < 32 && base<<n > 0 && (max <= 0 || base<<n < max) {
+		ceiling = base << n
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
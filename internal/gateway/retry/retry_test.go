@@ -0,0 +1,168 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func response(status int) *http.Response {
+	return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(""))}
+}
+
+func policy(t *testing.T, retries int, conditions ...string) Policy {
+	c, err := ParseConditions(conditions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Policy{MaxRetries: retries, Conditions: c, BaseBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+}
+
+func TestDoRetriesUntilSuccess(t *testing.T) {
+	r := NewRetrier(NewBudget(20, 10))
+	statuses := []int{503, 502, 200}
+
+	resp, retries, err := r.Do(context.Background(), "origin", policy(t, 3), func(ctx context.Context, n int) (*http.Response, error) {
+		return response(statuses[n]), nil
+	})
+	if err != nil || resp.StatusCode != 200 || retries != 2 {
+		t.Fatalf("expected a 200 after 2 retries, got %v after %d (%v)", resp, retries, err)
+	}
+}
+
+func TestDoStopsAtMaxRetries(t *testing.T) {
+	r := NewRetrier(NewBudget(20, 10))
+	attempts := 0
+
+	resp, retries, _ := r.Do(context.Background(), "origin", policy(t, 2), func(ctx context.Context, n int) (*http.Response, error) {
+		attempts++
+		return response(503), nil
+	})
+	if attempts != 3 || retries != 2 || resp.StatusCode != 503 {
+		t.Fatalf("expected the last 503 after 3 attempts, got %d attempts", attempts)
+	}
+}
+
+func TestDoOnlyRetriesListedConditions(t *testing.T) {
+	r := NewRetrier(NewBudget(20, 10))
+	dialErr := &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+	readErr := &net.OpError{Op: "read", Err: errors.New("connection reset")}
+
+	tests := []struct {
+		name       string
+		conditions []string
+		resp       *http.Response
+		err        error
+		retried    bool
+	}{
+		{"listed status", []string{"503"}, response(503), nil, true},
+		{"unlisted status", []string{"503"}, response(500), nil, false},
+		{"success", nil, response(200), nil, false},
+		{"connect error", []string{OnConnectError}, nil, dialErr, true},
+		{"reset not listed", nil, nil, readErr, false},
+		{"reset listed", []string{OnReset}, nil, readErr, true},
+		{"timeout", []string{OnTimeout}, nil, context.DeadlineExceeded, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, retries, _ := r.Do(context.Background(), tt.name, policy(t, 1, tt.conditions...), func(ctx context.Context, n int) (*http.Response, error) {
+				if n > 0 {
+					return response(200), nil
+				}
+				return tt.resp, tt.err
+			})
+			if (retries > 0) != tt.retried {
+				t.Fatalf("expected retried=%v, got %d retries", tt.retried, retries)
+			}
+		})
+	}
+}
+
+func TestDoStopsWhenContextDone(t *testing.T) {
+	r := NewRetrier(NewBudget(20, 10))
+	ctx, cancel := context.WithCancel(context.Background())
+	p := policy(t, 5)
+	p.BaseBackoff, p.MaxBackoff = time.Hour, time.Hour
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	_, _, err := r.Do(ctx, "origin", p, func(ctx context.Context, n int) (*http.Response, error) {
+		return response(503), nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the backoff to end with the context, got %v", err)
+	}
+}
+
+func TestBudgetLimitsRetries(t *testing.T) {
+	b := NewBudget(10, 1)
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	// A new origin starts with a second's worth of minimum retries
+	if !b.Withdraw("a") || b.Withdraw("a") {
+		t.Fatal("expected exactly one retry to be available initially")
+	}
+
+	// Ten requests earn one retry at 10%
+	for i := 0; i < 10; i++ {
+		b.Deposit("a")
+	}
+	if !b.Withdraw("a") || b.Withdraw("a") {
+		t.Fatal("expected one retry per ten requests")
+	}
+
+	// The minimum rate refills over time, up to the budget's capacity
+	now = now.Add(time.Hour)
+	withdrawn := 0
+	for b.Withdraw("a") {
+		withdrawn++
+	}
+	if withdrawn != budgetWindow {
+		t.Fatalf("expected the budget to be capped at %d retries, got %d", budgetWindow, withdrawn)
+	}
+
+	// Origins have separate budgets
+	if !b.Withdraw("b") {
+		t.Fatal("expected another origin to have its own budget")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for n := 0; n < 40; n++ {
+		d := Backoff(10*time.Millisecond, time.Second, n)
+		ceiling := time.Second
+		if n < 7 {
+			ceiling = 10 * time.Millisecond << n
+		}
+		if d < 0 || d > ceiling {
+			t.Fatalf("retry %d: backoff %v outside [0, %v]", n+1, d, ceiling)
+		}
+	}
+}
+
+func TestIdempotent(t *testing.T) {
+	get, _ := http.NewRequest(http.MethodGet, "/", nil)
+	post, _ := http.NewRequest(http.MethodPost, "/", nil)
+	keyed, _ := http.NewRequest(http.MethodPost, "/", nil)
+	keyed.Header.Set(IdempotencyKeyHeader, "abc")
+
+	if !Idempotent(get) || Idempotent(post) || !Idempotent(keyed) {
+		t.Fatal("expected safe methods and keyed requests only to be idempotent")
+	}
+}
+
+func TestParseConditions(t *testing.T) {
+	for _, invalid := range []string{"404", "sometimes", ""} {
+		if _, err := ParseConditions([]string{invalid}); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vantageedge/backend/internal/gateway/middleware"
+)
+
+func TestRequestLogRecordsAuthentication(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	tenant := newTestTenant("acme", srv.URL)
+	tenant.route().AuthMode = middleware.AuthModeBoth
+	key := tenant.addAPIKey("acme-key")
+	g := newTestGateway(t, newTestConfig(), tenant)
+	url := "http://acme." + testDomain + "/items"
+
+	if w := g.do(http.MethodGet, url, http.Header{"X-API-Key": {"acme-key"}}); w.Code != http.StatusOK {
+		t.Fatalf("got %d %s", w.Code, w.Body.String())
+	}
+	entry := g.nextLog(t)
+	if entry.AuthMethod == nil || *entry.AuthMethod != middleware.AuthMethodAPIKey {
+		t.Fatalf("expected auth method %s, got %v", middleware.AuthMethodAPIKey, entry.AuthMethod)
+	}
+	if entry.APIKeyID == nil || *entry.APIKeyID != key.ID {
+		t.Fatalf("expected API key %s, got %v", key.ID, entry.APIKeyID)
+	}
+
+	// Rejected requests record the error, not an identity
+	w := g.do(http.MethodGet, url, http.Header{"X-API-Key": {"unknown"}})
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("got %d %s", w.Code, w.Body.String())
+	}
+	entry = g.nextLog(t)
+	if entry.AuthMethod != nil || entry.APIKeyID != nil || entry.ErrorCode == nil || *entry.ErrorCode != "invalid_api_key" {
+		t.Fatalf("unexpected log of a rejected request: method %v, key %v, error %v", entry.AuthMethod, entry.APIKeyID, entry.ErrorCode)
+	}
+}
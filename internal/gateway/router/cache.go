@@ -0,0 +1,252 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/vantageedge/backend/internal/gateway/middleware"
+	"github.com/vantageedge/backend/internal/gateway/proxy"
+	"github.com/vantageedge/backend/internal/models"
+)
+
+// serveCacheable answers a GET on a cache-enabled route. Fresh entries are
+// served directly and stale ones within the stale-while-revalidate window
+// are served while being refreshed in the background. Otherwise the request
+// goes to the origin, conditional on the stored entry's validators, with
+// concurrent misses for the same key collapsed into one origin fetch. Stale
+// entries within the stale-if-error window stand in for origin failures.
+func (g *Gateway) serveCacheable(w http.ResponseWriter, r *http.Request, route *models.Route, up *upstream, key string, log *models.RequestLog) {
+	now := time.Now()
+	reqCC := middleware.ParseCacheControl(r.Header)
+
+	cached, ok := g.cache.Lookup(key, r)
+	if ok && !reqCC.NoCache {
+		switch {
+		case cached.Fresh(now) && !(reqCC.HasMaxAge && cached.CurrentAge(now) > reqCC.MaxAge):
+			g.serveEntry(w, r, cached, middleware.CacheHit, log)
+			return
+		case !cached.Fresh(now) && cached.ServableWhileRevalidating(now):
+			g.refreshInBackground(r, route, up, key, cached)
+			g.serveEntry(w, r, cached, middleware.CacheStale, log)
+			return
+		}
+	}
+
+	release, leader := g.fills.join(r.Context(), key)
+	if leader {
+		defer release()
+	} else if filled, ok := g.cache.Lookup(key, r); ok && filled.Fresh(time.Now()) {
+		// Another request has just fetched the response
+		g.serveEntry(w, r, filled, middleware.CacheHit, log)
+		return
+	}
+
+	resp, origin, err := g.proxyRequest(r.Context(), originRequest(r, cached), route, up)
+	setOriginURL(log, origin, up, r)
+	if err != nil {
+		if ok && cached.ServableOnError(time.Now()) {
+			g.logger.Warn().Err(err).Str("origin", origin.URL).Msg("Origin request failed, serving stale response")
+			g.serveEntry(w, r, cached, middleware.CacheStale, log)
+			return
+		}
+		g.originError(w, origin, log, err)
+		return
+	}
+	if ok && resp.StatusCode >= http.StatusInternalServerError && cached.ServableOnError(time.Now()) {
+		resp.Body.Close()
+		g.serveEntry(w, r, cached, middleware.CacheStale, log)
+		return
+	}
+
+	if ok && cached.HasValidators() && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		g.serveEntry(w, r, g.refreshEntry(r, route, key, cached, resp.Header), middleware.CacheRevalidated, log)
+		return
+	}
+
+	w.Header().Set("X-Cache", middleware.CacheMiss)
+	if err := g.writeAndCache(w, r, resp, route, key, release); err != nil {
+		g.logger.Warn().Err(err).Str("origin", origin.URL).Msg("Failed to relay origin response")
+	}
+}
+
+// serveEntry writes a stored response, reporting how it was obtained in
+// X-Cache
+func (g *Gateway) serveEntry(w http.ResponseWriter, r *http.Request, entry *middleware.CacheEntry, status string, log *models.RequestLog) {
+	log.CacheHit = true
+	w.Header().Set("X-Cache", status)
+	entry.Serve(w, r)
+}
+
+// originRequest prepares r for the origin: the client's validators are
+// replaced by those of the stored entry, if any, so the origin answers with
+// either a full response the cache can store or a 304 for the stored entry
+func originRequest(r *http.Request, cached *middleware.CacheEntry) *http.Request {
+	out := r.Clone(r.Context())
+	middleware.StripConditionals(out.Header)
+	if cached != nil && cached.HasValidators() {
+		cached.SetConditionals(out.Header)
+	}
+	return out
+}
+
+// refreshEntry applies the headers of a 304 from the origin to a stored
+// entry, renewing its freshness, and returns the updated entry
+func (g *Gateway) refreshEntry(r *http.Request, route *models.Route, key string, cached *middleware.CacheEntry, notModified http.Header) *middleware.CacheEntry {
+	header := cached.Header.Clone()
+	for name, values := range notModified {
+		header[name] = values
+	}
+	proxy.RemoveHopByHopHeaders(header)
+	header.Del("Content-Length")
+
+	updated, ok := g.newCacheEntry(r, route, cached.StatusCode, header, time.Now())
+	if !ok {
+		return cached
+	}
+	updated.Body = cached.Body
+	g.cache.Store(key, r, updated)
+	return updated
+}
+
+// refreshInBackground revalidates a stale entry off the request path, unless
+// a fill for key is already in flight
+func (g *Gateway) refreshInBackground(r *http.Request, route *models.Route, up *upstream, key string, cached *middleware.CacheEntry) {
+	release, ok := g.fills.tryLead(key)
+	if !ok {
+		return
+	}
+
+	// The refresh outlives the client's request
+	req := originRequest(r.Clone(context.WithoutCancel(r.Context())), cached)
+	go func() {
+		defer release()
+
+		resp, origin, err := g.proxyRequest(req.Context(), req, route, up)
+		if err != nil {
+			g.logger.Warn().Err(err).Str("origin", origin.URL).Msg("Background cache refresh failed")
+			return
+		}
+		if resp.StatusCode == http.StatusNotModified && cached.HasValidators() {
+			resp.Body.Close()
+			g.refreshEntry(req, route, key, cached, resp.Header)
+			return
+		}
+		if resp.StatusCode >= http.StatusInternalServerError {
+			// Keep serving the stale entry rather than caching the error
+			resp.Body.Close()
+			return
+		}
+		if err := g.writeAndCache(&discardWriter{}, req, resp, route, key, release); err != nil {
+			g.logger.Warn().Err(err).Str("origin", origin.URL).Msg("Background cache refresh failed")
+		}
+	}()
+}
+
+// writeAndCache stores an origin response under key and relays it to the
+// client. The body is read in full and stored before the client is answered,
+// and filled is called once the entry is stored or known not to be, so
+// requests waiting on the fill are not held up by a slow client.
+// Clients whose validators match the response get a 304. Responses the
+// origin marks uncacheable or that exceed the entry size limit are relayed
+// without being stored.
+func (g *Gateway) writeAndCache(w http.ResponseWriter, r *http.Request, resp *http.Response, route *models.Route, key string, filled func()) error {
+	notModified := resp.StatusCode == http.StatusOK && middleware.NotModified(r, resp.Header)
+
+	header := resp.Header.Clone()
+	proxy.RemoveHopByHopHeaders(header)
+	header.Del("Content-Length")
+
+	maxSize := int64(g.config.Cache.MaxEntrySizeKB) * 1024
+	entry, ok := g.newCacheEntry(r, route, resp.StatusCode, header, time.Now())
+	if !ok || resp.ContentLength > maxSize {
+		filled()
+		if notModified {
+			resp.Body.Close()
+			middleware.WriteNotModified(w, header)
+			return nil
+		}
+		return g.proxy.WriteResponse(w, resp)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil || int64(len(body)) > maxSize {
+		// Relay what was read followed by the rest of the body, or the
+		// error that interrupted it
+		filled()
+		rest := resp.Body
+		if err != nil {
+			rest = io.NopCloser(errReader{err})
+		}
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), rest), resp.Body}
+		return g.proxy.WriteResponse(w, resp)
+	}
+	resp.Body.Close()
+
+	entry.Body = body
+	g.cache.Store(key, r, entry)
+	filled()
+
+	if notModified {
+		middleware.WriteNotModified(w, header)
+		return nil
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return g.proxy.WriteResponse(w, resp)
+}
+
+// newCacheEntry builds a cache entry under the route's cache policy
+func (g *Gateway) newCacheEntry(r *http.Request, route *models.Route, status int, header http.Header, now time.Time) (*middleware.CacheEntry, bool) {
+	entry, ok := middleware.NewCacheEntry(r, status, header, g.cachePolicy(route), now)
+	if ok {
+		entry.RouteID = route.ID
+	}
+	return entry, ok
+}
+
+// cachePolicy returns the route's cache policy, using the configured
+// default TTL when the route sets none
+func (g *Gateway) cachePolicy(route *models.Route) middleware.CachePolicy {
+	policy := middleware.CachePolicy{
+		DefaultTTL:           g.config.Cache.DefaultTTL,
+		RetainStale:          g.config.Cache.RetainStale,
+		StaleWhileRevalidate: time.Duration(route.CacheStaleWhileRevalidateSeconds) * time.Second,
+		StaleIfError:         time.Duration(route.CacheStaleIfErrorSeconds) * time.Second,
+	}
+	if route.CacheTTLSeconds > 0 {
+		policy.DefaultTTL = time.Duration(route.CacheTTLSeconds) * time.Second
+	}
+	return policy
+}
+
+// errReader returns the error that interrupted reading a body
+type errReader struct {
+	err error
+}
+
+func (e errReader) Read([]byte) (int, error) {
+	return 0, e.err
+}
+
+// discardWriter is the response writer of background refreshes, which have
+// no client to answer
+type discardWriter struct {
+	header http.Header
+}
+
+func (d *discardWriter) Header() http.Header {
+	if d.header == nil {
+		d.header = make(http.Header)
+	}
+	return d.header
+}
+
+func (d *discardWriter) Write(p []byte) (int, error) { return len(p), nil }
+
+func (d *discardWriter) WriteHeader(int) {}
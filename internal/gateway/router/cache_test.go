@@ -0,0 +1,377 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/gateway/middleware"
+	"github.com/vantageedge/backend/internal/gateway/proxy"
+	"github.com/vantageedge/backend/internal/gateway/retry"
+	"github.com/vantageedge/backend/internal/models"
+	"github.com/vantageedge/backend/pkg/config"
+	"github.com/vantageedge/backend/pkg/logger"
+)
+
+func newCacheGateway() *Gateway {
+	return &Gateway{
+		config: &config.Config{Cache: config.CacheConfig{
+			Enabled:        true,
+			DefaultTTL:     time.Minute,
+			MaxEntrySizeKB: 1,
+			RetainStale:    time.Hour,
+		}},
+		cache:   middleware.NewCache(1<<20, nil, 0, nil),
+		fills:   newFillGroup(),
+		proxy:   proxy.NewReverseProxy(0),
+		retrier: retry.NewRetrier(retry.NewBudget(20, 5)),
+		logger:  logger.New("error", "json"),
+	}
+}
+
+type cacheResult struct {
+	status int
+	xcache string
+	body   string
+}
+
+func get(g *Gateway, route *models.Route, origin *models.Origin, header http.Header) cacheResult {
+	r := httptest.NewRequest(http.MethodGet, "/items", nil)
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	g.serveCacheable(w, r, route, singleOrigin(origin), "k", &models.RequestLog{})
+	return cacheResult{w.Code, w.Header().Get("X-Cache"), w.Body.String()}
+}
+
+func TestCacheRevalidatesWithOrigin(t *testing.T) {
+	var requests, conditional int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", "no-cache")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&conditional, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+
+	g := newCacheGateway()
+	route := &models.Route{ID: uuid.New()}
+	origin := &models.Origin{URL: srv.URL}
+
+	if res := get(g, route, origin, nil); res != (cacheResult{200, middleware.CacheMiss, "hello"}) {
+		t.Fatalf("unexpected first response %+v", res)
+	}
+	// no-cache responses are revalidated on every request
+	if res := get(g, route, origin, nil); res != (cacheResult{200, middleware.CacheRevalidated, "hello"}) {
+		t.Fatalf("unexpected revalidated response %+v", res)
+	}
+	if atomic.LoadInt32(&requests) != 2 || atomic.LoadInt32(&conditional) != 1 {
+		t.Fatalf("expected one full and one conditional origin request, got %d and %d", atomic.LoadInt32(&requests), atomic.LoadInt32(&conditional))
+	}
+
+	// Clients with a matching validator get a 304 from the gateway
+	res := get(g, route, origin, http.Header{"If-None-Match": {`"v1"`}})
+	if res.status != http.StatusNotModified || res.body != "" {
+		t.Fatalf("expected 304 without a body, got %+v", res)
+	}
+}
+
+func TestCacheHonoursOriginFreshness(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 && r.Header.Get("If-None-Match") != "" {
+			t.Error("expected client validators to be stripped on a miss")
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+
+	g := newCacheGateway()
+	route := &models.Route{ID: uuid.New()}
+	origin := &models.Origin{URL: srv.URL}
+
+	// A conditional miss is stored in full and answered with a 304
+	if res := get(g, route, origin, http.Header{"If-None-Match": {`"v1"`}}); res.status != http.StatusNotModified {
+		t.Fatalf("expected 304, got %+v", res)
+	}
+	if res := get(g, route, origin, nil); res != (cacheResult{200, middleware.CacheHit, "hello"}) {
+		t.Fatalf("expected a fresh hit, got %+v", res)
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Fatalf("expected one origin request, got %d", n)
+	}
+
+	// Request no-cache forces revalidation
+	get(g, route, origin, http.Header{"Cache-Control": {"no-cache"}})
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Fatalf("expected request no-cache to reach the origin, got %d requests", n)
+	}
+}
+
+func TestCacheSkipsNoStore(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write([]byte("secret"))
+	}))
+	defer srv.Close()
+
+	g := newCacheGateway()
+	route := &models.Route{ID: uuid.New()}
+	origin := &models.Origin{URL: srv.URL}
+
+	for i := 0; i < 2; i++ {
+		if res := get(g, route, origin, nil); res != (cacheResult{200, middleware.CacheMiss, "secret"}) {
+			t.Fatalf("unexpected response %+v", res)
+		}
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Fatalf("expected no-store responses not to be cached, got %d origin requests", n)
+	}
+}
+
+// expire moves the stored entry's freshness into the past
+func expire(t *testing.T, g *Gateway, by time.Duration) {
+	t.Helper()
+	entry, ok := g.cache.Get(context.Background(), "k")
+	if !ok {
+		t.Fatal("expected a stored entry")
+	}
+	entry.ExpiresAt = time.Now().Add(-by)
+}
+
+func TestCacheCoalescesConcurrentMisses(t *testing.T) {
+	var requests int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+
+	g := newCacheGateway()
+	route := &models.Route{ID: uuid.New()}
+	origin := &models.Origin{URL: srv.URL}
+
+	results := make(chan cacheResult, 10)
+	for i := 0; i < cap(results); i++ {
+		go func() { results <- get(g, route, origin, nil) }()
+	}
+	// Let every request reach the cache before the origin answers
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	for i := 0; i < cap(results); i++ {
+		if res := <-results; res.status != http.StatusOK || res.body != "hello" {
+			t.Fatalf("unexpected response %+v", res)
+		}
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Fatalf("expected concurrent misses to share one origin request, got %d", n)
+	}
+}
+
+func TestCacheConcurrentMissesOnUncacheableResponse(t *testing.T) {
+	var requests int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+
+	g := newCacheGateway()
+	route := &models.Route{ID: uuid.New()}
+	origin := &models.Origin{URL: srv.URL}
+
+	results := make(chan cacheResult, 5)
+	for i := 0; i < cap(results); i++ {
+		go func() { results <- get(g, route, origin, nil) }()
+	}
+	// Let the requests join the first one's fill before the origin answers
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	// Nothing was stored, so the waiting requests go to the origin themselves
+	for i := 0; i < cap(results); i++ {
+		if res := <-results; res != (cacheResult{200, middleware.CacheMiss, "hello"}) {
+			t.Fatalf("unexpected response %+v", res)
+		}
+	}
+	if n := atomic.LoadInt32(&requests); n != int32(cap(results)) {
+		t.Fatalf("expected every request to reach the origin, got %d", n)
+	}
+}
+
+// blockingWriter is a client that stalls on the body until unblocked
+type blockingWriter struct {
+	*httptest.ResponseRecorder
+	once    sync.Once
+	writing chan struct{}
+	unblock chan struct{}
+}
+
+func (b *blockingWriter) Write(p []byte) (int, error) {
+	b.once.Do(func() { close(b.writing) })
+	<-b.unblock
+	return b.ResponseRecorder.Write(p)
+}
+
+func TestCacheReleasesWaitersBeforeSlowClient(t *testing.T) {
+	respond := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-respond
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+
+	g := newCacheGateway()
+	route := &models.Route{ID: uuid.New()}
+	up := singleOrigin(&models.Origin{URL: srv.URL})
+
+	slow := &blockingWriter{ResponseRecorder: httptest.NewRecorder(), writing: make(chan struct{}), unblock: make(chan struct{})}
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		g.serveCacheable(slow, httptest.NewRequest(http.MethodGet, "/items", nil), route, up, "k", &models.RequestLog{})
+	}()
+	defer func() {
+		close(slow.unblock)
+		<-leaderDone
+	}()
+
+	// The second request waits on the leader's fill
+	waiter := make(chan cacheResult, 1)
+	time.Sleep(20 * time.Millisecond)
+	go func() { waiter <- get(g, route, up.first, nil) }()
+	time.Sleep(20 * time.Millisecond)
+	close(respond)
+
+	<-slow.writing
+	select {
+	case res := <-waiter:
+		if res != (cacheResult{200, middleware.CacheHit, "hello"}) {
+			t.Fatalf("unexpected response for the waiting request %+v", res)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the waiting request to be served while the leader's client is still reading")
+	}
+}
+
+func TestCacheServesStaleWhileRevalidating(t *testing.T) {
+	var version int32 = 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprintf(w, "v%d", atomic.LoadInt32(&version))
+	}))
+	defer srv.Close()
+
+	g := newCacheGateway()
+	route := &models.Route{ID: uuid.New(), CacheStaleWhileRevalidateSeconds: 30}
+	origin := &models.Origin{URL: srv.URL}
+
+	get(g, route, origin, nil)
+	expire(t, g, 10*time.Second)
+	atomic.StoreInt32(&version, 2)
+
+	if res := get(g, route, origin, nil); res != (cacheResult{200, middleware.CacheStale, "v1"}) {
+		t.Fatalf("expected the stale entry while refreshing, got %+v", res)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		if res := get(g, route, origin, nil); res == (cacheResult{200, middleware.CacheHit, "v2"}) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the background refresh to store the new response")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Beyond the window the request waits for the origin
+	expire(t, g, time.Minute)
+	if res := get(g, route, origin, nil); res.xcache != middleware.CacheMiss {
+		t.Fatalf("expected a miss beyond the stale window, got %+v", res)
+	}
+}
+
+func TestCacheServesStaleOnError(t *testing.T) {
+	var failing int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+
+	g := newCacheGateway()
+	route := &models.Route{ID: uuid.New(), CacheStaleIfErrorSeconds: 300}
+	origin := &models.Origin{URL: srv.URL}
+
+	get(g, route, origin, nil)
+	expire(t, g, time.Minute)
+
+	atomic.StoreInt32(&failing, 1)
+	if res := get(g, route, origin, nil); res != (cacheResult{200, middleware.CacheStale, "hello"}) {
+		t.Fatalf("expected the stale entry on a 503, got %+v", res)
+	}
+
+	srv.Close()
+	if res := get(g, route, origin, nil); res != (cacheResult{200, middleware.CacheStale, "hello"}) {
+		t.Fatalf("expected the stale entry with the origin down, got %+v", res)
+	}
+
+	expire(t, g, time.Hour)
+	if res := get(g, route, origin, nil); res.status != http.StatusBadGateway {
+		t.Fatalf("expected 502 beyond the stale-if-error window, got %+v", res)
+	}
+}
+
+func TestCacheKeepsAPIKeyResponsesPrivate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("account of " + r.Header.Get("X-API-Key")))
+	}))
+	defer srv.Close()
+
+	tenant := newTestTenant("acme", srv.URL)
+	route := tenant.route()
+	route.AuthMode = middleware.AuthModeAPIKeyRequired
+	route.CacheEnabled = true
+	tenant.addAPIKey("key-a")
+	tenant.addAPIKey("key-b")
+	g := newTestGateway(t, newTestConfig(), tenant)
+
+	for _, key := range []string{"key-a", "key-b", "key-a"} {
+		w := g.do(http.MethodGet, "http://acme."+testDomain+"/items", http.Header{"X-API-Key": {key}})
+		if w.Code != http.StatusOK || w.Body.String() != "account of "+key {
+			t.Fatalf("%s: got %d %q", key, w.Code, w.Body.String())
+		}
+		if xcache := w.Header().Get("X-Cache"); xcache == middleware.CacheHit {
+			t.Fatalf("%s: expected a private response not to be served from the cache", key)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package router
+
+import (
+	"context"
+	"sync"
+)
+
+// fillGroup tracks the cache fills in flight so concurrent misses for the
+// same key wait for a single origin fetch instead of all reaching the origin
+type fillGroup struct {
+	mu    sync.Mutex
+	fills map[string]chan struct{}
+}
+
+func newFillGroup() *fillGroup {
+	return &fillGroup{fills: make(map[string]chan struct{})}
+}
+
+// tryLead makes the caller the filler of key unless a fill is already in
+// flight. The returned func must be called once the fill is done.
+func (f *fillGroup) tryLead(key string) (func(), bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.fills[key]; ok {
+		return nil, false
+	}
+	return f.lead(key), true
+}
+
+// join makes the caller the filler of key like tryLead. If a fill is already
+// in flight it waits for it to finish, or for ctx to be done, and returns
+// false with a release func that does nothing.
+func (f *fillGroup) join(ctx context.Context, key string) (func(), bool) {
+	f.mu.Lock()
+	done, ok := f.fills[key]
+	if !ok {
+		release := f.lead(key)
+		f.mu.Unlock()
+		return release, true
+	}
+	f.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+	return func() {}, false
+}
+
+// lead registers a fill for key; f.mu must be held. The returned func may be
+// called more than once, only the first call releases the fill.
+func (f *fillGroup) lead(key string) func() {
+	done := make(chan struct{})
+	f.fills[key] = done
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			f.mu.Lock()
+			delete(f.fills, key)
+			f.mu.Unlock()
+			close(done)
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package router
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/gateway/middleware"
+	"github.com/vantageedge/backend/internal/gateway/routetable"
+	"github.com/vantageedge/backend/internal/models"
+	"github.com/vantageedge/backend/internal/ratelimit"
+	"github.com/vantageedge/backend/internal/repository"
+	"github.com/vantageedge/backend/pkg/config"
+	"github.com/vantageedge/backend/pkg/logger"
+)
+
+const testDomain = "vantageedge.test"
+
+// testTenant is a tenant's configuration as the fake repositories serve it
+type testTenant struct {
+	tenant  *models.Tenant
+	routes  []*models.Route
+	origins []*models.Origin
+	domains []*models.TenantDomain
+	apiKeys map[string]*models.APIKey // by raw key
+}
+
+// newTestTenant returns a tenant on subdomain with one origin at url and one
+// public route on every path to it
+func newTestTenant(subdomain, url string) *testTenant {
+	tenant := &models.Tenant{ID: uuid.New(), Subdomain: subdomain}
+	origin := &models.Origin{ID: uuid.New(), TenantID: tenant.ID, URL: url, IsHealthy: true}
+	route := &models.Route{
+		ID:          uuid.New(),
+		TenantID:    tenant.ID,
+		OriginID:    &origin.ID,
+		PathPattern: "/*",
+		Methods:     models.StringArray{http.MethodGet, http.MethodPost},
+		AuthMode:    middleware.AuthModePublic,
+		IsActive:    true,
+	}
+	return &testTenant{tenant: tenant, routes: []*models.Route{route}, origins: []*models.Origin{origin}, apiKeys: map[string]*models.APIKey{}}
+}
+
+func (tt *testTenant) route() *models.Route {
+	return tt.routes[0]
+}
+
+// addAPIKey registers an active API key of the tenant
+func (tt *testTenant) addAPIKey(raw string) *models.APIKey {
+	key := &models.APIKey{ID: uuid.New(), TenantID: tt.tenant.ID, IsActive: true}
+	tt.apiKeys[raw] = key
+	return key
+}
+
+// testGateway is a gateway over fake repositories, recording the request
+// logs it writes
+type testGateway struct {
+	http.Handler
+	logs chan *models.RequestLog
+}
+
+func newTestConfig() *config.Config {
+	return &config.Config{
+		Gateway:   config.GatewayConfig{Domain: testDomain},
+		RateLimit: config.RateLimitConfig{Enabled: true, DefaultRPS: 100, DefaultBurst: 100},
+		Cache:     config.CacheConfig{Enabled: true, DefaultTTL: time.Minute, MaxEntrySizeKB: 64},
+	}
+}
+
+func newTestGateway(t *testing.T, cfg *config.Config, tenants ...*testTenant) *testGateway {
+	t.Helper()
+	logs := make(chan *models.RequestLog, 100)
+	repos := newFakeRepos(logs, tenants...)
+	log := logger.New("error", "json")
+
+	routes := routetable.New(repos, log)
+	if err := routes.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	cache := middleware.NewCache(1<<20, nil, 0, nil)
+	handler := New(cfg, repos, routes, nil, ratelimit.NewRegistry(time.Minute), cache, nil, nil, nil, log)
+	return &testGateway{Handler: handler, logs: logs}
+}
+
+// do sends a request for url, whose host selects the tenant
+func (g *testGateway) do(method, url string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, url, nil)
+	for name, values := range header {
+		for _, value := range values {
+			r.Header.Add(name, value)
+		}
+	}
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, r)
+	return w
+}
+
+// nextLog returns the next request log written
+func (g *testGateway) nextLog(t *testing.T) *models.RequestLog {
+	t.Helper()
+	select {
+	case entry := <-g.logs:
+		return entry
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a request log")
+		return nil
+	}
+}
+
+func newFakeRepos(logs chan *models.RequestLog, tenants ...*testTenant) *repository.Repository {
+	store := &fakeStore{tenants: make(map[uuid.UUID]*testTenant, len(tenants)), apiKeys: make(map[string]*models.APIKey)}
+	for _, tt := range tenants {
+		store.tenants[tt.tenant.ID] = tt
+		for raw, key := range tt.apiKeys {
+			hash := sha256.Sum256([]byte(raw))
+			store.apiKeys[hex.EncodeToString(hash[:])] = key
+		}
+	}
+	return &repository.Repository{
+		Tenant:  fakeTenants{store: store},
+		Route:   fakeRoutes{store: store},
+		Origin:  fakeOrigins{store: store},
+		Pool:    fakePools{},
+		Domain:  fakeDomains{store: store},
+		APIKey:  fakeAPIKeys{store: store},
+		Request: fakeRequestLogs(logs),
+	}
+}
+
+// fakeStore backs the fake repositories, which implement only the methods
+// the gateway calls
+type fakeStore struct {
+	tenants map[uuid.UUID]*testTenant
+	apiKeys map[string]*models.APIKey // by key hash
+}
+
+var errNotFound = fmt.Errorf("not found")
+
+type fakeTenants struct {
+	repository.TenantRepository
+	store *fakeStore
+}
+
+func (f fakeTenants) ListConfigVersions(ctx context.Context) ([]*models.TenantConfigVersion, error) {
+	var versions []*models.TenantConfigVersion
+	for id := range f.store.tenants {
+		versions = append(versions, &models.TenantConfigVersion{TenantID: id})
+	}
+	return versions, nil
+}
+
+func (f fakeTenants) GetByID(ctx context.Context, id uuid.UUID) (*models.Tenant, error) {
+	if tt, ok := f.store.tenants[id]; ok {
+		return tt.tenant, nil
+	}
+	return nil, errNotFound
+}
+
+type fakeRoutes struct {
+	repository.RouteRepository
+	store *fakeStore
+}
+
+func (f fakeRoutes) ListByTenant(ctx context.Context, tenantID uuid.UUID) ([]*models.Route, error) {
+	return f.store.tenants[tenantID].routes, nil
+}
+
+type fakeOrigins struct {
+	repository.OriginRepository
+	store *fakeStore
+}
+
+func (f fakeOrigins) ListByTenant(ctx context.Context, tenantID uuid.UUID) ([]*models.Origin, error) {
+	return f.store.tenants[tenantID].origins, nil
+}
+
+type fakeDomains struct {
+	repository.TenantDomainRepository
+	store *fakeStore
+}
+
+func (f fakeDomains) ListByTenant(ctx context.Context, tenantID uuid.UUID) ([]*models.TenantDomain, error) {
+	return f.store.tenants[tenantID].domains, nil
+}
+
+type fakePools struct {
+	repository.UpstreamPoolRepository
+}
+
+func (fakePools) ListByTenant(ctx context.Context, tenantID uuid.UUID) ([]*models.UpstreamPool, error) {
+	return nil, nil
+}
+
+type fakeAPIKeys struct {
+	repository.APIKeyRepository
+	store *fakeStore
+}
+
+func (f fakeAPIKeys) GetByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	if key, ok := f.store.apiKeys[hash]; ok {
+		return key, nil
+	}
+	return nil, errNotFound
+}
+
+type fakeRequestLogs chan *models.RequestLog
+
+func (f fakeRequestLogs) Create(ctx context.Context, entry *models.RequestLog) error {
+	f <- entry
+	return nil
+}
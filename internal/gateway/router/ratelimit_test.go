@@ -0,0 +1,72 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vantageedge/backend/internal/gateway/middleware"
+	"github.com/vantageedge/backend/internal/ratelimit"
+)
+
+func TestOverrideLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		route ratelimit.Limit
+		rps   int
+		want  ratelimit.Limit
+	}{
+		{name: "keeps burst ratio", route: ratelimit.Limit{RequestsPerSecond: 10, Burst: 20}, rps: 50, want: ratelimit.Limit{RequestsPerSecond: 50, Burst: 100}},
+		{name: "lower rate", route: ratelimit.Limit{RequestsPerSecond: 100, Burst: 150}, rps: 10, want: ratelimit.Limit{RequestsPerSecond: 10, Burst: 15}},
+		{name: "burst at least one", route: ratelimit.Limit{RequestsPerSecond: 100, Burst: 1}, rps: 5, want: ratelimit.Limit{RequestsPerSecond: 5, Burst: 1}},
+		{name: "no route rate", route: ratelimit.Limit{Burst: 20}, rps: 5, want: ratelimit.Limit{RequestsPerSecond: 5, Burst: 5}},
+		{name: "no route burst", route: ratelimit.Limit{RequestsPerSecond: 10}, rps: 5, want: ratelimit.Limit{RequestsPerSecond: 5, Burst: 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := overrideLimit(tt.route, tt.rps); got != tt.want {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIKeyOverrideReplacesRouteLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	tenant := newTestTenant("acme", srv.URL)
+	route := tenant.route()
+	route.AuthMode = middleware.AuthModeAPIKeyRequired
+	route.RateLimitEnabled = true
+	route.RateLimitRequestsPerSecond = 1
+	route.RateLimitBurst = 1
+	route.RateLimitKeyStrategy = ratelimit.KeyStrategyIP
+	override := 5
+	tenant.addAPIKey("premium").RateLimitOverride = &override
+	tenant.addAPIKey("standard")
+	g := newTestGateway(t, newTestConfig(), tenant)
+
+	send := func(key string) int {
+		return g.do(http.MethodGet, "http://acme."+testDomain+"/items", http.Header{"X-API-Key": {key}}).Code
+	}
+
+	// The overridden key gets its own quota of five, scaled from the route's
+	// burst-to-rate ratio
+	for i := 0; i < override; i++ {
+		if code := send("premium"); code != http.StatusOK {
+			t.Fatalf("request %d with the overridden key: got %d", i+1, code)
+		}
+	}
+	if code := send("premium"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected the overridden key to be limited after its burst, got %d", code)
+	}
+
+	// The overridden key did not count against the route's per-IP quota
+	if code := send("standard"); code != http.StatusOK {
+		t.Fatalf("expected the first request of another key from the same IP to pass, got %d", code)
+	}
+	if code := send("standard"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected a key without an override to get the route's limit, got %d", code)
+	}
+}
@@ -0,0 +1,109 @@
+package router
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/models"
+	"github.com/vantageedge/backend/internal/repository"
+	"github.com/vantageedge/backend/pkg/logger"
+)
+
+const requestLogBufferSize = 1024
+
+// requestLogger persists request logs from a background worker so the
+// database write stays off the request path. Logs are dropped when the
+// buffer is full.
+type requestLogger struct {
+	repo    repository.RequestLogRepository
+	logger  *logger.Logger
+	entries chan *models.RequestLog
+}
+
+func newRequestLogger(repo repository.RequestLogRepository, log *logger.Logger) *requestLogger {
+	rl := &requestLogger{
+		repo:    repo,
+		logger:  log,
+		entries: make(chan *models.RequestLog, requestLogBufferSize),
+	}
+	go rl.run()
+	return rl
+}
+
+func (rl *requestLogger) Record(entry *models.RequestLog) {
+	select {
+	case rl.entries <- entry:
+	default:
+		rl.logger.Warn().Str("tenant_id", entry.TenantID.String()).Msg("Request log buffer full, dropping entry")
+	}
+}
+
+func (rl *requestLogger) run() {
+	for entry := range rl.entries {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := rl.repo.Create(ctx, entry); err != nil {
+			rl.logger.Error().Err(err).Msg("Failed to write request log")
+		}
+		cancel()
+	}
+}
+
+// newRequestLog starts a request log entry from the incoming request
+func newRequestLog(tenantID uuid.UUID, r *http.Request) *models.RequestLog {
+	entry := &models.RequestLog{
+		TenantID: tenantID,
+		Method:   r.Method,
+		Path:     r.URL.Path,
+	}
+	if r.URL.RawQuery != "" {
+		query := r.URL.RawQuery
+		entry.QueryString = &query
+	}
+	if ua := r.UserAgent(); ua != "" {
+		entry.UserAgent = &ua
+	}
+	if ip := clientIP(r); ip != "" {
+		entry.IPAddress = &ip
+	}
+	return entry
+}
+
+// clientIP returns the address of the directly connected client
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// responseRecorder captures the status code and body size written to a client
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func newResponseRecorder(w http.ResponseWriter) *responseRecorder {
+	return &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	rr.status = status
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	n, err := rr.ResponseWriter.Write(b)
+	rr.size += n
+	return n, err
+}
+
+func (rr *responseRecorder) Flush() {
+	if f, ok := rr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
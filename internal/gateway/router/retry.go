@@ -0,0 +1,203 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/gateway/circuitbreaker"
+	"github.com/vantageedge/backend/internal/gateway/proxy"
+	"github.com/vantageedge/backend/internal/gateway/retry"
+	"github.com/vantageedge/backend/internal/models"
+)
+
+// proxyRequest sends r upstream with the route's request header rules
+// applied, retrying the failures covered by the route's retry policy.
+// Retries go to pool members not tried yet when there are any, and so do
+// attempts refused by an origin's open circuit breaker. Each attempt is
+// bounded by the route's timeout, or else the origin's. It returns the
+// origin of the last attempt.
+func (g *Gateway) proxyRequest(ctx context.Context, r *http.Request, route *models.Route, up *upstream) (*http.Response, *models.Origin, error) {
+	policy := g.retryPolicy(r, route, up.first)
+
+	if up.requestHeaders != nil {
+		r = r.Clone(r.Context())
+		up.requestHeaders.Apply(r.Header, up.vars)
+	}
+
+	// Attempts after the first need the request body again
+	var body []byte
+	if policy.MaxRetries > 0 && r.Body != nil && r.Body != http.NoBody {
+		r = r.WithContext(ctx)
+		var replayable bool
+		body, replayable = bufferBody(r, int64(g.config.Retry.MaxBodyKB)*1024)
+		if !replayable {
+			policy.MaxRetries = 0
+		}
+	}
+
+	origin := up.first
+	tried := make(map[uuid.UUID]bool, len(up.origins))
+	resp, _, err := g.retrier.Do(ctx, up.first.ID.String(), policy, func(attemptCtx context.Context, n int) (*http.Response, error) {
+		if n > 0 {
+			next, ok, err := up.pick(ctx, tried)
+			if !ok && err == nil {
+				// Every origin was tried; start over
+				next, _, err = up.pick(ctx, nil)
+			}
+			if err != nil {
+				return nil, retry.Permanent(err)
+			}
+			origin = next
+		}
+
+		var done func(circuitbreaker.Outcome)
+		for {
+			tried[origin.ID] = true
+			var err error
+			if done, err = g.allow(route, origin); err == nil {
+				break
+			}
+			next, ok, _ := up.pick(ctx, tried)
+			if !ok {
+				// Retrying would only be refused again
+				return nil, retry.Permanent(err)
+			}
+			origin = next
+		}
+
+		req := r
+		if body != nil {
+			req = r.WithContext(attemptCtx)
+			req.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		timeout := time.Duration(route.TimeoutSeconds) * time.Second
+		if timeout <= 0 {
+			timeout = time.Duration(origin.TimeoutSeconds) * time.Second
+		}
+		cancel := context.CancelFunc(func() {})
+		if timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(attemptCtx, timeout)
+		}
+		resp, err := up.roundTrip(ctx, origin, func() (*http.Response, error) {
+			return g.proxy.ProxyRequest(attemptCtx, req, origin, up.path, up.bodies)
+		})
+		outcome := breakerOutcome(ctx, resp, err)
+		done(outcome)
+		if g.outliers != nil && outcome != circuitbreaker.Ignored {
+			g.outliers.Record(origin.ID, outcome == circuitbreaker.Failure)
+		}
+		if err != nil {
+			cancel()
+			if errors.Is(err, proxy.ErrInvalidRequest) {
+				// Another origin would not take it either
+				return nil, retry.Permanent(err)
+			}
+			return nil, err
+		}
+		// The attempt's deadline covers reading the body too
+		resp.Body = &closeFuncs{ReadCloser: resp.Body, funcs: []func(){cancel}}
+		return resp, nil
+	})
+	return resp, origin, err
+}
+
+// allow asks the route's circuit breaker for origin to let a request
+// through. Routes without a breaker always do.
+func (g *Gateway) allow(route *models.Route, origin *models.Origin) (func(circuitbreaker.Outcome), error) {
+	if !route.CircuitBreakerEnabled || g.breakers == nil {
+		return func(circuitbreaker.Outcome) {}, nil
+	}
+	key := circuitbreaker.Key{TenantID: route.TenantID, RouteID: route.ID, OriginID: origin.ID}
+	return g.breakers.Get(key, route.CircuitBreakerThreshold).Allow()
+}
+
+// breakerOutcome classifies an attempt for the circuit breaker and outlier
+// detection: transport errors and 5xx responses are failures, unless the
+// client went away or its request could not be sent as it was
+func breakerOutcome(ctx context.Context, resp *http.Response, err error) circuitbreaker.Outcome {
+	switch {
+	case ctx.Err() != nil, errors.Is(err, proxy.ErrInvalidRequest):
+		return circuitbreaker.Ignored
+	case err != nil || resp.StatusCode >= http.StatusInternalServerError:
+		return circuitbreaker.Failure
+	}
+	return circuitbreaker.Success
+}
+
+// retryPolicy returns the retry policy of requests to the route. Retries
+// come from the route or else the origin, capped by the configured maximum.
+// Requests that are not idempotent are only retried on routes that allow it.
+func (g *Gateway) retryPolicy(r *http.Request, route *models.Route, origin *models.Origin) retry.Policy {
+	retries := route.RetryAttempts
+	if retries <= 0 {
+		retries = origin.MaxRetries
+	}
+	if retries > g.config.LoadBalancer.MaxRetryAttempts {
+		retries = g.config.LoadBalancer.MaxRetryAttempts
+	}
+	if retries < 0 || (!route.RetryNonIdempotent && !retry.Idempotent(r)) {
+		retries = 0
+	}
+
+	conditions, err := retry.ParseConditions(route.RetryOn)
+	if err != nil {
+		g.logger.Warn().Err(err).Str("route_id", route.ID.String()).Msg("Invalid retry conditions, not retrying")
+		retries = 0
+	}
+
+	return retry.Policy{
+		MaxRetries:  retries,
+		Conditions:  conditions,
+		BaseBackoff: g.config.Retry.BaseBackoff,
+		MaxBackoff:  g.config.Retry.MaxBackoff,
+		OnRetry: func(n int, condition string) {
+			g.logger.Warn().
+				Str("route_id", route.ID.String()).
+				Str("origin", origin.URL).
+				Int("retry", n).
+				Str("condition", condition).
+				Msg("Retrying origin request")
+		},
+	}
+}
+
+// bufferBody reads the body of r so it can be sent more than once. Bodies
+// larger than limit, or that fail to read, are left to be streamed once and
+// bufferBody returns false.
+func bufferBody(r *http.Request, limit int64) ([]byte, bool) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil || int64(len(body)) > limit {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		return nil, false
+	}
+	r.Body.Close()
+	return body, true
+}
+
+// closeFuncs runs funcs, such as releasing an attempt's context, once the
+// response body is closed
+type closeFuncs struct {
+	io.ReadCloser
+	funcs []func()
+	once  sync.Once
+}
+
+func (c *closeFuncs) Close() error {
+	err := c.ReadCloser.Close()
+	c.once.Do(func() {
+		for _, f := range c.funcs {
+			f()
+		}
+	})
+	return err
+}
@@ -0,0 +1,352 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/gateway/circuitbreaker"
+	"github.com/vantageedge/backend/internal/gateway/proxy"
+	"github.com/vantageedge/backend/internal/gateway/retry"
+	"github.com/vantageedge/backend/internal/gateway/routetable"
+	"github.com/vantageedge/backend/internal/loadbalancer"
+	"github.com/vantageedge/backend/internal/loadbalancer/leastconn"
+	"github.com/vantageedge/backend/internal/loadbalancer/outlier"
+	"github.com/vantageedge/backend/internal/models"
+	"github.com/vantageedge/backend/pkg/config"
+	"github.com/vantageedge/backend/pkg/logger"
+)
+
+func newRetryGateway() *Gateway {
+	return &Gateway{
+		config: &config.Config{
+			LoadBalancer: config.LoadBalancerConfig{MaxRetryAttempts: 3},
+			Retry:        config.RetryConfig{BaseBackoff: time.Millisecond, MaxBackoff: time.Millisecond, MaxBodyKB: 1},
+		},
+		proxy:   proxy.NewReverseProxy(0),
+		retrier: retry.NewRetrier(retry.NewBudget(20, 10)),
+		logger:  logger.New("error", "json"),
+	}
+}
+
+// flakyOrigin fails the first failures requests with a 503 and echoes the
+// request body afterwards
+func flakyOrigin(t *testing.T, failures int32) (*models.Origin, *int32) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.Copy(w, r.Body)
+	}))
+	t.Cleanup(srv.Close)
+	return &models.Origin{ID: uuid.New(), URL: srv.URL, MaxRetries: 2}, &calls
+}
+
+func send(t *testing.T, g *Gateway, r *http.Request, route *models.Route, origin *models.Origin) (int, string) {
+	resp, _, err := g.proxyRequest(r.Context(), r, route, singleOrigin(origin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestProxyRequestRetriesIdempotentRequests(t *testing.T) {
+	g := newRetryGateway()
+	route := &models.Route{ID: uuid.New()}
+
+	origin, calls := flakyOrigin(t, 2)
+	status, _ := send(t, g, httptest.NewRequest(http.MethodGet, "/items", nil), route, origin)
+	if status != http.StatusOK || atomic.LoadInt32(calls) != 3 {
+		t.Fatalf("expected a 200 on the third attempt, got %d after %d", status, atomic.LoadInt32(calls))
+	}
+
+	// Retries stop at the origin's max_retries
+	origin, calls = flakyOrigin(t, 5)
+	status, _ = send(t, g, httptest.NewRequest(http.MethodGet, "/items", nil), route, origin)
+	if status != http.StatusServiceUnavailable || atomic.LoadInt32(calls) != 3 {
+		t.Fatalf("expected the 503 after 3 attempts, got %d after %d", status, atomic.LoadInt32(calls))
+	}
+}
+
+func TestProxyRequestRetriesOnlyIdempotentWrites(t *testing.T) {
+	g := newRetryGateway()
+	route := &models.Route{ID: uuid.New()}
+
+	origin, calls := flakyOrigin(t, 1)
+	status, _ := send(t, g, httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader("order")), route, origin)
+	if status != http.StatusServiceUnavailable || atomic.LoadInt32(calls) != 1 {
+		t.Fatalf("expected a POST not to be retried, got %d after %d attempts", status, atomic.LoadInt32(calls))
+	}
+
+	// With an Idempotency-Key the body is replayed on the retry
+	origin, calls = flakyOrigin(t, 1)
+	r := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader("order"))
+	r.Header.Set(retry.IdempotencyKeyHeader, "order-1")
+	status, body := send(t, g, r, route, origin)
+	if status != http.StatusOK || body != "order" || atomic.LoadInt32(calls) != 2 {
+		t.Fatalf("expected the keyed POST to be retried with its body, got %d %q after %d attempts", status, body, atomic.LoadInt32(calls))
+	}
+
+	// Bodies too large to buffer are sent once
+	origin, calls = flakyOrigin(t, 1)
+	large := strings.Repeat("x", 2048)
+	r = httptest.NewRequest(http.MethodPut, "/orders", strings.NewReader(large))
+	r.Header.Set(retry.IdempotencyKeyHeader, "order-2")
+	if status, _ := send(t, g, r, route, origin); status != http.StatusServiceUnavailable || atomic.LoadInt32(calls) != 1 {
+		t.Fatalf("expected a large body not to be retried, got %d after %d attempts", status, atomic.LoadInt32(calls))
+	}
+}
+
+func TestProxyRequestRetriesTimeouts(t *testing.T) {
+	g := newRetryGateway()
+	route := &models.Route{ID: uuid.New(), TimeoutSeconds: 1, RetryOn: models.StringArray{retry.OnTimeout}}
+
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	origin := &models.Origin{ID: uuid.New(), URL: srv.URL, MaxRetries: 1}
+
+	r := httptest.NewRequest(http.MethodGet, "/slow", nil).WithContext(context.Background())
+	if status, body := send(t, g, r, route, origin); status != http.StatusOK || body != "ok" {
+		t.Fatalf("expected the timed out attempt to be retried, got %d %q", status, body)
+	}
+}
+
+func TestProxyRequestFailsFastWhenCircuitOpen(t *testing.T) {
+	g := newRetryGateway()
+	g.breakers = circuitbreaker.NewRegistry(circuitbreaker.Settings{OpenTimeout: time.Minute}, nil)
+	route := &models.Route{ID: uuid.New(), CircuitBreakerEnabled: true, CircuitBreakerThreshold: 3}
+
+	// The request and its two retries fail, opening the circuit
+	origin, calls := flakyOrigin(t, 100)
+	if status, _ := send(t, g, httptest.NewRequest(http.MethodGet, "/items", nil), route, origin); status != http.StatusServiceUnavailable {
+		t.Fatalf("expected the origin's 503, got %d", status)
+	}
+	attempts := atomic.LoadInt32(calls)
+
+	r := httptest.NewRequest(http.MethodGet, "/items", nil)
+	_, _, err := g.proxyRequest(r.Context(), r, route, singleOrigin(origin))
+	if !errors.Is(err, circuitbreaker.ErrOpen) || atomic.LoadInt32(calls) != attempts {
+		t.Fatalf("expected the open circuit to refuse the request without reaching the origin, got %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	g.originError(w, origin, &models.RequestLog{}, err)
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "60" {
+		t.Fatalf("expected a 503 with Retry-After: 60, got %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+}
+
+func TestProxyRequestDoesNotBlameOriginForInvalidRequests(t *testing.T) {
+	g := newRetryGateway()
+	g.breakers = circuitbreaker.NewRegistry(circuitbreaker.Settings{OpenTimeout: time.Minute}, nil)
+	g.outliers = outlier.NewDetector(outlier.Settings{ConsecutiveFailures: 1, BaseEjectionTime: time.Minute, MaxEjectionPercent: 100}, nil)
+	route := &models.Route{ID: uuid.New(), CircuitBreakerEnabled: true, CircuitBreakerThreshold: 1}
+	origin, calls := flakyOrigin(t, 0)
+
+	for i := 0; i < 4; i++ {
+		r := httptest.NewRequest(http.MethodGet, "/items", nil)
+		if i%2 == 0 {
+			r.Header.Set("X-Item", "a\x00b")
+		} else {
+			r.URL.Path = "/items/a\x00b"
+		}
+		_, _, err := g.proxyRequest(r.Context(), r, route, singleOrigin(origin))
+		if !errors.Is(err, proxy.ErrInvalidRequest) {
+			t.Fatalf("expected an invalid request error, got %v", err)
+		}
+		if i == 0 {
+			w := httptest.NewRecorder()
+			g.originError(w, origin, &models.RequestLog{}, err)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("expected a 400, got %d", w.Code)
+			}
+		}
+	}
+	if n := atomic.LoadInt32(calls); n != 0 {
+		t.Fatalf("expected the invalid requests not to reach the origin, got %d", n)
+	}
+
+	// Neither the circuit breaker nor outlier detection counted them
+	if g.outliers.IsEjected(origin.ID) {
+		t.Fatal("expected the origin not to be ejected")
+	}
+	if status, _ := send(t, g, httptest.NewRequest(http.MethodGet, "/items", nil), route, origin); status != http.StatusOK {
+		t.Fatalf("expected the circuit to stay closed, got %d", status)
+	}
+}
+
+func TestProxyRequestRetriesOnAnotherPoolOrigin(t *testing.T) {
+	g := newRetryGateway()
+	g.breakers = circuitbreaker.NewRegistry(circuitbreaker.Settings{OpenTimeout: time.Minute}, nil)
+	route := &models.Route{ID: uuid.New()}
+
+	failing, failingCalls := flakyOrigin(t, 100)
+	healthy, healthyCalls := flakyOrigin(t, 0)
+	balancer, _ := loadbalancer.New(loadbalancer.Options{Strategy: loadbalancer.StrategyRoundRobin})
+	up := &upstream{first: failing, origins: []*models.Origin{failing, healthy}, balancer: balancer}
+
+	r := httptest.NewRequest(http.MethodGet, "/items", nil)
+	resp, origin, err := g.proxyRequest(r.Context(), r, route, up)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || origin != healthy || atomic.LoadInt32(failingCalls) != 1 || atomic.LoadInt32(healthyCalls) != 1 {
+		t.Fatalf("expected the retry to reach the other origin, got %d from %s", resp.StatusCode, origin.URL)
+	}
+
+	// An origin whose circuit is open is skipped without an attempt
+	route.CircuitBreakerEnabled, route.CircuitBreakerThreshold = true, 1
+	done, _ := g.breakers.Get(circuitbreaker.Key{RouteID: route.ID, OriginID: failing.ID}, 1).Allow()
+	done(circuitbreaker.Failure)
+	calls := atomic.LoadInt32(failingCalls)
+	resp, origin, err = g.proxyRequest(r.Context(), r, route, up)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if origin != healthy || atomic.LoadInt32(failingCalls) != calls {
+		t.Fatalf("expected the open circuit to send the request to the other origin, got %s", origin.URL)
+	}
+}
+
+func TestProxyRequestTracksRoundTrips(t *testing.T) {
+	g := newRetryGateway()
+	route := &models.Route{ID: uuid.New()}
+	origin, _ := flakyOrigin(t, 0)
+	balancer := leastconn.NewLeastConnBalancer()
+	up := &upstream{first: origin, origins: []*models.Origin{origin}, balancer: balancer}
+
+	r := httptest.NewRequest(http.MethodGet, "/items", nil)
+	resp, _, err := g.proxyRequest(r.Context(), r, route, up)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := balancer.GetConnectionCount(origin.ID.String()); n != 1 {
+		t.Fatalf("expected the request in flight until its body is closed, got %d", n)
+	}
+	resp.Body.Close()
+	if n := balancer.GetConnectionCount(origin.ID.String()); n != 0 {
+		t.Fatalf("expected no request in flight, got %d", n)
+	}
+}
+
+func TestProxyRequestShiftsAwayFromSlowOrigin(t *testing.T) {
+	g := newRetryGateway()
+	route := &models.Route{ID: uuid.New()}
+
+	var slowCalls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&slowCalls, 1)
+		time.Sleep(20 * time.Millisecond)
+	}))
+	t.Cleanup(srv.Close)
+	slow := &models.Origin{ID: uuid.New(), URL: srv.URL}
+	fast, fastCalls := flakyOrigin(t, 0)
+
+	balancer, _ := loadbalancer.New(loadbalancer.Options{Strategy: loadbalancer.StrategyPeakEWMA})
+	up := &upstream{origins: []*models.Origin{slow, fast}, balancer: balancer}
+	for i := 0; i < 20; i++ {
+		up.first, _, _ = up.pick(context.Background(), nil)
+		r := httptest.NewRequest(http.MethodGet, "/items", nil)
+		resp, _, err := g.proxyRequest(r.Context(), r, route, up)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	// Each origin is tried once, then the fast one wins every comparison
+	if slowN, fastN := atomic.LoadInt32(&slowCalls), atomic.LoadInt32(fastCalls); slowN != 1 || fastN != 19 {
+		t.Fatalf("expected traffic to move to the fast origin, slow got %d and fast %d", slowN, fastN)
+	}
+}
+
+func TestUpstreamLeavesOutEjectedOrigins(t *testing.T) {
+	g := newRetryGateway()
+	g.pools = loadbalancer.NewPools(loadbalancer.Options{Strategy: loadbalancer.StrategyRoundRobin})
+	g.outliers = outlier.NewDetector(outlier.Settings{ConsecutiveFailures: 2, BaseEjectionTime: time.Minute, MaxEjectionPercent: 50}, nil)
+	route := &models.Route{ID: uuid.New()}
+
+	failing, failingCalls := flakyOrigin(t, 100)
+	healthy, _ := flakyOrigin(t, 0)
+	match := &routetable.Match{Route: route, Pool: &routetable.Pool{
+		UpstreamPool: &models.UpstreamPool{ID: uuid.New()},
+		Origins:      []*models.Origin{failing, healthy},
+	}}
+
+	for i := 0; i < 10; i++ {
+		r := httptest.NewRequest(http.MethodPost, "/items", nil)
+		up, err := g.upstream(r.Context(), r, match)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, _, err := g.proxyRequest(r.Context(), r, route, up)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	if calls := atomic.LoadInt32(failingCalls); calls != 2 {
+		t.Fatalf("expected the failing origin to be ejected after 2 failures, it got %d requests", calls)
+	}
+}
+
+func TestUpstreamRewritesPath(t *testing.T) {
+	g := newRetryGateway()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.URL.RequestURI())
+	}))
+	t.Cleanup(srv.Close)
+	origin := &models.Origin{ID: uuid.New(), URL: srv.URL + "/"}
+
+	rewrite, err := proxy.NewPathRewrite("/api", `^/v1/users/([0-9]+)$`, "/users/{id}/v1-$1", "/internal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	route := &models.Route{ID: uuid.New()}
+	match := &routetable.Match{Route: route, Origin: origin, Params: map[string]string{"id": "42"}, Rewrite: rewrite}
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/users/42?full=1", nil)
+	up, err := g.upstream(r.Context(), r, match)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, _, err := g.proxyRequest(r.Context(), r, route, up)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if got := string(body); got != "/internal/users/42/v1-42?full=1" {
+		t.Fatalf("expected the rewritten path at the origin, got %q", got)
+	}
+
+	entry := &models.RequestLog{}
+	setOriginURL(entry, origin, up, r)
+	if want := srv.URL + "/internal/users/42/v1-42"; *entry.OriginURL != want {
+		t.Fatalf("expected the origin URL %q in the request log, got %q", want, *entry.OriginURL)
+	}
+}
@@ -1,30 +1,78 @@
 package router
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"net"
 	"net/http"
-	"net/http/httputil"
-	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/auth/apikey"
+	"github.com/vantageedge/backend/internal/auth/jwt"
+	"github.com/vantageedge/backend/internal/gateway/circuitbreaker"
+	"github.com/vantageedge/backend/internal/gateway/middleware"
+	"github.com/vantageedge/backend/internal/gateway/pathpattern"
+	"github.com/vantageedge/backend/internal/gateway/proxy"
+	"github.com/vantageedge/backend/internal/gateway/retry"
+	"github.com/vantageedge/backend/internal/gateway/routetable"
+	"github.com/vantageedge/backend/internal/loadbalancer"
+	"github.com/vantageedge/backend/internal/loadbalancer/outlier"
+	"github.com/vantageedge/backend/internal/models"
+	"github.com/vantageedge/backend/internal/ratelimit"
 	"github.com/vantageedge/backend/internal/repository"
 	"github.com/vantageedge/backend/pkg/config"
 	"github.com/vantageedge/backend/pkg/logger"
 )
 
+// Error codes of origin failures
+const (
+	ErrCodeBadGateway  = "bad_gateway"  // 502: the origin could not be reached
+	ErrCodeCircuitOpen = "circuit_open" // 503: the origin's circuit breaker is open
+	ErrCodeBadRequest  = "bad_request"  // 400: the request cannot be sent to the origin
+)
+
 type Gateway struct {
-	config  *config.Config
-	repos   *repository.Repository
-	logger  *logger.Logger
+	config      *config.Config
+	repos       *repository.Repository
+	routes      *routetable.Table
+	auth        *middleware.Authenticator
+	limiter     ratelimit.Limiter
+	cache       *middleware.Cache
+	fills       *fillGroup
+	proxy       *proxy.ReverseProxy
+	retrier     *retry.Retrier
+	breakers    *circuitbreaker.Registry
+	pools       *loadbalancer.Pools
+	health      *loadbalancer.HealthChecker
+	outliers    *outlier.Detector
+	requestLogs *requestLogger
+	logger      *logger.Logger
 }
 
-func New(cfg *config.Config, repos *repository.Repository, log *logger.Logger) http.Handler {
+func New(cfg *config.Config, repos *repository.Repository, routes *routetable.Table, jwtValidator *jwt.JWTValidator, limiter ratelimit.Limiter, cache *middleware.Cache, breakers *circuitbreaker.Registry, health *loadbalancer.HealthChecker, outliers *outlier.Detector, log *logger.Logger) http.Handler {
 	g := &Gateway{
-		config:  cfg,
-		repos:   repos,
-		logger:  log,
+		config:   cfg,
+		repos:    repos,
+		routes:   routes,
+		auth:     middleware.NewAuthenticator(apikey.NewValidator(repos), jwtValidator, repos.User),
+		limiter:  limiter,
+		cache:    cache,
+		fills:    newFillGroup(),
+		proxy:    proxy.NewReverseProxy(int64(cfg.Gateway.TransformMaxBodyKB) * 1024),
+		retrier:  retry.NewRetrier(retry.NewBudget(cfg.Retry.BudgetPercent, cfg.Retry.BudgetMinPerSecond)),
+		breakers: breakers,
+		pools: loadbalancer.NewPools(loadbalancer.Options{
+			Strategy:   cfg.LoadBalancer.Strategy,
+			LoadFactor: cfg.LoadBalancer.HashLoadFactor,
+			Decay:      cfg.LoadBalancer.EWMADecay,
+		}),
+		health:      health,
+		outliers:    outliers,
+		requestLogs: newRequestLogger(repos.Request, log),
+		logger:      log,
 	}
 
 	mux := http.NewServeMux()
@@ -44,72 +92,200 @@ func New(cfg *config.Config, repos *repository.Repository, log *logger.Logger) h
 func (g *Gateway) handleRequest(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 	
-	// Extract tenant from subdomain
-	tenantID, err := g.extractTenant(r)
+	// Resolve tenant from custom domain or subdomain
+	tenant, err := g.extractTenant(r)
 	if err != nil {
 		g.logger.Error().Err(err).Msg("Failed to extract tenant")
 		http.Error(w, "Invalid tenant", http.StatusBadRequest)
 		return
 	}
 
-	// Find matching route
-	route, err := g.repos.Route.FindMatchingRoute(r.Context(), tenantID, r.URL.Path, r.Method)
-	if err != nil {
-		g.logger.Error().Err(err).Msg("No matching route")
-		http.Error(w, "Route not found", http.StatusNotFound)
+	rec := newResponseRecorder(w)
+	entry := newRequestLog(tenant.Tenant.ID, r)
+	defer func() {
+		entry.StatusCode = rec.status
+		entry.ResponseTimeMs = int(time.Since(start).Milliseconds())
+		size := rec.size
+		entry.ResponseSizeBytes = &size
+		g.requestLogs.Record(entry)
+
+		g.logger.Info().
+			Str("path", r.URL.Path).
+			Str("method", r.Method).
+			Int("status", rec.status).
+			Dur("duration", time.Since(start)).
+			Msg("Request processed")
+	}()
+
+	// Find matching route and its origin in the compiled route table
+	match, ok := tenant.Match(r.URL.Path, r.Method)
+	if !ok {
+		g.logger.Error().Str("path", r.URL.Path).Msg("No matching route")
+		http.Error(rec, "Route not found", http.StatusNotFound)
 		return
 	}
+	route := match.Route
+	entry.RouteID = &route.ID
+
+	// Expose captured path parameters to the later pipeline stages
+	ctx := pathpattern.NewContext(r.Context(), match.Params)
+
+	// Enforce the route's auth mode
+	identity, authErr := g.auth.Authenticate(r, route, tenant.Tenant.ID)
+	if authErr != nil {
+		entry.ErrorCode = &authErr.Code
+		entry.ErrorMessage = &authErr.Message
+		middleware.WriteAuthError(rec, authErr)
+		return
+	}
+	if identity.Method != "" {
+		entry.AuthMethod = &identity.Method
+	}
+	entry.UserID = identity.UserID
+	entry.APIKeyID = identity.APIKeyID
+	r = r.WithContext(middleware.WithIdentity(ctx, identity))
+
+	// Apply the route's rate limit
+	if g.config.RateLimit.Enabled && route.RateLimitEnabled {
+		limit := g.routeLimit(route)
+		key := "route:" + route.ID.String() + "|" + middleware.RateLimitKey(route.RateLimitKeyStrategy, r, identity, clientIP(r))
+		if identity.RateLimitOverride != nil {
+			// A per-key quota replaces the route's limit and key strategy
+			limit = overrideLimit(limit, *identity.RateLimitOverride)
+			key = "route:" + route.ID.String() + "|api_key:" + identity.APIKeyID.String()
+		}
+		decision, err := g.limiter.Allow(r.Context(), key, limit)
+		if err != nil {
+			g.logger.Warn().Err(err).Str("route_id", route.ID.String()).Msg("Rate limiter failed, applying failure policy")
+		}
 
-	// Get origin
-	origin, err := g.repos.Origin.GetByID(r.Context(), route.OriginID)
+		window := time.Duration(float64(limit.Burst) / float64(limit.RequestsPerSecond) * float64(time.Second))
+		middleware.SetRateLimitHeaders(rec.Header(), decision, window)
+		if !decision.Allowed {
+			entry.RateLimited = true
+			code, message := middleware.ErrCodeRateLimited, "Rate limit exceeded"
+			entry.ErrorCode = &code
+			entry.ErrorMessage = &message
+			middleware.WriteRateLimitError(rec, decision)
+			return
+		}
+	}
+
+	// Responses from here on get the route's response header rules
+	vars := templateVars(r, tenant.Tenant.ID, identity, match.Params)
+	out := responseHeaders(rec, match.ResponseHeaders, vars)
+
+	// Pick the origin, among the pool's healthy ones for pool routes
+	up, err := g.upstream(r.Context(), r, match)
 	if err != nil {
-		g.logger.Error().Err(err).Msg("Origin not found")
-		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		g.logger.Error().Err(err).Str("route_id", route.ID.String()).Msg("Failed to select origin")
+		code, message := ErrCodeBadGateway, "No origin available"
+		entry.ErrorCode = &code
+		entry.ErrorMessage = &message
+		middleware.WriteError(out, http.StatusBadGateway, code, message)
 		return
 	}
+	up.requestHeaders, up.vars = match.RequestHeaders, vars
+	setOriginURL(entry, up.first, up, r)
 
-	// TODO: Apply authentication middleware
-	// TODO: Apply rate limiting
-	// TODO: Check cache
+	// GET requests on cache-enabled routes go through the response cache
+	if g.config.Cache.Enabled && route.CacheEnabled {
+		if r.Method == http.MethodGet && !middleware.CacheBypassed(r, route.CacheBypassRules) {
+			cacheKey := middleware.CacheKey(tenant.Tenant.ID, route.CacheKeyPattern, r)
+			entry.CacheKey = &cacheKey
+			g.serveCacheable(out, r, route, up, cacheKey, entry)
+			return
+		}
+		out.Header().Set("X-Cache", middleware.CacheBypass)
+	}
 
-	// Proxy request
-	g.proxyRequest(w, r, origin.URL)
+	resp, origin, err := g.proxyRequest(r.Context(), r, route, up)
+	setOriginURL(entry, origin, up, r)
+	if err != nil {
+		g.originError(out, origin, entry, err)
+		return
+	}
+	if err := g.proxy.WriteResponse(out, resp); err != nil {
+		g.logger.Warn().Err(err).Str("origin", origin.URL).Msg("Failed to relay origin response")
+	}
+}
 
-	duration := time.Since(start)
-	g.logger.Info().
-		Str("path", r.URL.Path).
-		Str("method", r.Method).
-		Dur("duration", duration).
-		Msg("Request processed")
+// originError answers with a 502 when the origin could not be reached, after
+// any retries, a 503 when its circuit breaker refused the request, or a 400
+// when the client's request could not be sent on
+func (g *Gateway) originError(w http.ResponseWriter, origin *models.Origin, entry *models.RequestLog, err error) {
+	status, code, message := http.StatusBadGateway, ErrCodeBadGateway, "Origin unavailable"
+	var openErr *circuitbreaker.OpenError
+	if errors.As(err, &openErr) {
+		status, code, message = http.StatusServiceUnavailable, ErrCodeCircuitOpen, "Origin circuit breaker is open"
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(openErr.RetryAfter.Seconds()))))
+		g.logger.Warn().Str("origin", origin.URL).Msg("Circuit breaker open, failing fast")
+	} else if errors.Is(err, proxy.ErrInvalidRequest) {
+		status, code, message = http.StatusBadRequest, ErrCodeBadRequest, "Request cannot be sent to the origin"
+		g.logger.Warn().Err(err).Str("origin", origin.URL).Msg("Invalid origin request")
+	} else {
+		g.logger.Error().Err(err).Str("origin", origin.URL).Msg("Origin request failed")
+	}
+	entry.ErrorCode = &code
+	entry.ErrorMessage = &message
+	middleware.WriteError(w, status, code, message)
 }
 
-func (g *Gateway) extractTenant(r *http.Request) (uuid.UUID, error) {
-	host := r.Host
-	parts := strings.Split(host, ".")
-	
-	if len(parts) < 2 {
-		return uuid.Nil, fmt.Errorf("invalid host format")
+// routeLimit returns the route's rate limit, using the configured defaults
+// for unset values
+func (g *Gateway) routeLimit(route *models.Route) ratelimit.Limit {
+	limit := ratelimit.Limit{
+		RequestsPerSecond: route.RateLimitRequestsPerSecond,
+		Burst:             route.RateLimitBurst,
 	}
-	
-	subdomain := parts[0]
-	
-	// Get tenant by subdomain
-	tenant, err := g.repos.Tenant.GetBySubdomain(r.Context(), subdomain)
-	if err != nil {
-		return uuid.Nil, err
+	if limit.RequestsPerSecond <= 0 {
+		limit.RequestsPerSecond = g.config.RateLimit.DefaultRPS
 	}
-	
-	return tenant.ID, nil
+	if limit.Burst <= 0 {
+		limit.Burst = g.config.RateLimit.DefaultBurst
+	}
+	return limit
 }
 
-func (g *Gateway) proxyRequest(w http.ResponseWriter, r *http.Request, originURL string) {
-	target, err := url.Parse(originURL)
-	if err != nil {
-		g.logger.Error().Err(err).Msg("Invalid origin URL")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+// overrideLimit applies an API key's requests per second, scaling the burst
+// so the override keeps the route's burst-to-rate ratio. Without a usable
+// route rate the burst equals the override.
+func overrideLimit(route ratelimit.Limit, rps int) ratelimit.Limit {
+	burst := rps
+	if route.RequestsPerSecond > 0 && route.Burst > 0 {
+		burst = int(int64(rps) * int64(route.Burst) / int64(route.RequestsPerSecond))
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return ratelimit.Limit{RequestsPerSecond: rps, Burst: burst}
+}
+
+// extractTenant resolves the tenant from the Host header. Verified custom
+// domains are matched exactly first; otherwise the host must be a single
+// label under the gateway domain, i.e. <subdomain>.<Gateway.Domain>.
+func (g *Gateway) extractTenant(r *http.Request) (*routetable.TenantRoutes, error) {
+	host := strings.ToLower(r.Host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(host, ".")
+
+	if tenant, ok := g.routes.TenantByDomain(host); ok {
+		return tenant, nil
+	}
+
+	suffix := "." + strings.ToLower(g.config.Gateway.Domain)
+	subdomain := strings.TrimSuffix(host, suffix)
+	if subdomain == host || subdomain == "" || strings.Contains(subdomain, ".") {
+		return nil, fmt.Errorf("host %q is neither a custom domain nor a subdomain of %s", host, g.config.Gateway.Domain)
+	}
+
+	// Get tenant by subdomain
+	tenant, ok := g.routes.TenantBySubdomain(subdomain)
+	if !ok {
+		return nil, fmt.Errorf("unknown tenant subdomain: %s", subdomain)
 	}
 
-	proxy := httputil.NewSingleHostReverseProxy(target)
-	proxy.ServeHTTP(w, r)
+	return tenant, nil
 }
@@ -0,0 +1,68 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vantageedge/backend/internal/gateway/routetable"
+	"github.com/vantageedge/backend/internal/models"
+	"github.com/vantageedge/backend/pkg/logger"
+)
+
+func TestExtractTenant(t *testing.T) {
+	acme := newTestTenant("acme", "http://origin.invalid")
+	acme.domains = []*models.TenantDomain{
+		{TenantID: acme.tenant.ID, Domain: "api.acme.com", Status: "verified"},
+		{TenantID: acme.tenant.ID, Domain: "pending.acme.com", Status: "pending"},
+	}
+	globex := newTestTenant("globex", "http://origin.invalid")
+
+	routes := routetable.New(newFakeRepos(nil, acme, globex), logger.New("error", "json"))
+	if err := routes.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	g := &Gateway{config: newTestConfig(), routes: routes}
+
+	tests := []struct {
+		host string
+		want *testTenant
+	}{
+		{host: "api.acme.com", want: acme},
+		{host: "API.Acme.com.:8443", want: acme},
+		{host: "acme." + testDomain, want: acme},
+		{host: "globex." + testDomain + ":443", want: globex},
+		{host: "GLOBEX.VantageEdge.Test", want: globex},
+
+		// Only verified custom domains route to their tenant
+		{host: "pending.acme.com"},
+		// Tenants are a single label below the gateway domain
+		{host: "api.acme." + testDomain},
+		{host: "x.globex." + testDomain},
+		{host: testDomain},
+		{host: "." + testDomain},
+		{host: "initech." + testDomain},
+		{host: "acme.example.com"},
+		{host: "acme" + testDomain},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Host = tt.host
+			tenant, err := g.extractTenant(r)
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("expected no tenant, got %s", tenant.Tenant.Subdomain)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tenant.Tenant.ID != tt.want.tenant.ID {
+				t.Fatalf("got tenant %s, want %s", tenant.Tenant.Subdomain, tt.want.tenant.Subdomain)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/gateway/middleware"
+	"github.com/vantageedge/backend/internal/gateway/pathpattern"
+	"github.com/vantageedge/backend/internal/gateway/transform"
+)
+
+// requestIDHeader carries the ID of a request
+const requestIDHeader = "X-Request-ID"
+
+// templateVars collects the values header templates can refer to
+func templateVars(r *http.Request, tenantID uuid.UUID, identity *middleware.Identity, params pathpattern.Params) *transform.Vars {
+	vars := &transform.Vars{
+		ClientIP:  clientIP(r),
+		TenantID:  tenantID.String(),
+		RequestID: r.Header.Get(requestIDHeader),
+		Params:    params,
+	}
+	if vars.RequestID == "" {
+		vars.RequestID = uuid.NewString()
+	}
+	if identity != nil {
+		if identity.UserID != nil {
+			vars.UserID = identity.UserID.String()
+		}
+		if identity.APIKeyID != nil {
+			vars.APIKeyID = identity.APIKeyID.String()
+		}
+		vars.Claims = identity.Claims
+	}
+	return vars
+}
+
+// responseHeaders returns w applying rules to the response headers, or w
+// itself when there are none
+func responseHeaders(w http.ResponseWriter, rules *transform.Headers, vars *transform.Vars) http.ResponseWriter {
+	if rules == nil {
+		return w
+	}
+	return &headerWriter{ResponseWriter: w, rules: rules, vars: vars}
+}
+
+// headerWriter applies header rules to a response right before its headers
+// are written, whether it comes from the origin, the cache or the gateway
+type headerWriter struct {
+	http.ResponseWriter
+	rules   *transform.Headers
+	vars    *transform.Vars
+	applied bool
+}
+
+func (hw *headerWriter) WriteHeader(status int) {
+	hw.apply()
+	hw.ResponseWriter.WriteHeader(status)
+}
+
+func (hw *headerWriter) Write(b []byte) (int, error) {
+	hw.apply()
+	return hw.ResponseWriter.Write(b)
+}
+
+func (hw *headerWriter) Flush() {
+	hw.apply()
+	if f, ok := hw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (hw *headerWriter) apply() {
+	if !hw.applied {
+		hw.applied = true
+		hw.rules.Apply(hw.Header(), hw.vars)
+	}
+}
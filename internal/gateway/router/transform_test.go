@@ -0,0 +1,106 @@
+package router
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/gateway/middleware"
+	"github.com/vantageedge/backend/internal/gateway/pathpattern"
+	"github.com/vantageedge/backend/internal/gateway/transform"
+	"github.com/vantageedge/backend/internal/models"
+)
+
+func TestHeaderRulesApplyToOriginRequestAndResponse(t *testing.T) {
+	g := newRetryGateway()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "legacy/1.0")
+		w.Header().Set("X-Internal-Id", "db-7")
+		io.WriteString(w, r.Header.Get("X-User-ID")+"|"+r.Header.Get("X-Item")+"|"+r.Header.Get("Cookie"))
+	}))
+	t.Cleanup(srv.Close)
+	origin := &models.Origin{ID: uuid.New(), URL: srv.URL}
+
+	requestRules, err := transform.CompileHeaders(transform.HeaderRules{
+		Remove: []string{"Cookie"},
+		Set:    map[string]string{"X-User-ID": "${user_id}", "X-Item": "${param.id}"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	responseRules, err := transform.CompileHeaders(transform.HeaderRules{
+		Remove: []string{"Server"},
+		Rename: map[string]string{"X-Internal-Id": "X-Record"},
+		Add:    map[string]string{"X-Request-ID": "${request_id}"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userID := uuid.New()
+	r := httptest.NewRequest(http.MethodGet, "/items/42", nil)
+	r.Header.Set("Cookie", "session=secret")
+	r.Header.Set("X-User-ID", "spoofed")
+	vars := templateVars(r, uuid.New(), &middleware.Identity{UserID: &userID}, pathpattern.Params{"id": "42"})
+
+	up := singleOrigin(origin)
+	up.requestHeaders, up.vars = requestRules, vars
+	resp, _, err := g.proxyRequest(r.Context(), r, &models.Route{ID: uuid.New()}, up)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	if err := g.proxy.WriteResponse(responseHeaders(rec, responseRules, vars), resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := rec.Body.String(), userID.String()+"|42|"; got != want {
+		t.Fatalf("expected the origin to see %q, got %q", want, got)
+	}
+	if r.Header.Get("Cookie") == "" {
+		t.Fatal("expected the client's request to be left untouched")
+	}
+	if rec.Header().Get("Server") != "" || rec.Header().Get("X-Record") != "db-7" || rec.Header().Get("X-Internal-Id") != "" {
+		t.Fatalf("expected the response rules applied, got %v", rec.Header())
+	}
+	if got := rec.Header().Get("X-Request-ID"); got != vars.RequestID || got == "" {
+		t.Fatalf("expected the generated request ID, got %q", got)
+	}
+}
+
+func TestHeaderRulesDropControlCharactersFromPathParams(t *testing.T) {
+	g := newRetryGateway()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Header.Get("X-Item"))
+	}))
+	t.Cleanup(srv.Close)
+
+	rules, err := transform.CompileHeaders(transform.HeaderRules{Set: map[string]string{"X-Item": "${param.id}"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/items/ab", nil)
+	up := singleOrigin(&models.Origin{ID: uuid.New(), URL: srv.URL})
+	up.requestHeaders = rules
+	up.vars = templateVars(r, uuid.New(), &middleware.Identity{}, pathpattern.Params{"id": "a\x00b"})
+
+	resp, _, err := g.proxyRequest(r.Context(), r, &models.Route{ID: uuid.New()}, up)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); string(body) != "ab" {
+		t.Fatalf("expected the origin to get the parameter without the NUL, got %q", body)
+	}
+}
+
+func TestTemplateVarsKeepClientRequestID(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Request-ID", "abc-123")
+	if vars := templateVars(r, uuid.New(), nil, nil); vars.RequestID != "abc-123" {
+		t.Fatalf("expected the client's request ID, got %q", vars.RequestID)
+	}
+}
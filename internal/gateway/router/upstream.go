@@ -0,0 +1,138 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/gateway/proxy"
+	"github.com/vantageedge/backend/internal/gateway/routetable"
+	"github.com/vantageedge/backend/internal/gateway/transform"
+	"github.com/vantageedge/backend/internal/loadbalancer"
+	"github.com/vantageedge/backend/internal/models"
+)
+
+// upstream is where a matched request goes: the route's origin, or the
+// healthy origins of its pool spread by the pool's balancer, at the path
+// rewritten by the route and with the route's body transformations
+type upstream struct {
+	first    *models.Origin        // origin of the first attempt
+	origins  []*models.Origin      // candidates for retries
+	balancer loadbalancer.Balancer // nil for routes targeting one origin
+	key      string                // balancing key of the request
+	path     string                // origin request path; empty for the client's
+	bodies   proxy.BodyTransforms  // the route's JSON body transformations
+
+	requestHeaders *transform.Headers // the route's request header rules
+	vars           *transform.Vars    // values of their templates
+}
+
+// singleOrigin returns the upstream of a route targeting origin
+func singleOrigin(origin *models.Origin) *upstream {
+	return &upstream{first: origin, origins: []*models.Origin{origin}}
+}
+
+// upstream resolves the origins of a matched route and picks the first one.
+// Unhealthy pool members are left out unless none is healthy, and so are
+// members ejected by outlier detection within its limits. Requests are
+// keyed by the pool's hash key source, or by client IP when the request
+// lacks it.
+func (g *Gateway) upstream(ctx context.Context, r *http.Request, match *routetable.Match) (*upstream, error) {
+	path := match.Rewrite.RewritePath(r.URL.Path, match.Params)
+	bodies := proxy.BodyTransforms{Request: match.RequestBody, Response: match.ResponseBody}
+	if match.Pool == nil {
+		up := singleOrigin(match.Origin)
+		up.path, up.bodies = path, bodies
+		return up, nil
+	}
+
+	balancer, err := g.pools.Balancer(match.Pool.UpstreamPool)
+	if err != nil {
+		return nil, err
+	}
+	origins := match.Pool.Origins
+	if g.health != nil {
+		origins = g.health.GetHealthyOrigins(origins)
+	}
+	if g.outliers != nil {
+		origins = g.outliers.Filter(origins)
+	}
+
+	key := loadbalancer.HashKey(r, match.Pool.HashKeySource, match.Pool.HashKeyName)
+	if key == "" {
+		key = clientIP(r)
+	}
+
+	up := &upstream{origins: origins, balancer: balancer, key: key, path: path, bodies: bodies}
+	if up.first, _, err = up.pick(ctx, nil); err != nil {
+		return nil, err
+	}
+	return up, nil
+}
+
+// pick returns the balancer's choice among the candidates not in tried, or
+// false when every candidate was tried
+func (u *upstream) pick(ctx context.Context, tried map[uuid.UUID]bool) (*models.Origin, bool, error) {
+	candidates := make([]*models.Origin, 0, len(u.origins))
+	for _, origin := range u.origins {
+		if !tried[origin.ID] {
+			candidates = append(candidates, origin)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, false, nil
+	}
+	if u.balancer == nil {
+		return candidates[0], true, nil
+	}
+
+	origin, err := u.balancer.Select(ctx, u.key, candidates)
+	if err != nil {
+		return nil, false, err
+	}
+	return origin, true, nil
+}
+
+// roundTrip sends a request to origin with send, letting the balancer see
+// it: the request counts as in flight until its response body is closed, and
+// its latency to the response headers is observed unless ctx, the client's
+// request context, ended first
+func (u *upstream) roundTrip(ctx context.Context, origin *models.Origin, send func() (*http.Response, error)) (*http.Response, error) {
+	tracker, _ := u.balancer.(loadbalancer.ConnectionTracker)
+	observer, _ := u.balancer.(loadbalancer.LatencyObserver)
+	if tracker == nil && observer == nil {
+		return send()
+	}
+
+	id := origin.ID.String()
+	end := func() {}
+	if tracker != nil {
+		tracker.IncrementConnections(id)
+		end = func() { tracker.DecrementConnections(id) }
+	}
+
+	start := time.Now()
+	resp, err := send()
+	if observer != nil && ctx.Err() == nil && !errors.Is(err, proxy.ErrInvalidRequest) {
+		observer.ObserveLatency(id, time.Since(start), err)
+	}
+	if err != nil {
+		end()
+		return nil, err
+	}
+	resp.Body = &closeFuncs{ReadCloser: resp.Body, funcs: []func(){end}}
+	return resp, nil
+}
+
+// setOriginURL records the origin URL a request was sent to, after path
+// rewriting
+func setOriginURL(entry *models.RequestLog, origin *models.Origin, up *upstream, r *http.Request) {
+	path := up.path
+	if path == "" {
+		path = r.URL.Path
+	}
+	originURL := proxy.OriginURL(origin, path)
+	entry.OriginURL = &originURL
+}
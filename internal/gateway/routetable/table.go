@@ -0,0 +1,349 @@
+package routetable
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/gateway/pathpattern"
+	"github.com/vantageedge/backend/internal/gateway/proxy"
+	"github.com/vantageedge/backend/internal/gateway/transform"
+	"github.com/vantageedge/backend/internal/models"
+	"github.com/vantageedge/backend/internal/repository"
+	"github.com/vantageedge/backend/pkg/logger"
+)
+
+// Match is the result of a successful route lookup. Either Origin or Pool is
+// set, depending on what the route targets. Rewrite, the header rules and
+// the body rules are compiled from the route, nil when it has none.
+type Match struct {
+	Route           *models.Route
+	Origin          *models.Origin
+	Pool            *Pool
+	Params          pathpattern.Params
+	Rewrite         *proxy.PathRewrite
+	RequestHeaders  *transform.Headers
+	ResponseHeaders *transform.Headers
+	RequestBody     *transform.Body
+	ResponseBody    *transform.Body
+}
+
+// Pool is an upstream pool resolved to its member origins
+type Pool struct {
+	*models.UpstreamPool
+	Origins []*models.Origin
+}
+
+// TenantRoutes is the compiled routing configuration of a single tenant.
+// It is immutable once built and safe for concurrent use.
+type TenantRoutes struct {
+	Tenant  *models.Tenant
+	Domains []string // verified custom domains
+	origins map[uuid.UUID]*models.Origin
+	pools   map[uuid.UUID]*Pool
+	root    *node
+	version models.TenantConfigVersion
+}
+
+// Match returns the best active route matching path and method. Routes are
+// ranked by priority and then by pattern specificity.
+func (t *TenantRoutes) Match(path, method string) (*Match, bool) {
+	e, params := t.root.lookup(path, method)
+	if e == nil {
+		return nil, false
+	}
+	return &Match{
+		Route:           e.route,
+		Origin:          e.origin,
+		Pool:            e.pool,
+		Params:          params,
+		Rewrite:         e.rewrite,
+		RequestHeaders:  e.requestHeaders,
+		ResponseHeaders: e.responseHeaders,
+		RequestBody:     e.requestBody,
+		ResponseBody:    e.responseBody,
+	}, true
+}
+
+// Origin returns an origin of the tenant by ID
+func (t *TenantRoutes) Origin(id uuid.UUID) (*models.Origin, bool) {
+	origin, ok := t.origins[id]
+	return origin, ok
+}
+
+// Origins returns every origin of the tenant
+func (t *TenantRoutes) Origins() []*models.Origin {
+	origins := make([]*models.Origin, 0, len(t.origins))
+	for _, origin := range t.origins {
+		origins = append(origins, origin)
+	}
+	return origins
+}
+
+// snapshot is an immutable view over every tenant's routes
+type snapshot struct {
+	byID        map[uuid.UUID]*TenantRoutes
+	bySubdomain map[string]*TenantRoutes
+	byDomain    map[string]*TenantRoutes
+}
+
+// Table holds a compiled routing snapshot for every tenant so the gateway can
+// resolve tenant, route and origin without touching the database. The
+// snapshot is swapped atomically; Refresh only reloads tenants whose
+// configuration version changed.
+type Table struct {
+	repos    *repository.Repository
+	logger   *logger.Logger
+	current  atomic.Pointer[snapshot]
+	mu       sync.Mutex // serialises refreshes
+	stopChan chan struct{}
+	stopOnce sync.Once
+	onReload func()
+}
+
+func New(repos *repository.Repository, log *logger.Logger) *Table {
+	t := &Table{
+		repos:    repos,
+		logger:   log,
+		stopChan: make(chan struct{}),
+	}
+	t.current.Store(&snapshot{
+		byID:        make(map[uuid.UUID]*TenantRoutes),
+		bySubdomain: make(map[string]*TenantRoutes),
+		byDomain:    make(map[string]*TenantRoutes),
+	})
+	return t
+}
+
+// TenantBySubdomain returns the compiled routes of the tenant owning subdomain
+func (t *Table) TenantBySubdomain(subdomain string) (*TenantRoutes, bool) {
+	tr, ok := t.current.Load().bySubdomain[subdomain]
+	return tr, ok
+}
+
+// TenantByDomain returns the compiled routes of the tenant that verified the
+// custom domain
+func (t *Table) TenantByDomain(domain string) (*TenantRoutes, bool) {
+	tr, ok := t.current.Load().byDomain[domain]
+	return tr, ok
+}
+
+// Tenant returns the compiled routes of a tenant by ID
+func (t *Table) Tenant(id uuid.UUID) (*TenantRoutes, bool) {
+	tr, ok := t.current.Load().byID[id]
+	return tr, ok
+}
+
+// Origins returns the origins of every tenant
+func (t *Table) Origins() []*models.Origin {
+	var origins []*models.Origin
+	for _, tr := range t.current.Load().byID {
+		origins = append(origins, tr.Origins()...)
+	}
+	return origins
+}
+
+// OnReload sets a func called after each refresh that reloaded or dropped
+// tenants. It must be set before Start.
+func (t *Table) OnReload(fn func()) {
+	t.onReload = fn
+}
+
+// Start refreshes the table periodically until Stop is called
+func (t *Table) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-t.stopChan:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				if err := t.Refresh(ctx); err != nil {
+					t.logger.Error().Err(err).Msg("Failed to refresh route table")
+				}
+				cancel()
+			}
+		}
+	}()
+}
+
+// Stop stops the background refresh
+func (t *Table) Stop() {
+	t.stopOnce.Do(func() { close(t.stopChan) })
+}
+
+// Refresh reloads the configuration of every tenant whose version changed
+// since the last refresh and drops tenants that no longer exist. A tenant that
+// fails to load keeps its previous routes and is retried on the next refresh.
+func (t *Table) Refresh(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	versions, err := t.repos.Tenant.ListConfigVersions(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list tenant config versions: %w", err)
+	}
+
+	old := t.current.Load()
+	next := &snapshot{
+		byID:        make(map[uuid.UUID]*TenantRoutes, len(versions)),
+		bySubdomain: make(map[string]*TenantRoutes, len(versions)),
+		byDomain:    make(map[string]*TenantRoutes),
+	}
+
+	reloaded := 0
+	for _, version := range versions {
+		tr, ok := old.byID[version.TenantID]
+		if !ok || tr.version != *version {
+			loaded, err := t.loadTenant(ctx, version)
+			if err != nil {
+				t.logger.Error().Err(err).Str("tenant_id", version.TenantID.String()).Msg("Failed to load tenant routes")
+			} else {
+				tr = loaded
+				reloaded++
+			}
+		}
+		if tr == nil {
+			continue
+		}
+
+		next.byID[tr.Tenant.ID] = tr
+		next.bySubdomain[tr.Tenant.Subdomain] = tr
+		for _, domain := range tr.Domains {
+			next.byDomain[domain] = tr
+		}
+	}
+
+	t.current.Store(next)
+
+	if reloaded > 0 || len(next.byID) != len(old.byID) {
+		t.logger.Info().
+			Int("tenants", len(next.byID)).
+			Int("reloaded", reloaded).
+			Msg("Route table refreshed")
+		if t.onReload != nil {
+			t.onReload()
+		}
+	}
+
+	return nil
+}
+
+func (t *Table) loadTenant(ctx context.Context, version *models.TenantConfigVersion) (*TenantRoutes, error) {
+	tenant, err := t.repos.Tenant.GetByID(ctx, version.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	routes, err := t.repos.Route.ListByTenant(ctx, tenant.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	origins, err := t.repos.Origin.ListByTenant(ctx, tenant.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	pools, err := t.repos.Pool.ListByTenant(ctx, tenant.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	domains, err := t.repos.Domain.ListByTenant(ctx, tenant.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	tr, errs := Compile(tenant, routes, origins, pools)
+	for _, err := range errs {
+		t.logger.Warn().Err(err).Str("tenant_id", tenant.ID.String()).Msg("Skipping route")
+	}
+	for _, domain := range domains {
+		if domain.Status == "verified" {
+			tr.Domains = append(tr.Domains, domain.Domain)
+		}
+	}
+	tr.version = *version
+	return tr, nil
+}
+
+// Compile builds the routing structure for a tenant. Inactive routes and
+// routes whose origin or pool is missing are skipped, as are routes whose
+// pool has no origins; routes with an invalid path pattern are skipped and
+// reported in the returned errors.
+func Compile(tenant *models.Tenant, routes []*models.Route, origins []*models.Origin, pools []*models.UpstreamPool) (*TenantRoutes, []error) {
+	tr := &TenantRoutes{
+		Tenant:  tenant,
+		origins: make(map[uuid.UUID]*models.Origin, len(origins)),
+		pools:   make(map[uuid.UUID]*Pool, len(pools)),
+		root:    &node{},
+	}
+
+	for _, origin := range origins {
+		tr.origins[origin.ID] = origin
+	}
+	for _, pool := range pools {
+		compiled := &Pool{UpstreamPool: pool}
+		for _, id := range pool.OriginIDs {
+			if origin, ok := tr.origins[id]; ok {
+				compiled.Origins = append(compiled.Origins, origin)
+			}
+		}
+		tr.pools[pool.ID] = compiled
+	}
+
+	var errs []error
+	for _, route := range routes {
+		if !route.IsActive {
+			continue
+		}
+		e := &entry{route: route}
+		switch {
+		case route.OriginID != nil:
+			e.origin = tr.origins[*route.OriginID]
+		case route.PoolID != nil:
+			if pool := tr.pools[*route.PoolID]; pool != nil && len(pool.Origins) > 0 {
+				e.pool = pool
+			}
+		}
+		if e.origin == nil && e.pool == nil {
+			continue
+		}
+		pattern, err := pathpattern.Parse(route.PathPattern)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("route %s: %w", route.ID, err))
+			continue
+		}
+		e.pattern = pattern
+		if e.rewrite, err = proxy.RouteRewrite(route); err != nil {
+			errs = append(errs, fmt.Errorf("route %s: %w", route.ID, err))
+			continue
+		}
+		if e.requestHeaders, err = transform.ParseHeaders(route.RequestHeaders); err != nil {
+			errs = append(errs, fmt.Errorf("route %s: request_headers: %w", route.ID, err))
+			continue
+		}
+		if e.responseHeaders, err = transform.ParseHeaders(route.ResponseHeaders); err != nil {
+			errs = append(errs, fmt.Errorf("route %s: response_headers: %w", route.ID, err))
+			continue
+		}
+		if e.requestBody, err = transform.ParseBody(route.RequestBodyTransform); err != nil {
+			errs = append(errs, fmt.Errorf("route %s: request_body_transform: %w", route.ID, err))
+			continue
+		}
+		if e.responseBody, err = transform.ParseBody(route.ResponseBodyTransform); err != nil {
+			errs = append(errs, fmt.Errorf("route %s: response_body_transform: %w", route.ID, err))
+			continue
+		}
+		tr.root.insert(e)
+	}
+
+	return tr, errs
+}
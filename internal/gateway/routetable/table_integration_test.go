@@ -0,0 +1,61 @@
+//go:build integration
+
+package routetable
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/vantageedge/backend/internal/repository"
+	"github.com/vantageedge/backend/pkg/config"
+	"github.com/vantageedge/backend/pkg/database"
+	"github.com/vantageedge/backend/pkg/logger"
+)
+
+// BenchmarkRepositoryMatch measures the per-request lookups the gateway made
+// before the route table: tenant by subdomain, matching route and origin.
+// It needs a migrated and seeded database reachable through the DB_* variables.
+func BenchmarkRepositoryMatch(b *testing.B) {
+	port, _ := strconv.Atoi(os.Getenv("DB_PORT"))
+	if port == 0 {
+		port = 5432
+	}
+	cfg := &config.DatabaseConfig{
+		Host:               os.Getenv("DB_HOST"),
+		Port:               port,
+		User:               os.Getenv("DB_USER"),
+		Password:           os.Getenv("DB_PASSWORD"),
+		Name:               os.Getenv("DB_NAME"),
+		SSLMode:            "disable",
+		MaxConnections:     5,
+		MaxIdleConnections: 5,
+		MaxLifetime:        time.Minute,
+	}
+
+	db, err := database.New(cfg, logger.New("error", "json"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+
+	repos := repository.New(db)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tenant, err := repos.Tenant.GetBySubdomain(ctx, "demo")
+		if err != nil {
+			b.Fatal(err)
+		}
+		route, err := repos.Route.FindMatchingRoute(ctx, tenant.ID, "/api/public/posts", "GET")
+		if err == nil && route.OriginID != nil {
+			if _, err := repos.Origin.GetByID(ctx, *route.OriginID); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package routetable
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/gateway/pathpattern"
+	"github.com/vantageedge/backend/internal/models"
+)
+
+func newRoute(origin *models.Origin, pattern string, priority int, methods ...string) *models.Route {
+	if len(methods) == 0 {
+		methods = []string{"GET", "POST", "PUT", "DELETE", "PATCH"}
+	}
+	return &models.Route{
+		ID:          uuid.New(),
+		TenantID:    origin.TenantID,
+		OriginID:    &origin.ID,
+		Name:        pattern,
+		PathPattern: pattern,
+		Methods:     methods,
+		Priority:    priority,
+		IsActive:    true,
+	}
+}
+
+func newTenant() (*models.Tenant, *models.Origin) {
+	tenant := &models.Tenant{ID: uuid.New(), Subdomain: "acme"}
+	origin := &models.Origin{ID: uuid.New(), TenantID: tenant.ID, URL: "http://origin"}
+	return tenant, origin
+}
+
+func TestTenantRoutesMatch(t *testing.T) {
+	tenant, origin := newTenant()
+	routes := []*models.Route{
+		newRoute(origin, "/api/%", 10),
+		newRoute(origin, "/api/users/*", 10),
+		newRoute(origin, "/api/users/{id}", 10),
+		newRoute(origin, "/api/users/{id:[0-9]+}", 10),
+		newRoute(origin, "/api/users/me", 5),
+		newRoute(origin, "/api/orders/*", 50, "POST"),
+		newRoute(origin, "/v{version:[0-9]+}/health", 0),
+		newRoute(origin, "/files/{name}.{ext}", 0),
+		newRoute(origin, "/invalid/{", 100),
+	}
+	inactive := newRoute(origin, "/api/users/inactive", 100)
+	inactive.IsActive = false
+	routes = append(routes, inactive)
+
+	tr, errs := Compile(tenant, routes, []*models.Origin{origin}, nil)
+	if len(errs) != 1 {
+		t.Fatalf("expected one invalid route, got %v", errs)
+	}
+
+	tests := []struct {
+		path, method string
+		want         string
+		params       map[string]string
+	}{
+		{"/api/users/42", "GET", "/api/users/{id:[0-9]+}", map[string]string{"id": "42"}},
+		{"/api/users/bob", "GET", "/api/users/{id}", map[string]string{"id": "bob"}},
+		{"/api/users/me", "GET", "/api/users/{id}", map[string]string{"id": "me"}},
+		{"/api/users/inactive", "GET", "/api/users/{id}", map[string]string{"id": "inactive"}},
+		{"/api/users/1/posts", "GET", "/api/users/*", map[string]string{"*": "1/posts"}},
+		{"/api/orders/1", "POST", "/api/orders/*", map[string]string{"*": "1"}},
+		{"/api/orders/1", "GET", "/api/%", map[string]string{"*": "orders/1"}},
+		{"/v2/health", "GET", "/v{version:[0-9]+}/health", map[string]string{"version": "2"}},
+		{"/vx/health", "GET", "", nil},
+		{"/files/report.pdf", "GET", "/files/{name}.{ext}", map[string]string{"name": "report", "ext": "pdf"}},
+		{"/other", "GET", "", nil},
+	}
+
+	for _, tt := range tests {
+		match, ok := tr.Match(tt.path, tt.method)
+		got := ""
+		if ok {
+			got = match.Route.PathPattern
+			if match.Origin != origin {
+				t.Errorf("%s %s: unexpected origin", tt.method, tt.path)
+			}
+		}
+		if got != tt.want {
+			t.Errorf("%s %s: got route %q, want %q", tt.method, tt.path, got, tt.want)
+			continue
+		}
+		if ok && !reflect.DeepEqual(map[string]string(match.Params), tt.params) {
+			t.Errorf("%s %s: got params %v, want %v", tt.method, tt.path, match.Params, tt.params)
+		}
+	}
+}
+
+// benchmarkRoutes builds a tenant with n routes spread over distinct prefixes
+func benchmarkRoutes(n int) (*models.Tenant, []*models.Route, []*models.Origin) {
+	tenant, origin := newTenant()
+	routes := make([]*models.Route, 0, n)
+	for i := 0; i < n; i++ {
+		routes = append(routes, newRoute(origin, fmt.Sprintf("/api/v1/service%d/resource%d/%%", i%20, i), i%10))
+	}
+	return tenant, routes, []*models.Origin{origin}
+}
+
+func BenchmarkSnapshotMatch(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		tenant, routes, origins := benchmarkRoutes(n)
+		tr, _ := Compile(tenant, routes, origins, nil)
+		path := fmt.Sprintf("/api/v1/service%d/resource%d/items/42", (n-1)%20, n-1)
+
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, ok := tr.Match(path, "GET"); !ok {
+					b.Fatal("expected a match")
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkLinearScan evaluates every route's pattern in turn, the way the
+// repository query scans rows, without the round trip.
+func BenchmarkLinearScan(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		_, routes, _ := benchmarkRoutes(n)
+		patterns := make([]*pathpattern.Pattern, len(routes))
+		for i, route := range routes {
+			patterns[i] = pathpattern.MustParse(route.PathPattern)
+		}
+		path := fmt.Sprintf("/api/v1/service%d/resource%d/items/42", (n-1)%20, n-1)
+
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var best *models.Route
+				for j, p := range patterns {
+					if best != nil && routes[j].Priority <= best.Priority {
+						continue
+					}
+					if _, ok := p.Match(path); ok {
+						best = routes[j]
+					}
+				}
+				if best == nil {
+					b.Fatal("expected a match")
+				}
+			}
+		})
+	}
+}
+
+func TestCompilePoolRoutes(t *testing.T) {
+	tenant, origin := newTenant()
+	other := &models.Origin{ID: uuid.New(), TenantID: tenant.ID, URL: "http://other"}
+	pool := &models.UpstreamPool{ID: uuid.New(), TenantID: tenant.ID, OriginIDs: []uuid.UUID{origin.ID, other.ID, uuid.New()}}
+	empty := &models.UpstreamPool{ID: uuid.New(), TenantID: tenant.ID}
+
+	pooled := newRoute(origin, "/api/%", 0)
+	pooled.OriginID, pooled.PoolID = nil, &pool.ID
+	unbalanced := newRoute(origin, "/empty/%", 0)
+	unbalanced.OriginID, unbalanced.PoolID = nil, &empty.ID
+
+	tr, errs := Compile(tenant, []*models.Route{pooled, unbalanced}, []*models.Origin{origin, other}, []*models.UpstreamPool{pool, empty})
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+
+	m, ok := tr.Match("/api/users", "GET")
+	if !ok || m.Origin != nil || m.Pool == nil || len(m.Pool.Origins) != 2 {
+		t.Fatalf("expected the route to target its pool's two known origins, got %+v", m)
+	}
+	if _, ok := tr.Match("/empty/users", "GET"); ok {
+		t.Fatal("expected the route of an empty pool to be skipped")
+	}
+	if got := tr.Origins(); len(got) != 2 {
+		t.Fatalf("expected the tenant's origins, got %d", len(got))
+	}
+}
@@ -0,0 +1,142 @@
+package routetable
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/vantageedge/backend/internal/gateway/pathpattern"
+	"github.com/vantageedge/backend/internal/gateway/proxy"
+	"github.com/vantageedge/backend/internal/gateway/transform"
+	"github.com/vantageedge/backend/internal/models"
+)
+
+// entry is a route compiled into the tree together with its origin or pool
+type entry struct {
+	route   *models.Route
+	origin  *models.Origin
+	pool    *Pool
+	pattern *pathpattern.Pattern
+	rewrite *proxy.PathRewrite
+
+	requestHeaders  *transform.Headers
+	responseHeaders *transform.Headers
+	requestBody     *transform.Body
+	responseBody    *transform.Body
+}
+
+// outranks reports whether e should be preferred over o: higher priority
+// wins, and on equal priority the more specific pattern wins
+func (e *entry) outranks(o *entry) bool {
+	if e.route.Priority != o.route.Priority {
+		return e.route.Priority > o.route.Priority
+	}
+	return e.pattern.Specificity().Compare(o.pattern.Specificity()) > 0
+}
+
+func (e *entry) allowsMethod(method string) bool {
+	for _, m := range e.route.Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// node is a radix tree node keyed on the literal prefix of route patterns.
+// Entries are kept sorted by rank, best first.
+type node struct {
+	label    string
+	children []*node
+	entries  []*entry
+}
+
+func (n *node) child(c byte) *node {
+	for _, child := range n.children {
+		if child.label[0] == c {
+			return child
+		}
+	}
+	return nil
+}
+
+func (n *node) insert(e *entry) {
+	key := e.pattern.Prefix()
+	for {
+		if key == "" {
+			n.entries = append(n.entries, e)
+			sort.SliceStable(n.entries, func(i, j int) bool {
+				return n.entries[i].outranks(n.entries[j])
+			})
+			return
+		}
+
+		child := n.child(key[0])
+		if child == nil {
+			n.children = append(n.children, &node{label: key, entries: []*entry{e}})
+			return
+		}
+
+		common := commonPrefixLen(key, child.label)
+		if common < len(child.label) {
+			// Split the edge so the shared prefix gets its own node
+			split := &node{label: child.label[:common], children: []*node{child}}
+			child.label = child.label[common:]
+			for i, c := range n.children {
+				if c == child {
+					n.children[i] = split
+					break
+				}
+			}
+			child = split
+		}
+
+		key = key[common:]
+		n = child
+	}
+}
+
+// lookup walks the tree along path and returns the best matching entry
+// together with the parameters it captured
+func (n *node) lookup(path, method string) (*entry, pathpattern.Params) {
+	var best *entry
+	var bestParams pathpattern.Params
+	consumed := 0
+
+	for n != nil {
+		rest := path[consumed:]
+
+		for _, e := range n.entries {
+			if best != nil && !e.outranks(best) {
+				break
+			}
+			if !e.allowsMethod(method) {
+				continue
+			}
+			if params, ok := e.pattern.Match(path); ok {
+				best, bestParams = e, params
+				break
+			}
+		}
+
+		if rest == "" {
+			break
+		}
+
+		child := n.child(rest[0])
+		if child == nil || !strings.HasPrefix(rest, child.label) {
+			break
+		}
+		consumed += len(child.label)
+		n = child
+	}
+
+	return best, bestParams
+}
+
+func commonPrefixLen(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,314 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/vantageedge/backend/internal/models"
+)
+
+// BodyRules is the schema of a route's request_body_transform and
+// response_body_transform, applied to JSON bodies. Fields are addressed by
+// selectors (see Selector). The rules apply in the order of the fields:
+//
+//	unwrap  replaces the body by the value of a selector, such as the data
+//	        of an envelope
+//	map     sets target selectors to copies of source selectors; sources
+//	        are read before any target is set, and one with a wildcard
+//	        gives an array of every match
+//	rename  renames the fields selectors point at
+//	remove  removes the fields or elements selectors point at
+//	add     sets target selectors to JSON values, replacing existing ones
+//	wrap    puts the body in an envelope, at a selector of fields
+//
+// Objects missing on the path of a map, add or wrap target are created.
+// Rules whose selectors match nothing are skipped.
+type BodyRules struct {
+	Unwrap string                 `json:"unwrap,omitempty"`
+	Map    map[string]string      `json:"map,omitempty"`
+	Rename map[string]string      `json:"rename,omitempty"`
+	Remove []string               `json:"remove,omitempty"`
+	Add    map[string]interface{} `json:"add,omitempty"`
+	Wrap   string                 `json:"wrap,omitempty"`
+}
+
+// Body is a compiled set of body rules. It is immutable and safe for
+// concurrent use.
+type Body struct {
+	unwrap  *Selector
+	mapping []fieldMapping
+	rename  []fieldRename
+	remove  []*Selector
+	add     []fieldValue
+	wrap    []string
+}
+
+type fieldMapping struct {
+	target, source *Selector
+}
+
+type fieldRename struct {
+	field *Selector
+	to    string
+}
+
+type fieldValue struct {
+	target *Selector
+	value  interface{}
+}
+
+// ParseBody validates and compiles body rules stored in a route. It returns
+// nil for empty rules.
+func ParseBody(raw models.JSONB) (*Body, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+	decoder.UseNumber()
+	var rules BodyRules
+	if err := decoder.Decode(&rules); err != nil {
+		return nil, fmt.Errorf("invalid body rules: %w", err)
+	}
+	return CompileBody(rules)
+}
+
+// CompileBody validates and compiles body rules. It returns nil when there
+// are none.
+func CompileBody(rules BodyRules) (*Body, error) {
+	b := &Body{}
+	var err error
+	if rules.Unwrap != "" {
+		if b.unwrap, err = targetSelector(rules.Unwrap); err != nil {
+			return nil, fmt.Errorf("unwrap: %w", err)
+		}
+		if !b.unwrap.definite() {
+			return nil, fmt.Errorf("unwrap: selector %s has a wildcard", b.unwrap)
+		}
+	}
+
+	for _, target := range sortedKeys(rules.Map) {
+		m := fieldMapping{}
+		if m.target, err = targetSelector(target); err != nil {
+			return nil, fmt.Errorf("map: %w", err)
+		}
+		if !m.target.definite() {
+			return nil, fmt.Errorf("map: target %s has a wildcard", target)
+		}
+		if m.source, err = ParseSelector(rules.Map[target]); err != nil {
+			return nil, fmt.Errorf("map %s: %w", target, err)
+		}
+		b.mapping = append(b.mapping, m)
+	}
+
+	for _, field := range sortedKeys(rules.Rename) {
+		r := fieldRename{to: rules.Rename[field]}
+		if r.field, err = targetSelector(field); err != nil {
+			return nil, fmt.Errorf("rename: %w", err)
+		}
+		if r.field.steps[len(r.field.steps)-1].kind != fieldStep {
+			return nil, fmt.Errorf("rename: %s does not select a field", field)
+		}
+		if r.to == "" {
+			return nil, fmt.Errorf("rename %s: empty field name", field)
+		}
+		b.rename = append(b.rename, r)
+	}
+
+	for _, field := range rules.Remove {
+		sel, err := targetSelector(field)
+		if err != nil {
+			return nil, fmt.Errorf("remove: %w", err)
+		}
+		b.remove = append(b.remove, sel)
+	}
+
+	for _, target := range sortedKeys(rules.Add) {
+		v := fieldValue{value: rules.Add[target]}
+		if v.target, err = targetSelector(target); err != nil {
+			return nil, fmt.Errorf("add: %w", err)
+		}
+		if v.target.steps[len(v.target.steps)-1].kind == wildcardStep {
+			return nil, fmt.Errorf("add: target %s ends in a wildcard", target)
+		}
+		b.add = append(b.add, v)
+	}
+
+	if rules.Wrap != "" {
+		sel, err := targetSelector(rules.Wrap)
+		if err != nil {
+			return nil, fmt.Errorf("wrap: %w", err)
+		}
+		if !fieldsOnly(sel.steps) {
+			return nil, fmt.Errorf("wrap: %s must select fields only", rules.Wrap)
+		}
+		for _, st := range sel.steps {
+			b.wrap = append(b.wrap, st.field)
+		}
+	}
+
+	if b.unwrap == nil && len(b.mapping)+len(b.rename)+len(b.remove)+len(b.add)+len(b.wrap) == 0 {
+		return nil, nil
+	}
+	return b, nil
+}
+
+// targetSelector parses a selector that must point below the root
+func targetSelector(s string) (*Selector, error) {
+	sel, err := ParseSelector(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(sel.steps) == 0 {
+		return nil, fmt.Errorf("selector %s must select below the root", s)
+	}
+	return sel, nil
+}
+
+// Transform applies the rules to a JSON document. It fails when data is not
+// a single JSON value.
+func (b *Body) Transform(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after the JSON value")
+	}
+
+	doc = b.apply(doc)
+
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(out.Bytes(), []byte("\n")), nil
+}
+
+func (b *Body) apply(doc interface{}) interface{} {
+	if b.unwrap != nil {
+		if matches := b.unwrap.get(doc); len(matches) > 0 {
+			doc = matches[0]
+		}
+	}
+
+	if len(b.mapping) > 0 {
+		values := make([]interface{}, len(b.mapping))
+		found := make([]bool, len(b.mapping))
+		for i, m := range b.mapping {
+			matches := m.source.get(doc)
+			switch {
+			case !m.source.definite():
+				values[i], found[i] = deepCopy(matches), true
+			case len(matches) > 0:
+				values[i], found[i] = deepCopy(matches[0]), true
+			}
+		}
+		for i, m := range b.mapping {
+			if found[i] {
+				doc = m.target.modify(doc, true, setValue(values[i]))
+			}
+		}
+	}
+
+	for _, r := range b.rename {
+		to := r.to
+		doc = r.field.modify(doc, false, func(container interface{}, last step) interface{} {
+			if object, ok := container.(map[string]interface{}); ok && last.field != to {
+				if value, ok := object[last.field]; ok {
+					delete(object, last.field)
+					object[to] = value
+				}
+			}
+			return container
+		})
+	}
+
+	for _, sel := range b.remove {
+		doc = sel.modify(doc, false, removeValue)
+	}
+
+	for _, v := range b.add {
+		doc = v.target.modify(doc, true, setValue(v.value))
+	}
+
+	for i := len(b.wrap) - 1; i >= 0; i-- {
+		doc = map[string]interface{}{b.wrap[i]: doc}
+	}
+	return doc
+}
+
+// setValue returns a leaf setting the selected field or element to a copy
+// of value
+func setValue(value interface{}) func(interface{}, step) interface{} {
+	return func(container interface{}, last step) interface{} {
+		switch container := container.(type) {
+		case map[string]interface{}:
+			if last.kind == fieldStep {
+				container[last.field] = deepCopy(value)
+			}
+		case []interface{}:
+			if last.kind != indexStep {
+				break
+			}
+			if i, ok := last.resolve(len(container)); ok {
+				container[i] = deepCopy(value)
+			}
+		}
+		return container
+	}
+}
+
+// removeValue is a leaf removing the selected fields or elements
+func removeValue(container interface{}, last step) interface{} {
+	switch container := container.(type) {
+	case map[string]interface{}:
+		switch last.kind {
+		case fieldStep:
+			delete(container, last.field)
+		case wildcardStep:
+			for key := range container {
+				delete(container, key)
+			}
+		}
+	case []interface{}:
+		switch last.kind {
+		case indexStep:
+			if i, ok := last.resolve(len(container)); ok {
+				return append(container[:i:i], container[i+1:]...)
+			}
+		case wildcardStep:
+			return []interface{}{}
+		}
+	}
+	return container
+}
+
+// deepCopy copies the objects and arrays of a decoded JSON value
+func deepCopy(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(value))
+		for key, child := range value {
+			out[key] = deepCopy(child)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(value))
+		for i, child := range value {
+			out[i] = deepCopy(child)
+		}
+		return out
+	}
+	return value
+}
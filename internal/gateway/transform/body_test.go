@@ -0,0 +1,147 @@
+package transform
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/vantageedge/backend/internal/models"
+)
+
+func parseBody(t *testing.T, raw string) *Body {
+	t.Helper()
+	var rules models.JSONB
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ParseBody(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestTransformBody(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+		body  string
+		want  string
+	}{
+		{
+			name:  "unwrap envelope",
+			rules: `{"unwrap": "$.data"}`,
+			body:  `{"data": {"id": 1}, "status": "ok"}`,
+			want:  `{"id":1}`,
+		},
+		{
+			name:  "unwrap missing leaves body",
+			rules: `{"unwrap": "$.data"}`,
+			body:  `{"error": "not found"}`,
+			want:  `{"error":"not found"}`,
+		},
+		{
+			name:  "wrap envelope",
+			rules: `{"wrap": "$.request.payload"}`,
+			body:  `[1, 2]`,
+			want:  `{"request":{"payload":[1,2]}}`,
+		},
+		{
+			name:  "rename and remove in every element",
+			rules: `{"rename": {"$.items[*].user_name": "username"}, "remove": ["$.items[*].secret", "$.debug"]}`,
+			body:  `{"items": [{"user_name": "a", "secret": 1}, {"user_name": "b"}], "debug": true}`,
+			want:  `{"items":[{"username":"a"},{"username":"b"}]}`,
+		},
+		{
+			name:  "map reads the body before setting",
+			rules: `{"map": {"$.customer.id": "$.user.id", "$.user": "$.account", "$.ids": "$.items[*].id"}, "remove": ["$.account", "$.items"]}`,
+			body:  `{"user": {"id": 7}, "account": {"id": 8}, "items": [{"id": 1}, {"id": 2}]}`,
+			want:  `{"customer":{"id":7},"ids":[1,2],"user":{"id":8}}`,
+		},
+		{
+			name:  "map missing source",
+			rules: `{"map": {"$.name": "$.missing"}}`,
+			body:  `{"id": 1}`,
+			want:  `{"id":1}`,
+		},
+		{
+			name:  "add values",
+			rules: `{"add": {"$.meta.source": "gateway", "$.items[*].version": 2, "$.items[-1].last": true}}`,
+			body:  `{"items": [{}, {}]}`,
+			want:  `{"items":[{"version":2},{"last":true,"version":2}],"meta":{"source":"gateway"}}`,
+		},
+		{
+			name:  "quoted fields",
+			rules: `{"rename": {"$['first.name']": "first_name"}}`,
+			body:  `{"first.name": "Ada"}`,
+			want:  `{"first_name":"Ada"}`,
+		},
+		{
+			name:  "remove array elements",
+			rules: `{"remove": ["$[0]"]}`,
+			body:  `[1, 2, 3]`,
+			want:  `[2,3]`,
+		},
+		{
+			name:  "numbers and markup kept",
+			rules: `{"remove": ["$.x"]}`,
+			body:  `{"big": 12345678901234567890, "html": "<b>&</b>"}`,
+			want:  `{"big":12345678901234567890,"html":"<b>&</b>"}`,
+		},
+		{
+			name:  "all rules in order",
+			rules: `{"unwrap": "$.result", "map": {"$.total": "$.count"}, "rename": {"$.rows": "records"}, "remove": ["$.count"], "add": {"$.version": "v1"}, "wrap": "$.data"}`,
+			body:  `{"result": {"rows": [1], "count": 1}}`,
+			want:  `{"data":{"records":[1],"total":1,"version":"v1"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := parseBody(t, tt.rules).Transform([]byte(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != tt.want {
+				t.Fatalf("got %s, want %s", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformBodyRejectsInvalidJSON(t *testing.T) {
+	b := parseBody(t, `{"remove": ["$.a"]}`)
+	for _, body := range []string{``, `{"a": `, `{} {}`, `not json`} {
+		if _, err := b.Transform([]byte(body)); err == nil {
+			t.Errorf("%q: expected an error", body)
+		}
+	}
+}
+
+func TestParseBodyRejectsInvalidRules(t *testing.T) {
+	for name, raw := range map[string]string{
+		"unknown operation":     `{"flatten": true}`,
+		"missing root":          `{"remove": ["data"]}`,
+		"root target":           `{"remove": ["$"]}`,
+		"empty field":           `{"remove": ["$..a"]}`,
+		"bad index":             `{"remove": ["$.a[x]"]}`,
+		"unterminated bracket":  `{"remove": ["$.a[0"]}`,
+		"unterminated quote":    `{"remove": ["$['a]"]}`,
+		"wildcard unwrap":       `{"unwrap": "$.items[*]"}`,
+		"wildcard map target":   `{"map": {"$.items[*].id": "$.id"}}`,
+		"rename index":          `{"rename": {"$.items[0]": "first"}}`,
+		"rename to nothing":     `{"rename": {"$.a": ""}}`,
+		"add wildcard":          `{"add": {"$.a.*": 1}}`,
+		"wrap in array element": `{"wrap": "$.data[0]"}`,
+	} {
+		var rules models.JSONB
+		if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ParseBody(rules); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	if b, err := ParseBody(models.JSONB{}); b != nil || err != nil {
+		t.Fatalf("expected no rules for an empty object, got %v, %v", b, err)
+	}
+}
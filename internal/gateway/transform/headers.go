@@ -0,0 +1,205 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/vantageedge/backend/internal/models"
+)
+
+// HeaderRules is the schema of a route's request_headers and
+// response_headers. They apply in the order of the fields: headers are
+// removed, renamed, set (replacing any value) and added (keeping existing
+// values). Set and add values are templates; a header whose value expands
+// to nothing is left as it is.
+type HeaderRules struct {
+	Remove []string          `json:"remove,omitempty"`
+	Rename map[string]string `json:"rename,omitempty"`
+	Set    map[string]string `json:"set,omitempty"`
+	Add    map[string]string `json:"add,omitempty"`
+}
+
+// protectedHeaders describe the message framing or the connection and are
+// managed by the gateway alone
+var protectedHeaders = map[string]bool{
+	"Connection":        true,
+	"Content-Length":    true,
+	"Host":              true,
+	"Keep-Alive":        true,
+	"Te":                true,
+	"Trailer":           true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+}
+
+// Headers is a compiled set of header rules. It is immutable and safe for
+// concurrent use; a nil one changes nothing.
+type Headers struct {
+	remove []string
+	rename []headerRename
+	set    []headerValue
+	add    []headerValue
+}
+
+type headerRename struct {
+	from, to string
+}
+
+type headerValue struct {
+	name  string
+	value *Template
+}
+
+// ParseHeaders validates and compiles header rules stored in a route. It
+// returns nil for empty rules.
+func ParseHeaders(raw models.JSONB) (*Headers, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+	var rules HeaderRules
+	if err := decoder.Decode(&rules); err != nil {
+		return nil, fmt.Errorf("invalid header rules: %w", err)
+	}
+	return CompileHeaders(rules)
+}
+
+// CompileHeaders validates and compiles header rules. It returns nil when
+// there are none.
+func CompileHeaders(rules HeaderRules) (*Headers, error) {
+	h := &Headers{}
+	for _, name := range rules.Remove {
+		canonical, err := headerName(name)
+		if err != nil {
+			return nil, fmt.Errorf("remove: %w", err)
+		}
+		h.remove = append(h.remove, canonical)
+	}
+	for _, from := range sortedKeys(rules.Rename) {
+		source, err := headerName(from)
+		if err != nil {
+			return nil, fmt.Errorf("rename: %w", err)
+		}
+		target, err := headerName(rules.Rename[from])
+		if err != nil {
+			return nil, fmt.Errorf("rename %s: %w", from, err)
+		}
+		h.rename = append(h.rename, headerRename{from: source, to: target})
+	}
+	var err error
+	if h.set, err = headerValues("set", rules.Set); err != nil {
+		return nil, err
+	}
+	if h.add, err = headerValues("add", rules.Add); err != nil {
+		return nil, err
+	}
+
+	if len(h.remove)+len(h.rename)+len(h.set)+len(h.add) == 0 {
+		return nil, nil
+	}
+	return h, nil
+}
+
+// Apply transforms header in place
+func (h *Headers) Apply(header http.Header, v *Vars) {
+	if h == nil {
+		return
+	}
+	for _, name := range h.remove {
+		header.Del(name)
+	}
+	for _, r := range h.rename {
+		if values, ok := header[r.from]; ok {
+			delete(header, r.from)
+			header[r.to] = values
+		}
+	}
+	for _, hv := range h.set {
+		if value := hv.value.Expand(v); value != "" {
+			header.Set(hv.name, value)
+		}
+	}
+	for _, hv := range h.add {
+		if value := hv.value.Expand(v); value != "" {
+			header.Add(hv.name, value)
+		}
+	}
+}
+
+func headerValues(op string, values map[string]string) ([]headerValue, error) {
+	var out []headerValue
+	for _, name := range sortedKeys(values) {
+		canonical, err := headerName(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if !ValidHeaderValue(values[name]) {
+			return nil, fmt.Errorf("%s %s: value contains a control character", op, name)
+		}
+		value, err := ParseTemplate(values[name])
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", op, name, err)
+		}
+		out = append(out, headerValue{name: canonical, value: value})
+	}
+	return out, nil
+}
+
+// headerName validates a header name and returns its canonical form
+func headerName(name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("empty header name")
+	}
+	for i := 0; i < len(name); i++ {
+		if !isTokenChar(name[i]) {
+			return "", fmt.Errorf("invalid header name %q", name)
+		}
+	}
+	canonical := http.CanonicalHeaderKey(name)
+	if protectedHeaders[canonical] {
+		return "", fmt.Errorf("header %s cannot be transformed", canonical)
+	}
+	return canonical, nil
+}
+
+// ValidHeaderValue reports whether v may be sent as a header value: it
+// holds no control characters other than tab (RFC 9110 field-value)
+func ValidHeaderValue(v string) bool {
+	for i := 0; i < len(v); i++ {
+		if isControl(v[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isControl(c byte) bool {
+	return (c < ' ' && c != '\t') || c == 0x7f
+}
+
+// isTokenChar reports whether c may appear in a header name (RFC 9110 tchar)
+func isTokenChar(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
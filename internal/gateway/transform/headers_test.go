@@ -0,0 +1,125 @@
+package transform
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/vantageedge/backend/internal/auth/jwt"
+	"github.com/vantageedge/backend/internal/gateway/pathpattern"
+	"github.com/vantageedge/backend/internal/models"
+)
+
+func parse(t *testing.T, raw string) *Headers {
+	t.Helper()
+	var rules models.JSONB
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		t.Fatal(err)
+	}
+	h, err := ParseHeaders(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestApplyHeaders(t *testing.T) {
+	h := parse(t, `{
+		"remove": ["x-debug"],
+		"rename": {"X-Old-Auth": "X-Auth"},
+		"set": {"X-Tenant": "${tenant_id}", "X-User": "${user_id}", "X-Forwarded-Host": "api.example.com"},
+		"add": {"X-Trace": "req-${request_id}", "Via": "1.1 vantageedge"}
+	}`)
+
+	header := http.Header{
+		"X-Debug":          {"1"},
+		"X-Old-Auth":       {"token"},
+		"X-Tenant":         {"spoofed"},
+		"X-Forwarded-Host": {"client.example.com"},
+		"Via":              {"1.1 cdn"},
+	}
+	h.Apply(header, &Vars{TenantID: "t1", RequestID: "r1"})
+
+	want := http.Header{
+		"X-Auth":           {"token"},
+		"X-Tenant":         {"t1"},
+		"X-Forwarded-Host": {"api.example.com"},
+		"X-Trace":          {"req-r1"},
+		"Via":              {"1.1 cdn", "1.1 vantageedge"},
+	}
+	if !reflect.DeepEqual(header, want) {
+		t.Fatalf("got %v, want %v", header, want)
+	}
+}
+
+func TestTemplateVariables(t *testing.T) {
+	var claims jwt.Claims
+	if err := json.Unmarshal([]byte(`{"sub":"user_1","org":{"id":"org_1"},"roles":["admin","dev"],"level":3}`), &claims); err != nil {
+		t.Fatal(err)
+	}
+	vars := &Vars{
+		ClientIP: "203.0.113.7",
+		APIKeyID: "key_1",
+		Params:   pathpattern.Params{"id": "42"},
+		Claims:   &claims,
+	}
+
+	tests := map[string]string{
+		"${client_ip}":                       "203.0.113.7",
+		"key=${api_key_id}":                  "key=key_1",
+		"/items/${param.id}":                 "/items/42",
+		"${claim.sub}@${claim.org.id}":       "user_1@org_1",
+		"${claim.roles};${claim.level}":      "admin,dev;3",
+		"${claim.missing}${param.missing}$$": "$",
+		"${user_id}":                         "",
+	}
+	for raw, want := range tests {
+		tmpl, err := ParseTemplate(raw)
+		if err != nil {
+			t.Fatalf("%s: %v", raw, err)
+		}
+		if got := tmpl.Expand(vars); got != want {
+			t.Errorf("%s: got %q, want %q", raw, got, want)
+		}
+	}
+
+	// Values cannot break out of a header or make it invalid
+	tmpl, _ := ParseTemplate("${param.id}")
+	for value, want := range map[string]string{
+		"a\r\nX-Injected: 1": "aX-Injected: 1",
+		"a\x00b\x1fc\x7fd":   "abcd",
+		"a\tb é":             "a\tb é",
+	} {
+		if got := tmpl.Expand(&Vars{Params: pathpattern.Params{"id": value}}); got != want {
+			t.Errorf("%q: expected control characters dropped, got %q", value, got)
+		}
+	}
+}
+
+func TestParseHeadersRejectsInvalidRules(t *testing.T) {
+	for name, raw := range map[string]string{
+		"unknown operation":   `{"append": {"X-A": "1"}}`,
+		"flat map":            `{"X-A": "1"}`,
+		"unknown variable":    `{"set": {"X-A": "${secret}"}}`,
+		"unterminated":        `{"set": {"X-A": "${tenant_id"}}`,
+		"lone dollar":         `{"add": {"X-A": "$5"}}`,
+		"invalid name":        `{"remove": ["X A"]}`,
+		"protected header":    `{"set": {"content-length": "0"}}`,
+		"protected rename":    `{"rename": {"X-A": "Transfer-Encoding"}}`,
+		"line break in value": `{"set": {"X-A": "a\r\nb"}}`,
+		"control in value":    `{"set": {"X-A": "a\u0000b"}}`,
+	} {
+		var rules models.JSONB
+		if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ParseHeaders(rules); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	if h, err := ParseHeaders(models.JSONB{}); h != nil || err != nil {
+		t.Fatalf("expected no rules for an empty object, got %v, %v", h, err)
+	}
+}
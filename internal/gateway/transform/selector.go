@@ -0,0 +1,211 @@
+package transform
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Selector is a parsed JSONPath-style selector of values in a JSON document.
+// It starts at the root, $, followed by steps:
+//
+//	.name, ['name']  field of an object
+//	[n]              element of an array; negative indexes count from the end
+//	.*, [*]          every field or element
+//
+// A Selector is immutable and safe for concurrent use.
+type Selector struct {
+	raw   string
+	steps []step
+}
+
+type stepKind int
+
+const (
+	fieldStep stepKind = iota
+	indexStep
+	wildcardStep
+)
+
+type step struct {
+	kind  stepKind
+	field string
+	index int
+}
+
+// ParseSelector parses a selector
+func ParseSelector(s string) (*Selector, error) {
+	if !strings.HasPrefix(s, "$") {
+		return nil, fmt.Errorf("selector %q must start with '$'", s)
+	}
+	sel := &Selector{raw: s}
+	for i := 1; i < len(s); {
+		switch s[i] {
+		case '.':
+			end := i + 1
+			for end < len(s) && s[end] != '.' && s[end] != '[' {
+				end++
+			}
+			switch name := s[i+1 : end]; name {
+			case "":
+				return nil, fmt.Errorf("selector %q: empty field name at offset %d", s, i)
+			case "*":
+				sel.steps = append(sel.steps, step{kind: wildcardStep})
+			default:
+				sel.steps = append(sel.steps, step{kind: fieldStep, field: name})
+			}
+			i = end
+		case '[':
+			st, n, err := parseBracket(s[i:])
+			if err != nil {
+				return nil, fmt.Errorf("selector %q: %w at offset %d", s, err, i)
+			}
+			sel.steps = append(sel.steps, st)
+			i += n
+		default:
+			return nil, fmt.Errorf("selector %q: unexpected %q at offset %d", s, s[i], i)
+		}
+	}
+	return sel, nil
+}
+
+// parseBracket parses a bracketed step at the start of s and returns it with
+// the bytes it took
+func parseBracket(s string) (step, int, error) {
+	if len(s) > 1 && (s[1] == '\'' || s[1] == '"') {
+		end := strings.IndexByte(s[2:], s[1])
+		if end < 0 || !strings.HasPrefix(s[2+end+1:], "]") {
+			return step{}, 0, fmt.Errorf("unterminated quoted field")
+		}
+		return step{kind: fieldStep, field: s[2 : 2+end]}, end + 4, nil
+	}
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return step{}, 0, fmt.Errorf("unterminated '['")
+	}
+	if s[1:end] == "*" {
+		return step{kind: wildcardStep}, end + 1, nil
+	}
+	index, err := strconv.Atoi(s[1:end])
+	if err != nil {
+		return step{}, 0, fmt.Errorf("invalid index %q", s[1:end])
+	}
+	return step{kind: indexStep, index: index}, end + 1, nil
+}
+
+// String returns the selector as it was written
+func (s *Selector) String() string {
+	return s.raw
+}
+
+// definite reports whether the selector selects at most one value
+func (s *Selector) definite() bool {
+	for _, st := range s.steps {
+		if st.kind == wildcardStep {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldsOnly reports whether every step of the selector is a field
+func fieldsOnly(steps []step) bool {
+	for _, st := range steps {
+		if st.kind != fieldStep {
+			return false
+		}
+	}
+	return true
+}
+
+// get returns the values the selector selects in doc
+func (s *Selector) get(doc interface{}) []interface{} {
+	var out []interface{}
+	collect(doc, s.steps, &out)
+	return out
+}
+
+func collect(node interface{}, steps []step, out *[]interface{}) {
+	if len(steps) == 0 {
+		*out = append(*out, node)
+		return
+	}
+	st, rest := steps[0], steps[1:]
+	switch container := node.(type) {
+	case map[string]interface{}:
+		switch st.kind {
+		case fieldStep:
+			if child, ok := container[st.field]; ok {
+				collect(child, rest, out)
+			}
+		case wildcardStep:
+			for _, key := range sortedKeys(container) {
+				collect(container[key], rest, out)
+			}
+		}
+	case []interface{}:
+		switch st.kind {
+		case indexStep:
+			if i, ok := st.resolve(len(container)); ok {
+				collect(container[i], rest, out)
+			}
+		case wildcardStep:
+			for _, child := range container {
+				collect(child, rest, out)
+			}
+		}
+	}
+}
+
+// modify calls leaf with each container the selector's last step applies to
+// and stores what leaf returns in its place. With create, missing objects on
+// a path of fields are created. It returns doc, or its replacement.
+func (s *Selector) modify(doc interface{}, create bool, leaf func(container interface{}, last step) interface{}) interface{} {
+	return modify(doc, s.steps, create, leaf)
+}
+
+func modify(node interface{}, steps []step, create bool, leaf func(interface{}, step) interface{}) interface{} {
+	if len(steps) == 1 {
+		return leaf(node, steps[0])
+	}
+	st, rest := steps[0], steps[1:]
+	switch container := node.(type) {
+	case map[string]interface{}:
+		switch st.kind {
+		case fieldStep:
+			child, ok := container[st.field]
+			if !ok {
+				if !create || !fieldsOnly(rest) {
+					return node
+				}
+				child = map[string]interface{}{}
+			}
+			container[st.field] = modify(child, rest, create, leaf)
+		case wildcardStep:
+			for key, child := range container {
+				container[key] = modify(child, rest, create, leaf)
+			}
+		}
+	case []interface{}:
+		switch st.kind {
+		case indexStep:
+			if i, ok := st.resolve(len(container)); ok {
+				container[i] = modify(container[i], rest, create, leaf)
+			}
+		case wildcardStep:
+			for i, child := range container {
+				container[i] = modify(child, rest, create, leaf)
+			}
+		}
+	}
+	return node
+}
+
+// resolve returns the position of an index step in an array of length n
+func (st step) resolve(n int) (int, bool) {
+	i := st.index
+	if i < 0 {
+		i += n
+	}
+	return i, i >= 0 && i < n
+}
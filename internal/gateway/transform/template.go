@@ -0,0 +1,178 @@
+// Package transform implements the request and response transformations
+// routes configure.
+//
+// Header values are templates: ${name} is replaced by a variable of the
+// request and $$ by a literal $. The variables are:
+//
+//	client_ip      address of the client
+//	tenant_id      ID of the tenant
+//	user_id        ID of the authenticated user, if any
+//	api_key_id     ID of the API key used, if any
+//	request_id     the client's X-Request-ID, or one generated by the gateway
+//	param.<name>   path parameter captured by the route pattern
+//	claim.<name>   claim of the JWT the request carried; dots reach into
+//	               nested objects
+package transform
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/vantageedge/backend/internal/auth/jwt"
+	"github.com/vantageedge/backend/internal/gateway/pathpattern"
+)
+
+// Vars are the values templates are expanded with
+type Vars struct {
+	ClientIP  string
+	TenantID  string
+	UserID    string
+	APIKeyID  string
+	RequestID string
+	Params    pathpattern.Params
+	Claims    *jwt.Claims
+}
+
+// Template is a parsed value template. It is immutable and safe for
+// concurrent use.
+type Template struct {
+	parts []templatePart
+}
+
+type templatePart struct {
+	literal  string
+	variable string // empty for literal text
+	arg      string // name after "param." or "claim."
+}
+
+// ParseTemplate parses and validates a value template
+func ParseTemplate(s string) (*Template, error) {
+	t := &Template{}
+	var literal strings.Builder
+	flush := func() {
+		if literal.Len() > 0 {
+			t.parts = append(t.parts, templatePart{literal: literal.String()})
+			literal.Reset()
+		}
+	}
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] != '$':
+			literal.WriteByte(s[i])
+		case strings.HasPrefix(s[i:], "$$"):
+			literal.WriteByte('$')
+			i++
+		case strings.HasPrefix(s[i:], "${"):
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated '${' at offset %d", i)
+			}
+			part, err := parseVariable(s[i+2 : i+end])
+			if err != nil {
+				return nil, err
+			}
+			flush()
+			t.parts = append(t.parts, part)
+			i += end
+		default:
+			return nil, fmt.Errorf("'$' at offset %d must start ${name} or be doubled", i)
+		}
+	}
+	flush()
+	return t, nil
+}
+
+func parseVariable(name string) (templatePart, error) {
+	switch name {
+	case "client_ip", "tenant_id", "user_id", "api_key_id", "request_id":
+		return templatePart{variable: name}, nil
+	}
+	if kind, arg, ok := strings.Cut(name, "."); ok && arg != "" && (kind == "param" || kind == "claim") {
+		return templatePart{variable: kind, arg: arg}, nil
+	}
+	return templatePart{}, fmt.Errorf("unknown variable %q", name)
+}
+
+// Expand returns the template with its variables replaced. Control
+// characters other than tab are dropped from variable values, so values
+// taken from the request can neither end a header nor make it invalid.
+func (t *Template) Expand(v *Vars) string {
+	if len(t.parts) == 1 && t.parts[0].variable == "" {
+		return t.parts[0].literal
+	}
+	var b strings.Builder
+	for _, part := range t.parts {
+		if part.variable == "" {
+			b.WriteString(part.literal)
+			continue
+		}
+		b.WriteString(dropControls(v.lookup(part)))
+	}
+	return b.String()
+}
+
+// dropControls removes the characters a header value may not contain
+func dropControls(s string) string {
+	if ValidHeaderValue(s) {
+		return s
+	}
+	return strings.Map(func(r rune) rune {
+		if r < utf8.RuneSelf && isControl(byte(r)) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func (v *Vars) lookup(part templatePart) string {
+	if v == nil {
+		return ""
+	}
+	switch part.variable {
+	case "client_ip":
+		return v.ClientIP
+	case "tenant_id":
+		return v.TenantID
+	case "user_id":
+		return v.UserID
+	case "api_key_id":
+		return v.APIKeyID
+	case "request_id":
+		return v.RequestID
+	case "param":
+		return v.Params[part.arg]
+	case "claim":
+		value, ok := v.Claims.Claim(part.arg)
+		if !ok {
+			return ""
+		}
+		return claimString(value)
+	}
+	return ""
+}
+
+// claimString formats a JSON claim value for a header
+func claimString(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	case []interface{}:
+		items := make([]string, 0, len(value))
+		for _, item := range value {
+			items = append(items, claimString(item))
+		}
+		return strings.Join(items, ",")
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
@@ -0,0 +1,133 @@
+package loadbalancer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/loadbalancer/consistenthash"
+	"github.com/vantageedge/backend/internal/loadbalancer/leastconn"
+	"github.com/vantageedge/backend/internal/loadbalancer/peakewma"
+	"github.com/vantageedge/backend/internal/loadbalancer/roundrobin"
+	"github.com/vantageedge/backend/internal/models"
+)
+
+// Load balancing strategies of upstream pools
+const (
+	StrategyRoundRobin     = "round_robin"
+	StrategyLeastConn      = "least_conn"
+	StrategyConsistentHash = "consistent_hash"
+	StrategyPeakEWMA       = "peak_ewma"
+)
+
+// Strategies lists the strategies New accepts
+var Strategies = []string{StrategyRoundRobin, StrategyLeastConn, StrategyConsistentHash, StrategyPeakEWMA}
+
+// Balancer picks the origin of a request among the candidates of a pool. key
+// identifies the client for strategies with affinity; others ignore it.
+type Balancer interface {
+	Select(ctx context.Context, key string, origins []*models.Origin) (*models.Origin, error)
+}
+
+// ConnectionTracker is implemented by balancers that need to know the
+// requests in flight to each origin
+type ConnectionTracker interface {
+	IncrementConnections(originID string)
+	DecrementConnections(originID string)
+}
+
+// LatencyObserver is implemented by balancers that weigh origins by how long
+// they take to answer. rtt runs until the response headers arrived or the
+// request failed with err.
+type LatencyObserver interface {
+	ObserveLatency(originID string, rtt time.Duration, err error)
+}
+
+// ValidateStrategy checks a strategy against the implemented ones
+func ValidateStrategy(strategy string) error {
+	for _, s := range Strategies {
+		if strategy == s {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown load balancing strategy %q", strategy)
+}
+
+// Options tunes the balancer of a pool
+type Options struct {
+	Strategy   string
+	LoadFactor float64       // consistent_hash: bound on an origin's load relative to the average; below 1 leaves loads unbounded
+	Decay      time.Duration // peak_ewma: time constant of the latency average
+}
+
+// ValidateLoadFactor checks a consistent hashing load factor; zero means
+// unset
+func ValidateLoadFactor(factor float64) error {
+	if factor != 0 && factor < 1 {
+		return fmt.Errorf("load factor must be at least 1")
+	}
+	return nil
+}
+
+// New returns a balancer implementing opts.Strategy
+func New(opts Options) (Balancer, error) {
+	switch opts.Strategy {
+	case StrategyRoundRobin:
+		return roundrobin.NewRoundRobinBalancer(), nil
+	case StrategyLeastConn:
+		return leastconn.NewLeastConnBalancer(), nil
+	case StrategyConsistentHash:
+		return consistenthash.NewConsistentHashBalancer(opts.LoadFactor), nil
+	case StrategyPeakEWMA:
+		return peakewma.NewPeakEWMABalancer(opts.Decay), nil
+	}
+	return nil, ValidateStrategy(opts.Strategy)
+}
+
+// Pools holds the balancer of each upstream pool, created on first use and
+// replaced when the pool's balancing options change
+type Pools struct {
+	mu        sync.Mutex
+	defaults  Options
+	balancers map[uuid.UUID]*poolBalancer
+}
+
+type poolBalancer struct {
+	opts     Options
+	balancer Balancer
+}
+
+// NewPools returns a registry whose pools use defaults for the options they
+// leave unset
+func NewPools(defaults Options) *Pools {
+	return &Pools{
+		defaults:  defaults,
+		balancers: make(map[uuid.UUID]*poolBalancer),
+	}
+}
+
+// Balancer returns the balancer of pool
+func (p *Pools) Balancer(pool *models.UpstreamPool) (Balancer, error) {
+	opts := Options{Strategy: pool.Strategy, LoadFactor: pool.HashLoadFactor, Decay: p.defaults.Decay}
+	if opts.Strategy == "" {
+		opts.Strategy = p.defaults.Strategy
+	}
+	if opts.LoadFactor == 0 {
+		opts.LoadFactor = p.defaults.LoadFactor
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if pb, ok := p.balancers[pool.ID]; ok && pb.opts == opts {
+		return pb.balancer, nil
+	}
+	balancer, err := New(opts)
+	if err != nil {
+		return nil, err
+	}
+	p.balancers[pool.ID] = &poolBalancer{opts: opts, balancer: balancer}
+	return balancer, nil
+}
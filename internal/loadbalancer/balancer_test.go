@@ -0,0 +1,117 @@
+package loadbalancer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/loadbalancer/consistenthash"
+	"github.com/vantageedge/backend/internal/loadbalancer/roundrobin"
+	"github.com/vantageedge/backend/internal/models"
+)
+
+func newOrigins(n int) []*models.Origin {
+	origins := make([]*models.Origin, n)
+	for i := range origins {
+		origins[i] = &models.Origin{ID: uuid.New(), URL: fmt.Sprintf("http://origin-%d", i)}
+	}
+	return origins
+}
+
+func TestPoolsBalancer(t *testing.T) {
+	pools := NewPools(Options{Strategy: StrategyRoundRobin})
+	pool := &models.UpstreamPool{ID: uuid.New()}
+
+	first, err := pools.Balancer(pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := first.(*roundrobin.RoundRobinBalancer); !ok {
+		t.Fatalf("expected the default strategy, got %T", first)
+	}
+	if again, _ := pools.Balancer(pool); again != first {
+		t.Fatal("expected the pool to keep its balancer")
+	}
+
+	pool.Strategy = StrategyConsistentHash
+	if changed, _ := pools.Balancer(pool); changed == first {
+		t.Fatal("expected a new balancer once the strategy changed")
+	} else if _, ok := changed.(*consistenthash.ConsistentHashBalancer); !ok {
+		t.Fatalf("expected a consistent hash balancer, got %T", changed)
+	}
+
+	consistent, _ := pools.Balancer(pool)
+	pool.HashLoadFactor = 2
+	if changed, _ := pools.Balancer(pool); changed == consistent {
+		t.Fatal("expected a new balancer once the load factor changed")
+	}
+
+	pool.Strategy = "random"
+	if _, err := pools.Balancer(pool); err == nil {
+		t.Fatal("expected an unknown strategy to fail")
+	}
+}
+
+func TestConsistentHashSkipsExcludedOrigins(t *testing.T) {
+	ctx := context.Background()
+	balancer, _ := New(Options{Strategy: StrategyConsistentHash})
+	origins := newOrigins(4)
+
+	before := make(map[string]*models.Origin)
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("client-%d", i)
+		origin, err := balancer.Select(ctx, key, origins)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again, _ := balancer.Select(ctx, key, origins); again != origin {
+			t.Fatalf("expected %s to stick to one origin", key)
+		}
+		before[key] = origin
+	}
+
+	// Leaving an origin out only moves the keys it served
+	excluded := origins[0]
+	for key, origin := range before {
+		after, _ := balancer.Select(ctx, key, origins[1:])
+		if after == excluded {
+			t.Fatalf("expected %s to avoid the excluded origin", key)
+		}
+		if origin != excluded && after != origin {
+			t.Fatalf("expected %s to keep its origin", key)
+		}
+	}
+}
+
+func TestHashKey(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/users/42?tenant=acme", nil)
+	r.Header.Set("X-User", "u-1")
+	r.AddCookie(&http.Cookie{Name: "session", Value: "s-1"})
+
+	tests := []struct {
+		source, name, want string
+	}{
+		{HashKeyClientIP, "", ""},
+		{HashKeyHeader, "X-User", "u-1"},
+		{HashKeyHeader, "X-Missing", ""},
+		{HashKeyCookie, "session", "s-1"},
+		{HashKeyCookie, "missing", ""},
+		{HashKeyPath, "", "/users/42"},
+		{HashKeyQuery, "tenant", "acme"},
+	}
+	for _, tt := range tests {
+		if got := HashKey(r, tt.source, tt.name); got != tt.want {
+			t.Errorf("HashKey(%s, %q) = %q, want %q", tt.source, tt.name, got, tt.want)
+		}
+	}
+
+	if err := ValidateHashKey(HashKeyHeader, ""); err == nil {
+		t.Error("expected a header source without a name to be invalid")
+	}
+	if err := ValidateHashKey("body", ""); err == nil {
+		t.Error("expected an unknown source to be invalid")
+	}
+}
@@ -3,47 +3,73 @@ package consistenthash
 import (
 	"context"
 	"fmt"
-	"hash/crc32"
+	"hash/fnv"
+	"math"
 	"sort"
+	"strconv"
 	"sync"
 
 	"github.com/vantageedge/backend/internal/models"
 )
 
+// ConsistentHashBalancer maps keys to origins on a hash ring so a key keeps
+// reaching the same origin while the origins don't change. Each origin gets
+// virtual nodes in proportion to its weight.
+//
+// With a load factor, it implements consistent hashing with bounded loads: an
+// origin already serving more than loadFactor times the average number of
+// requests in flight is passed over for the next one on the ring, so hot keys
+// spill over instead of overloading a single origin.
 type ConsistentHashBalancer struct {
-	mu    sync.RWMutex
-	ring  map[uint32]string     // hash -> origin_id
-	keys  []uint32              // sorted hashes
-	nodes map[string]int        // origin_id -> replica count
+	mu          sync.RWMutex
+	loadFactor  float64
+	ring        []vnode
+	nodes       map[string]*node // origin_id -> node
+	selections  uint64
+	connections map[string]int // origin_id -> requests in flight
 }
 
-const defaultReplicas = 3
+type vnode struct {
+	hash     uint64
+	originID string
+}
+
+type node struct {
+	weight   int
+	lastSeen uint64
+}
+
+const (
+	// replicasPerOrigin is the number of virtual nodes of the heaviest origin
+	// on the ring; lighter ones get proportionally fewer
+	replicasPerOrigin = 160
 
-func NewConsistentHashBalancer() *ConsistentHashBalancer {
+	// pruneEvery is how many selections an origin may go without being a
+	// candidate before it leaves the ring
+	pruneEvery = 4096
+)
+
+// NewConsistentHashBalancer returns a balancer bounding each origin's load to
+// loadFactor times the average; a loadFactor below 1 leaves loads unbounded
+func NewConsistentHashBalancer(loadFactor float64) *ConsistentHashBalancer {
+	if loadFactor < 1 {
+		loadFactor = 0
+	}
 	return &ConsistentHashBalancer{
-		ring:  make(map[uint32]string),
-		keys:  make([]uint32, 0),
-		nodes: make(map[string]int),
+		loadFactor:  loadFactor,
+		nodes:       make(map[string]*node),
+		connections: make(map[string]int),
 	}
 }
 
-// AddOrigin adds an origin to the consistent hash ring
+// AddOrigin adds an origin to the consistent hash ring, or updates its weight
 func (b *ConsistentHashBalancer) AddOrigin(origin *models.Origin) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	originID := origin.ID.String()
-	b.nodes[originID] = defaultReplicas
-
-	for i := 0; i < defaultReplicas; i++ {
-		hash := b.hash(fmt.Sprintf("%s-%d", originID, i))
-		b.ring[hash] = originID
-		b.keys = append(b.keys, hash)
+	if b.upsert(origin) {
+		b.rebuild()
 	}
-
-	sort.Slice(b.keys, func(i, j int) bool {
-		return b.keys[i] < b.keys[j]
-	})
 }
 
 // RemoveOrigin removes an origin from the consistent hash ring
@@ -51,60 +77,162 @@ func (b *ConsistentHashBalancer) RemoveOrigin(originID string) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	replicas := b.nodes[originID]
-	delete(b.nodes, originID)
-
-	newKeys := make([]uint32, 0)
-	for i := 0; i < replicas; i++ {
-		hash := b.hash(fmt.Sprintf("%s-%d", originID, i))
-		delete(b.ring, hash)
+	if _, ok := b.nodes[originID]; ok {
+		delete(b.nodes, originID)
+		b.rebuild()
 	}
-
-	// Rebuild keys
-	for hash := range b.ring {
-		newKeys = append(newKeys, hash)
-	}
-	sort.Slice(newKeys, func(i, j int) bool {
-		return newKeys[i] < newKeys[j]
-	})
-	b.keys = newKeys
 }
 
-// SelectOrigin selects an origin based on consistent hashing of the key
+// SelectOrigin selects the origin of key among origins. Candidates missing
+// from the ring, or whose weight changed, are (re)placed on it first.
 func (b *ConsistentHashBalancer) SelectOrigin(ctx context.Context, key string, origins []*models.Origin) (*models.Origin, error) {
 	if len(origins) == 0 {
 		return nil, fmt.Errorf("no origins available")
 	}
 
-	b.mu.RLock()
-	defer b.mu.RUnlock()
+	b.mu.Lock()
+	defer b.mu.Unlock()
 
-	if len(b.keys) == 0 {
-		return origins[0], nil
+	b.selections++
+	changed := false
+	for _, origin := range origins {
+		if b.upsert(origin) {
+			changed = true
+		}
+		b.nodes[origin.ID.String()].lastSeen = b.selections
+	}
+	if b.selections%pruneEvery == 0 {
+		for id, n := range b.nodes {
+			if b.selections-n.lastSeen >= pruneEvery {
+				delete(b.nodes, id)
+				changed = true
+			}
+		}
+	}
+	if changed {
+		b.rebuild()
 	}
 
-	hash := b.hash(key)
-	idx := sort.Search(len(b.keys), func(i int) bool {
-		return b.keys[i] >= hash
+	candidates := make(map[string]*models.Origin, len(origins))
+	inFlight := 0
+	for _, origin := range origins {
+		id := origin.ID.String()
+		candidates[id] = origin
+		inFlight += b.connections[id]
+	}
+
+	// Walk the ring from the key's position to the first candidate, so keys
+	// of excluded origins move to their neighbours only, skipping candidates
+	// at capacity
+	capacity := math.MaxInt
+	if b.loadFactor > 0 {
+		capacity = int(math.Ceil(b.loadFactor * float64(inFlight+1) / float64(len(origins))))
+	}
+	hash := hashKey(key)
+	idx := sort.Search(len(b.ring), func(i int) bool {
+		return b.ring[i].hash >= hash
 	})
+	var first *models.Origin
+	for i := 0; i < len(b.ring); i++ {
+		id := b.ring[(idx+i)%len(b.ring)].originID
+		origin, ok := candidates[id]
+		if !ok {
+			continue
+		}
+		if b.connections[id] < capacity {
+			return origin, nil
+		}
+		if first == nil {
+			first = origin
+		}
+	}
 
-	if idx == len(b.keys) {
-		idx = 0
+	// Unreachable as some candidate is always below capacity
+	if first != nil {
+		return first, nil
 	}
+	return origins[0], nil
+}
 
-	originID := b.ring[b.keys[idx]]
+// Select implements loadbalancer.Balancer
+func (b *ConsistentHashBalancer) Select(ctx context.Context, key string, origins []*models.Origin) (*models.Origin, error) {
+	return b.SelectOrigin(ctx, key, origins)
+}
 
-	// Find the origin by ID
-	for _, origin := range origins {
-		if origin.ID.String() == originID {
-			return origin, nil
+// IncrementConnections counts a request in flight to an origin, for bounding
+// loads
+func (b *ConsistentHashBalancer) IncrementConnections(originID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.connections[originID]++
+}
+
+// DecrementConnections ends a request in flight to an origin
+func (b *ConsistentHashBalancer) DecrementConnections(originID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if count := b.connections[originID]; count > 1 {
+		b.connections[originID]--
+	} else {
+		delete(b.connections, originID)
+	}
+}
+
+// upsert records origin's weight and reports whether the ring must be rebuilt
+func (b *ConsistentHashBalancer) upsert(origin *models.Origin) bool {
+	id := origin.ID.String()
+	weight := origin.Weight
+	if weight < 1 {
+		weight = 1
+	}
+	if n, ok := b.nodes[id]; ok {
+		if n.weight == weight {
+			return false
 		}
+		n.weight = weight
+		return true
 	}
+	b.nodes[id] = &node{weight: weight, lastSeen: b.selections}
+	return true
+}
 
-	return origins[0], nil
+// rebuild places every origin's virtual nodes on the ring. The heaviest
+// origin gets replicasPerOrigin of them and the others a share in proportion
+// to their weight. Virtual node positions only depend on the origin ID, so
+// origins keep their positions across rebuilds.
+func (b *ConsistentHashBalancer) rebuild() {
+	maxWeight := 1
+	for _, n := range b.nodes {
+		maxWeight = max(maxWeight, n.weight)
+	}
+
+	ring := make([]vnode, 0, len(b.nodes)*replicasPerOrigin)
+	for id, n := range b.nodes {
+		replicas := max(1, replicasPerOrigin*n.weight/maxWeight)
+		for i := 0; i < replicas; i++ {
+			ring = append(ring, vnode{hash: hashKey(id + "-" + strconv.Itoa(i)), originID: id})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool {
+		if ring[i].hash != ring[j].hash {
+			return ring[i].hash < ring[j].hash
+		}
+		return ring[i].originID < ring[j].originID
+	})
+	b.ring = ring
 }
 
-// hash generates a hash value for a key
-func (b *ConsistentHashBalancer) hash(key string) uint32 {
-	return crc32.ChecksumIEEE([]byte(key))
+// hashKey hashes a key with 64-bit FNV-1a followed by a finalizer mixing its
+// bits, as FNV alone spreads similar keys poorly. Unlike maphash it is stable
+// across processes, so every gateway instance maps keys the same way.
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
 }
@@ -0,0 +1,120 @@
+package consistenthash
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/models"
+)
+
+// weighted returns origins of the given weights. Their IDs are derived from
+// their names, so the ring and the key spread are the same on every run.
+func weighted(weights ...int) []*models.Origin {
+	origins := make([]*models.Origin, len(weights))
+	for i, w := range weights {
+		name := fmt.Sprintf("origin-%d", i)
+		origins[i] = &models.Origin{ID: uuid.NewSHA1(uuid.NameSpaceOID, []byte(name)), Name: name, Weight: w}
+	}
+	return origins
+}
+
+func TestKeysSpreadByWeight(t *testing.T) {
+	b := NewConsistentHashBalancer(0)
+	origins := weighted(200, 100, 100)
+
+	const keys = 30000
+	counts := make(map[*models.Origin]int)
+	for i := 0; i < keys; i++ {
+		origin, err := b.Select(context.Background(), fmt.Sprintf("client-%d", i), origins)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[origin]++
+	}
+
+	// With 80 virtual nodes for the lighter origins, their arcs of the ring
+	// vary by about a fifth around their weight's share
+	for _, origin := range origins {
+		want := float64(keys*origin.Weight) / 400
+		if got := float64(counts[origin]); math.Abs(got-want) > want*0.25 {
+			t.Errorf("%s (weight %d): got %v keys, want about %v", origin.Name, origin.Weight, got, want)
+		}
+	}
+}
+
+func TestWeightChangeMovesFewKeys(t *testing.T) {
+	b := NewConsistentHashBalancer(0)
+	origins := weighted(100, 100, 100, 100)
+
+	const keys = 5000
+	before := make([]*models.Origin, keys)
+	for i := range before {
+		before[i], _ = b.Select(context.Background(), fmt.Sprintf("client-%d", i), origins)
+	}
+
+	// Halving one origin's weight only moves keys away from it
+	origins[0].Weight = 50
+	moved := 0
+	for i, origin := range before {
+		after, _ := b.Select(context.Background(), fmt.Sprintf("client-%d", i), origins)
+		if after != origin {
+			if origin != origins[0] {
+				t.Fatalf("client-%d moved between unchanged origins", i)
+			}
+			moved++
+		}
+	}
+	if moved == 0 || moved > keys/4 {
+		t.Fatalf("expected some of the reweighted origin's keys to move, %d did", moved)
+	}
+}
+
+func TestBoundedLoads(t *testing.T) {
+	b := NewConsistentHashBalancer(1.25)
+	origins := weighted(100, 100, 100, 100)
+
+	// A single hot key spills over once its origin is at capacity
+	inFlight := 0
+	for i := 0; i < 400; i++ {
+		origin, err := b.Select(context.Background(), "hot", origins)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.IncrementConnections(origin.ID.String())
+		inFlight++
+
+		capacity := int(math.Ceil(1.25 * float64(inFlight) / float64(len(origins))))
+		for _, o := range origins {
+			if conns := b.connections[o.ID.String()]; conns > capacity {
+				t.Fatalf("%s has %d requests in flight, over the capacity of %d", o.Name, conns, capacity)
+			}
+		}
+	}
+
+	// Once the load drops, the key returns to its own origin
+	home, _ := NewConsistentHashBalancer(0).Select(context.Background(), "hot", origins)
+	for _, o := range origins {
+		for b.connections[o.ID.String()] > 0 {
+			b.DecrementConnections(o.ID.String())
+		}
+	}
+	if got, _ := b.Select(context.Background(), "hot", origins); got != home {
+		t.Fatalf("expected the key back on %s, got %s", home.Name, got.Name)
+	}
+}
+
+func TestUnboundedLoads(t *testing.T) {
+	b := NewConsistentHashBalancer(0)
+	origins := weighted(100, 100)
+
+	first, _ := b.Select(context.Background(), "hot", origins)
+	for i := 0; i < 100; i++ {
+		b.IncrementConnections(first.ID.String())
+		if got, _ := b.Select(context.Background(), "hot", origins); got != first {
+			t.Fatal("expected the key to stick to its origin regardless of load")
+		}
+	}
+}
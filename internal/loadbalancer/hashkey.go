@@ -0,0 +1,52 @@
+package loadbalancer
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Sources of the key consistent hashing maps requests by
+const (
+	HashKeyClientIP = "client_ip"
+	HashKeyHeader   = "header" // the request header named by the pool's hash key name
+	HashKeyCookie   = "cookie" // the cookie named by the pool's hash key name
+	HashKeyPath     = "path"
+	HashKeyQuery    = "query" // the query parameter named by the pool's hash key name
+)
+
+// HashKeySources lists the sources ValidateHashKey accepts
+var HashKeySources = []string{HashKeyClientIP, HashKeyHeader, HashKeyCookie, HashKeyPath, HashKeyQuery}
+
+// ValidateHashKey checks a hash key source and the name it needs. An empty
+// source means the client IP.
+func ValidateHashKey(source, name string) error {
+	switch source {
+	case "", HashKeyClientIP, HashKeyPath:
+		return nil
+	case HashKeyHeader, HashKeyCookie, HashKeyQuery:
+		if name == "" {
+			return fmt.Errorf("hash key source %q needs a name", source)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown hash key source %q", source)
+}
+
+// HashKey returns the key of r from source. It returns "" for the client IP
+// source, which the caller resolves, and when r lacks the header, cookie or
+// query parameter; callers then fall back to the client IP.
+func HashKey(r *http.Request, source, name string) string {
+	switch source {
+	case HashKeyHeader:
+		return r.Header.Get(name)
+	case HashKeyCookie:
+		if cookie, err := r.Cookie(name); err == nil {
+			return cookie.Value
+		}
+	case HashKeyPath:
+		return r.URL.Path
+	case HashKeyQuery:
+		return r.URL.Query().Get(name)
+	}
+	return ""
+}
@@ -1,139 +1,397 @@
 package loadbalancer
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/vantageedge/backend/internal/models"
 	"github.com/vantageedge/backend/pkg/logger"
 )
 
+// Types of active health checks
+const (
+	HealthCheckHTTP = "http"
+	HealthCheckTCP  = "tcp"
+	HealthCheckNone = "none"
+)
+
+// maxHealthBody bounds how much of a health check response is searched for
+// the expected body
+const maxHealthBody = 64 * 1024
+
+// HealthStore persists health check results
+type HealthStore interface {
+	UpdateHealth(ctx context.Context, id uuid.UUID, isHealthy bool) error
+}
+
+// HealthChecker actively checks the health of origins, each on its own
+// interval with its own settings. Sync replaces the set of checked origins
+// at runtime. Results are kept in memory for balancing and persisted to
+// the store.
 type HealthChecker struct {
-	mu             sync.RWMutex
-	logger         *logger.Logger
-	client         *http.Client
-	checkInterval  time.Duration
-	checkTimeout   time.Duration
-	healthStatuses map[string]bool // origin_id -> is_healthy
-	stopChan       chan struct{}
+	mu       sync.RWMutex
+	logger   *logger.Logger
+	client   *http.Client
+	store    HealthStore
+	interval time.Duration // for origins without an interval
+	timeout  time.Duration // for origins without a timeout
+	checks   map[uuid.UUID]*originCheck
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// originCheck is the checking goroutine of one origin and its state
+type originCheck struct {
+	origin    *models.Origin
+	stop      context.CancelFunc
+	healthy   bool
+	successes int // passing checks in a row
+	failures  int // failing checks in a row
 }
 
-func NewHealthChecker(log *logger.Logger, interval time.Duration) *HealthChecker {
+// NewHealthChecker returns a checker persisting results to store, which may
+// be nil. interval and timeout apply to origins that set none.
+func NewHealthChecker(store HealthStore, interval, timeout time.Duration, log *logger.Logger) *HealthChecker {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &HealthChecker{
-		logger:         log,
-		checkInterval:  interval,
-		checkTimeout:   5 * time.Second,
-		healthStatuses: make(map[string]bool),
-		stopChan:       make(chan struct{}),
+		logger:   log,
+		store:    store,
+		interval: interval,
+		timeout:  timeout,
+		checks:   make(map[uuid.UUID]*originCheck),
+		ctx:      ctx,
+		cancel:   cancel,
 		client: &http.Client{
-			Timeout: 5 * time.Second,
+			// Health checks judge the response they get, not where it points
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
 		},
 	}
 }
 
-// Start begins periodic health checks for origins
-func (hc *HealthChecker) Start(origins []*models.Origin) {
-	go hc.runHealthChecks(origins)
+// Sync makes origins the set of checked origins: checks start for new
+// origins, stop for origins no longer present, and restart with the new
+// settings for changed ones, keeping their health. Origins start out with
+// their persisted health, or healthy when never checked.
+func (hc *HealthChecker) Sync(origins []*models.Origin) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	if hc.ctx.Err() != nil {
+		return
+	}
+
+	seen := make(map[uuid.UUID]bool, len(origins))
+	for _, origin := range origins {
+		if seen[origin.ID] {
+			continue
+		}
+		seen[origin.ID] = true
+
+		check, ok := hc.checks[origin.ID]
+		if ok && sameHealthCheck(check.origin, origin) {
+			check.origin = origin
+			continue
+		}
+		if ok {
+			check.stop()
+		} else {
+			check = &originCheck{healthy: origin.IsHealthy || origin.LastHealthCheck == nil}
+			hc.checks[origin.ID] = check
+		}
+		check.origin = origin
+		check.successes, check.failures = 0, 0
+
+		if !checksHealth(origin) {
+			check.stop = func() {}
+			check.healthy = true
+			continue
+		}
+		ctx, stop := context.WithCancel(hc.ctx)
+		check.stop = stop
+		go hc.run(ctx, origin)
+	}
+
+	for id, check := range hc.checks {
+		if !seen[id] {
+			check.stop()
+			delete(hc.checks, id)
+		}
+	}
 }
 
-// Stop stops the health checker
+// Stop stops every check
 func (hc *HealthChecker) Stop() {
-	close(hc.stopChan)
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.cancel()
 }
 
-// IsHealthy checks if an origin is currently healthy
+// IsHealthy checks if an origin is currently healthy. Origins not checked
+// are assumed healthy.
 func (hc *HealthChecker) IsHealthy(originID string) bool {
+	id, err := uuid.Parse(originID)
+	if err != nil {
+		return false
+	}
 	hc.mu.RLock()
 	defer hc.mu.RUnlock()
-	status, exists := hc.healthStatuses[originID]
-	return exists && status
+	check, ok := hc.checks[id]
+	return !ok || check.healthy
+}
+
+// GetHealthyOrigins returns only healthy origins from the list. Origins not
+// checked are assumed healthy.
+func (hc *HealthChecker) GetHealthyOrigins(origins []*models.Origin) []*models.Origin {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+
+	var healthy []*models.Origin
+	for _, origin := range origins {
+		if check, ok := hc.checks[origin.ID]; !ok || check.healthy {
+			healthy = append(healthy, origin)
+		}
+	}
+
+	if len(healthy) == 0 {
+		return origins // Return all if none are healthy
+	}
+
+	return healthy
 }
 
 // CheckHealth performs a single health check on an origin
-func (hc *HealthChecker) CheckHealth(ctx context.Context, origin *models.Origin) bool {
+func (hc *HealthChecker) CheckHealth(ctx context.Context, origin *models.Origin) error {
+	ctx, cancel := context.WithTimeout(ctx, hc.timeoutOf(origin))
+	defer cancel()
+
+	switch healthCheckType(origin) {
+	case HealthCheckNone:
+		return nil
+	case HealthCheckTCP:
+		return checkTCP(ctx, origin)
+	}
 	if origin.HealthCheckPath == "" {
-		return true // No health check configured
+		return nil // No health check configured
 	}
 
-	url := origin.URL + origin.HealthCheckPath
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(origin.URL, "/")+origin.HealthCheckPath, nil)
 	if err != nil {
-		hc.logger.Error().Err(err).Str("origin_id", origin.ID.String()).Msg("Failed to create health check request")
-		return false
+		return err
 	}
-
 	resp, err := hc.client.Do(req)
 	if err != nil {
-		hc.logger.Warn().Err(err).Str("origin_id", origin.ID.String()).Msg("Health check failed")
-		return false
+		return err
 	}
 	defer resp.Body.Close()
 
-	isHealthy := resp.StatusCode >= 200 && resp.StatusCode < 300
-	return isHealthy
+	statuses, err := ParseStatusRanges(origin.ExpectedStatuses)
+	if err != nil {
+		return err
+	}
+	if !matchStatus(statuses, resp.StatusCode) {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if origin.BodyMatch != "" {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxHealthBody))
+		if err != nil {
+			return err
+		}
+		if !bytes.Contains(body, []byte(origin.BodyMatch)) {
+			return fmt.Errorf("body does not contain %q", origin.BodyMatch)
+		}
+	}
+	return nil
 }
 
-// runHealthChecks periodically checks the health of origins
-func (hc *HealthChecker) runHealthChecks(origins []*models.Origin) {
-	ticker := time.NewTicker(hc.checkInterval)
-	defer ticker.Stop()
-
-	// Run initial checks
-	for _, origin := range origins {
-		hc.checkOriginHealth(origin)
-	}
+// run checks an origin on its interval until ctx ends. The first check is
+// delayed by a random part of the interval to spread checks out.
+func (hc *HealthChecker) run(ctx context.Context, origin *models.Origin) {
+	interval := hc.intervalOf(origin)
+	timer := time.NewTimer(time.Duration(rand.Int63n(int64(interval))))
+	defer timer.Stop()
 
 	for {
 		select {
-		case <-hc.stopChan:
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		err := hc.CheckHealth(ctx, origin)
+		if ctx.Err() != nil {
 			return
-		case <-ticker.C:
-			for _, origin := range origins {
-				hc.checkOriginHealth(origin)
-			}
 		}
+		hc.record(ctx, origin, err)
+		timer.Reset(interval)
 	}
 }
 
-// checkOriginHealth checks health of a single origin and updates status
-func (hc *HealthChecker) checkOriginHealth(origin *models.Origin) {
-	ctx, cancel := context.WithTimeout(context.Background(), hc.checkTimeout)
-	defer cancel()
-
-	isHealthy := hc.CheckHealth(ctx, origin)
-
+// record applies a check result to the origin's health, which flips once
+// the healthy or unhealthy threshold is reached, and persists it
+func (hc *HealthChecker) record(ctx context.Context, origin *models.Origin, checkErr error) {
 	hc.mu.Lock()
-	defer hc.mu.Unlock()
-
-	oldStatus := hc.healthStatuses[origin.ID.String()]
-	hc.healthStatuses[origin.ID.String()] = isHealthy
+	check, ok := hc.checks[origin.ID]
+	if !ok || !sameHealthCheck(check.origin, origin) {
+		hc.mu.Unlock()
+		return
+	}
+	wasHealthy := check.healthy
+	if checkErr == nil {
+		check.successes++
+		check.failures = 0
+		if check.successes >= max(origin.HealthyThreshold, 1) {
+			check.healthy = true
+		}
+	} else {
+		check.failures++
+		check.successes = 0
+		if check.failures >= max(origin.UnhealthyThreshold, 1) {
+			check.healthy = false
+		}
+	}
+	healthy := check.healthy
+	hc.mu.Unlock()
 
-	if oldStatus != isHealthy {
-		if isHealthy {
+	if healthy != wasHealthy {
+		if healthy {
 			hc.logger.Info().Str("origin_id", origin.ID.String()).Msg("Origin became healthy")
 		} else {
-			hc.logger.Warn().Str("origin_id", origin.ID.String()).Msg("Origin became unhealthy")
+			hc.logger.Warn().Err(checkErr).Str("origin_id", origin.ID.String()).Msg("Origin became unhealthy")
+		}
+	}
+
+	if hc.store != nil {
+		storeCtx, cancel := context.WithTimeout(ctx, hc.timeoutOf(origin))
+		defer cancel()
+		if err := hc.store.UpdateHealth(storeCtx, origin.ID, healthy); err != nil {
+			hc.logger.Warn().Err(err).Str("origin_id", origin.ID.String()).Msg("Failed to persist origin health")
 		}
 	}
 }
 
-// GetHealthyOrigins returns only healthy origins from the list
-func (hc *HealthChecker) GetHealthyOrigins(origins []*models.Origin) []*models.Origin {
-	hc.mu.RLock()
-	defer hc.mu.RUnlock()
+func (hc *HealthChecker) intervalOf(origin *models.Origin) time.Duration {
+	if origin.HealthCheckInterval > 0 {
+		return time.Duration(origin.HealthCheckInterval) * time.Second
+	}
+	if hc.interval > 0 {
+		return hc.interval
+	}
+	return 30 * time.Second
+}
 
-	var healthy []*models.Origin
-	for _, origin := range origins {
-		if hc.healthStatuses[origin.ID.String()] || origin.HealthCheckPath == "" {
-			healthy = append(healthy, origin)
+func (hc *HealthChecker) timeoutOf(origin *models.Origin) time.Duration {
+	if origin.HealthCheckTimeout > 0 {
+		return time.Duration(origin.HealthCheckTimeout) * time.Second
+	}
+	if hc.timeout > 0 {
+		return hc.timeout
+	}
+	return 5 * time.Second
+}
+
+// checkTCP connects to the origin's host and port
+func checkTCP(ctx context.Context, origin *models.Origin) error {
+	u, err := url.Parse(origin.URL)
+	if err != nil {
+		return err
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
 		}
 	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
 
-	if len(healthy) == 0 {
-		return origins // Return all if none are healthy
+func healthCheckType(origin *models.Origin) string {
+	if origin.HealthCheckType == "" {
+		return HealthCheckHTTP
 	}
+	return origin.HealthCheckType
+}
 
-	return healthy
+// checksHealth reports whether an origin has an active check
+func checksHealth(origin *models.Origin) bool {
+	switch healthCheckType(origin) {
+	case HealthCheckHTTP:
+		return origin.HealthCheckPath != ""
+	case HealthCheckTCP:
+		return true
+	}
+	return false
+}
+
+// sameHealthCheck reports whether two versions of an origin are checked the
+// same way
+func sameHealthCheck(a, b *models.Origin) bool {
+	return a.URL == b.URL &&
+		healthCheckType(a) == healthCheckType(b) &&
+		a.HealthCheckPath == b.HealthCheckPath &&
+		a.HealthCheckInterval == b.HealthCheckInterval &&
+		a.HealthCheckTimeout == b.HealthCheckTimeout &&
+		a.HealthyThreshold == b.HealthyThreshold &&
+		a.UnhealthyThreshold == b.UnhealthyThreshold &&
+		a.ExpectedStatuses == b.ExpectedStatuses &&
+		a.BodyMatch == b.BodyMatch
+}
+
+// StatusRange is an inclusive range of HTTP status codes
+type StatusRange struct {
+	From, To int
+}
+
+// ParseStatusRanges parses a comma separated list of status codes and
+// ranges such as "200-299,304". An empty list means 200-299.
+func ParseStatusRanges(s string) ([]StatusRange, error) {
+	if strings.TrimSpace(s) == "" {
+		return []StatusRange{{From: 200, To: 299}}, nil
+	}
+
+	var ranges []StatusRange
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		from, to, isRange := strings.Cut(part, "-")
+		lo, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil {
+			return nil, fmt.Errorf("invalid status %q", part)
+		}
+		hi := lo
+		if isRange {
+			if hi, err = strconv.Atoi(strings.TrimSpace(to)); err != nil {
+				return nil, fmt.Errorf("invalid status range %q", part)
+			}
+		}
+		if lo < 100 || hi > 599 || lo > hi {
+			return nil, fmt.Errorf("invalid status range %q", part)
+		}
+		ranges = append(ranges, StatusRange{From: lo, To: hi})
+	}
+	return ranges, nil
+}
+
+func matchStatus(ranges []StatusRange, status int) bool {
+	for _, r := range ranges {
+		if status >= r.From && status <= r.To {
+			return true
+		}
+	}
+	return false
 }
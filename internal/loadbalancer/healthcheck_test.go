@@ -0,0 +1,216 @@
+package loadbalancer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/models"
+	"github.com/vantageedge/backend/pkg/logger"
+)
+
+type fakeHealthStore struct {
+	mu      sync.Mutex
+	updates map[uuid.UUID][]bool
+}
+
+func (s *fakeHealthStore) UpdateHealth(ctx context.Context, id uuid.UUID, isHealthy bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.updates == nil {
+		s.updates = make(map[uuid.UUID][]bool)
+	}
+	s.updates[id] = append(s.updates[id], isHealthy)
+	return nil
+}
+
+func (s *fakeHealthStore) get(id uuid.UUID) []bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]bool(nil), s.updates[id]...)
+}
+
+func newTestHealthChecker(store HealthStore) *HealthChecker {
+	return NewHealthChecker(store, time.Second, time.Second, logger.New("error", "json"))
+}
+
+func TestCheckHealthHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			w.Write([]byte(`{"status":"ok"}`))
+		case "/moved":
+			http.Redirect(w, r, "/ok", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	hc := newTestHealthChecker(nil)
+	defer hc.Stop()
+
+	tests := []struct {
+		name     string
+		path     string
+		statuses string
+		body     string
+		healthy  bool
+	}{
+		{name: "default statuses", path: "/ok", healthy: true},
+		{name: "unexpected status", path: "/down", healthy: false},
+		{name: "expected failure status", path: "/down", statuses: "200-299,503", healthy: true},
+		{name: "redirect not followed", path: "/moved", healthy: false},
+		{name: "redirect expected", path: "/moved", statuses: "302", healthy: true},
+		{name: "body matches", path: "/ok", body: `"status":"ok"`, healthy: true},
+		{name: "body does not match", path: "/ok", body: "degraded", healthy: false},
+		{name: "no path", path: "", healthy: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			origin := &models.Origin{ID: uuid.New(), URL: server.URL, HealthCheckPath: tt.path, ExpectedStatuses: tt.statuses, BodyMatch: tt.body}
+			err := hc.CheckHealth(context.Background(), origin)
+			if healthy := err == nil; healthy != tt.healthy {
+				t.Fatalf("expected healthy=%v, got error %v", tt.healthy, err)
+			}
+		})
+	}
+}
+
+func TestCheckHealthTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	hc := newTestHealthChecker(nil)
+	defer hc.Stop()
+	origin := &models.Origin{ID: uuid.New(), URL: "http://" + ln.Addr().String(), HealthCheckType: HealthCheckTCP}
+
+	if err := hc.CheckHealth(context.Background(), origin); err != nil {
+		t.Fatalf("expected the open port to pass, got %v", err)
+	}
+	ln.Close()
+	if err := hc.CheckHealth(context.Background(), origin); err == nil {
+		t.Fatal("expected the closed port to fail")
+	}
+}
+
+func TestHealthThresholds(t *testing.T) {
+	store := &fakeHealthStore{}
+	hc := newTestHealthChecker(store)
+	defer hc.Stop()
+
+	// Checks of type none never run, so results are fed in by hand
+	origin := &models.Origin{ID: uuid.New(), HealthCheckType: HealthCheckNone, HealthyThreshold: 2, UnhealthyThreshold: 3}
+	hc.Sync([]*models.Origin{origin})
+	failed := errors.New("down")
+
+	steps := []struct {
+		err     error
+		healthy bool
+	}{
+		{failed, true},
+		{failed, true},
+		{nil, true}, // a pass resets the failures
+		{failed, true},
+		{failed, true},
+		{failed, false},
+		{nil, false},
+		{nil, true},
+	}
+	for i, step := range steps {
+		hc.record(context.Background(), origin, step.err)
+		if got := hc.IsHealthy(origin.ID.String()); got != step.healthy {
+			t.Fatalf("step %d: expected healthy=%v, got %v", i, step.healthy, got)
+		}
+	}
+	if updates := store.get(origin.ID); len(updates) != len(steps) || updates[5] || !updates[7] {
+		t.Fatalf("expected every result persisted, got %v", updates)
+	}
+}
+
+func TestHealthCheckerSync(t *testing.T) {
+	var mu sync.Mutex
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	store := &fakeHealthStore{}
+	hc := newTestHealthChecker(store)
+	defer hc.Stop()
+
+	checked := &models.Origin{ID: uuid.New(), URL: server.URL, HealthCheckPath: "/health", HealthCheckInterval: 1, HealthyThreshold: 1, UnhealthyThreshold: 1}
+	unchecked := &models.Origin{ID: uuid.New(), URL: server.URL, HealthCheckType: HealthCheckNone}
+	hc.Sync([]*models.Origin{checked, unchecked})
+
+	waitFor(t, func() bool { return !hc.IsHealthy(checked.ID.String()) })
+	if healthy := hc.GetHealthyOrigins([]*models.Origin{checked, unchecked}); len(healthy) != 1 || healthy[0] != unchecked {
+		t.Fatalf("expected only the unchecked origin to be healthy, got %d", len(healthy))
+	}
+
+	// Expecting the failing status brings the origin back
+	changed := *checked
+	changed.ExpectedStatuses = "503"
+	hc.Sync([]*models.Origin{&changed, unchecked})
+	waitFor(t, func() bool { return hc.IsHealthy(checked.ID.String()) })
+
+	// Dropped origins are no longer checked
+	hc.Sync([]*models.Origin{unchecked})
+	n := len(store.get(checked.ID))
+	time.Sleep(1500 * time.Millisecond)
+	if got := len(store.get(checked.ID)); got != n {
+		t.Fatalf("expected checks to stop for a dropped origin, got %d more", got-n)
+	}
+	if len(store.get(unchecked.ID)) != 0 {
+		t.Fatal("expected no results for an origin without checks")
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the health checker")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestParseStatusRanges(t *testing.T) {
+	ranges, err := ParseStatusRanges("200-299, 304")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for status, want := range map[int]bool{200: true, 299: true, 304: true, 300: false, 404: false} {
+		if got := matchStatus(ranges, status); got != want {
+			t.Errorf("status %d: expected %v", status, want)
+		}
+	}
+
+	for _, s := range []string{"abc", "200-", "299-200", "99", "200-600"} {
+		if _, err := ParseStatusRanges(s); err == nil {
+			t.Errorf("expected %q to be rejected", s)
+		}
+	}
+}
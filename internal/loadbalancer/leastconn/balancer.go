@@ -47,6 +47,11 @@ func (b *LeastConnBalancer) SelectOrigin(ctx context.Context, origins []*models.
 	return selected, nil
 }
 
+// Select implements loadbalancer.Balancer; least connections ignores key
+func (b *LeastConnBalancer) Select(ctx context.Context, key string, origins []*models.Origin) (*models.Origin, error) {
+	return b.SelectOrigin(ctx, origins)
+}
+
 // IncrementConnections increments the connection count for an origin
 func (b *LeastConnBalancer) IncrementConnections(originID string) {
 	b.mu.Lock()
@@ -0,0 +1,212 @@
+package outlier
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/errorrate"
+	"github.com/vantageedge/backend/internal/models"
+)
+
+// Reasons an origin is ejected for
+const (
+	ReasonConsecutiveFailures = "consecutive_failures"
+	ReasonErrorRate           = "error_rate"
+)
+
+// Settings tune outlier detection. An origin is ejected after
+// ConsecutiveFailures failures in a row, or when at least ErrorRatePercent
+// of its requests in Window failed once Window holds MinRequests. Zero
+// disables either rule.
+//
+// Ejections last BaseEjectionTime, doubled for each ejection in a row up to
+// MaxEjectionTime; an origin that stays in for MaxEjectionTime starts over.
+// At most MaxEjectionPercent of a pool's origins are left out at once, and
+// never all of them.
+type Settings struct {
+	ConsecutiveFailures int
+	ErrorRatePercent    int
+	MinRequests         int
+	Window              time.Duration
+	BaseEjectionTime    time.Duration
+	MaxEjectionTime     time.Duration
+	MaxEjectionPercent  int
+}
+
+// Event reports an origin being ejected or let back in
+type Event struct {
+	OriginID  uuid.UUID
+	Ejected   bool
+	Reason    string        // why the origin was ejected
+	Duration  time.Duration // how long it is ejected for
+	Ejections int           // ejections in a row, this one included
+}
+
+// Detector ejects origins that fail live traffic from load balancing, in
+// addition to the active health checks. It is safe for concurrent use.
+type Detector struct {
+	mu       sync.Mutex
+	settings Settings
+	onEvent  func(Event)
+	now      func() time.Time
+	origins  map[uuid.UUID]*state
+}
+
+type state struct {
+	consecutive  int
+	window       *errorrate.Window
+	ejectedUntil time.Time // zero while not ejected
+	ejections    int
+	releasedAt   time.Time
+}
+
+// NewDetector returns a detector calling onEvent, if set, on every ejection
+// and return. onEvent is called with the detector locked and must not use
+// it.
+func NewDetector(settings Settings, onEvent func(Event)) *Detector {
+	if settings.Window <= 0 {
+		settings.Window = 10 * time.Second
+	}
+	if settings.MaxEjectionTime < settings.BaseEjectionTime {
+		settings.MaxEjectionTime = settings.BaseEjectionTime
+	}
+	return &Detector{
+		settings: settings,
+		onEvent:  onEvent,
+		now:      time.Now,
+		origins:  make(map[uuid.UUID]*state),
+	}
+}
+
+// Record counts the result of a request to an origin: failed is a
+// connection failure or 5xx response
+func (d *Detector) Record(originID uuid.UUID, failed bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	s, ok := d.origins[originID]
+	if !ok {
+		s = &state{window: errorrate.NewWindow(d.settings.Window)}
+		d.origins[originID] = s
+	}
+	if d.ejected(originID, s, now) {
+		// Requests still reaching an ejected origin carry no news
+		return
+	}
+
+	s.window.Add(now, failed)
+	if !failed {
+		s.consecutive = 0
+		return
+	}
+	s.consecutive++
+	if d.settings.ConsecutiveFailures > 0 && s.consecutive >= d.settings.ConsecutiveFailures {
+		d.eject(originID, s, now, ReasonConsecutiveFailures)
+		return
+	}
+	if s.window.Exceeds(now, d.settings.ErrorRatePercent, d.settings.MinRequests) {
+		d.eject(originID, s, now, ReasonErrorRate)
+	}
+}
+
+// Filter returns the origins of a pool that are not ejected. When more than
+// MaxEjectionPercent of them are ejected, the ones due back soonest are kept,
+// and at least one origin always is.
+func (d *Detector) Filter(origins []*models.Origin) []*models.Origin {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	var ejected []*models.Origin
+	for _, origin := range origins {
+		if s, ok := d.origins[origin.ID]; ok && d.ejected(origin.ID, s, now) {
+			ejected = append(ejected, origin)
+		}
+	}
+	if len(ejected) == 0 {
+		return origins
+	}
+
+	allowed := min(len(origins)*d.settings.MaxEjectionPercent/100, len(origins)-1)
+	if allowed <= 0 {
+		return origins
+	}
+	if len(ejected) > allowed {
+		// Leave out the origins ejected for longest
+		sort.SliceStable(ejected, func(i, j int) bool {
+			return d.origins[ejected[i].ID].ejectedUntil.After(d.origins[ejected[j].ID].ejectedUntil)
+		})
+		ejected = ejected[:allowed]
+	}
+
+	out := make(map[uuid.UUID]bool, len(ejected))
+	for _, origin := range ejected {
+		out[origin.ID] = true
+	}
+	kept := make([]*models.Origin, 0, len(origins)-len(ejected))
+	for _, origin := range origins {
+		if !out[origin.ID] {
+			kept = append(kept, origin)
+		}
+	}
+	return kept
+}
+
+// IsEjected reports whether an origin is currently ejected
+func (d *Detector) IsEjected(originID uuid.UUID) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s, ok := d.origins[originID]
+	return ok && d.ejected(originID, s, d.now())
+}
+
+// ejected reports whether an origin is ejected, letting it back in first if
+// its time is over; d.mu must be held
+func (d *Detector) ejected(originID uuid.UUID, s *state, now time.Time) bool {
+	d.release(originID, s, now)
+	return !s.ejectedUntil.IsZero()
+}
+
+// eject takes an origin out for its ejection time; d.mu must be held
+func (d *Detector) eject(originID uuid.UUID, s *state, now time.Time, reason string) {
+	if !s.releasedAt.IsZero() && now.Sub(s.releasedAt) >= d.settings.MaxEjectionTime {
+		s.ejections = 0
+	}
+	s.ejections++
+
+	duration := d.settings.BaseEjectionTime
+	for i := 1; i < s.ejections && duration < d.settings.MaxEjectionTime; i++ {
+		duration *= 2
+	}
+	duration = min(duration, d.settings.MaxEjectionTime)
+
+	s.ejectedUntil = now.Add(duration)
+	d.reset(s)
+	if d.onEvent != nil {
+		d.onEvent(Event{OriginID: originID, Ejected: true, Reason: reason, Duration: duration, Ejections: s.ejections})
+	}
+}
+
+// release lets an origin back in once its ejection time is over; d.mu must
+// be held
+func (d *Detector) release(originID uuid.UUID, s *state, now time.Time) {
+	if s.ejectedUntil.IsZero() || now.Before(s.ejectedUntil) {
+		return
+	}
+	s.ejectedUntil = time.Time{}
+	s.releasedAt = now
+	d.reset(s)
+	if d.onEvent != nil {
+		d.onEvent(Event{OriginID: originID, Ejections: s.ejections})
+	}
+}
+
+// reset clears an origin's counters; d.mu must be held
+func (d *Detector) reset(s *state) {
+	s.consecutive = 0
+	s.window.Reset()
+}
@@ -0,0 +1,130 @@
+package outlier
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/models"
+)
+
+func newTestDetector(settings Settings) (*Detector, *time.Time, *[]Event) {
+	now := time.Unix(1000, 0)
+	var events []Event
+	d := NewDetector(settings, func(e Event) { events = append(events, e) })
+	d.now = func() time.Time { return now }
+	return d, &now, &events
+}
+
+func newOrigins(n int) []*models.Origin {
+	origins := make([]*models.Origin, n)
+	for i := range origins {
+		origins[i] = &models.Origin{ID: uuid.New()}
+	}
+	return origins
+}
+
+func fail(d *Detector, id uuid.UUID, n int) {
+	for i := 0; i < n; i++ {
+		d.Record(id, true)
+	}
+}
+
+func TestEjectsAfterConsecutiveFailures(t *testing.T) {
+	d, now, events := newTestDetector(Settings{ConsecutiveFailures: 3, BaseEjectionTime: 10 * time.Second, MaxEjectionPercent: 100})
+	origins := newOrigins(2)
+	id := origins[0].ID
+
+	fail(d, id, 2)
+	d.Record(id, false)
+	fail(d, id, 2)
+	if d.IsEjected(id) {
+		t.Fatal("expected a success to reset the consecutive failures")
+	}
+	fail(d, id, 1)
+	if !d.IsEjected(id) {
+		t.Fatal("expected the origin to be ejected")
+	}
+	if kept := d.Filter(origins); len(kept) != 1 || kept[0] != origins[1] {
+		t.Fatalf("expected the ejected origin to be left out, got %d origins", len(kept))
+	}
+
+	*now = now.Add(10 * time.Second)
+	if d.IsEjected(id) {
+		t.Fatal("expected the origin back once its ejection time is over")
+	}
+	if len(*events) != 2 || !(*events)[0].Ejected || (*events)[0].Reason != ReasonConsecutiveFailures || (*events)[1].Ejected {
+		t.Fatalf("expected an ejection and a return event, got %+v", *events)
+	}
+}
+
+func TestEjectsOnErrorRate(t *testing.T) {
+	d, _, events := newTestDetector(Settings{ErrorRatePercent: 50, MinRequests: 10, Window: 10 * time.Second, BaseEjectionTime: time.Minute, MaxEjectionPercent: 100})
+	id := uuid.New()
+
+	for i := 0; i < 9; i++ {
+		d.Record(id, i%2 == 0)
+	}
+	if d.IsEjected(id) {
+		t.Fatal("expected no ejection below the minimum number of requests")
+	}
+	d.Record(id, false)
+	d.Record(id, true)
+	if !d.IsEjected(id) || (*events)[0].Reason != ReasonErrorRate {
+		t.Fatalf("expected an error rate ejection, got %+v", *events)
+	}
+}
+
+func TestEjectionTimeGrowsExponentially(t *testing.T) {
+	d, now, events := newTestDetector(Settings{ConsecutiveFailures: 1, BaseEjectionTime: 10 * time.Second, MaxEjectionTime: 35 * time.Second, MaxEjectionPercent: 100})
+	id := uuid.New()
+
+	want := []time.Duration{10 * time.Second, 20 * time.Second, 35 * time.Second, 35 * time.Second}
+	for i, duration := range want {
+		fail(d, id, 1)
+		e := (*events)[len(*events)-1]
+		if !e.Ejected || e.Duration != duration || e.Ejections != i+1 {
+			t.Fatalf("ejection %d: got %+v, want %v", i+1, e, duration)
+		}
+		*now = now.Add(duration)
+		d.IsEjected(id)
+	}
+
+	// Staying in for the maximum ejection time starts over
+	*now = now.Add(35 * time.Second)
+	fail(d, id, 1)
+	if e := (*events)[len(*events)-1]; e.Duration != 10*time.Second || e.Ejections != 1 {
+		t.Fatalf("expected the ejection time to start over, got %+v", e)
+	}
+}
+
+func TestMaxEjectionPercent(t *testing.T) {
+	d, now, _ := newTestDetector(Settings{ConsecutiveFailures: 1, BaseEjectionTime: 10 * time.Second, MaxEjectionPercent: 50})
+	origins := newOrigins(4)
+
+	fail(d, origins[0].ID, 1)
+	*now = now.Add(time.Second)
+	fail(d, origins[1].ID, 1)
+	*now = now.Add(time.Second)
+	fail(d, origins[2].ID, 1)
+
+	// Only two of four may be left out; the one due back first is kept
+	kept := d.Filter(origins)
+	if len(kept) != 2 || kept[0] != origins[0] || kept[1] != origins[3] {
+		t.Fatalf("expected origins 0 and 3 to be kept, got %d origins", len(kept))
+	}
+}
+
+func TestNeverEmptiesPool(t *testing.T) {
+	d, _, _ := newTestDetector(Settings{ConsecutiveFailures: 1, BaseEjectionTime: 10 * time.Second, MaxEjectionPercent: 100})
+	origins := newOrigins(2)
+	fail(d, origins[0].ID, 1)
+	fail(d, origins[1].ID, 1)
+
+	if kept := d.Filter(origins); len(kept) != 1 {
+		t.Fatalf("expected one origin to be kept, got %d", len(kept))
+	}
+	if kept := d.Filter(origins[:1]); len(kept) != 1 {
+		t.Fatal("expected the only origin of a pool to be kept")
+	}
+}
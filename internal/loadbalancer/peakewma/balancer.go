@@ -0,0 +1,168 @@
+package peakewma
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/vantageedge/backend/internal/models"
+)
+
+const (
+	// DefaultDecay is how fast latency observations are forgotten when none
+	// is given
+	DefaultDecay = 10 * time.Second
+
+	// failurePenalty is the latency recorded for failed requests at least,
+	// so an origin failing fast does not look like the fastest one
+	failurePenalty = time.Second
+
+	// pruneEvery is how many selections pass between drops of origins that
+	// have been idle for longer than staleAfter
+	pruneEvery = 1024
+	staleAfter = 5 * time.Minute
+)
+
+// PeakEWMABalancer picks origins by power of two choices: it draws two
+// candidates at random and takes the one with the lower cost, its peak EWMA
+// latency times its requests in flight plus one. The moving average jumps
+// to latency spikes and decays towards faster responses, so traffic moves
+// away from an origin as soon as it slows down. Idle origins decay towards
+// zero cost and get probed again.
+//
+// Latency and requests in flight come from the gateway wrapping each round
+// trip to an origin (see ObserveLatency and the connection counters).
+type PeakEWMABalancer struct {
+	mu         sync.Mutex
+	decay      time.Duration
+	peers      map[string]*peer // origin_id -> state
+	selections uint64
+	now        func() time.Time
+	intn       func(n int) int
+}
+
+type peer struct {
+	ewma    float64 // nanoseconds
+	stamp   time.Time
+	pending int
+}
+
+// NewPeakEWMABalancer returns a balancer whose latency average decays with
+// the time constant decay
+func NewPeakEWMABalancer(decay time.Duration) *PeakEWMABalancer {
+	if decay <= 0 {
+		decay = DefaultDecay
+	}
+	return &PeakEWMABalancer{
+		decay: decay,
+		peers: make(map[string]*peer),
+		now:   time.Now,
+		intn:  rand.Intn,
+	}
+}
+
+// SelectOrigin picks the cheaper of two random origins
+func (b *PeakEWMABalancer) SelectOrigin(ctx context.Context, origins []*models.Origin) (*models.Origin, error) {
+	switch len(origins) {
+	case 0:
+		return nil, fmt.Errorf("no origins available")
+	case 1:
+		return origins[0], nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.selections++
+	if b.selections%pruneEvery == 0 {
+		for id, p := range b.peers {
+			if p.pending == 0 && now.Sub(p.stamp) > staleAfter {
+				delete(b.peers, id)
+			}
+		}
+	}
+
+	i := b.intn(len(origins))
+	j := b.intn(len(origins) - 1)
+	if j >= i {
+		j++
+	}
+	a, c := origins[i], origins[j]
+	if b.cost(c.ID.String(), now) < b.cost(a.ID.String(), now) {
+		return c, nil
+	}
+	return a, nil
+}
+
+// Select implements loadbalancer.Balancer; peak EWMA ignores key
+func (b *PeakEWMABalancer) Select(ctx context.Context, key string, origins []*models.Origin) (*models.Origin, error) {
+	return b.SelectOrigin(ctx, origins)
+}
+
+// IncrementConnections counts a request in flight to an origin
+func (b *PeakEWMABalancer) IncrementConnections(originID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.peer(originID).pending++
+}
+
+// DecrementConnections ends a request in flight to an origin
+func (b *PeakEWMABalancer) DecrementConnections(originID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if p := b.peer(originID); p.pending > 0 {
+		p.pending--
+	}
+}
+
+// ObserveLatency feeds the time an origin took to answer into its moving
+// average. Latencies above the average replace it; lower ones are blended in
+// with a weight growing with the time since the last observation. Failed
+// requests count as taking at least failurePenalty.
+func (b *PeakEWMABalancer) ObserveLatency(originID string, rtt time.Duration, err error) {
+	if err != nil && rtt < failurePenalty {
+		rtt = failurePenalty
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	p := b.peer(originID)
+	if sample := float64(rtt); sample > p.ewma {
+		p.ewma = sample
+	} else {
+		w := math.Exp(-float64(now.Sub(p.stamp)) / float64(b.decay))
+		p.ewma = p.ewma*w + sample*(1-w)
+	}
+	p.stamp = now
+}
+
+// cost returns the load of an origin: its latency average, decayed for the
+// time without observations, times its requests in flight plus one. Idle
+// origins never observed cost nothing so they get tried, while those with
+// requests in flight but no answer yet count as failing.
+func (b *PeakEWMABalancer) cost(originID string, now time.Time) float64 {
+	p, ok := b.peers[originID]
+	if !ok {
+		return 0
+	}
+	ewma := p.ewma * math.Exp(-float64(now.Sub(p.stamp))/float64(b.decay))
+	if p.ewma == 0 && p.pending > 0 {
+		ewma = float64(failurePenalty)
+	}
+	return ewma * float64(p.pending+1)
+}
+
+func (b *PeakEWMABalancer) peer(originID string) *peer {
+	p, ok := b.peers[originID]
+	if !ok {
+		p = &peer{stamp: b.now()}
+		b.peers[originID] = p
+	}
+	return p
+}
@@ -0,0 +1,114 @@
+package peakewma
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/models"
+)
+
+// newTestBalancer returns a balancer on a fake clock
+func newTestBalancer() (*PeakEWMABalancer, *time.Time) {
+	now := time.Unix(0, 0)
+	b := NewPeakEWMABalancer(10 * time.Second)
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+func newOrigins(n int) []*models.Origin {
+	origins := make([]*models.Origin, n)
+	for i := range origins {
+		origins[i] = &models.Origin{ID: uuid.New(), Name: fmt.Sprintf("origin-%d", i)}
+	}
+	return origins
+}
+
+func countPicks(t *testing.T, b *PeakEWMABalancer, origins []*models.Origin, n int) map[*models.Origin]int {
+	t.Helper()
+	counts := make(map[*models.Origin]int)
+	for i := 0; i < n; i++ {
+		origin, err := b.Select(context.Background(), "", origins)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[origin]++
+	}
+	return counts
+}
+
+func TestSlowOriginAvoided(t *testing.T) {
+	b, _ := newTestBalancer()
+	origins := newOrigins(3)
+	b.ObserveLatency(origins[0].ID.String(), 10*time.Millisecond, nil)
+	b.ObserveLatency(origins[1].ID.String(), 12*time.Millisecond, nil)
+	b.ObserveLatency(origins[2].ID.String(), 300*time.Millisecond, nil)
+
+	// Two distinct candidates are drawn, so the slowest of three never wins
+	counts := countPicks(t, b, origins, 3000)
+	if counts[origins[2]] != 0 {
+		t.Fatalf("expected the slow origin to be avoided, it got %d picks", counts[origins[2]])
+	}
+	if counts[origins[0]] < 1500 {
+		t.Fatalf("expected the fastest origin to get most picks, got %d", counts[origins[0]])
+	}
+}
+
+func TestPeakLatencyDecays(t *testing.T) {
+	b, now := newTestBalancer()
+	origins := newOrigins(2)
+	fast, slow := origins[0].ID.String(), origins[1].ID.String()
+	b.ObserveLatency(fast, 50*time.Millisecond, nil)
+	b.ObserveLatency(slow, 10*time.Millisecond, nil)
+
+	// A spike replaces the average at once
+	b.ObserveLatency(slow, 500*time.Millisecond, nil)
+	if got, _ := b.Select(context.Background(), "", origins); got != origins[0] {
+		t.Fatal("expected the spike to move traffic away at once")
+	}
+
+	// Faster responses pull it back down over time
+	for i := 0; i < 20; i++ {
+		*now = now.Add(2 * time.Second)
+		b.ObserveLatency(fast, 50*time.Millisecond, nil)
+		b.ObserveLatency(slow, 10*time.Millisecond, nil)
+	}
+	if got, _ := b.Select(context.Background(), "", origins); got != origins[1] {
+		t.Fatal("expected the recovered origin to be preferred again")
+	}
+}
+
+func TestRequestsInFlightRaiseCost(t *testing.T) {
+	b, _ := newTestBalancer()
+	origins := newOrigins(2)
+	a, c := origins[0].ID.String(), origins[1].ID.String()
+	b.ObserveLatency(a, 10*time.Millisecond, nil)
+	b.ObserveLatency(c, 30*time.Millisecond, nil)
+
+	for i := 0; i < 5; i++ {
+		b.IncrementConnections(a)
+	}
+	if got, _ := b.Select(context.Background(), "", origins); got != origins[1] {
+		t.Fatal("expected the busy origin to cost more than the slower idle one")
+	}
+	for i := 0; i < 5; i++ {
+		b.DecrementConnections(a)
+	}
+	if got, _ := b.Select(context.Background(), "", origins); got != origins[0] {
+		t.Fatal("expected the faster origin once idle")
+	}
+}
+
+func TestFailuresArePenalised(t *testing.T) {
+	b, _ := newTestBalancer()
+	origins := newOrigins(2)
+	b.ObserveLatency(origins[0].ID.String(), time.Millisecond, errors.New("connection refused"))
+	b.ObserveLatency(origins[1].ID.String(), 200*time.Millisecond, nil)
+
+	if got, _ := b.Select(context.Background(), "", origins); got != origins[1] {
+		t.Fatal("expected an origin failing fast not to look fastest")
+	}
+}
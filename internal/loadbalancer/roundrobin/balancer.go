@@ -8,16 +8,35 @@ import (
 	"github.com/vantageedge/backend/internal/models"
 )
 
+// pruneEvery is how many selections an origin may go without being a
+// candidate before its state is dropped
+const pruneEvery = 1024
+
+// RoundRobinBalancer spreads requests over origins in proportion to their
+// weights with nginx's smooth weighted round robin: every selection adds each
+// candidate's weight to its current weight, picks the highest and subtracts
+// the candidates' total weight from it. Heavier origins are picked more often
+// without being picked in bursts.
+//
+// Current weights are kept per origin, so weight changes and origins joining
+// or leaving the candidates shift the distribution without restarting it.
 type RoundRobinBalancer struct {
-	mu      sync.Mutex
-	counter int
+	mu         sync.Mutex
+	selections uint64
+	peers      map[string]*peer // origin_id -> state
+}
+
+type peer struct {
+	current  int
+	lastSeen uint64
 }
 
 func NewRoundRobinBalancer() *RoundRobinBalancer {
-	return &RoundRobinBalancer{counter: 0}
+	return &RoundRobinBalancer{peers: make(map[string]*peer)}
 }
 
-// SelectOrigin selects an origin using round-robin algorithm
+// SelectOrigin selects an origin using smooth weighted round robin. Origins
+// without a positive weight count as weight 1.
 func (b *RoundRobinBalancer) SelectOrigin(ctx context.Context, origins []*models.Origin) (*models.Origin, error) {
 	if len(origins) == 0 {
 		return nil, fmt.Errorf("no origins available")
@@ -26,16 +45,57 @@ func (b *RoundRobinBalancer) SelectOrigin(ctx context.Context, origins []*models
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	// Select origin by rotating through the list
-	selected := origins[b.counter%len(origins)]
-	b.counter++
+	b.selections++
+
+	var selected *models.Origin
+	var best *peer
+	total := 0
+	for _, origin := range origins {
+		weight := effectiveWeight(origin)
+		total += weight
+
+		id := origin.ID.String()
+		p, ok := b.peers[id]
+		if !ok {
+			p = &peer{}
+			b.peers[id] = p
+		}
+		p.current += weight
+		p.lastSeen = b.selections
+
+		if best == nil || p.current > best.current {
+			selected, best = origin, p
+		}
+	}
+	best.current -= total
+
+	if b.selections%pruneEvery == 0 {
+		for id, p := range b.peers {
+			if b.selections-p.lastSeen >= pruneEvery {
+				delete(b.peers, id)
+			}
+		}
+	}
 
 	return selected, nil
 }
 
-// Reset resets the counter
+// Select implements loadbalancer.Balancer; round robin ignores key
+func (b *RoundRobinBalancer) Select(ctx context.Context, key string, origins []*models.Origin) (*models.Origin, error) {
+	return b.SelectOrigin(ctx, origins)
+}
+
+// Reset forgets the current weights
 func (b *RoundRobinBalancer) Reset() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	b.counter = 0
+	b.selections = 0
+	b.peers = make(map[string]*peer)
+}
+
+func effectiveWeight(origin *models.Origin) int {
+	if origin.Weight < 1 {
+		return 1
+	}
+	return origin.Weight
 }
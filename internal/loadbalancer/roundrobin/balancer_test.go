@@ -0,0 +1,123 @@
+package roundrobin
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/models"
+)
+
+func weighted(weights ...int) []*models.Origin {
+	origins := make([]*models.Origin, len(weights))
+	for i, w := range weights {
+		origins[i] = &models.Origin{ID: uuid.New(), Name: fmt.Sprintf("origin-%d", i), Weight: w}
+	}
+	return origins
+}
+
+// count selects n times among origins and counts the picks of each origin
+func count(t *testing.T, b *RoundRobinBalancer, origins []*models.Origin, n int) map[*models.Origin]int {
+	t.Helper()
+	counts := make(map[*models.Origin]int)
+	for i := 0; i < n; i++ {
+		origin, err := b.SelectOrigin(context.Background(), origins)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[origin]++
+	}
+	return counts
+}
+
+// assertShare checks that every origin got its weight's share of n picks,
+// give or take tolerance
+func assertShare(t *testing.T, counts map[*models.Origin]int, origins []*models.Origin, n, tolerance int) {
+	t.Helper()
+	total := 0
+	for _, origin := range origins {
+		total += origin.Weight
+	}
+	for _, origin := range origins {
+		want := n * origin.Weight / total
+		if got := counts[origin]; got < want-tolerance || got > want+tolerance {
+			t.Errorf("%s (weight %d): got %d picks, want %d±%d", origin.Name, origin.Weight, got, want, tolerance)
+		}
+	}
+}
+
+func TestSmoothWeightedSequence(t *testing.T) {
+	b := NewRoundRobinBalancer()
+	origins := weighted(5, 1, 1)
+	a, c, d := origins[0], origins[1], origins[2]
+
+	// The heavy origin is interleaved with the others rather than picked
+	// five times in a row
+	want := []*models.Origin{a, a, c, a, d, a, a}
+	for round := 0; round < 3; round++ {
+		for i, expected := range want {
+			got, _ := b.SelectOrigin(context.Background(), origins)
+			if got != expected {
+				t.Fatalf("round %d, pick %d: got %s, want %s", round, i, got.Name, expected.Name)
+			}
+		}
+	}
+}
+
+func TestWeightedDistribution(t *testing.T) {
+	b := NewRoundRobinBalancer()
+	origins := weighted(100, 50, 25, 25)
+
+	counts := count(t, b, origins, 20000)
+	assertShare(t, counts, origins, 20000, 0)
+}
+
+func TestEqualWeightsRotate(t *testing.T) {
+	b := NewRoundRobinBalancer()
+	origins := weighted(0, 0, 0)
+
+	for i := 0; i < 9; i++ {
+		got, _ := b.SelectOrigin(context.Background(), origins)
+		if got != origins[i%3] {
+			t.Fatalf("pick %d: got %s, want %s", i, got.Name, origins[i%3].Name)
+		}
+	}
+}
+
+func TestWeightChangeKeepsDistribution(t *testing.T) {
+	b := NewRoundRobinBalancer()
+	origins := weighted(1, 1)
+	count(t, b, origins, 101)
+
+	origins[0].Weight = 3
+	counts := count(t, b, origins, 4000)
+	assertShare(t, counts, origins, 4000, 2)
+}
+
+func TestOriginsJoiningAndLeaving(t *testing.T) {
+	b := NewRoundRobinBalancer()
+	origins := weighted(2, 1, 1)
+	count(t, b, origins[:2], 1001)
+
+	counts := count(t, b, origins, 8000)
+	assertShare(t, counts, origins, 8000, 2)
+
+	remaining := []*models.Origin{origins[0], origins[2]}
+	counts = count(t, b, remaining, 6000)
+	assertShare(t, counts, remaining, 6000, 2)
+}
+
+func TestPrunesOriginsThatLeft(t *testing.T) {
+	b := NewRoundRobinBalancer()
+	origins := weighted(1, 1)
+	count(t, b, origins, 10)
+	count(t, b, origins[:1], 2*pruneEvery)
+
+	if _, ok := b.peers[origins[1].ID.String()]; ok {
+		t.Fatal("expected the state of the origin that left to be dropped")
+	}
+	if len(b.peers) != 1 {
+		t.Fatalf("expected 1 tracked origin, got %d", len(b.peers))
+	}
+}
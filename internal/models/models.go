@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -37,14 +38,24 @@ type User struct {
 	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
 }
 
-// Origin represents a backend service/API
+// Origin represents a backend service/API. HealthCheckType is http (a GET
+// of HealthCheckPath, if set, answered with one of ExpectedStatuses and
+// containing BodyMatch), tcp (a connection) or none. An origin turns healthy
+// after HealthyThreshold passing checks in a row and unhealthy after
+// UnhealthyThreshold failing ones.
 type Origin struct {
 	ID                  uuid.UUID  `json:"id" db:"id"`
 	TenantID            uuid.UUID  `json:"tenant_id" db:"tenant_id"`
 	Name                string     `json:"name" db:"name"`
 	URL                 string     `json:"url" db:"url"`
+	HealthCheckType     string     `json:"health_check_type" db:"health_check_type"`
 	HealthCheckPath     string     `json:"health_check_path" db:"health_check_path"`
 	HealthCheckInterval int        `json:"health_check_interval" db:"health_check_interval"`
+	HealthCheckTimeout  int        `json:"health_check_timeout_seconds" db:"health_check_timeout_seconds"`
+	HealthyThreshold    int        `json:"healthy_threshold" db:"healthy_threshold"`
+	UnhealthyThreshold  int        `json:"unhealthy_threshold" db:"unhealthy_threshold"`
+	ExpectedStatuses    string     `json:"health_check_expected_statuses" db:"health_check_expected_statuses"`
+	BodyMatch           string     `json:"health_check_body_match" db:"health_check_body_match"`
 	TimeoutSeconds      int        `json:"timeout_seconds" db:"timeout_seconds"`
 	MaxRetries          int        `json:"max_retries" db:"max_retries"`
 	Weight              int        `json:"weight" db:"weight"`
@@ -55,11 +66,32 @@ type Origin struct {
 	UpdatedAt           time.Time  `json:"updated_at" db:"updated_at"`
 }
 
+// UpstreamPool is a set of origins whose requests are spread by a load
+// balancing strategy. An empty Strategy uses the gateway's default.
+//
+// Consistent hashing maps requests by the key from HashKeySource (client IP
+// by default), naming the header, cookie or query parameter in HashKeyName.
+// A zero HashLoadFactor uses the gateway's default bound on origin loads.
+type UpstreamPool struct {
+	ID             uuid.UUID   `json:"id" db:"id"`
+	TenantID       uuid.UUID   `json:"tenant_id" db:"tenant_id"`
+	Name           string      `json:"name" db:"name"`
+	Strategy       string      `json:"strategy" db:"strategy"`
+	HashKeySource  string      `json:"hash_key_source" db:"hash_key_source"`
+	HashKeyName    string      `json:"hash_key_name" db:"hash_key_name"`
+	HashLoadFactor float64     `json:"hash_load_factor" db:"hash_load_factor"`
+	OriginIDs      []uuid.UUID `json:"origin_ids" db:"-"`
+	Metadata       JSONB       `json:"metadata" db:"metadata"`
+	CreatedAt      time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at" db:"updated_at"`
+}
+
 // Route represents a routing rule
 type Route struct {
 	ID                      uuid.UUID      `json:"id" db:"id"`
 	TenantID                uuid.UUID      `json:"tenant_id" db:"tenant_id"`
-	OriginID                uuid.UUID      `json:"origin_id" db:"origin_id"`
+	OriginID                *uuid.UUID     `json:"origin_id,omitempty" db:"origin_id"`
+	PoolID                  *uuid.UUID     `json:"pool_id,omitempty" db:"pool_id"`
 	Name                    string         `json:"name" db:"name"`
 	PathPattern             string         `json:"path_pattern" db:"path_pattern"`
 	Methods                 StringArray    `json:"methods" db:"methods"`
@@ -74,20 +106,28 @@ type Route struct {
 	RateLimitKeyStrategy        string `json:"rate_limit_key_strategy" db:"rate_limit_key_strategy"`
 	
 	// Caching
-	CacheEnabled      bool   `json:"cache_enabled" db:"cache_enabled"`
-	CacheTTLSeconds   int    `json:"cache_ttl_seconds" db:"cache_ttl_seconds"`
-	CacheKeyPattern   string `json:"cache_key_pattern" db:"cache_key_pattern"`
-	CacheBypassRules  JSONB  `json:"cache_bypass_rules" db:"cache_bypass_rules"`
+	CacheEnabled                     bool             `json:"cache_enabled" db:"cache_enabled"`
+	CacheTTLSeconds                  int              `json:"cache_ttl_seconds" db:"cache_ttl_seconds"`
+	CacheKeyPattern                  string           `json:"cache_key_pattern" db:"cache_key_pattern"`
+	CacheBypassRules                 CacheBypassRules `json:"cache_bypass_rules" db:"cache_bypass_rules"`
+	CacheStaleWhileRevalidateSeconds int              `json:"cache_stale_while_revalidate_seconds" db:"cache_stale_while_revalidate_seconds"`
+	CacheStaleIfErrorSeconds         int              `json:"cache_stale_if_error_seconds" db:"cache_stale_if_error_seconds"`
 	
 	// Transformation
-	RequestHeaders      JSONB   `json:"request_headers" db:"request_headers"`
-	ResponseHeaders     JSONB   `json:"response_headers" db:"response_headers"`
-	PathRewritePattern  *string `json:"path_rewrite_pattern,omitempty" db:"path_rewrite_pattern"`
-	PathRewriteTarget   *string `json:"path_rewrite_target,omitempty" db:"path_rewrite_target"`
+	RequestHeaders        JSONB   `json:"request_headers" db:"request_headers"`
+	ResponseHeaders       JSONB   `json:"response_headers" db:"response_headers"`
+	PathRewritePattern    *string `json:"path_rewrite_pattern,omitempty" db:"path_rewrite_pattern"`
+	PathRewriteTarget     *string `json:"path_rewrite_target,omitempty" db:"path_rewrite_target"`
+	StripPathPrefix       *string `json:"strip_path_prefix,omitempty" db:"strip_path_prefix"`
+	AddPathPrefix         *string `json:"add_path_prefix,omitempty" db:"add_path_prefix"`
+	RequestBodyTransform  JSONB   `json:"request_body_transform" db:"request_body_transform"`
+	ResponseBodyTransform JSONB   `json:"response_body_transform" db:"response_body_transform"`
 	
 	// Advanced
 	TimeoutSeconds          int  `json:"timeout_seconds" db:"timeout_seconds"`
 	RetryAttempts           int  `json:"retry_attempts" db:"retry_attempts"`
+	RetryOn                 StringArray `json:"retry_on" db:"retry_on"`
+	RetryNonIdempotent      bool `json:"retry_non_idempotent" db:"retry_non_idempotent"`
 	CircuitBreakerEnabled   bool `json:"circuit_breaker_enabled" db:"circuit_breaker_enabled"`
 	CircuitBreakerThreshold int  `json:"circuit_breaker_threshold" db:"circuit_breaker_threshold"`
 	
@@ -143,6 +183,26 @@ type RequestLog struct {
 	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
 }
 
+// TenantDomain represents a custom domain pointed at a tenant
+type TenantDomain struct {
+	ID                uuid.UUID  `json:"id" db:"id"`
+	TenantID          uuid.UUID  `json:"tenant_id" db:"tenant_id"`
+	Domain            string     `json:"domain" db:"domain"`
+	VerificationToken string     `json:"verification_token" db:"verification_token"`
+	Status            string     `json:"status" db:"status"`
+	VerifiedAt        *time.Time `json:"verified_at,omitempty" db:"verified_at"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// TenantConfigVersion summarises the routing configuration of a tenant so the
+// gateway can detect changes without reloading every route and origin
+type TenantConfigVersion struct {
+	TenantID  uuid.UUID `json:"tenant_id" db:"tenant_id"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ItemCount int64     `json:"item_count" db:"item_count"`
+}
+
 // Custom types for database compatibility
 
 // JSONB represents a PostgreSQL JSONB field
@@ -169,6 +229,45 @@ func (j *JSONB) Scan(value interface{}) error {
 	return json.Unmarshal(bytes, j)
 }
 
+// CacheBypassRule sends matching requests straight to the origin. Type is
+// one of header, cookie, query, method or path_prefix. Name selects the
+// header, cookie or query parameter; an empty Value matches any value.
+type CacheBypassRule struct {
+	Type  string `json:"type"`
+	Name  string `json:"name,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+// CacheBypassRules is the JSONB array stored in routes.cache_bypass_rules
+type CacheBypassRules []CacheBypassRule
+
+func (r CacheBypassRules) Value() (driver.Value, error) {
+	if r == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(r)
+}
+
+func (r *CacheBypassRules) Scan(value interface{}) error {
+	if value == nil {
+		*r = CacheBypassRules{}
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("failed to unmarshal CacheBypassRules value: %v", value)
+	}
+
+	// Rows written before the column held an array may contain an empty object
+	if trimmed := strings.TrimSpace(string(bytes)); trimmed == "{}" || trimmed == "null" {
+		*r = CacheBypassRules{}
+		return nil
+	}
+
+	return json.Unmarshal(bytes, r)
+}
+
 // StringArray represents a PostgreSQL TEXT[] field
 type StringArray []string
 
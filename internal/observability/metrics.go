@@ -1,6 +1,8 @@
 package observability
 
 import (
+	"encoding/json"
+	"net/http"
 	"sync"
 	"time"
 )
@@ -27,6 +29,20 @@ type Metrics struct {
 	// Origin metrics
 	originRequests map[string]int64
 	originErrors   map[string]int64
+
+	// Cache tier metrics, keyed by tier name
+	cacheTierHits   map[string]int64
+	cacheTierMisses map[string]int64
+
+	// Circuit breaker states keyed by "route_id|origin_id", and
+	// transitions counted by the state entered
+	circuitStates      map[string]string
+	circuitTransitions map[string]int64
+
+	// Origins currently ejected by outlier detection, and ejections
+	// counted by reason
+	ejectedOrigins   map[string]bool
+	outlierEjections map[string]int64
 }
 
 func NewMetrics() *Metrics {
@@ -34,6 +50,12 @@ func NewMetrics() *Metrics {
 		statusCodes:      make(map[int]int64),
 		originRequests:   make(map[string]int64),
 		originErrors:     make(map[string]int64),
+		cacheTierHits:    make(map[string]int64),
+		cacheTierMisses:  make(map[string]int64),
+		circuitStates:      make(map[string]string),
+		circuitTransitions: make(map[string]int64),
+		ejectedOrigins:     make(map[string]bool),
+		outlierEjections:   make(map[string]int64),
 		minLatencyMs:     -1,
 	}
 }
@@ -72,11 +94,51 @@ func (m *Metrics) RecordRequest(statusCode int, latencyMs float64, cacheHit bool
 	}
 }
 
+// RecordCacheLookup records a lookup in one tier of the response cache
+func (m *Metrics) RecordCacheLookup(tier string, hit bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if hit {
+		m.cacheTierHits[tier]++
+	} else {
+		m.cacheTierMisses[tier]++
+	}
+}
+
+// RecordCircuitTransition records a circuit breaker entering state
+func (m *Metrics) RecordCircuitTransition(breaker, state string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.circuitStates[breaker] = state
+	m.circuitTransitions[state]++
+}
+
+// RecordOutlierEjection records an origin being ejected for reason, or let
+// back in when ejected is false
+func (m *Metrics) RecordOutlierEjection(originID string, ejected bool, reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !ejected {
+		delete(m.ejectedOrigins, originID)
+		return
+	}
+	m.ejectedOrigins[originID] = true
+	m.outlierEjections[reason]++
+}
+
 // GetMetrics returns a snapshot of current metrics
 func (m *Metrics) GetMetrics() map[string]interface{} {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
+	return m.snapshot()
+}
+
+// snapshot builds the metrics map; m.mu must be held
+func (m *Metrics) snapshot() map[string]interface{} {
 	totalRequests := m.totalRequests
 	if totalRequests == 0 {
 		totalRequests = 1
@@ -85,18 +147,24 @@ func (m *Metrics) GetMetrics() map[string]interface{} {
 	cacheHitRate := float64(m.totalCacheHits) / float64(m.totalRequests+1) * 100
 
 	return map[string]interface{}{
-		"total_requests":    m.totalRequests,
-		"total_errors":      m.totalErrors,
-		"error_rate":        float64(m.totalErrors) / float64(totalRequests) * 100,
-		"cache_hits":        m.totalCacheHits,
-		"cache_misses":      m.totalCacheMisses,
-		"cache_hit_rate":    cacheHitRate,
-		"avg_latency_ms":    m.avgLatencyMs,
-		"min_latency_ms":    m.minLatencyMs,
-		"max_latency_ms":    m.maxLatencyMs,
-		"status_codes":      m.statusCodes,
-		"origin_requests":   m.originRequests,
-		"origin_errors":     m.originErrors,
+		"total_requests":              m.totalRequests,
+		"total_errors":                m.totalErrors,
+		"error_rate":                  float64(m.totalErrors) / float64(totalRequests) * 100,
+		"cache_hits":                  m.totalCacheHits,
+		"cache_misses":                m.totalCacheMisses,
+		"cache_hit_rate":              cacheHitRate,
+		"avg_latency_ms":              m.avgLatencyMs,
+		"min_latency_ms":              m.minLatencyMs,
+		"max_latency_ms":              m.maxLatencyMs,
+		"status_codes":                m.statusCodes,
+		"origin_requests":             m.originRequests,
+		"origin_errors":               m.originErrors,
+		"cache_tier_hits":             m.cacheTierHits,
+		"cache_tier_misses":           m.cacheTierMisses,
+		"circuit_breakers":            m.circuitStates,
+		"circuit_breaker_transitions": m.circuitTransitions,
+		"ejected_origins":             m.ejectedOrigins,
+		"outlier_ejections":           m.outlierEjections,
 	}
 }
 
@@ -116,6 +184,27 @@ func (m *Metrics) Reset() {
 	m.statusCodes = make(map[int]int64)
 	m.originRequests = make(map[string]int64)
 	m.originErrors = make(map[string]int64)
+	m.cacheTierHits = make(map[string]int64)
+	m.cacheTierMisses = make(map[string]int64)
+	m.circuitStates = make(map[string]string)
+	m.circuitTransitions = make(map[string]int64)
+	m.ejectedOrigins = make(map[string]bool)
+	m.outlierEjections = make(map[string]int64)
+}
+
+// Handler serves a JSON snapshot of the metrics
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.mu.RLock()
+		body, err := json.Marshal(m.snapshot())
+		m.mu.RUnlock()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
 }
 
 // TimingSample records timing information
@@ -0,0 +1,154 @@
+package gcra
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/vantageedge/backend/internal/cache/redis"
+	"github.com/vantageedge/backend/internal/ratelimit"
+)
+
+// Failure policies applied while Redis is unreachable
+const (
+	FailOpen   = "open"
+	FailClosed = "closed"
+)
+
+const (
+	keyPrefix       = "ratelimit:"
+	defaultCooldown = time.Second
+)
+
+// ErrUnavailable wraps Redis errors returned by Allow
+var ErrUnavailable = errors.New("rate limit store unavailable")
+
+// script implements the generic cell rate algorithm. The key stores the
+// theoretical arrival time (TAT) in microseconds of the Redis clock, so every
+// gateway instance shares the same clock and state.
+//
+// KEYS[1] bucket key
+// ARGV[1] emission interval in microseconds (1s / rate)
+// ARGV[2] burst
+// ARGV[3] cost of the request
+//
+// Returns {allowed, remaining, retry_after_us, reset_after_us}
+var script = redis.NewScript(`
+redis.replicate_commands()
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local interval = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local tolerance = interval * burst
+
+local tat = tonumber(redis.call('GET', KEYS[1]))
+if not tat or tat < now then
+	tat = now
+end
+
+local new_tat = tat + interval * cost
+local allow_at = new_tat - tolerance
+if now < allow_at then
+	return {0, 0, allow_at - now, tat - now}
+end
+
+local reset_after = new_tat - now
+redis.call('SET', KEYS[1], string.format('%d', new_tat), 'PX', math.ceil(reset_after / 1000))
+return {1, math.floor((tolerance - reset_after) / interval), 0, reset_after}
+`)
+
+// Options configures a Limiter
+type Options struct {
+	FailOpen bool          // allow requests while Redis is unreachable
+	Timeout  time.Duration // bound on each Redis call, 0 for none
+	Cooldown time.Duration // how long Redis is skipped after a failure
+}
+
+// Limiter is a ratelimit.Limiter shared by every gateway instance through
+// Redis. When Redis fails it applies the failure policy locally and skips
+// Redis for a cooldown so an outage does not add latency to every request.
+type Limiter struct {
+	client   *redis.Client
+	opts     Options
+	mu       sync.Mutex
+	downTill time.Time
+}
+
+var _ ratelimit.Limiter = (*Limiter)(nil)
+
+func NewLimiter(client *redis.Client, opts Options) *Limiter {
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = defaultCooldown
+	}
+	return &Limiter{client: client, opts: opts}
+}
+
+// Allow runs the GCRA script for key. Redis failures are returned wrapped in
+// ErrUnavailable together with the failure policy's decision; while in
+// cooldown the policy's decision is returned without an error.
+func (l *Limiter) Allow(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Decision, error) {
+	if limit.RequestsPerSecond <= 0 || limit.Burst <= 0 {
+		return ratelimit.Decision{}, fmt.Errorf("invalid rate limit %d/s burst %d", limit.RequestsPerSecond, limit.Burst)
+	}
+	if l.inCooldown() {
+		return l.policyDecision(), nil
+	}
+
+	if l.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.opts.Timeout)
+		defer cancel()
+	}
+
+	interval := time.Second.Microseconds() / int64(limit.RequestsPerSecond)
+	if interval < 1 {
+		interval = 1
+	}
+	res, err := l.client.Run(ctx, script, []string{keyPrefix + key}, interval, limit.Burst, 1)
+	if err != nil {
+		l.startCooldown()
+		return l.policyDecision(), fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	values, ok := res.([]interface{})
+	if !ok || len(values) != 4 {
+		return l.policyDecision(), fmt.Errorf("%w: unexpected script result %v", ErrUnavailable, res)
+	}
+	ints := make([]int64, len(values))
+	for i, v := range values {
+		ints[i], _ = v.(int64)
+	}
+
+	return ratelimit.Decision{
+		Allowed:    ints[0] == 1,
+		Limit:      limit.Burst,
+		Remaining:  int(ints[1]),
+		RetryAfter: time.Duration(ints[2]) * time.Microsecond,
+		ResetAfter: time.Duration(ints[3]) * time.Microsecond,
+	}, nil
+}
+
+// policyDecision is the decision applied while Redis is unavailable. No quota
+// is known, so Limit is left at zero.
+func (l *Limiter) policyDecision() ratelimit.Decision {
+	if l.opts.FailOpen {
+		return ratelimit.Decision{Allowed: true}
+	}
+	return ratelimit.Decision{RetryAfter: l.opts.Cooldown}
+}
+
+func (l *Limiter) inCooldown() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return time.Now().Before(l.downTill)
+}
+
+func (l *Limiter) startCooldown() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.downTill = time.Now().Add(l.opts.Cooldown)
+}
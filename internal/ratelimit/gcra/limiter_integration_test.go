@@ -0,0 +1,84 @@
+//go:build integration
+
+package gcra
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/cache/redis"
+	"github.com/vantageedge/backend/internal/ratelimit"
+	"github.com/vantageedge/backend/pkg/config"
+)
+
+// newRedisClient connects to the Redis reachable through the REDIS_*
+// variables, where the limiter script runs as in production
+func newRedisClient(t *testing.T) *redis.Client {
+	host := os.Getenv("REDIS_HOST")
+	if host == "" {
+		host = "localhost"
+	}
+	port, _ := strconv.Atoi(os.Getenv("REDIS_PORT"))
+	if port == 0 {
+		port = 6379
+	}
+	client, err := redis.New(&config.RedisConfig{Host: host, Port: port, Password: os.Getenv("REDIS_PASSWORD")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestLimiterSharesStateAcrossInstances(t *testing.T) {
+	limit := ratelimit.Limit{RequestsPerSecond: 10, Burst: 3}
+	ctx := context.Background()
+	key := "test:" + uuid.NewString()
+
+	// Two gateway replicas with their own connections
+	replicas := []*Limiter{
+		NewLimiter(newRedisClient(t), Options{}),
+		NewLimiter(newRedisClient(t), Options{}),
+	}
+	t.Cleanup(func() { replicas[0].client.Delete(context.Background(), keyPrefix+key, keyPrefix+key+"-other") })
+
+	allowed := 0
+	for i := 0; i < 6; i++ {
+		d, err := replicas[i%2].Allow(ctx, key, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Allowed {
+			allowed++
+		}
+	}
+	if allowed != 3 {
+		t.Fatalf("expected the burst of 3 to be shared across replicas, %d allowed", allowed)
+	}
+
+	// One emission interval later a single request is allowed again
+	time.Sleep(110 * time.Millisecond)
+	if d, err := replicas[0].Allow(ctx, key, limit); err != nil || !d.Allowed {
+		t.Fatalf("expected a request after one interval, got %+v, %v", d, err)
+	}
+	d, err := replicas[1].Allow(ctx, key, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Allowed {
+		t.Fatal("expected the refilled token to be consumed")
+	}
+	if d.Limit != 3 || d.Remaining != 0 || d.RetryAfter <= 0 || d.RetryAfter > 100*time.Millisecond ||
+		d.ResetAfter <= 200*time.Millisecond || d.ResetAfter > 300*time.Millisecond {
+		t.Fatalf("unexpected throttled decision %+v", d)
+	}
+
+	d, err = replicas[1].Allow(ctx, key+"-other", limit)
+	if err != nil || !d.Allowed || d.Remaining != 2 || d.ResetAfter != 100*time.Millisecond {
+		t.Fatalf("expected a different key to have its own limit, got %+v, %v", d, err)
+	}
+}
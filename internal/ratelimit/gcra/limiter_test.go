@@ -0,0 +1,209 @@
+package gcra
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vantageedge/backend/internal/cache/redis"
+	"github.com/vantageedge/backend/internal/ratelimit"
+)
+
+// fakeRedis is an in-process server speaking enough of the Redis protocol for
+// go-redis to connect and run scripts, so the failure handling can be tested
+// without Redis. Every script call is allowed; the script itself runs against
+// a real Redis in limiter_integration_test.go.
+type fakeRedis struct {
+	ln      net.Listener
+	mu      sync.Mutex
+	scripts map[string]bool
+	evals   int
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRedis{
+		ln:      ln,
+		scripts: make(map[string]bool),
+	}
+	go f.serve()
+	t.Cleanup(func() { ln.Close() })
+	return f
+}
+
+func (f *fakeRedis) URL() string {
+	return "redis://" + f.ln.Addr().String()
+}
+
+func (f *fakeRedis) evalCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.evals
+}
+
+func (f *fakeRedis) serve() {
+	for {
+		conn, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		if _, err := io.WriteString(conn, f.exec(args)); err != nil {
+			return
+		}
+	}
+}
+
+func (f *fakeRedis) exec(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "CLIENT", "SELECT":
+		return "+OK\r\n"
+	case "EVALSHA":
+		if !f.scripts[args[1]] {
+			return "-NOSCRIPT No matching script. Please use EVAL.\r\n"
+		}
+		f.evals++
+		return respInts(1, 0, 0, 0)
+	case "EVAL":
+		sum := sha1.Sum([]byte(args[1]))
+		f.scripts[hex.EncodeToString(sum[:])] = true
+		f.evals++
+		return respInts(1, 0, 0, 0)
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+	}
+}
+
+func respInts(values ...int64) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(values))
+	for _, v := range values {
+		fmt.Fprintf(&b, ":%d\r\n", v)
+	}
+	return b.String()
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("unexpected line %q", line)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(header[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func newClient(t *testing.T, f *fakeRedis) *redis.Client {
+	client, err := redis.NewClient(f.URL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestLimiterFailurePolicy(t *testing.T) {
+	for _, failOpen := range []bool{true, false} {
+		t.Run(fmt.Sprintf("fail_open=%v", failOpen), func(t *testing.T) {
+			f := newFakeRedis(t)
+			limiter := NewLimiter(newClient(t, f), Options{
+				FailOpen: failOpen,
+				Timeout:  200 * time.Millisecond,
+				Cooldown: time.Hour,
+			})
+			limit := ratelimit.Limit{RequestsPerSecond: 1, Burst: 1}
+
+			f.ln.Close()
+			limiter.client.Close()
+
+			d, err := limiter.Allow(context.Background(), "k", limit)
+			if !errors.Is(err, ErrUnavailable) {
+				t.Fatalf("expected ErrUnavailable, got %v", err)
+			}
+			if d.Allowed != failOpen || d.Limit != 0 {
+				t.Fatalf("expected policy decision %v while Redis is down, got %+v", failOpen, d)
+			}
+
+			// Within the cooldown Redis is not retried
+			for i := 0; i < 5; i++ {
+				d, err := limiter.Allow(context.Background(), "k", limit)
+				if err != nil || d.Allowed != failOpen {
+					t.Fatalf("expected %v without error during cooldown, got %+v, %v", failOpen, d, err)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiterRecoversAfterCooldown(t *testing.T) {
+	f := newFakeRedis(t)
+	limiter := NewLimiter(newClient(t, f), Options{Cooldown: 10 * time.Millisecond})
+	limiter.startCooldown()
+
+	limit := ratelimit.Limit{RequestsPerSecond: 1, Burst: 1}
+	if _, err := limiter.Allow(context.Background(), "k", limit); err != nil {
+		t.Fatal(err)
+	}
+	if f.evalCount() != 0 {
+		t.Fatal("expected Redis to be skipped during cooldown")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if _, err := limiter.Allow(context.Background(), "k", limit); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.evalCount(); n != 1 {
+		t.Fatalf("expected Redis to be used after cooldown, %d evals", n)
+	}
+}
@@ -0,0 +1,139 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vantageedge/backend/internal/ratelimit/tokenbucket"
+)
+
+// Key strategies, matching routes.rate_limit_key_strategy. A header strategy
+// is written "header:<Header-Name>".
+const (
+	KeyStrategyIP         = "ip"
+	KeyStrategyTenant     = "tenant"
+	KeyStrategyTenantUser = "tenant_user"
+	KeyStrategyAPIKey     = "api_key"
+	KeyStrategyHeader     = "header:"
+
+	DefaultKeyStrategy = KeyStrategyTenantUser
+)
+
+// ValidateKeyStrategy checks that strategy is one the gateway understands.
+// An empty strategy selects DefaultKeyStrategy.
+func ValidateKeyStrategy(strategy string) error {
+	switch strategy {
+	case "", KeyStrategyIP, KeyStrategyTenant, KeyStrategyTenantUser, KeyStrategyAPIKey:
+		return nil
+	}
+	if name, ok := strings.CutPrefix(strategy, KeyStrategyHeader); ok {
+		if name == "" {
+			return fmt.Errorf("header key strategy requires a header name")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown key strategy %q", strategy)
+}
+
+// Limit is the rate and burst applied to one key
+type Limit struct {
+	RequestsPerSecond int
+	Burst             int
+}
+
+// Decision is a limiter's verdict on one request together with the quota
+// state reported to the client
+type Decision struct {
+	Allowed    bool
+	Limit      int           // quota size, zero when unknown (failure policy)
+	Remaining  int           // requests left before throttling
+	RetryAfter time.Duration // until a throttled request could succeed
+	ResetAfter time.Duration // until the full quota is available again
+}
+
+// Limiter decides whether a request identified by key may proceed under
+// limit. Keys are opaque; callers namespace them per route.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (Decision, error)
+}
+
+type registryEntry struct {
+	bucket   *tokenbucket.TokenBucket
+	limit    Limit
+	lastUsed time.Time
+}
+
+// Registry is the in-process Limiter and holds one token bucket per key.
+// Buckets idle for longer than idleTTL are evicted so memory stays bounded
+// under many distinct clients; eviction runs inline at most once per sweep
+// interval.
+type Registry struct {
+	mu        sync.Mutex
+	entries   map[string]*registryEntry
+	idleTTL   time.Duration
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewRegistry(idleTTL time.Duration) *Registry {
+	return &Registry{
+		entries:   make(map[string]*registryEntry),
+		idleTTL:   idleTTL,
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Allow takes a token from the bucket of key, creating the bucket on first
+// use. A bucket whose limit changed is replaced. It never fails.
+func (r *Registry) Allow(ctx context.Context, key string, limit Limit) (Decision, error) {
+	result := r.bucket(key, limit).Take(1)
+	return Decision{
+		Allowed:    result.Allowed,
+		Limit:      limit.Burst,
+		Remaining:  result.Remaining,
+		RetryAfter: result.RetryAfter,
+		ResetAfter: result.ResetAfter,
+	}, nil
+}
+
+func (r *Registry) bucket(id string, limit Limit) *tokenbucket.TokenBucket {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	if now.Sub(r.lastSweep) >= r.idleTTL/2 {
+		r.sweep(now)
+	}
+
+	e, ok := r.entries[id]
+	if !ok || e.limit != limit {
+		e = &registryEntry{
+			bucket: tokenbucket.NewTokenBucket(float64(limit.Burst), float64(limit.RequestsPerSecond)),
+			limit:  limit,
+		}
+		r.entries[id] = e
+	}
+	e.lastUsed = now
+	return e.bucket
+}
+
+// sweep evicts idle buckets. Callers must hold mu.
+func (r *Registry) sweep(now time.Time) {
+	for id, e := range r.entries {
+		if now.Sub(e.lastUsed) > r.idleTTL {
+			delete(r.entries, id)
+		}
+	}
+	r.lastSweep = now
+}
+
+// Len returns the number of live buckets
+func (r *Registry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.entries)
+}
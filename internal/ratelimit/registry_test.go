@@ -0,0 +1,112 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func allow(r *Registry, key string, limit Limit) bool {
+	d, _ := r.Allow(context.Background(), key, limit)
+	return d.Allowed
+}
+
+func TestRegistryIsolatesKeys(t *testing.T) {
+	r := NewRegistry(time.Minute)
+	limit := Limit{RequestsPerSecond: 1, Burst: 2}
+
+	for i := 0; i < 2; i++ {
+		if !allow(r, "route:a|ip:1.1.1.1", limit) {
+			t.Fatalf("request %d within burst was rejected", i)
+		}
+	}
+	if allow(r, "route:a|ip:1.1.1.1", limit) {
+		t.Fatal("expected request beyond burst to be rejected")
+	}
+
+	if !allow(r, "route:a|ip:2.2.2.2", limit) {
+		t.Fatal("expected a different key to get its own bucket")
+	}
+	if !allow(r, "route:b|ip:1.1.1.1", limit) {
+		t.Fatal("expected a different route to get its own bucket")
+	}
+}
+
+func TestRegistryReplacesBucketOnLimitChange(t *testing.T) {
+	r := NewRegistry(time.Minute)
+
+	if !allow(r, "k", Limit{RequestsPerSecond: 1, Burst: 1}) {
+		t.Fatal("first request rejected")
+	}
+	if allow(r, "k", Limit{RequestsPerSecond: 1, Burst: 1}) {
+		t.Fatal("expected exhausted bucket to reject")
+	}
+	if !allow(r, "k", Limit{RequestsPerSecond: 10, Burst: 10}) {
+		t.Fatal("expected a raised limit to take effect immediately")
+	}
+}
+
+func TestRegistryEvictsIdleBuckets(t *testing.T) {
+	r := NewRegistry(time.Minute)
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	limit := Limit{RequestsPerSecond: 1, Burst: 1}
+	for i := 0; i < 1000; i++ {
+		allow(r, "ip:"+strconv.Itoa(i), limit)
+	}
+	if r.Len() != 1000 {
+		t.Fatalf("expected 1000 buckets, got %d", r.Len())
+	}
+
+	// Keep one key active while the rest go idle
+	now = now.Add(45 * time.Second)
+	allow(r, "ip:0", limit)
+
+	now = now.Add(45 * time.Second)
+	allow(r, "ip:0", limit)
+
+	if r.Len() != 1 {
+		t.Fatalf("expected idle buckets to be evicted, %d remain", r.Len())
+	}
+}
+
+func TestRegistryDecision(t *testing.T) {
+	r := NewRegistry(time.Minute)
+	limit := Limit{RequestsPerSecond: 2, Burst: 3}
+
+	d, _ := r.Allow(context.Background(), "k", limit)
+	if !d.Allowed || d.Limit != 3 || d.Remaining != 2 || d.RetryAfter != 0 {
+		t.Fatalf("unexpected first decision %+v", d)
+	}
+	if d.ResetAfter <= 0 || d.ResetAfter > 500*time.Millisecond {
+		t.Fatalf("expected one token to refill within 500ms, got %v", d.ResetAfter)
+	}
+
+	r.Allow(context.Background(), "k", limit)
+	r.Allow(context.Background(), "k", limit)
+	d, _ = r.Allow(context.Background(), "k", limit)
+	if d.Allowed || d.Remaining != 0 {
+		t.Fatalf("expected exhausted bucket, got %+v", d)
+	}
+	if d.RetryAfter <= 0 || d.RetryAfter > 500*time.Millisecond {
+		t.Fatalf("expected retry within one refill interval, got %v", d.RetryAfter)
+	}
+	if d.ResetAfter < time.Second || d.ResetAfter > 1500*time.Millisecond {
+		t.Fatalf("expected full refill in about 1.5s, got %v", d.ResetAfter)
+	}
+}
+
+func TestValidateKeyStrategy(t *testing.T) {
+	for _, s := range []string{"", "ip", "tenant", "tenant_user", "api_key", "header:X-Client-ID"} {
+		if err := ValidateKeyStrategy(s); err != nil {
+			t.Errorf("%q: unexpected error %v", s, err)
+		}
+	}
+	for _, s := range []string{"user", "header:", "IP"} {
+		if err := ValidateKeyStrategy(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
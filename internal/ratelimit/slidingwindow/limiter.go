@@ -5,6 +5,14 @@ import (
 	"time"
 )
 
+// Result describes the outcome of Take and the window state after it
+type Result struct {
+	Allowed    bool
+	Remaining  int           // requests left in the current window
+	RetryAfter time.Duration // until the request could be allowed, 0 when allowed
+	ResetAfter time.Duration // until the window is empty again
+}
+
 type SlidingWindow struct {
 	mu              sync.Mutex
 	maxRequests     int
@@ -75,6 +83,45 @@ func (sw *SlidingWindow) AllowN(n int) bool {
 	return false
 }
 
+// Take records n requests if they fit in the window and reports the
+// resulting state
+func (sw *SlidingWindow) Take(n int) Result {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	now := time.Now()
+	windowStart := now.Add(-sw.windowSize)
+
+	// Remove old request timings, which are kept oldest first
+	validRequests := make([]time.Time, 0, len(sw.requestTimings)+n)
+	for _, reqTime := range sw.requestTimings {
+		if reqTime.After(windowStart) {
+			validRequests = append(validRequests, reqTime)
+		}
+	}
+
+	result := Result{}
+	if len(validRequests)+n <= sw.maxRequests {
+		for i := 0; i < n; i++ {
+			validRequests = append(validRequests, now)
+		}
+		result.Allowed = true
+	} else if excess := len(validRequests) + n - sw.maxRequests; excess <= len(validRequests) {
+		// Wait until enough of the oldest requests leave the window
+		result.RetryAfter = validRequests[excess-1].Add(sw.windowSize).Sub(now)
+	} else {
+		// n alone exceeds the window; it can never be allowed
+		result.RetryAfter = sw.windowSize
+	}
+	sw.requestTimings = validRequests
+
+	result.Remaining = sw.maxRequests - len(validRequests)
+	if len(validRequests) > 0 {
+		result.ResetAfter = validRequests[len(validRequests)-1].Add(sw.windowSize).Sub(now)
+	}
+	return result
+}
+
 // GetRemainingRequests returns the number of requests allowed in the current window
 func (sw *SlidingWindow) GetRemainingRequests() int {
 	sw.mu.Lock()
@@ -0,0 +1,34 @@
+package slidingwindow
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTakeReportsRetryAfter(t *testing.T) {
+	sw := NewSlidingWindow(2, time.Second)
+
+	first := sw.Take(1)
+	if !first.Allowed || first.Remaining != 1 {
+		t.Fatalf("unexpected first result %+v", first)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	sw.Take(1)
+
+	denied := sw.Take(1)
+	if denied.Allowed || denied.Remaining != 0 {
+		t.Fatalf("expected a full window, got %+v", denied)
+	}
+	// The oldest request leaves the window first
+	if denied.RetryAfter <= 900*time.Millisecond || denied.RetryAfter > 990*time.Millisecond {
+		t.Fatalf("expected retry when the first request expires, got %v", denied.RetryAfter)
+	}
+	if denied.ResetAfter <= denied.RetryAfter || denied.ResetAfter > time.Second {
+		t.Fatalf("expected reset when the newest request expires, got %v", denied.ResetAfter)
+	}
+
+	if tooMany := sw.Take(3); tooMany.Allowed || tooMany.RetryAfter != time.Second {
+		t.Fatalf("expected a request larger than the window to be rejected, got %+v", tooMany)
+	}
+}
@@ -1,10 +1,19 @@
 package tokenbucket
 
 import (
+	"math"
 	"sync"
 	"time"
 )
 
+// Result describes the outcome of Take and the bucket state after it
+type Result struct {
+	Allowed    bool
+	Remaining  int           // whole tokens left
+	RetryAfter time.Duration // until the request could be allowed, 0 when allowed
+	ResetAfter time.Duration // until the bucket is full again
+}
+
 type TokenBucket struct {
 	mu              sync.Mutex
 	capacity        float64
@@ -24,17 +33,39 @@ func NewTokenBucket(capacity, refillRate float64) *TokenBucket {
 
 // Allow checks if a request is allowed based on token availability
 func (tb *TokenBucket) Allow(tokens float64) bool {
+	return tb.Take(tokens).Allowed
+}
+
+// Take removes tokens from the bucket if enough are available and reports
+// the resulting state
+func (tb *TokenBucket) Take(tokens float64) Result {
 	tb.mu.Lock()
 	defer tb.mu.Unlock()
 
 	tb.refill()
 
+	result := Result{}
 	if tb.tokensAvailable >= tokens {
 		tb.tokensAvailable -= tokens
-		return true
+		result.Allowed = true
+	} else {
+		result.RetryAfter = tb.durationFor(tokens - tb.tokensAvailable)
 	}
 
-	return false
+	result.Remaining = int(math.Floor(tb.tokensAvailable))
+	result.ResetAfter = tb.durationFor(tb.capacity - tb.tokensAvailable)
+	return result
+}
+
+// durationFor returns how long refilling the given number of tokens takes
+func (tb *TokenBucket) durationFor(tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	if tb.refillRate <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(tokens / tb.refillRate * float64(time.Second))
 }
 
 // AllowN allows n requests at once
@@ -14,6 +14,7 @@ type APIKeyRepository interface {
 	Update(ctx context.Context, key *models.APIKey) error
 	Delete(ctx context.Context, id uuid.UUID) error
 	UpdateUsage(ctx context.Context, id uuid.UUID) error
+	SetRateLimitOverride(ctx context.Context, id uuid.UUID, override *int) (*models.APIKey, error)
 }
 
 type apiKeyRepository struct {
@@ -25,10 +26,10 @@ func NewAPIKeyRepository(db *database.DB) APIKeyRepository {
 }
 
 func (r *apiKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
-	query := `INSERT INTO api_keys (tenant_id, user_id, name, key_prefix, key_hash, scopes, expires_at)
-	          VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at, updated_at`
+	query := `INSERT INTO api_keys (tenant_id, user_id, name, key_prefix, key_hash, scopes, rate_limit_override, expires_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created_at, updated_at`
 	return r.db.QueryRowContext(ctx, query,
-		key.TenantID, key.UserID, key.Name, key.KeyPrefix, key.KeyHash, key.Scopes, key.ExpiresAt).
+		key.TenantID, key.UserID, key.Name, key.KeyPrefix, key.KeyHash, key.Scopes, key.RateLimitOverride, key.ExpiresAt).
 		Scan(&key.ID, &key.CreatedAt, &key.UpdatedAt)
 }
 
@@ -63,3 +64,12 @@ func (r *apiKeyRepository) UpdateUsage(ctx context.Context, id uuid.UUID) error
 	_, err := r.db.ExecContext(ctx, query, id)
 	return err
 }
+
+// SetRateLimitOverride sets the key's requests per second, or clears it when
+// override is nil, and returns the updated key
+func (r *apiKeyRepository) SetRateLimitOverride(ctx context.Context, id uuid.UUID, override *int) (*models.APIKey, error) {
+	var key models.APIKey
+	query := `UPDATE api_keys SET rate_limit_override = $1 WHERE id = $2 RETURNING *`
+	err := r.db.GetContext(ctx, &key, query, override, id)
+	return &key, err
+}
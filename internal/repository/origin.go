@@ -25,10 +25,14 @@ func NewOriginRepository(db *database.DB) OriginRepository {
 }
 
 func (r *originRepository) Create(ctx context.Context, origin *models.Origin) error {
-	query := `INSERT INTO origins (tenant_id, name, url, health_check_path, timeout_seconds)
-	          VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at, updated_at`
+	query := `INSERT INTO origins (tenant_id, name, url, health_check_type, health_check_path, health_check_interval,
+	          health_check_timeout_seconds, healthy_threshold, unhealthy_threshold, health_check_expected_statuses,
+	          health_check_body_match, timeout_seconds, weight)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id, created_at, updated_at`
 	return r.db.QueryRowContext(ctx, query,
-		origin.TenantID, origin.Name, origin.URL, origin.HealthCheckPath, origin.TimeoutSeconds).
+		origin.TenantID, origin.Name, origin.URL, origin.HealthCheckType, origin.HealthCheckPath, origin.HealthCheckInterval,
+		origin.HealthCheckTimeout, origin.HealthyThreshold, origin.UnhealthyThreshold, origin.ExpectedStatuses,
+		origin.BodyMatch, origin.TimeoutSeconds, origin.Weight).
 		Scan(&origin.ID, &origin.CreatedAt, &origin.UpdatedAt)
 }
 
@@ -47,8 +51,14 @@ func (r *originRepository) ListByTenant(ctx context.Context, tenantID uuid.UUID)
 }
 
 func (r *originRepository) Update(ctx context.Context, origin *models.Origin) error {
-	query := `UPDATE origins SET name = $1, url = $2, timeout_seconds = $3 WHERE id = $4`
-	_, err := r.db.ExecContext(ctx, query, origin.Name, origin.URL, origin.TimeoutSeconds, origin.ID)
+	query := `UPDATE origins SET name = $1, url = $2, timeout_seconds = $3, weight = $4, health_check_type = $5,
+	          health_check_path = $6, health_check_interval = $7, health_check_timeout_seconds = $8,
+	          healthy_threshold = $9, unhealthy_threshold = $10, health_check_expected_statuses = $11,
+	          health_check_body_match = $12 WHERE id = $13`
+	_, err := r.db.ExecContext(ctx, query, origin.Name, origin.URL, origin.TimeoutSeconds, origin.Weight, origin.HealthCheckType,
+		origin.HealthCheckPath, origin.HealthCheckInterval, origin.HealthCheckTimeout,
+		origin.HealthyThreshold, origin.UnhealthyThreshold, origin.ExpectedStatuses,
+		origin.BodyMatch, origin.ID)
 	return err
 }
 
@@ -11,6 +11,8 @@ type Repository struct {
 	Route   RouteRepository
 	APIKey  APIKeyRepository
 	Request RequestLogRepository
+	Domain  TenantDomainRepository
+	Pool    UpstreamPoolRepository
 }
 
 func New(db *database.DB) *Repository {
@@ -21,6 +23,8 @@ func New(db *database.DB) *Repository {
 		Route:   NewRouteRepository(db),
 		APIKey:  NewAPIKeyRepository(db),
 		Request: NewRequestLogRepository(db),
+		Domain:  NewTenantDomainRepository(db),
+		Pool:    NewUpstreamPoolRepository(db),
 	}
 }
 
@@ -19,11 +19,13 @@ func NewRequestLogRepository(db *database.DB) RequestLogRepository {
 }
 
 func (r *requestLogRepository) Create(ctx context.Context, log *models.RequestLog) error {
-	query := `INSERT INTO request_logs (tenant_id, route_id, user_id, method, path, status_code,
-	          response_time_ms, cache_hit, auth_method, trace_id)
-	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	query := `INSERT INTO request_logs (tenant_id, route_id, user_id, method, path, query_string,
+	          user_agent, ip_address, status_code, response_time_ms, response_size_bytes, cache_hit,
+	          cache_key, origin_url, rate_limited, auth_method, api_key_id, error_message, error_code, trace_id)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)`
 	_, err := r.db.ExecContext(ctx, query,
-		log.TenantID, log.RouteID, log.UserID, log.Method, log.Path,
-		log.StatusCode, log.ResponseTimeMs, log.CacheHit, log.AuthMethod, log.TraceID)
+		log.TenantID, log.RouteID, log.UserID, log.Method, log.Path, log.QueryString,
+		log.UserAgent, log.IPAddress, log.StatusCode, log.ResponseTimeMs, log.ResponseSizeBytes, log.CacheHit,
+		log.CacheKey, log.OriginURL, log.RateLimited, log.AuthMethod, log.APIKeyID, log.ErrorMessage, log.ErrorCode, log.TraceID)
 	return err
 }
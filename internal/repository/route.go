@@ -25,17 +25,25 @@ func NewRouteRepository(db *database.DB) RouteRepository {
 }
 
 func (r *routeRepository) Create(ctx context.Context, route *models.Route) error {
-	query := `INSERT INTO routes (tenant_id, origin_id, name, path_pattern, methods, priority, auth_mode,
+	query := `INSERT INTO routes (tenant_id, origin_id, pool_id, name, path_pattern, methods, priority, auth_mode,
 	          rate_limit_enabled, rate_limit_requests_per_second, rate_limit_burst, rate_limit_key_strategy,
 	          cache_enabled, cache_ttl_seconds, cache_key_pattern, cache_bypass_rules,
-	          request_headers, response_headers, timeout_seconds, retry_attempts, metadata) 
-	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20) 
+	          cache_stale_while_revalidate_seconds, cache_stale_if_error_seconds,
+	          request_headers, response_headers, timeout_seconds, retry_attempts, retry_on, retry_non_idempotent, metadata,
+	          path_rewrite_pattern, path_rewrite_target, strip_path_prefix, add_path_prefix,
+	          request_body_transform, response_body_transform) 
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25,
+	          $26, $27, $28, $29, $30, $31) 
 	          RETURNING id, created_at, updated_at`
 	return r.db.QueryRowContext(ctx, query,
-		route.TenantID, route.OriginID, route.Name, route.PathPattern, route.Methods, route.Priority, route.AuthMode,
+		route.TenantID, route.OriginID, route.PoolID, route.Name, route.PathPattern, route.Methods, route.Priority, route.AuthMode,
 		route.RateLimitEnabled, route.RateLimitRequestsPerSecond, route.RateLimitBurst, route.RateLimitKeyStrategy,
 		route.CacheEnabled, route.CacheTTLSeconds, route.CacheKeyPattern, route.CacheBypassRules,
-		route.RequestHeaders, route.ResponseHeaders, route.TimeoutSeconds, route.RetryAttempts, route.Metadata).
+		route.CacheStaleWhileRevalidateSeconds, route.CacheStaleIfErrorSeconds,
+		route.RequestHeaders, route.ResponseHeaders, route.TimeoutSeconds, route.RetryAttempts,
+		route.RetryOn, route.RetryNonIdempotent, route.Metadata,
+		route.PathRewritePattern, route.PathRewriteTarget, route.StripPathPrefix, route.AddPathPrefix,
+		route.RequestBodyTransform, route.ResponseBodyTransform).
 		Scan(&route.ID, &route.CreatedAt, &route.UpdatedAt)
 }
 
@@ -55,10 +63,25 @@ func (r *routeRepository) ListByTenant(ctx context.Context, tenantID uuid.UUID)
 
 func (r *routeRepository) Update(ctx context.Context, route *models.Route) error {
 	query := `UPDATE routes SET name = $1, path_pattern = $2, methods = $3, priority = $4,
-	          auth_mode = $5, is_active = $6 WHERE id = $7`
+	          auth_mode = $5, is_active = $6, rate_limit_enabled = $7, rate_limit_requests_per_second = $8,
+	          rate_limit_burst = $9, rate_limit_key_strategy = $10, cache_enabled = $11,
+	          cache_ttl_seconds = $12, cache_key_pattern = $13, cache_bypass_rules = $14,
+	          cache_stale_while_revalidate_seconds = $15, cache_stale_if_error_seconds = $16,
+	          timeout_seconds = $17, retry_attempts = $18, retry_on = $19, retry_non_idempotent = $20,
+	          origin_id = $21, pool_id = $22, path_rewrite_pattern = $23, path_rewrite_target = $24,
+	          strip_path_prefix = $25, add_path_prefix = $26, request_headers = $27, response_headers = $28,
+	          request_body_transform = $29, response_body_transform = $30
+	          WHERE id = $31`
 	_, err := r.db.ExecContext(ctx, query,
 		route.Name, route.PathPattern, route.Methods, route.Priority,
-		route.AuthMode, route.IsActive, route.ID)
+		route.AuthMode, route.IsActive, route.RateLimitEnabled, route.RateLimitRequestsPerSecond,
+		route.RateLimitBurst, route.RateLimitKeyStrategy, route.CacheEnabled,
+		route.CacheTTLSeconds, route.CacheKeyPattern, route.CacheBypassRules,
+		route.CacheStaleWhileRevalidateSeconds, route.CacheStaleIfErrorSeconds,
+		route.TimeoutSeconds, route.RetryAttempts, route.RetryOn, route.RetryNonIdempotent,
+		route.OriginID, route.PoolID, route.PathRewritePattern, route.PathRewriteTarget,
+		route.StripPathPrefix, route.AddPathPrefix, route.RequestHeaders, route.ResponseHeaders,
+		route.RequestBodyTransform, route.ResponseBodyTransform, route.ID)
 	return err
 }
 
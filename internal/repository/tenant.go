@@ -16,6 +16,7 @@ type TenantRepository interface {
 	List(ctx context.Context) ([]*models.Tenant, error)
 	Update(ctx context.Context, tenant *models.Tenant) error
 	Delete(ctx context.Context, id uuid.UUID) error
+	ListConfigVersions(ctx context.Context) ([]*models.TenantConfigVersion, error)
 }
 
 type tenantRepository struct {
@@ -74,3 +75,25 @@ func (r *tenantRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	_, err := r.db.ExecContext(ctx, query, id)
 	return err
 }
+
+// ListConfigVersions returns, per tenant, the latest modification time and the
+// number of routes, origins, upstream pools and their members, and custom
+// domains. Any insert, update or delete changes at least one of the two
+// values.
+func (r *tenantRepository) ListConfigVersions(ctx context.Context) ([]*models.TenantConfigVersion, error) {
+	var versions []*models.TenantConfigVersion
+	query := `SELECT t.id AS tenant_id,
+	                 GREATEST(t.updated_at,
+	                          COALESCE((SELECT MAX(updated_at) FROM routes WHERE tenant_id = t.id), t.updated_at),
+	                          COALESCE((SELECT MAX(updated_at) FROM origins WHERE tenant_id = t.id), t.updated_at),
+	                          COALESCE((SELECT MAX(updated_at) FROM upstream_pools WHERE tenant_id = t.id), t.updated_at),
+	                          COALESCE((SELECT MAX(updated_at) FROM tenant_domains WHERE tenant_id = t.id), t.updated_at)) AS updated_at,
+	                 (SELECT COUNT(*) FROM routes WHERE tenant_id = t.id) +
+	                 (SELECT COUNT(*) FROM origins WHERE tenant_id = t.id) +
+	                 (SELECT COUNT(*) FROM upstream_pools WHERE tenant_id = t.id) +
+	                 (SELECT COUNT(*) FROM upstream_pool_members m JOIN upstream_pools p ON p.id = m.pool_id WHERE p.tenant_id = t.id) +
+	                 (SELECT COUNT(*) FROM tenant_domains WHERE tenant_id = t.id) AS item_count
+	          FROM tenants t`
+	err := r.db.SelectContext(ctx, &versions, query)
+	return versions, err
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/vantageedge/backend/internal/models"
+	"github.com/vantageedge/backend/pkg/database"
+)
+
+type TenantDomainRepository interface {
+	Create(ctx context.Context, domain *models.TenantDomain) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.TenantDomain, error)
+	ListByTenant(ctx context.Context, tenantID uuid.UUID) ([]*models.TenantDomain, error)
+	MarkVerified(ctx context.Context, id uuid.UUID) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type tenantDomainRepository struct {
+	db *database.DB
+}
+
+func NewTenantDomainRepository(db *database.DB) TenantDomainRepository {
+	return &tenantDomainRepository{db: db}
+}
+
+func (r *tenantDomainRepository) Create(ctx context.Context, domain *models.TenantDomain) error {
+	query := `INSERT INTO tenant_domains (tenant_id, domain, verification_token)
+	          VALUES ($1, $2, $3) RETURNING id, status, created_at, updated_at`
+	return r.db.QueryRowContext(ctx, query, domain.TenantID, domain.Domain, domain.VerificationToken).
+		Scan(&domain.ID, &domain.Status, &domain.CreatedAt, &domain.UpdatedAt)
+}
+
+func (r *tenantDomainRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.TenantDomain, error) {
+	var domain models.TenantDomain
+	query := `SELECT * FROM tenant_domains WHERE id = $1`
+	err := r.db.GetContext(ctx, &domain, query, id)
+	return &domain, err
+}
+
+func (r *tenantDomainRepository) ListByTenant(ctx context.Context, tenantID uuid.UUID) ([]*models.TenantDomain, error) {
+	var domains []*models.TenantDomain
+	query := `SELECT * FROM tenant_domains WHERE tenant_id = $1 ORDER BY created_at DESC`
+	err := r.db.SelectContext(ctx, &domains, query, tenantID)
+	return domains, err
+}
+
+func (r *tenantDomainRepository) MarkVerified(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE tenant_domains SET status = 'verified', verified_at = NOW() WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+func (r *tenantDomainRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM tenant_domains WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
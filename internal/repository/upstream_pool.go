@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/vantageedge/backend/internal/models"
+	"github.com/vantageedge/backend/pkg/database"
+)
+
+type UpstreamPoolRepository interface {
+	Create(ctx context.Context, pool *models.UpstreamPool) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.UpstreamPool, error)
+	ListByTenant(ctx context.Context, tenantID uuid.UUID) ([]*models.UpstreamPool, error)
+	Update(ctx context.Context, pool *models.UpstreamPool) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type upstreamPoolRepository struct {
+	db *database.DB
+}
+
+func NewUpstreamPoolRepository(db *database.DB) UpstreamPoolRepository {
+	return &upstreamPoolRepository{db: db}
+}
+
+// Create inserts the pool together with its members
+func (r *upstreamPoolRepository) Create(ctx context.Context, pool *models.UpstreamPool) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO upstream_pools (tenant_id, name, strategy, hash_key_source, hash_key_name, hash_load_factor, metadata)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at, updated_at`
+	err = tx.QueryRowContext(ctx, query, pool.TenantID, pool.Name, pool.Strategy,
+		pool.HashKeySource, pool.HashKeyName, pool.HashLoadFactor, pool.Metadata).
+		Scan(&pool.ID, &pool.CreatedAt, &pool.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if err := insertPoolMembers(ctx, tx, pool); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *upstreamPoolRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.UpstreamPool, error) {
+	var pool models.UpstreamPool
+	query := `SELECT * FROM upstream_pools WHERE id = $1`
+	if err := r.db.GetContext(ctx, &pool, query, id); err != nil {
+		return &pool, err
+	}
+
+	query = `SELECT origin_id FROM upstream_pool_members WHERE pool_id = $1 ORDER BY created_at, origin_id`
+	err := r.db.SelectContext(ctx, &pool.OriginIDs, query, id)
+	return &pool, err
+}
+
+func (r *upstreamPoolRepository) ListByTenant(ctx context.Context, tenantID uuid.UUID) ([]*models.UpstreamPool, error) {
+	var pools []*models.UpstreamPool
+	query := `SELECT * FROM upstream_pools WHERE tenant_id = $1 ORDER BY created_at DESC`
+	if err := r.db.SelectContext(ctx, &pools, query, tenantID); err != nil {
+		return nil, err
+	}
+
+	var members []struct {
+		PoolID   uuid.UUID `db:"pool_id"`
+		OriginID uuid.UUID `db:"origin_id"`
+	}
+	query = `SELECT m.pool_id, m.origin_id FROM upstream_pool_members m
+	         JOIN upstream_pools p ON p.id = m.pool_id
+	         WHERE p.tenant_id = $1 ORDER BY m.created_at, m.origin_id`
+	if err := r.db.SelectContext(ctx, &members, query, tenantID); err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uuid.UUID]*models.UpstreamPool, len(pools))
+	for _, pool := range pools {
+		pool.OriginIDs = []uuid.UUID{}
+		byID[pool.ID] = pool
+	}
+	for _, m := range members {
+		if pool, ok := byID[m.PoolID]; ok {
+			pool.OriginIDs = append(pool.OriginIDs, m.OriginID)
+		}
+	}
+	return pools, nil
+}
+
+// Update saves the pool and replaces its members
+func (r *upstreamPoolRepository) Update(ctx context.Context, pool *models.UpstreamPool) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Touching the pool also bumps the tenant's config version when only
+	// the members change
+	query := `UPDATE upstream_pools SET name = $1, strategy = $2, hash_key_source = $3, hash_key_name = $4,
+	          hash_load_factor = $5, updated_at = NOW() WHERE id = $6`
+	_, err = tx.ExecContext(ctx, query, pool.Name, pool.Strategy,
+		pool.HashKeySource, pool.HashKeyName, pool.HashLoadFactor, pool.ID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM upstream_pool_members WHERE pool_id = $1`, pool.ID); err != nil {
+		return err
+	}
+	if err := insertPoolMembers(ctx, tx, pool); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *upstreamPoolRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM upstream_pools WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+func insertPoolMembers(ctx context.Context, tx *sqlx.Tx, pool *models.UpstreamPool) error {
+	ids := make([]string, len(pool.OriginIDs))
+	for i, id := range pool.OriginIDs {
+		ids[i] = id.String()
+	}
+	query := `INSERT INTO upstream_pool_members (pool_id, origin_id)
+	          SELECT $1, unnest($2::uuid[])`
+	if _, err := tx.ExecContext(ctx, query, pool.ID, pq.Array(ids)); err != nil {
+		return fmt.Errorf("failed to insert pool members: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS update_tenant_domains_updated_at ON tenant_domains;
+DROP TABLE IF EXISTS tenant_domains;
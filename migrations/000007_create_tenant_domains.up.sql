@@ -0,0 +1,20 @@
+-- Create tenant custom domains table
+CREATE TABLE IF NOT EXISTS tenant_domains (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    domain VARCHAR(253) NOT NULL,
+    verification_token VARCHAR(100) NOT NULL,
+    status VARCHAR(50) DEFAULT 'pending' CHECK (status IN ('pending', 'verified')),
+    verified_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT unique_tenant_domain UNIQUE(tenant_id, domain)
+);
+
+-- A domain can be claimed by several tenants but verified by only one
+CREATE UNIQUE INDEX idx_tenant_domains_verified_domain ON tenant_domains(domain) WHERE status = 'verified';
+CREATE INDEX idx_tenant_domains_tenant_id ON tenant_domains(tenant_id);
+
+-- Create trigger for updated_at
+CREATE TRIGGER update_tenant_domains_updated_at BEFORE UPDATE ON tenant_domains
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
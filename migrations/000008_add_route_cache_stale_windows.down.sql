@@ -0,0 +1,3 @@
+ALTER TABLE routes
+    DROP COLUMN IF EXISTS cache_stale_if_error_seconds,
+    DROP COLUMN IF EXISTS cache_stale_while_revalidate_seconds;
@@ -0,0 +1,4 @@
+-- Windows in which expired cache entries may still be served
+ALTER TABLE routes
+    ADD COLUMN IF NOT EXISTS cache_stale_while_revalidate_seconds INTEGER DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS cache_stale_if_error_seconds INTEGER DEFAULT 0;
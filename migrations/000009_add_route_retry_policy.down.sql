@@ -0,0 +1,3 @@
+ALTER TABLE routes
+    DROP COLUMN IF EXISTS retry_non_idempotent,
+    DROP COLUMN IF EXISTS retry_on;
@@ -0,0 +1,5 @@
+-- Conditions under which failed origin requests are retried, and whether
+-- requests that are neither safe nor carry an Idempotency-Key may be
+ALTER TABLE routes
+    ADD COLUMN IF NOT EXISTS retry_on TEXT[] DEFAULT '{connect_error,timeout,502,503,504}',
+    ADD COLUMN IF NOT EXISTS retry_non_idempotent BOOLEAN DEFAULT false;
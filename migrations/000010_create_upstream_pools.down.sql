@@ -0,0 +1,11 @@
+DELETE FROM routes WHERE origin_id IS NULL;
+
+DROP INDEX IF EXISTS idx_routes_pool_id;
+ALTER TABLE routes
+    DROP CONSTRAINT IF EXISTS routes_single_upstream,
+    DROP COLUMN IF EXISTS pool_id,
+    ALTER COLUMN origin_id SET NOT NULL;
+
+DROP TRIGGER IF EXISTS update_upstream_pools_updated_at ON upstream_pools;
+DROP TABLE IF EXISTS upstream_pool_members;
+DROP TABLE IF EXISTS upstream_pools;
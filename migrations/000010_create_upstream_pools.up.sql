@@ -0,0 +1,35 @@
+-- Create upstream pools: sets of origins a route balances its requests over.
+-- An empty strategy uses the gateway's LB_STRATEGY.
+CREATE TABLE IF NOT EXISTS upstream_pools (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    strategy VARCHAR(50) NOT NULL DEFAULT '',
+    metadata JSONB DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT unique_tenant_pool_name UNIQUE(tenant_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS upstream_pool_members (
+    pool_id UUID NOT NULL REFERENCES upstream_pools(id) ON DELETE CASCADE,
+    origin_id UUID NOT NULL REFERENCES origins(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (pool_id, origin_id)
+);
+
+-- Create indexes
+CREATE INDEX idx_upstream_pools_tenant_id ON upstream_pools(tenant_id);
+CREATE INDEX idx_upstream_pool_members_origin_id ON upstream_pool_members(origin_id);
+
+-- Create trigger for updated_at
+CREATE TRIGGER update_upstream_pools_updated_at BEFORE UPDATE ON upstream_pools
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Routes target either a single origin or a pool
+ALTER TABLE routes
+    ALTER COLUMN origin_id DROP NOT NULL,
+    ADD COLUMN IF NOT EXISTS pool_id UUID REFERENCES upstream_pools(id) ON DELETE RESTRICT,
+    ADD CONSTRAINT routes_single_upstream CHECK ((origin_id IS NULL) <> (pool_id IS NULL));
+
+CREATE INDEX idx_routes_pool_id ON routes(pool_id);
@@ -0,0 +1,4 @@
+ALTER TABLE upstream_pools
+    DROP COLUMN IF EXISTS hash_load_factor,
+    DROP COLUMN IF EXISTS hash_key_name,
+    DROP COLUMN IF EXISTS hash_key_source;
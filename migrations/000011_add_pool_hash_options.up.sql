@@ -0,0 +1,6 @@
+-- Consistent hashing options of upstream pools. An empty hash_key_source
+-- hashes the client IP; a zero hash_load_factor uses LB_HASH_LOAD_FACTOR.
+ALTER TABLE upstream_pools
+    ADD COLUMN IF NOT EXISTS hash_key_source VARCHAR(50) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS hash_key_name VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS hash_load_factor DOUBLE PRECISION NOT NULL DEFAULT 0;
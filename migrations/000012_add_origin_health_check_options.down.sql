@@ -0,0 +1,12 @@
+DROP TRIGGER IF EXISTS update_origins_updated_at ON origins;
+CREATE TRIGGER update_origins_updated_at BEFORE UPDATE ON origins
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+DROP FUNCTION IF EXISTS update_origins_updated_at_column();
+
+ALTER TABLE origins
+    DROP COLUMN IF EXISTS health_check_body_match,
+    DROP COLUMN IF EXISTS health_check_expected_statuses,
+    DROP COLUMN IF EXISTS unhealthy_threshold,
+    DROP COLUMN IF EXISTS healthy_threshold,
+    DROP COLUMN IF EXISTS health_check_timeout_seconds,
+    DROP COLUMN IF EXISTS health_check_type;
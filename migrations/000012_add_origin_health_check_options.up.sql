@@ -0,0 +1,30 @@
+-- Active health check options of origins. health_check_type is 'http'
+-- (GET health_check_path, no check when it is empty), 'tcp' (connect only)
+-- or 'none'. health_check_expected_statuses lists status codes and ranges,
+-- e.g. '200-299,304'; a non-empty health_check_body_match must appear in
+-- the response body.
+ALTER TABLE origins
+    ADD COLUMN IF NOT EXISTS health_check_type VARCHAR(10) NOT NULL DEFAULT 'http',
+    ADD COLUMN IF NOT EXISTS health_check_timeout_seconds INTEGER NOT NULL DEFAULT 5,
+    ADD COLUMN IF NOT EXISTS healthy_threshold INTEGER NOT NULL DEFAULT 2,
+    ADD COLUMN IF NOT EXISTS unhealthy_threshold INTEGER NOT NULL DEFAULT 3,
+    ADD COLUMN IF NOT EXISTS health_check_expected_statuses VARCHAR(255) NOT NULL DEFAULT '200-299',
+    ADD COLUMN IF NOT EXISTS health_check_body_match VARCHAR(500) NOT NULL DEFAULT '';
+
+-- Health check results are written often and are not configuration: leave
+-- updated_at, and so the tenant's config version, alone when only they change
+CREATE OR REPLACE FUNCTION update_origins_updated_at_column()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF to_jsonb(NEW) - 'is_healthy' - 'last_health_check' - 'updated_at' =
+       to_jsonb(OLD) - 'is_healthy' - 'last_health_check' - 'updated_at' THEN
+        RETURN NEW;
+    END IF;
+    NEW.updated_at = CURRENT_TIMESTAMP;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS update_origins_updated_at ON origins;
+CREATE TRIGGER update_origins_updated_at BEFORE UPDATE ON origins
+    FOR EACH ROW EXECUTE FUNCTION update_origins_updated_at_column();
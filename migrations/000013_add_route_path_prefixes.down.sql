@@ -0,0 +1,3 @@
+ALTER TABLE routes
+    DROP COLUMN IF EXISTS add_path_prefix,
+    DROP COLUMN IF EXISTS strip_path_prefix;
@@ -0,0 +1,6 @@
+-- Path prefix shortcuts of routes: strip_path_prefix is removed from the
+-- request path before path_rewrite_pattern applies, add_path_prefix is put
+-- in front of the result.
+ALTER TABLE routes
+    ADD COLUMN IF NOT EXISTS strip_path_prefix VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS add_path_prefix VARCHAR(255);
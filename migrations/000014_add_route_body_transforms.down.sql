@@ -0,0 +1,3 @@
+ALTER TABLE routes
+    DROP COLUMN IF EXISTS response_body_transform,
+    DROP COLUMN IF EXISTS request_body_transform;
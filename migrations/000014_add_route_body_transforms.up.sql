@@ -0,0 +1,6 @@
+-- JSON body transformation rules of routes, applied by the gateway to
+-- request bodies before they reach the origin and to response bodies before
+-- they reach the client.
+ALTER TABLE routes
+    ADD COLUMN IF NOT EXISTS request_body_transform JSONB DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS response_body_transform JSONB DEFAULT '{}';
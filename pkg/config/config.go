@@ -4,23 +4,28 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	App           AppConfig
-	ControlPlane  ControlPlaneConfig
-	Gateway       GatewayConfig
-	Database      DatabaseConfig
-	Redis         RedisConfig
-	Clerk         ClerkConfig
-	RateLimit     RateLimitConfig
-	Cache         CacheConfig
-	LoadBalancer  LoadBalancerConfig
-	Observability ObservabilityConfig
-	CORS          CORSConfig
+	App            AppConfig
+	ControlPlane   ControlPlaneConfig
+	Gateway        GatewayConfig
+	Database       DatabaseConfig
+	Redis          RedisConfig
+	Clerk          ClerkConfig
+	JWT            JWTConfig
+	RateLimit      RateLimitConfig
+	Cache          CacheConfig
+	LoadBalancer   LoadBalancerConfig
+	Retry          RetryConfig
+	CircuitBreaker CircuitBreakerConfig
+	Outlier        OutlierConfig
+	Observability  ObservabilityConfig
+	CORS           CORSConfig
 }
 
 type AppConfig struct {
@@ -29,15 +34,18 @@ type AppConfig struct {
 }
 
 type ControlPlaneConfig struct {
-	Host     string
-	Port     int
-	GRPCPort int
+	Host        string
+	Port        int
+	GRPCPort    int
+	DNSResolver string
 }
 
 type GatewayConfig struct {
-	Host   string
-	Port   int
-	Domain string
+	Host                 string
+	Port                 int
+	Domain               string
+	RouteRefreshInterval time.Duration
+	TransformMaxBodyKB   int // bodies larger than this are not transformed
 }
 
 type DatabaseConfig struct {
@@ -67,25 +75,77 @@ type ClerkConfig struct {
 	APIURL         string
 }
 
+type JWTConfig struct {
+	JWKSURL                string
+	Issuer                 string
+	Audience               string
+	ClockSkew              time.Duration
+	JWKSRefreshInterval    time.Duration
+	JWKSMinRefetchInterval time.Duration
+}
+
 type RateLimitConfig struct {
 	Enabled      bool
 	DefaultRPS   int
 	DefaultBurst int
+	IdleTTL      time.Duration
+	Backend      string // "memory" or "redis"
+	FailureMode  string // "open" or "closed", applied while Redis is unreachable
+	RedisTimeout time.Duration
 }
 
 type CacheConfig struct {
-	Enabled    bool
-	DefaultTTL time.Duration
-	MaxSizeMB  int
+	Enabled        bool
+	DefaultTTL     time.Duration
+	MaxSizeMB      int
+	MaxEntrySizeKB int           // larger responses are relayed but not stored
+	RetainStale    time.Duration // how long expired entries with validators are kept for revalidation
+	Backend        string        // "memory" or "redis", which adds Redis behind the in-memory tier
+	RedisTimeout   time.Duration
 }
 
 type LoadBalancerConfig struct {
 	Strategy            string
+	HashLoadFactor      float64       // consistent_hash: no origin takes more than this times the average load; 0 leaves loads unbounded
+	EWMADecay           time.Duration // peak_ewma: how fast origin latencies are forgotten
 	HealthCheckInterval time.Duration
 	HealthCheckTimeout  time.Duration
 	MaxRetryAttempts    int
 }
 
+// RetryConfig tunes retries of failed origin requests. The number of retries
+// comes from the route or origin, capped by LoadBalancer.MaxRetryAttempts.
+type RetryConfig struct {
+	BaseBackoff        time.Duration // backoff before the first retry, doubled for each further one
+	MaxBackoff         time.Duration
+	BudgetPercent      int // retries allowed per origin as a percentage of its requests
+	BudgetMinPerSecond int // retries per origin allowed regardless of traffic
+	MaxBodyKB          int // larger request bodies are sent once, without retries
+}
+
+// CircuitBreakerConfig tunes the circuit breakers of routes that enable
+// them. The consecutive failure threshold is set per route.
+type CircuitBreakerConfig struct {
+	ErrorRatePercent int // opens when this share of requests in Window fail...
+	MinRequests      int // ...once Window holds at least this many
+	Window           time.Duration
+	OpenTimeout      time.Duration // how long a circuit stays open before probing
+	HalfOpenProbes   int           // successful probes needed to close
+	ReportInterval   time.Duration // how often breaker states are reported through Redis
+}
+
+// OutlierConfig tunes the ejection of pool origins failing live traffic
+type OutlierConfig struct {
+	Enabled             bool
+	ConsecutiveFailures int // ejects after this many connect failures or 5xx in a row...
+	ErrorRatePercent    int // ...or when this share of requests in Window fail...
+	MinRequests         int // ...once Window holds at least this many
+	Window              time.Duration
+	BaseEjectionTime    time.Duration // doubled for each ejection in a row
+	MaxEjectionTime     time.Duration
+	MaxEjectionPercent  int // of a pool's origins ejected at once
+}
+
 type ObservabilityConfig struct {
 	OTELEnabled          bool
 	OTELServiceName      string
@@ -113,14 +173,17 @@ func Load() (*Config, error) {
 			Name: getEnv("APP_NAME", "VantageEdge"),
 		},
 		ControlPlane: ControlPlaneConfig{
-			Host:     getEnv("CONTROL_PLANE_HOST", "0.0.0.0"),
-			Port:     getEnvAsInt("CONTROL_PLANE_PORT", 8080),
-			GRPCPort: getEnvAsInt("CONTROL_PLANE_GRPC_PORT", 9090),
+			Host:        getEnv("CONTROL_PLANE_HOST", "0.0.0.0"),
+			Port:        getEnvAsInt("CONTROL_PLANE_PORT", 8080),
+			GRPCPort:    getEnvAsInt("CONTROL_PLANE_GRPC_PORT", 9090),
+			DNSResolver: getEnv("CONTROL_PLANE_DNS_RESOLVER", ""),
 		},
 		Gateway: GatewayConfig{
-			Host:   getEnv("GATEWAY_HOST", "0.0.0.0"),
-			Port:   getEnvAsInt("GATEWAY_PORT", 8000),
-			Domain: getEnv("GATEWAY_DOMAIN", "vantageedge.dev"),
+			Host:                 getEnv("GATEWAY_HOST", "0.0.0.0"),
+			Port:                 getEnvAsInt("GATEWAY_PORT", 8000),
+			Domain:               getEnv("GATEWAY_DOMAIN", "vantageedge.dev"),
+			RouteRefreshInterval: getEnvAsDuration("GATEWAY_ROUTE_REFRESH_INTERVAL", 5*time.Second),
+			TransformMaxBodyKB:   getEnvAsInt("GATEWAY_TRANSFORM_MAX_BODY_KB", 1024),
 		},
 		Database: DatabaseConfig{
 			Host:               getEnv("DB_HOST", "localhost"),
@@ -146,22 +209,65 @@ func Load() (*Config, error) {
 			PublishableKey: getEnv("CLERK_PUBLISHABLE_KEY", ""),
 			APIURL:         getEnv("CLERK_API_URL", "https://api.clerk.com/v1"),
 		},
+		JWT: JWTConfig{
+			JWKSURL:                getEnv("JWT_JWKS_URL", ""),
+			Issuer:                 getEnv("JWT_ISSUER", ""),
+			Audience:               getEnv("JWT_AUDIENCE", ""),
+			ClockSkew:              getEnvAsDuration("JWT_CLOCK_SKEW", 60*time.Second),
+			JWKSRefreshInterval:    getEnvAsDuration("JWT_JWKS_REFRESH_INTERVAL", 15*time.Minute),
+			JWKSMinRefetchInterval: getEnvAsDuration("JWT_JWKS_MIN_REFETCH_INTERVAL", 30*time.Second),
+		},
 		RateLimit: RateLimitConfig{
 			Enabled:      getEnvAsBool("RATE_LIMIT_ENABLED", true),
 			DefaultRPS:   getEnvAsInt("RATE_LIMIT_DEFAULT_RPS", 100),
 			DefaultBurst: getEnvAsInt("RATE_LIMIT_DEFAULT_BURST", 200),
+			IdleTTL:      getEnvAsDuration("RATE_LIMIT_IDLE_TTL", 10*time.Minute),
+			Backend:      getEnv("RATE_LIMIT_BACKEND", "memory"),
+			FailureMode:  getEnv("RATE_LIMIT_FAILURE_MODE", "open"),
+			RedisTimeout: getEnvAsDuration("RATE_LIMIT_REDIS_TIMEOUT", 50*time.Millisecond),
 		},
 		Cache: CacheConfig{
-			Enabled:    getEnvAsBool("CACHE_ENABLED", true),
-			DefaultTTL: getEnvAsDuration("CACHE_DEFAULT_TTL", 5*time.Minute),
-			MaxSizeMB:  getEnvAsInt("CACHE_MAX_SIZE_MB", 512),
+			Enabled:        getEnvAsBool("CACHE_ENABLED", true),
+			DefaultTTL:     getEnvAsDuration("CACHE_DEFAULT_TTL", 5*time.Minute),
+			MaxSizeMB:      getEnvAsInt("CACHE_MAX_SIZE_MB", 512),
+			MaxEntrySizeKB: getEnvAsInt("CACHE_MAX_ENTRY_SIZE_KB", 1024),
+			RetainStale:    getEnvAsDuration("CACHE_RETAIN_STALE", 10*time.Minute),
+			Backend:        getEnv("CACHE_BACKEND", "memory"),
+			RedisTimeout:   getEnvAsDuration("CACHE_REDIS_TIMEOUT", 50*time.Millisecond),
 		},
 		LoadBalancer: LoadBalancerConfig{
 			Strategy:            getEnv("LB_STRATEGY", "round_robin"),
+			HashLoadFactor:      getEnvAsFloat("LB_HASH_LOAD_FACTOR", 1.25),
+			EWMADecay:           getEnvAsDuration("LB_EWMA_DECAY", 10*time.Second),
 			HealthCheckInterval: getEnvAsDuration("LB_HEALTH_CHECK_INTERVAL", 10*time.Second),
 			HealthCheckTimeout:  getEnvAsDuration("LB_HEALTH_CHECK_TIMEOUT", 5*time.Second),
 			MaxRetryAttempts:    getEnvAsInt("LB_MAX_RETRY_ATTEMPTS", 3),
 		},
+		Retry: RetryConfig{
+			BaseBackoff:        getEnvAsDuration("RETRY_BASE_BACKOFF", 25*time.Millisecond),
+			MaxBackoff:         getEnvAsDuration("RETRY_MAX_BACKOFF", time.Second),
+			BudgetPercent:      getEnvAsInt("RETRY_BUDGET_PERCENT", 20),
+			BudgetMinPerSecond: getEnvAsInt("RETRY_BUDGET_MIN_PER_SECOND", 5),
+			MaxBodyKB:          getEnvAsInt("RETRY_MAX_BODY_KB", 64),
+		},
+		CircuitBreaker: CircuitBreakerConfig{
+			ErrorRatePercent: getEnvAsInt("CIRCUIT_BREAKER_ERROR_RATE_PERCENT", 50),
+			MinRequests:      getEnvAsInt("CIRCUIT_BREAKER_MIN_REQUESTS", 20),
+			Window:           getEnvAsDuration("CIRCUIT_BREAKER_WINDOW", 10*time.Second),
+			OpenTimeout:      getEnvAsDuration("CIRCUIT_BREAKER_OPEN_TIMEOUT", 30*time.Second),
+			HalfOpenProbes:   getEnvAsInt("CIRCUIT_BREAKER_HALF_OPEN_PROBES", 1),
+			ReportInterval:   getEnvAsDuration("CIRCUIT_BREAKER_REPORT_INTERVAL", 10*time.Second),
+		},
+		Outlier: OutlierConfig{
+			Enabled:             getEnvAsBool("OUTLIER_DETECTION_ENABLED", true),
+			ConsecutiveFailures: getEnvAsInt("OUTLIER_CONSECUTIVE_FAILURES", 5),
+			ErrorRatePercent:    getEnvAsInt("OUTLIER_ERROR_RATE_PERCENT", 50),
+			MinRequests:         getEnvAsInt("OUTLIER_MIN_REQUESTS", 20),
+			Window:              getEnvAsDuration("OUTLIER_WINDOW", 10*time.Second),
+			BaseEjectionTime:    getEnvAsDuration("OUTLIER_BASE_EJECTION_TIME", 30*time.Second),
+			MaxEjectionTime:     getEnvAsDuration("OUTLIER_MAX_EJECTION_TIME", 5*time.Minute),
+			MaxEjectionPercent:  getEnvAsInt("OUTLIER_MAX_EJECTION_PERCENT", 50),
+		},
 		Observability: ObservabilityConfig{
 			OTELEnabled:          getEnvAsBool("OTEL_ENABLED", true),
 			OTELServiceName:      getEnv("OTEL_SERVICE_NAME", "vantageedge"),
@@ -190,6 +296,11 @@ func Load() (*Config, error) {
 		}(),
 	}
 
+	// Clerk publishes its signing keys under the issuer
+	if cfg.JWT.JWKSURL == "" && cfg.JWT.Issuer != "" {
+		cfg.JWT.JWKSURL = strings.TrimSuffix(cfg.JWT.Issuer, "/") + "/.well-known/jwks.json"
+	}
+
 	if err := cfg.Validate(); err != nil {
 		return nil, err
 	}
@@ -210,6 +321,65 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("REDIS_HOST is required")
 	}
 
+	if c.RateLimit.Backend != "memory" && c.RateLimit.Backend != "redis" {
+		return fmt.Errorf("RATE_LIMIT_BACKEND must be memory or redis")
+	}
+
+	if c.RateLimit.FailureMode != "open" && c.RateLimit.FailureMode != "closed" {
+		return fmt.Errorf("RATE_LIMIT_FAILURE_MODE must be open or closed")
+	}
+
+	if c.RateLimit.DefaultRPS <= 0 || c.RateLimit.DefaultBurst <= 0 {
+		return fmt.Errorf("RATE_LIMIT_DEFAULT_RPS and RATE_LIMIT_DEFAULT_BURST must be positive")
+	}
+
+	if c.RateLimit.IdleTTL <= 0 {
+		return fmt.Errorf("RATE_LIMIT_IDLE_TTL must be positive")
+	}
+
+	if c.Cache.Backend != "memory" && c.Cache.Backend != "redis" {
+		return fmt.Errorf("CACHE_BACKEND must be memory or redis")
+	}
+
+	if c.Cache.MaxSizeMB <= 0 {
+		return fmt.Errorf("CACHE_MAX_SIZE_MB must be positive")
+	}
+
+	switch c.LoadBalancer.Strategy {
+	case "round_robin", "least_conn", "consistent_hash", "peak_ewma":
+	default:
+		return fmt.Errorf("LB_STRATEGY must be round_robin, least_conn, consistent_hash or peak_ewma")
+	}
+
+	if c.LoadBalancer.HashLoadFactor != 0 && c.LoadBalancer.HashLoadFactor < 1 {
+		return fmt.Errorf("LB_HASH_LOAD_FACTOR must be 0 or at least 1")
+	}
+
+	if c.LoadBalancer.EWMADecay <= 0 {
+		return fmt.Errorf("LB_EWMA_DECAY must be positive")
+	}
+
+	if c.CircuitBreaker.ErrorRatePercent < 0 || c.CircuitBreaker.ErrorRatePercent > 100 {
+		return fmt.Errorf("CIRCUIT_BREAKER_ERROR_RATE_PERCENT must be between 0 and 100")
+	}
+
+	if c.CircuitBreaker.ReportInterval <= 0 {
+		return fmt.Errorf("CIRCUIT_BREAKER_REPORT_INTERVAL must be positive")
+	}
+
+	if c.Outlier.ErrorRatePercent < 0 || c.Outlier.ErrorRatePercent > 100 ||
+		c.Outlier.MaxEjectionPercent < 0 || c.Outlier.MaxEjectionPercent > 100 {
+		return fmt.Errorf("OUTLIER_ERROR_RATE_PERCENT and OUTLIER_MAX_EJECTION_PERCENT must be between 0 and 100")
+	}
+
+	if c.Outlier.Enabled && c.Outlier.BaseEjectionTime <= 0 {
+		return fmt.Errorf("OUTLIER_BASE_EJECTION_TIME must be positive")
+	}
+
+	if c.Retry.BudgetPercent < 0 || c.Retry.BudgetMinPerSecond < 0 {
+		return fmt.Errorf("RETRY_BUDGET_PERCENT and RETRY_BUDGET_MIN_PER_SECOND must not be negative")
+	}
+
 	return nil
 }
 
@@ -242,6 +412,15 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
 func getEnvAsBool(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
 		if boolValue, err := strconv.ParseBool(value); err == nil {